@@ -0,0 +1,194 @@
+// Package a11y audits article HTML for accessibility problems that are easy to miss in review:
+// images without alt text, skipped heading levels, links that say "click here" and figures
+// without captions.
+package a11y
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Rule names a check. It's used as the title of check run annotations.
+type Rule string
+
+const (
+	MissingAlt     Rule = "missing-alt"
+	HeadingOrder   Rule = "heading-order"
+	VagueLinkText  Rule = "vague-link-text"
+	MissingCaption Rule = "missing-caption"
+)
+
+// Finding is one problem in the HTML.
+type Finding struct {
+	Rule Rule
+	// Line is where the offending element starts, counting from 1.
+	Line    int
+	Message string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("line %d: %s", f.Line, f.Message)
+}
+
+// vagueLinkText is link text that doesn't say where the link goes, which screen reader users
+// often navigate by.
+var vagueLinkText = map[string]bool{
+	"here": true, "click here": true, "click": true, "this": true, "this link": true, "link": true,
+	"more": true, "read more": true, "learn more": true, "details": true, "go": true, "see here": true,
+}
+
+// filenameRe matches alt text that's really a file name, eg. "IMG_1234.jpg".
+var filenameRe = regexp.MustCompile(`(?i)^[\w-]+\.(jpe?g|png|gif|webp|svg|heic)$`)
+
+// Audit lists the problems in an article body. Bodies start at h2, since the headline is the
+// page's h1.
+func Audit(body string) []Finding {
+	a := &auditor{line: 1, lastHeading: 1}
+	tokenizer := html.NewTokenizer(strings.NewReader(body))
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		line := a.line
+		a.line += strings.Count(string(tokenizer.Raw()), "\n")
+		token := tokenizer.Token()
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			a.start(token, line, tt == html.SelfClosingTagToken)
+		case html.EndTagToken:
+			a.end(token)
+		case html.TextToken:
+			a.text(token.Data)
+		}
+	}
+	return a.findings
+}
+
+type auditor struct {
+	findings []Finding
+	line     int
+
+	lastHeading int
+	// link is the text of the <a> being read, if any.
+	link     *strings.Builder
+	linkLine int
+	// figures are the open <figure>s, innermost last.
+	figures []*figure
+}
+
+type figure struct {
+	line    int
+	caption bool
+	// captionText is set once the figcaption has some text.
+	captionText bool
+	inCaption   bool
+}
+
+func (a *auditor) add(rule Rule, line int, format string, args ...any) {
+	a.findings = append(a.findings, Finding{Rule: rule, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+func (a *auditor) start(token html.Token, line int, selfClosing bool) {
+	switch token.Data {
+	case "img":
+		alt, ok := attr(token, "alt")
+		src, _ := attr(token, "src")
+		switch {
+		case !ok:
+			a.add(MissingAlt, line, "<img> has no alt text; use alt=\"\" if it's decorative")
+		case filenameRe.MatchString(strings.TrimSpace(alt)) || (alt != "" && alt == path.Base(src)):
+			a.add(MissingAlt, line, "<img> alt text %q is a file name, not a description", alt)
+		}
+		// Alt text is what a linked image's link says.
+		if a.link != nil {
+			a.link.WriteString(" " + alt)
+		}
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level := int(token.Data[1] - '0')
+		switch {
+		case level == 1:
+			a.add(HeadingOrder, line, "<h1> in the body; the headline is the page's h1, start at h2")
+		case level > a.lastHeading+1:
+			a.add(HeadingOrder, line, "<%s> follows <h%d>, skipping a level", token.Data, a.lastHeading)
+		}
+		a.lastHeading = level
+	case "a":
+		if _, ok := attr(token, "href"); ok && !selfClosing {
+			a.link = &strings.Builder{}
+			a.linkLine = line
+		}
+	case "figure":
+		if !selfClosing {
+			a.figures = append(a.figures, &figure{line: line})
+		}
+	case "figcaption":
+		if f := a.figure(); f != nil && !selfClosing {
+			f.caption = true
+			f.inCaption = true
+		}
+	}
+}
+
+func (a *auditor) end(token html.Token) {
+	switch token.Data {
+	case "a":
+		if a.link == nil {
+			return
+		}
+		text := strings.ToLower(strings.Join(strings.Fields(a.link.String()), " "))
+		text = strings.Trim(text, ".,:;!?…→» ")
+		switch {
+		case text == "":
+			a.add(VagueLinkText, a.linkLine, "link has no text")
+		case vagueLinkText[text]:
+			a.add(VagueLinkText, a.linkLine, "link text %q doesn't say where it goes", text)
+		}
+		a.link = nil
+	case "figcaption":
+		if f := a.figure(); f != nil {
+			f.inCaption = false
+		}
+	case "figure":
+		f := a.figure()
+		if f == nil {
+			return
+		}
+		a.figures = a.figures[:len(a.figures)-1]
+		switch {
+		case !f.caption:
+			a.add(MissingCaption, f.line, "<figure> has no <figcaption>")
+		case !f.captionText:
+			a.add(MissingCaption, f.line, "<figure> has an empty <figcaption>")
+		}
+	}
+}
+
+func (a *auditor) text(text string) {
+	if a.link != nil {
+		a.link.WriteString(text)
+	}
+	if f := a.figure(); f != nil && f.inCaption && strings.TrimSpace(text) != "" {
+		f.captionText = true
+	}
+}
+
+func (a *auditor) figure() *figure {
+	if len(a.figures) == 0 {
+		return nil
+	}
+	return a.figures[len(a.figures)-1]
+}
+
+func attr(token html.Token, key string) (string, bool) {
+	for _, a := range token.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,172 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	"github.com/geomodulus/robots/github"
+	"github.com/geomodulus/robots/search"
+)
+
+// maxRecentErrors is how many handler errors the bot keeps for /botadmin.
+const maxRecentErrors = 20
+
+// HandlerError is an error returned by one of the bot's handlers.
+type HandlerError struct {
+	Time time.Time
+	// Source is what was being handled, eg. a slash command or action ID.
+	Source    string
+	ChannelID string
+	UserID    string
+	RequestID string
+	Err       error
+}
+
+func (b *SlackBot) recordError(env *Envelope, source string, err error) {
+	b.errMu.Lock()
+	defer b.errMu.Unlock()
+	b.recentErrors = append(b.recentErrors, HandlerError{
+		Time:      time.Now(),
+		Source:    source,
+		ChannelID: env.ChannelID,
+		UserID:    env.UserID,
+		RequestID: env.RequestID,
+		Err:       err,
+	})
+	if len(b.recentErrors) > maxRecentErrors {
+		b.recentErrors = b.recentErrors[len(b.recentErrors)-maxRecentErrors:]
+	}
+}
+
+// RecentErrors returns the last errors returned by handlers, newest first.
+func (b *SlackBot) RecentErrors() []HandlerError {
+	b.errMu.Lock()
+	defer b.errMu.Unlock()
+	errs := make([]HandlerError, len(b.recentErrors))
+	for i, handlerErr := range b.recentErrors {
+		errs[len(errs)-1-i] = handlerErr
+	}
+	return errs
+}
+
+// Uptime returns how long the bot has been running.
+func (b *SlackBot) Uptime() time.Duration {
+	if b.startedAt.IsZero() {
+		return 0
+	}
+	return time.Since(b.startedAt)
+}
+
+// QueueDepth returns how many Slack events are waiting to be handled.
+func (b *SlackBot) QueueDepth() int {
+	return len(b.Socket.Events)
+}
+
+// AdminCommand answers /botadmin with the bot's health, so debugging doesn't need a shell on the
+// host. Every field but Bot is optional; sections without one are left out. Call
+// HandleSlashCommand from the bot's handler for the command it's registered as.
+type AdminCommand struct {
+	Bot *SlackBot
+	// Admins are the Slack user IDs allowed to use the command.
+	Admins    []string
+	GitHub    *github.App
+	Search    *search.Client
+	Scheduler *Scheduler
+}
+
+func (c *AdminCommand) HandleSlashCommand(ctx context.Context, env *Envelope, cmd string) ([]slack.Block, error) {
+	if !isAdmin(c.Admins, env.UserID) {
+		return nil, fmt.Errorf("only admins can use %s", cmd)
+	}
+
+	sections := []string{
+		fmt.Sprintf("*Bot*\nUp %s, %d events queued", c.Bot.Uptime().Round(time.Second), c.Bot.QueueDepth()),
+	}
+
+	limits := []string{}
+	if c.GitHub != nil {
+		rates, _, err := c.GitHub.RateLimits(ctx)
+		if err != nil {
+			limits = append(limits, fmt.Sprintf("GitHub: error checking, %v", err))
+		} else if core := rates.GetCore(); core != nil {
+			limits = append(limits, fmt.Sprintf("GitHub: %d of %d left, resets %s",
+				core.Remaining, core.Limit, slackDate(core.Reset.Time)))
+		}
+	}
+	if c.Search != nil {
+		if limit := c.Search.OpenAIRateLimit(); limit.Updated.IsZero() {
+			limits = append(limits, "OpenAI: no requests yet")
+		} else {
+			limits = append(limits, fmt.Sprintf("OpenAI: %d requests (resets in %s) and %d tokens (resets in %s) left, as of %s",
+				limit.RemainingRequests, limit.ResetRequests, limit.RemainingTokens, limit.ResetTokens, slackDate(limit.Updated)))
+		}
+	}
+	if len(limits) > 0 {
+		sections = append(sections, "*Rate limits*\n"+strings.Join(limits, "\n"))
+	}
+
+	if c.GitHub != nil {
+		text := "*Tokens*\nGitHub installation token: "
+		if expiry := c.GitHub.InstallationTokenExpiry(); expiry.IsZero() {
+			text += "none created yet"
+		} else if time.Now().After(expiry) {
+			text += fmt.Sprintf(":warning: expired %s", slackDate(expiry))
+		} else {
+			text += fmt.Sprintf("expires %s", slackDate(expiry))
+		}
+		sections = append(sections, text)
+	}
+
+	if c.Scheduler != nil {
+		jobs := []string{}
+		for _, job := range c.Scheduler.Jobs() {
+			line := fmt.Sprintf("`%s` next %s", job.Name, slackDate(job.NextRun))
+			if !job.LastRun.IsZero() {
+				line += fmt.Sprintf(", last %s", slackDate(job.LastRun))
+			}
+			if job.LastErr != nil {
+				line += fmt.Sprintf(" :warning: %v", job.LastErr)
+			}
+			jobs = append(jobs, line)
+		}
+		if len(jobs) > 0 {
+			sections = append(sections, "*Scheduled jobs*\n"+strings.Join(jobs, "\n"))
+		}
+	}
+
+	errs := []string{}
+	for i, handlerErr := range c.Bot.RecentErrors() {
+		if i == 5 {
+			break
+		}
+		errs = append(errs, fmt.Sprintf("%s `%s` from <@%s> (request `%s`): %v", slackDate(handlerErr.Time), handlerErr.Source, handlerErr.UserID, handlerErr.RequestID, handlerErr.Err))
+	}
+	if len(errs) == 0 {
+		errs = append(errs, "None since startup")
+	}
+	sections = append(sections, "*Last errors*\n"+strings.Join(errs, "\n"))
+
+	blocks := []slack.Block{}
+	for _, section := range sections {
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, section, false, false), nil, nil))
+	}
+	return blocks, nil
+}
+
+// slackDate formats t in the reader's own time zone.
+func slackDate(t time.Time) string {
+	return fmt.Sprintf("<!date^%d^{date_short_pretty} {time}|%s>", t.Unix(), t.UTC().Format(time.RFC3339))
+}
+
+func isAdmin(admins []string, userID string) bool {
+	for _, admin := range admins {
+		if admin == userID {
+			return true
+		}
+	}
+	return false
+}
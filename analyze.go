@@ -0,0 +1,182 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+
+	"github.com/geomodulus/robots/a11y"
+	"github.com/geomodulus/robots/command"
+	"github.com/geomodulus/robots/github"
+	"github.com/geomodulus/robots/readability"
+)
+
+// Thresholds past which ArticleAnalyzer flags a figure.
+const (
+	maxGradeLevel   = 10.0
+	maxPassiveRatio = 0.15
+	// maxListedIssues bounds each list of warnings, so one messy article doesn't fill the channel.
+	maxListedIssues = 8
+)
+
+var analyzeGrammar = &command.Grammar{
+	Name: "@bot",
+	Commands: []*command.Command{{
+		Verb:    "analyze",
+		Aliases: []string{"analyse"},
+		Args:    []command.Arg{{Name: "slug", Kind: command.Slug}},
+		Help:    "Report on an article's length, reading level, dead links and accessibility.",
+	}},
+}
+
+// ArticleAnalyzer answers "@bot analyze <slug>" with the article's length, reading level,
+// passive voice, long paragraphs, dead links, accessibility findings and problems in its
+// article.js. Check HandlesMention from the bot's app mention handler and pass matching mentions
+// to HandleAppMention.
+type ArticleAnalyzer struct {
+	Bot      *SlackBot
+	Articles *github.App
+	// HTTPClient checks external links. Defaults to one with a 10 second timeout.
+	HTTPClient *http.Client
+	// MaxParagraphWords defaults to readability.DefaultMaxParagraphWords.
+	MaxParagraphWords int
+}
+
+// HandlesMention reports whether a mention asks for an analysis.
+func (a *ArticleAnalyzer) HandlesMention(text string) bool {
+	return analyzeGrammar.Mentions(text)
+}
+
+func (a *ArticleAnalyzer) HandleAppMention(ctx context.Context, env *Envelope, ev *slackevents.AppMentionEvent) error {
+	inv, help, err := parseMention(env, analyzeGrammar)
+	if err != nil {
+		return err
+	}
+	if inv == nil {
+		return a.Bot.ReplyTo(env, slack.MsgOptionBlocks(help...))
+	}
+	blocks, err := a.Analyze(ctx, inv.Arg("slug"))
+	if err != nil {
+		return err
+	}
+	return a.Bot.ReplyTo(env, slack.MsgOptionBlocks(blocks...))
+}
+
+// Analyze reads the article at slug from main and reports on it as Slack blocks.
+func (a *ArticleAnalyzer) Analyze(ctx context.Context, slug string) ([]slack.Block, error) {
+	checkout, err := a.Articles.FetchArticle(ctx, slug)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching article %q: %v", slug, err)
+	}
+	report := readability.Analyze(checkout.BodyHTML, a.MaxParagraphWords)
+
+	client := a.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	deadLinks, err := a.Articles.CheckLinks(ctx, checkout.BodyHTML, client)
+	if err != nil {
+		return nil, fmt.Errorf("error checking links: %v", err)
+	}
+	findings := a11y.Audit(checkout.BodyHTML)
+	scriptFindings := github.CheckArticleScript(checkout.Article, checkout.JavascriptFunction, false)
+
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType,
+			fmt.Sprintf("*Analysis of* _%s_", escapeMrkdwn(checkout.Article.Name)), false, false), nil, nil),
+		slack.NewSectionBlock(nil, []*slack.TextBlockObject{
+			analysisField("Length", fmt.Sprintf("%d words, %d paragraphs (~%d min)", report.Words, report.Paragraphs, report.ReadingMinutes())),
+			analysisField("Reading level", flagged(fmt.Sprintf("Grade %.1f", report.GradeLevel), report.GradeLevel > maxGradeLevel)),
+			analysisField("Passive voice", flagged(fmt.Sprintf("%.0f%% of %d sentences", 100*report.PassiveRatio(), report.Sentences), report.PassiveRatio() > maxPassiveRatio)),
+			analysisField("Dead links", flagged(fmt.Sprintf("%d", len(deadLinks)), len(deadLinks) > 0)),
+			analysisField("Script", flagged(fmt.Sprintf("%d issues", len(scriptFindings)), len(scriptFindings) > 0)),
+		}, nil),
+	}
+
+	if len(report.LongParagraphs) > 0 {
+		lines := []string{fmt.Sprintf(":warning: *%d long paragraphs*", len(report.LongParagraphs))}
+		for i, paragraph := range report.LongParagraphs {
+			if i == maxListedIssues {
+				lines = append(lines, fmt.Sprintf("…and %d more", len(report.LongParagraphs)-i))
+				break
+			}
+			lines = append(lines, fmt.Sprintf("• ¶%d, %d words: _%s_", paragraph.Index, paragraph.Words, escapeMrkdwn(excerpt(paragraph.Text, 60))))
+		}
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, strings.Join(lines, "\n"), false, false), nil, nil))
+	}
+
+	if len(deadLinks) > 0 {
+		lines := []string{":link: *Dead links*"}
+		for i, link := range deadLinks {
+			if i == maxListedIssues {
+				lines = append(lines, fmt.Sprintf("…and %d more", len(deadLinks)-i))
+				break
+			}
+			lines = append(lines, fmt.Sprintf("• `%s`: %s", escapeMrkdwn(link.Href), escapeMrkdwn(link.Reason)))
+		}
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, strings.Join(lines, "\n"), false, false), nil, nil))
+	}
+
+	if len(findings) > 0 {
+		lines := []string{":wheelchair: *Accessibility*"}
+		for i, finding := range findings {
+			if i == maxListedIssues {
+				lines = append(lines, fmt.Sprintf("…and %d more", len(findings)-i))
+				break
+			}
+			lines = append(lines, "• "+escapeMrkdwn(finding.String()))
+		}
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, strings.Join(lines, "\n"), false, false), nil, nil))
+	}
+
+	if len(scriptFindings) > 0 {
+		lines := []string{":world_map: *article.js*"}
+		for i, finding := range scriptFindings {
+			if i == maxListedIssues {
+				lines = append(lines, fmt.Sprintf("…and %d more", len(scriptFindings)-i))
+				break
+			}
+			lines = append(lines, "• "+escapeMrkdwn(finding.String()))
+		}
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, strings.Join(lines, "\n"), false, false), nil, nil))
+	}
+	return blocks, nil
+}
+
+func analysisField(label, value string) *slack.TextBlockObject {
+	return slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*%s*\n%s", label, value), false, false)
+}
+
+func flagged(value string, warn bool) string {
+	if warn {
+		return ":warning: " + value
+	}
+	return value
+}
+
+// excerpt shortens text to about n runes, on a word boundary.
+func excerpt(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	cut := string(runes[:n])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
+
+// excerptEnd keeps the last n runes of text, marking the cut with "…".
+func excerptEnd(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return "…" + string(runes[len(runes)-n:])
+}
@@ -0,0 +1,220 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+
+	"github.com/geomodulus/robots/search"
+)
+
+// defaultMinArchiveLength skips messages like "ok" and "thanks" that aren't worth recalling.
+const defaultMinArchiveLength = 40
+
+// MessageArchive embeds messages from the channels it's given, eg. pitches and decisions, so
+// they can be found later with /recall. Only the listed channels are ever read; the bot must be
+// a member of them. Call HandleMessage from the bot's message handler, and Backfill once to pick
+// up history.
+type MessageArchive struct {
+	Bot    *SlackBot
+	Search *search.Client
+	// Channels are the IDs of channels opted in to the archive.
+	Channels []string
+	// MinLength is the shortest message worth indexing. Zero means defaultMinArchiveLength.
+	MinLength int
+}
+
+// Archives reports whether a channel is opted in.
+func (a *MessageArchive) Archives(channelID string) bool {
+	for _, channel := range a.Channels {
+		if channel == channelID {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleMessage indexes new and edited messages and removes deleted ones.
+func (a *MessageArchive) HandleMessage(ctx context.Context, env *Envelope, ev *slackevents.MessageEvent) error {
+	if !a.Archives(ev.Channel) {
+		return nil
+	}
+	switch ev.SubType {
+	case "", "thread_broadcast":
+		_, err := a.indexMessage(ctx, ev.Channel, ev.User, ev.TimeStamp, ev.ThreadTimeStamp, ev.Text, ev.BotID)
+		return err
+
+	case "message_changed":
+		if ev.Message == nil {
+			return nil
+		}
+		_, err := a.indexMessage(ctx, ev.Channel, ev.Message.User, ev.Message.TimeStamp, ev.Message.ThreadTimeStamp, ev.Message.Text, ev.Message.BotID)
+		return err
+
+	case "message_deleted":
+		if ev.PreviousMessage == nil {
+			return nil
+		}
+		return a.Search.RemoveMessage(ctx, ev.Channel, ev.PreviousMessage.TimeStamp)
+	}
+	return nil
+}
+
+// Backfill indexes a channel's messages, and their thread replies, posted since oldest. It
+// returns how many were indexed and the tokens billed.
+func (a *MessageArchive) Backfill(ctx context.Context, channelID string, oldest time.Time) (int, int, error) {
+	if !a.Archives(channelID) {
+		return 0, 0, fmt.Errorf("channel %s isn't opted in to the archive", channelID)
+	}
+
+	indexed, tokens := 0, 0
+	indexAll := func(msgs []slack.Message) {
+		for _, msg := range msgs {
+			if msg.SubType != "" && msg.SubType != "thread_broadcast" {
+				continue
+			}
+			n, err := a.indexMessage(ctx, channelID, msg.User, msg.Timestamp, msg.ThreadTimestamp, msg.Text, msg.BotID)
+			if err != nil {
+				log.Printf("error archiving message %s in %s: %v", msg.Timestamp, channelID, err)
+				continue
+			}
+			if n > 0 {
+				indexed++
+				tokens += n
+			}
+		}
+	}
+
+	params := &slack.GetConversationHistoryParameters{
+		ChannelID: channelID,
+		Oldest:    strconv.FormatInt(oldest.Unix(), 10),
+		Limit:     200,
+	}
+	for {
+		history, err := a.Bot.GetConversationHistoryContext(ctx, params)
+		if err != nil {
+			return indexed, tokens, fmt.Errorf("error reading history of %s: %v", channelID, err)
+		}
+		indexAll(history.Messages)
+
+		for _, msg := range history.Messages {
+			if msg.ReplyCount == 0 {
+				continue
+			}
+			thread, err := a.Bot.ThreadMessages(ctx, channelID, msg.Timestamp)
+			if err != nil {
+				log.Printf("error reading replies to %s in %s: %v", msg.Timestamp, channelID, err)
+				continue
+			}
+			// The root was indexed with the rest of the history.
+			for i, reply := range thread {
+				if reply.Timestamp == msg.Timestamp {
+					thread = append(thread[:i], thread[i+1:]...)
+					break
+				}
+			}
+			indexAll(thread)
+		}
+
+		if !history.HasMore || history.ResponseMetaData.NextCursor == "" {
+			return indexed, tokens, nil
+		}
+		params.Cursor = history.ResponseMetaData.NextCursor
+	}
+}
+
+// indexMessage embeds a message if it's worth keeping, returning the tokens billed, or zero if
+// it was skipped.
+func (a *MessageArchive) indexMessage(ctx context.Context, channelID, userID, ts, threadTS, text, botID string) (int, error) {
+	minLength := a.MinLength
+	if minLength == 0 {
+		minLength = defaultMinArchiveLength
+	}
+	// The bots' own chatter, eg. search results and previews, would drown out people.
+	if botID != "" || len(strings.TrimSpace(text)) < minLength {
+		return 0, nil
+	}
+
+	permalink, err := a.Bot.GetPermalinkContext(ctx, &slack.PermalinkParameters{Channel: channelID, Ts: ts})
+	if err != nil {
+		return 0, fmt.Errorf("error getting permalink: %v", err)
+	}
+	if threadTS == ts {
+		threadTS = ""
+	}
+	return a.Search.IndexMessage(ctx, &search.Message{
+		ChannelID: channelID,
+		UserID:    userID,
+		TS:        ts,
+		ThreadTS:  threadTS,
+		Text:      text,
+		Permalink: permalink,
+	})
+}
+
+// RecallCommand answers /recall with archived messages about a query:
+//
+//	/recall bike lane pitch
+//
+// Results are only shown to whoever asked, and only come from channels they're in, so private
+// channels in the archive stay private. Call HandleSlashCommand from the bot's handler for the
+// command it's registered as.
+type RecallCommand struct {
+	Bot    *SlackBot
+	Search *search.Client
+	// Limit is how many messages to show. Zero means 5.
+	Limit int
+}
+
+func (c *RecallCommand) HandleSlashCommand(ctx context.Context, env *Envelope, cmd string) ([]slack.Block, error) {
+	var query string
+	if slashCmd, ok := env.Raw.(slack.SlashCommand); ok {
+		query = strings.TrimSpace(slashCmd.Text)
+	}
+	if query == "" {
+		return nil, fmt.Errorf("usage: %s <what you're trying to remember>", cmd)
+	}
+	limit := c.Limit
+	if limit == 0 {
+		limit = 5
+	}
+
+	channels, err := c.Bot.UserChannels(ctx, env.UserID)
+	if err != nil {
+		return nil, err
+	}
+	msgs := []*search.RecalledMessage{}
+	if len(channels) > 0 {
+		msgs, err = c.Search.Recall(query, search.WithLimit(limit), search.InChannels(channels...))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) == 0 {
+		return []slack.Block{
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType,
+				fmt.Sprintf("Nothing in the archive about _%s_.", query), false, false), nil, nil),
+		}, nil
+	}
+
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType,
+			fmt.Sprintf(":mag: From the archive, about _%s_:", query), false, false), nil, nil),
+	}
+	for _, msg := range msgs {
+		text := fmt.Sprintf("<@%s> in <#%s>, %s\n>%s", msg.UserID, msg.ChannelID, slackDate(msg.Time()),
+			strings.ReplaceAll(msg.Snippet, "\n", "\n>"))
+		if msg.Permalink != "" {
+			text += fmt.Sprintf("\n<%s|View message>", msg.Permalink)
+		}
+		blocks = append(blocks, slack.NewDividerBlock(),
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil))
+	}
+	return blocks, nil
+}
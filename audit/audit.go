@@ -0,0 +1,81 @@
+// Package audit records what the bots did, and with what, so results can be traced and
+// reproduced later.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/geomodulus/robots/reqid"
+)
+
+// Entry is one audited action.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Actor is who or what asked for the action, eg. a Slack user ID or a job name.
+	Actor  string            `json:"actor,omitempty"`
+	Action string            `json:"action"`
+	Fields map[string]string `json:"fields,omitempty"`
+	// RequestID ties the entry to the bot's log lines for the same event. Writer fills it in
+	// from ctx.
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Log records entries.
+type Log interface {
+	Record(ctx context.Context, entry Entry) error
+}
+
+// Discard is a Log that drops everything.
+var Discard Log = discard{}
+
+type discard struct{}
+
+func (discard) Record(context.Context, Entry) error { return nil }
+
+// Writer is a Log that writes entries as JSON lines, eg. to stdout for Cloud Logging or to a
+// file.
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (l *Writer) Record(ctx context.Context, entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if entry.RequestID == "" {
+		entry.RequestID = reqid.From(ctx)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error encoding audit entry: %v", err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("error writing audit entry: %v", err)
+	}
+	return nil
+}
+
+type actorKey struct{}
+
+// WithActor attaches the actor to ctx, so entries recorded deeper in the call can name them.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor returns the actor attached to ctx, if any.
+func Actor(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/geomodulus/robots/state"
+)
+
+// defaultDailyPrefix is where Daily keeps entries, unless Prefix says otherwise.
+const defaultDailyPrefix = "audit/"
+
+// Daily is a Log that keeps entries in a state.Store, under one key per UTC day, so jobs like
+// the daily digest can count what happened across restarts. Each entry rewrites its day's key,
+// so record searches and uploads to it, not every request.
+type Daily struct {
+	Store state.Store
+	// Prefix is the folder days are kept in, by default "audit/".
+	Prefix string
+
+	mu sync.Mutex
+}
+
+// NewDaily returns a Daily keeping entries in store.
+func NewDaily(store state.Store) *Daily {
+	return &Daily{Store: store}
+}
+
+func (l *Daily) Record(ctx context.Context, entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	key := l.key(entry.Time)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := []Entry{}
+	if _, err := l.Store.Get(ctx, key, &entries); err != nil {
+		return fmt.Errorf("error loading %s: %v", key, err)
+	}
+	if err := l.Store.Put(ctx, key, append(entries, entry)); err != nil {
+		return fmt.Errorf("error saving %s: %v", key, err)
+	}
+	return nil
+}
+
+// Entries returns the entries for action recorded in [from, to), oldest first. An empty action
+// matches every entry.
+func (l *Daily) Entries(ctx context.Context, action string, from, to time.Time) ([]Entry, error) {
+	matched := []Entry{}
+	for day := from.UTC().Truncate(24 * time.Hour); day.Before(to); day = day.Add(24 * time.Hour) {
+		key := l.key(day)
+		entries := []Entry{}
+		if _, err := l.Store.Get(ctx, key, &entries); err != nil {
+			return nil, fmt.Errorf("error loading %s: %v", key, err)
+		}
+		for _, entry := range entries {
+			if (action == "" || entry.Action == action) && !entry.Time.Before(from) && entry.Time.Before(to) {
+				matched = append(matched, entry)
+			}
+		}
+	}
+	return matched, nil
+}
+
+func (l *Daily) key(t time.Time) string {
+	prefix := defaultDailyPrefix
+	if l.Prefix != "" {
+		prefix = strings.TrimSuffix(l.Prefix, "/") + "/"
+	}
+	return prefix + t.UTC().Format("2006-01-02")
+}
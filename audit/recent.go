@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Recent is a Log that keeps the last entries in memory. It forgets everything on restart; use
+// Daily for counts that have to survive one, and pair either with a Writer using Tee to keep a
+// durable record.
+type Recent struct {
+	mu      sync.Mutex
+	max     int
+	entries []Entry
+}
+
+// NewRecent returns a Recent that keeps up to max entries.
+func NewRecent(max int) *Recent {
+	return &Recent{max: max}
+}
+
+func (l *Recent) Record(_ context.Context, entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+	if len(l.entries) > l.max {
+		l.entries = l.entries[len(l.entries)-l.max:]
+	}
+	return nil
+}
+
+// Entries returns the entries for action recorded in [from, to), oldest first. An empty action
+// matches every entry.
+func (l *Recent) Entries(action string, from, to time.Time) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := []Entry{}
+	for _, entry := range l.entries {
+		if (action == "" || entry.Action == action) && !entry.Time.Before(from) && entry.Time.Before(to) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Tee returns a Log that records every entry to each of logs, returning the first error.
+func Tee(logs ...Log) Log {
+	return tee(logs)
+}
+
+type tee []Log
+
+func (t tee) Record(ctx context.Context, entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	var firstErr error
+	for _, l := range t {
+		if err := l.Record(ctx, entry); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,251 @@
+package robots
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	"github.com/geomodulus/robots/github"
+	"github.com/geomodulus/robots/reqid"
+	"github.com/geomodulus/robots/search"
+)
+
+const (
+	// defaultBackupPrefix is where BackupJob keeps its dated folders, unless Prefix says
+	// otherwise.
+	defaultBackupPrefix = "backups/"
+	// defaultBackupRetention is how long backups are kept, unless Retention says otherwise.
+	defaultBackupRetention = 30 * 24 * time.Hour
+	// backupDateLayout names each day's folder.
+	backupDateLayout = "2006-01-02"
+)
+
+// BackupJob copies the content repos' metadata, every article.json and poi.json, and an export
+// of the search index into a dated folder of a GCS bucket, so the site can be rebuilt if GitHub
+// or Pinecone lose them. Each folder looks like
+//
+//	backups/2023-06-01/articles/articles/king-street-update/article.json
+//	backups/2023-06-01/places/active_places/union-station/poi.json
+//	backups/2023-06-01/search/articles.json
+//	backups/2023-06-01/manifest.json
+//
+// with files under articles/ and places/ at their paths in their repo. The manifest is written
+// last, so a folder without one is an incomplete backup. Folders older than Retention are deleted
+// after each complete backup. Register Run with a Scheduler, eg.
+//
+//	scheduler.Daily("backup", 2, 0, toronto, job.Run)
+type BackupJob struct {
+	Uploader *Uploader
+	// Bucket is where backups are kept. It should be private, so never the media bucket:
+	// drafts' metadata is in the backup.
+	Bucket   string
+	Articles *github.App
+	// Places and Search, if set, are backed up too.
+	Places *github.App
+	Search *search.Client
+	// Namespaces are the search namespaces exported, by default search.ExportedNamespaces.
+	Namespaces []string
+	// Prefix is the folder backups go in, by default "backups/".
+	Prefix string
+	// Retention is how long backups are kept, by default 30 days. Each day's folder is kept
+	// until it's older than Retention.
+	Retention time.Duration
+	// Location is the time zone that decides which day a backup is for, by default UTC.
+	Location *time.Location
+	// Bot and Channel, if set, get a note of each run.
+	Bot     *SlackBot
+	Channel string
+}
+
+// BackupManifest describes a backup, as manifest.json.
+type BackupManifest struct {
+	Date    string    `json:"date"`
+	Created time.Time `json:"created"`
+	// ArticlesSHA and PlacesSHA are the commits on main the metadata was copied from.
+	ArticlesSHA string `json:"articles_sha"`
+	PlacesSHA   string `json:"places_sha,omitempty"`
+	// Files lists every file in the backup but the manifest, relative to its folder.
+	Files []string `json:"files"`
+	// Vectors counts the vectors exported from each search namespace, "articles" being the
+	// default namespace.
+	Vectors map[string]int `json:"vectors,omitempty"`
+	// Truncated lists the search namespaces too large to export completely.
+	Truncated []string `json:"truncated,omitempty"`
+}
+
+func (j *BackupJob) Run(ctx context.Context) error {
+	if j.Bucket == "" {
+		return fmt.Errorf("no bucket set for backups")
+	}
+	loc := j.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	now := time.Now().In(loc)
+	date := now.Format(backupDateLayout)
+	folder := j.prefix() + date + "/"
+	manifest := &BackupManifest{
+		Date:    date,
+		Created: now,
+		Files:   []string{},
+		Vectors: map[string]int{},
+	}
+	put := func(name string, content []byte) error {
+		if err := j.Uploader.Put(ctx, j.Bucket, folder+name, content, "application/json"); err != nil {
+			return fmt.Errorf("error backing up %s: %w", name, err)
+		}
+		manifest.Files = append(manifest.Files, name)
+		return nil
+	}
+
+	articles, err := j.Articles.SnapshotMetadata(ctx)
+	if err != nil {
+		return fmt.Errorf("error snapshotting articles: %w", err)
+	}
+	manifest.ArticlesSHA = articles.SHA
+	if err := putSnapshot(articles, "articles/", put); err != nil {
+		return err
+	}
+	articleCount := len(articles.Files)
+
+	placeCount := 0
+	if j.Places != nil {
+		places, err := j.Places.SnapshotMetadata(ctx)
+		if err != nil {
+			return fmt.Errorf("error snapshotting places: %w", err)
+		}
+		manifest.PlacesSHA = places.SHA
+		if err := putSnapshot(places, "places/", put); err != nil {
+			return err
+		}
+		placeCount = len(places.Files)
+	}
+
+	if j.Search != nil {
+		namespaces := j.Namespaces
+		if namespaces == nil {
+			namespaces = search.ExportedNamespaces
+		}
+		for _, namespace := range namespaces {
+			export, err := j.Search.Export(ctx, namespace)
+			if err != nil {
+				return fmt.Errorf("error exporting search namespace %q: %w", namespace, err)
+			}
+			name := namespace
+			if name == "" {
+				name = "articles"
+			}
+			content, err := json.Marshal(export)
+			if err != nil {
+				return fmt.Errorf("error marshaling search namespace %q: %w", namespace, err)
+			}
+			if err := put("search/"+name+".json", content); err != nil {
+				return err
+			}
+			manifest.Vectors[name] = len(export.Vectors)
+			if export.Truncated {
+				manifest.Truncated = append(manifest.Truncated, name)
+			}
+		}
+	}
+
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling manifest: %w", err)
+	}
+	if err := j.Uploader.Put(ctx, j.Bucket, folder+"manifest.json", content, "application/json"); err != nil {
+		return fmt.Errorf("error backing up manifest.json: %w", err)
+	}
+
+	// Only a complete backup makes older ones expendable, so a run of failures never leaves us
+	// with nothing.
+	expired, err := j.expire(ctx, now)
+	if err != nil {
+		// Today's backup is safe, so a failed cleanup only costs storage until tomorrow.
+		reqid.Printf(ctx, "error expiring old backups: %v", err)
+	}
+
+	text := fmt.Sprintf("*Backup* — %d articles and %d places to `gs://%s/%s`", articleCount, placeCount, j.Bucket, folder)
+	if len(manifest.Vectors) > 0 {
+		total := 0
+		for _, count := range manifest.Vectors {
+			total += count
+		}
+		text += fmt.Sprintf(", with %d search vectors", total)
+	}
+	if len(manifest.Truncated) > 0 {
+		text += fmt.Sprintf("\n:warning: search namespaces too large to export in full: %s", strings.Join(manifest.Truncated, ", "))
+	}
+	if len(expired) > 0 {
+		text += fmt.Sprintf("\nDeleted backups older than %d days: %s", int(j.retention().Hours()/24), strings.Join(expired, ", "))
+	}
+	if err != nil {
+		text += fmt.Sprintf("\n:warning: couldn't delete old backups: %v", err)
+	}
+	reqid.Printf(ctx, "backed up %d articles and %d places to gs://%s/%s", articleCount, placeCount, j.Bucket, folder)
+	if j.Bot == nil || j.Channel == "" {
+		return nil
+	}
+	_, _, err = j.Bot.PostMessageContext(ctx, j.Channel, slack.MsgOptionBlocks(
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+	))
+	return err
+}
+
+// putSnapshot stores a snapshot's files under dir, in path order.
+func putSnapshot(snapshot *github.MetadataSnapshot, dir string, put func(name string, content []byte) error) error {
+	paths := make([]string, 0, len(snapshot.Files))
+	for p := range snapshot.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		if err := put(dir+p, snapshot.Files[p]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expire deletes the dated folders older than the retention period and returns their dates.
+// Folders whose names aren't dates are left alone.
+func (j *BackupJob) expire(ctx context.Context, now time.Time) ([]string, error) {
+	folders, err := j.Uploader.Folders(ctx, j.Bucket, j.prefix())
+	if err != nil {
+		return nil, err
+	}
+	cutoff := now.Add(-j.retention())
+	expired := []string{}
+	for _, folder := range folders {
+		date := path.Base(folder)
+		day, err := time.ParseInLocation(backupDateLayout, date, now.Location())
+		if err != nil || !day.Before(cutoff) {
+			continue
+		}
+		if _, err := j.Uploader.DeleteFolder(ctx, j.Bucket, folder); err != nil {
+			return expired, err
+		}
+		expired = append(expired, date)
+	}
+	return expired, nil
+}
+
+func (j *BackupJob) prefix() string {
+	if j.Prefix == "" {
+		return defaultBackupPrefix
+	}
+	return strings.TrimSuffix(j.Prefix, "/") + "/"
+}
+
+func (j *BackupJob) retention() time.Duration {
+	if j.Retention == 0 {
+		return defaultBackupRetention
+	}
+	return j.Retention
+}
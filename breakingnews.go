@@ -0,0 +1,239 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	"github.com/geomodulus/robots/audit"
+	"github.com/geomodulus/robots/command"
+	"github.com/geomodulus/robots/flags"
+	"github.com/geomodulus/robots/github"
+	"github.com/geomodulus/robots/state"
+)
+
+// Audit actions recorded by BreakingNews.
+const (
+	AuditBreakingNewsOn    = "breaking_news.on"
+	AuditBreakingNewsOff   = "breaking_news.off"
+	AuditBreakingNewsWaive = "breaking_news.waive"
+)
+
+// defaultBreakingNewsTTL is how long breaking-news mode lasts if no one switches it off, so it
+// can't be forgotten on a story for good.
+const defaultBreakingNewsTTL = 6 * time.Hour
+
+const breakingNewsKey = "breaking-news"
+
+// BreakingNews relaxes the normal workflow for the slugs an admin puts in breaking-news mode:
+// optional checks like image credits are skipped, uploads don't wait for their credit, and pull
+// requests go to a fast-review set of reviewers. Everything it waives is recorded in Audit.
+// Switching flags.AutoPublish off puts every story back through the normal workflow without
+// taking it out of the mode.
+//
+//	/breaking
+//	/breaking on transit-strike-day-one Union Station closed
+//	/breaking off transit-strike-day-one
+//
+// Call HandleSlashCommand from the bot's handler for the command it's registered as, and pass it
+// to the components that honour it. A nil BreakingNews is never active.
+type BreakingNews struct {
+	Store state.Store
+	Audit audit.Log
+	// Admins are the Slack user IDs allowed to switch the mode. Anyone can list it.
+	Admins []string
+	// Reviewers are requested instead of the usual reviewers on pull requests for slugs in
+	// breaking-news mode.
+	Reviewers []string
+	// TTL defaults to 6 hours.
+	TTL time.Duration
+	// Flags, if set, can stop the fast track with flags.AutoPublish.
+	Flags *flags.Set
+
+	mu sync.Mutex
+}
+
+type breakingNewsMode struct {
+	Actor   string    `json:"actor"`
+	Reason  string    `json:"reason,omitempty"`
+	Started time.Time `json:"started"`
+	Expires time.Time `json:"expires"`
+}
+
+var breakingNewsGrammar = &command.Grammar{
+	Default: "list",
+	Commands: []*command.Command{
+		{Verb: "list", Help: "Show the slugs in breaking-news mode."},
+		{
+			Verb: "on",
+			Args: []command.Arg{{Name: "slug", Kind: command.Slug}, {Name: "reason", Optional: true, Rest: true}},
+			Help: "Put a slug in breaking-news mode.",
+		},
+		{Verb: "off", Args: []command.Arg{{Name: "slug", Kind: command.Slug}}, Help: "Take a slug out of breaking-news mode."},
+	},
+}
+
+func (b *BreakingNews) HandleSlashCommand(ctx context.Context, env *Envelope, cmd string) ([]slack.Block, error) {
+	inv, help, err := parseCommand(env, breakingNewsGrammar.WithName(cmd))
+	if inv == nil {
+		return help, err
+	}
+	if inv.Verb() == "list" {
+		return b.list(ctx)
+	}
+	if !isAdmin(b.Admins, env.UserID) {
+		return nil, fmt.Errorf("only admins can switch breaking-news mode")
+	}
+	slug := inv.Arg("slug")
+	ctx = audit.WithActor(ctx, env.UserID)
+
+	if inv.Verb() == "on" {
+		ttl := b.TTL
+		if ttl == 0 {
+			ttl = defaultBreakingNewsTTL
+		}
+		reason := inv.Arg("reason")
+		now := time.Now()
+		if err := b.update(ctx, func(modes map[string]*breakingNewsMode) {
+			modes[slug] = &breakingNewsMode{Actor: env.UserID, Reason: reason, Started: now, Expires: now.Add(ttl)}
+		}); err != nil {
+			return nil, err
+		}
+		b.record(ctx, AuditBreakingNewsOn, slug, map[string]string{"reason": reason, "expires": now.Add(ttl).Format(time.RFC3339)})
+	} else {
+		if err := b.update(ctx, func(modes map[string]*breakingNewsMode) {
+			delete(modes, slug)
+		}); err != nil {
+			return nil, err
+		}
+		b.record(ctx, AuditBreakingNewsOff, slug, nil)
+	}
+	return b.list(ctx)
+}
+
+// Active reports whether slug is in breaking-news mode.
+func (b *BreakingNews) Active(ctx context.Context, slug string) bool {
+	if b == nil {
+		return false
+	}
+	modes, err := b.load(ctx)
+	if err != nil {
+		log.Printf("error loading breaking-news mode: %v", err)
+		return false
+	}
+	_, ok := modes[slug]
+	return ok
+}
+
+// fastTracked reports whether slug is in breaking-news mode and flags.AutoPublish is on.
+func (b *BreakingNews) fastTracked(ctx context.Context, slug string) bool {
+	return b.Active(ctx, slug) && b.Flags.Enabled(ctx, flags.AutoPublish)
+}
+
+// PullRequestOptions appends the fast-review reviewers and github.SkipOptionalChecks to opts if
+// slug is fast-tracked, recording the waiver. Otherwise opts are returned as they are.
+func (b *BreakingNews) PullRequestOptions(ctx context.Context, env *Envelope, slug string, opts ...github.Option) []github.Option {
+	if !b.fastTracked(ctx, slug) {
+		return opts
+	}
+	opts = append(opts, github.SkipOptionalChecks())
+	if len(b.Reviewers) > 0 {
+		opts = append(opts, github.WithReviewers(b.Reviewers...))
+	}
+	b.record(audit.WithActor(ctx, env.UserID), AuditBreakingNewsWaive, slug, map[string]string{
+		"waived":    "optional checks",
+		"reviewers": strings.Join(b.Reviewers, ","),
+	})
+	return opts
+}
+
+// Approves reports whether what, eg. an upload, goes ahead for slug without the usual approval
+// step because slug is fast-tracked, recording the waiver if so.
+func (b *BreakingNews) Approves(ctx context.Context, env *Envelope, slug, what string) bool {
+	if !b.fastTracked(ctx, slug) {
+		return false
+	}
+	b.record(audit.WithActor(ctx, env.UserID), AuditBreakingNewsWaive, slug, map[string]string{"waived": what})
+	return true
+}
+
+func (b *BreakingNews) list(ctx context.Context) ([]slack.Block, error) {
+	modes, err := b.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slugs := make([]string, 0, len(modes))
+	for slug := range modes {
+		slugs = append(slugs, slug)
+	}
+	sort.Strings(slugs)
+
+	lines := []string{}
+	for _, slug := range slugs {
+		mode := modes[slug]
+		line := fmt.Sprintf(":rotating_light: `%s`, by <@%s> until %s", slug, mode.Actor, slackDate(mode.Expires))
+		if mode.Reason != "" {
+			line += ": " + escapeMrkdwn(mode.Reason)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "No stories are in breaking-news mode.")
+	} else if !b.Flags.Enabled(ctx, flags.AutoPublish) {
+		lines = append(lines, fmt.Sprintf(":warning: `%s` is switched off, so these stories go through the normal workflow.", flags.AutoPublish))
+	}
+	return []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType,
+			"*Breaking news*\n"+strings.Join(lines, "\n"), false, false), nil, nil),
+	}, nil
+}
+
+// load returns the slugs in breaking-news mode, leaving out expired ones.
+func (b *BreakingNews) load(ctx context.Context) (map[string]*breakingNewsMode, error) {
+	modes := map[string]*breakingNewsMode{}
+	if _, err := b.Store.Get(ctx, breakingNewsKey, &modes); err != nil {
+		return nil, fmt.Errorf("error loading breaking-news mode: %v", err)
+	}
+	now := time.Now()
+	for slug, mode := range modes {
+		if now.After(mode.Expires) {
+			delete(modes, slug)
+		}
+	}
+	return modes, nil
+}
+
+func (b *BreakingNews) update(ctx context.Context, change func(map[string]*breakingNewsMode)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	modes, err := b.load(ctx)
+	if err != nil {
+		return err
+	}
+	change(modes)
+	if err := b.Store.Put(ctx, breakingNewsKey, modes); err != nil {
+		return fmt.Errorf("error saving breaking-news mode: %v", err)
+	}
+	return nil
+}
+
+func (b *BreakingNews) record(ctx context.Context, action, slug string, fields map[string]string) {
+	if b.Audit == nil {
+		return
+	}
+	entry := audit.Entry{Actor: audit.Actor(ctx), Action: action, Fields: map[string]string{"slug": slug}}
+	for key, value := range fields {
+		if value != "" {
+			entry.Fields[key] = value
+		}
+	}
+	if err := b.Audit.Record(ctx, entry); err != nil {
+		log.Printf("error auditing %s: %v", action, err)
+	}
+}
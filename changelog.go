@@ -0,0 +1,41 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/geomodulus/robots/github"
+	"github.com/geomodulus/robots/pubdate"
+)
+
+// ChangelogJob posts the past week's content changes to Channel as Markdown, ready for the
+// newsletter. Register Run with a Scheduler, eg.
+//
+//	scheduler.Weekly("changelog", time.Monday, 9, 0, toronto, job.Run)
+type ChangelogJob struct {
+	Bot      *SlackBot
+	Articles *github.App
+	Channel  string
+	// Location decides when the week began. Defaults to Toronto time.
+	Location *time.Location
+}
+
+func (j *ChangelogJob) Run(ctx context.Context) error {
+	loc := j.Location
+	if loc == nil {
+		loc = pubdate.Toronto
+	}
+	now := time.Now().In(loc)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	from := to.AddDate(0, 0, -7)
+
+	changelog, err := j.Articles.Changelog(ctx, from, to)
+	if err != nil {
+		return err
+	}
+	env := &Envelope{ChannelID: j.Channel, bot: j.Bot}
+	_, err = j.Bot.PostLong(ctx, env, fmt.Sprintf("Changelog for the week of %s", from.Format("January 2")),
+		changelog.Markdown(), OutputAuto)
+	return err
+}
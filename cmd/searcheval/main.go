@@ -0,0 +1,86 @@
+// Command searcheval scores search against a golden set of queries and the articles they should
+// find, so a change to embeddings or chunking can be measured before it ships:
+//
+//	OPENAI_API_KEY=… PINECONE_API_KEY=… go run ./cmd/searcheval -k 5 golden.jsonl
+//
+// The golden set has one {"query": …, "slug": …} object per line. It prints every miss, then
+// recall@k and MRR, and exits non-zero if recall is under -min-recall.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/geomodulus/robots/search"
+)
+
+func main() {
+	k := flag.Int("k", 5, "how many results count as found")
+	category := flag.String("category", "", "only search articles in this category")
+	minRecall := flag.Float64("min-recall", 0, "fail if recall@k is below this")
+	verbose := flag.Bool("v", false, "print every query, not just misses")
+	env := flag.String("env", string(search.Production), "search environment: prod, staging or dev")
+	synonymsPath := flag.String("synonyms", "", "expand queries with the synonyms in this JSON file")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] golden.jsonl\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	golden, err := search.ReadGoldenSet(f)
+	f.Close()
+	if err != nil {
+		log.Fatalf("%s: %v", flag.Arg(0), err)
+	}
+
+	clientOpts := []search.ClientOption{search.WithoutWarmUp(), search.WithEnvironment(search.Environment(*env))}
+	if *synonymsPath != "" {
+		f, err := os.Open(*synonymsPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		synonyms, err := search.LoadSynonyms(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("%s: %v", *synonymsPath, err)
+		}
+		clientOpts = append(clientOpts, search.WithSynonyms(synonyms))
+	}
+	client, err := search.NewClient(os.Getenv("OPENAI_API_KEY"), os.Getenv("PINECONE_API_KEY"), clientOpts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts := []search.QueryOption{search.WithLimit(*k)}
+	if *category != "" {
+		opts = append(opts, search.InCategory(*category))
+	}
+	report, err := client.Evaluate(context.Background(), golden, opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, result := range report.Results {
+		switch {
+		case result.Rank == 0:
+			fmt.Printf("MISS  %q wanted %s, got %s\n", result.Query, result.Slug, strings.Join(result.Slugs, ", "))
+		case *verbose:
+			fmt.Printf("#%-4d %q found %s\n", result.Rank, result.Query, result.Slug)
+		}
+	}
+	fmt.Println(report)
+	if report.Recall < *minRecall {
+		os.Exit(1)
+	}
+}
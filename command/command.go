@@ -0,0 +1,465 @@
+// Package command parses what editors type to the bots, as a slash command, eg.
+// "/rollback king-street-update", or a mention, eg. "@bot publish king-street-update with title
+// 'New data'", against a grammar of verbs, arguments and flags. The grammar also writes the
+// help and usage messages, so they can't drift from what's accepted.
+package command
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ErrHelp is returned by Parse when the user asks for help, eg. "/flags help". Reply with
+// Grammar.Help.
+var ErrHelp = errors.New("help requested")
+
+// Kind says how an argument or flag value is checked.
+type Kind int
+
+const (
+	// Text is any value.
+	Text Kind = iota
+	// Slug is an article or place slug, eg. "king-street-update". Backticks, quotes and angle
+	// brackets around it are dropped, since Slack adds them when slugs are pasted.
+	Slug
+)
+
+// Arg is a positional argument.
+type Arg struct {
+	Name string
+	Kind Kind
+	// Optional arguments can be left off the end.
+	Optional bool
+	// Rest takes the remaining words, joined by spaces, eg. a reason. It must be last.
+	Rest bool
+}
+
+// Flag is a named option, given as "--name value", "--name=value", or in a mention as "with
+// name value". Values with spaces need quotes.
+type Flag struct {
+	Name string
+	Kind Kind
+	// Bool flags take no value: "--draft" or "with draft".
+	Bool bool
+	Help string
+}
+
+// Command is one verb of a grammar.
+type Command struct {
+	// Verb is the first word, eg. "publish", or words, eg. "file a bug". A command with no verb
+	// takes any text that doesn't start with another command's verb, eg. "/rollback <slug>".
+	Verb    string
+	Aliases []string
+	Args    []Arg
+	Flags   []Flag
+	Help    string
+}
+
+// Grammar is the commands a handler understands.
+type Grammar struct {
+	// Name is how the commands are invoked, eg. "/flags" or "@bot", for help and usage.
+	Name     string
+	Commands []*Command
+	// Default is the verb run when there's no text at all, eg. "list".
+	Default string
+}
+
+// WithName returns a copy of g invoked as name, eg. the slash command a handler is registered
+// as.
+func (g *Grammar) WithName(name string) *Grammar {
+	named := *g
+	named.Name = name
+	return &named
+}
+
+// Invocation is a parsed command.
+type Invocation struct {
+	Command *Command
+	Args    map[string]string
+	Flags   map[string]string
+}
+
+// Verb is the command's verb, not whichever alias was typed.
+func (i *Invocation) Verb() string {
+	return i.Command.Verb
+}
+
+// Arg returns the named argument, or "" if it was left off.
+func (i *Invocation) Arg(name string) string {
+	return i.Args[name]
+}
+
+// Flag returns the named flag's value, or "" if it wasn't given.
+func (i *Invocation) Flag(name string) string {
+	return i.Flags[name]
+}
+
+// Has reports whether the named flag was given.
+func (i *Invocation) Has(name string) bool {
+	_, ok := i.Flags[name]
+	return ok
+}
+
+// UsageError is returned by Parse for text that doesn't fit the grammar. Its message says what's
+// wrong and how the command is used.
+type UsageError struct {
+	Reason string
+	Usage  string
+}
+
+func (e *UsageError) Error() string {
+	if e.Reason == "" {
+		return "usage: " + e.Usage
+	}
+	return e.Reason + "; usage: " + e.Usage
+}
+
+var (
+	// leadingMentionRe matches the bot's mention at the start of a mention's text.
+	leadingMentionRe = regexp.MustCompile(`^\s*<@[A-Z0-9]+(?:\|[^>]*)?>`)
+	slugRe           = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+)
+
+// token is a word of the input. Quoted words are never verbs or flags.
+type token struct {
+	text   string
+	quoted bool
+}
+
+// Parse matches text, a slash command's text or a mention's, against the grammar.
+func (g *Grammar) Parse(text string) (*Invocation, error) {
+	text = leadingMentionRe.ReplaceAllString(text, "")
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, &UsageError{Reason: err.Error(), Usage: g.usage()}
+	}
+
+	var cmd *Command
+	switch {
+	case len(tokens) == 0 && g.Default != "":
+		cmd = g.find(g.Default)
+	case len(tokens) > 0 && !tokens[0].quoted && strings.EqualFold(tokens[0].text, "help") && g.find("help") == nil:
+		return nil, ErrHelp
+	case len(tokens) > 0:
+		var n int
+		if cmd, n = g.match(tokens); cmd != nil {
+			tokens = tokens[n:]
+		}
+	}
+	if cmd == nil {
+		cmd = g.find("")
+	}
+	if cmd == nil {
+		if len(tokens) == 0 {
+			return nil, &UsageError{Usage: g.usage()}
+		}
+		return nil, &UsageError{Reason: fmt.Sprintf("unknown command %q", tokens[0].text), Usage: g.usage()}
+	}
+	return g.parseCommand(cmd, tokens)
+}
+
+// Search is Parse for mentions that ask in passing, eg. "@bot can you analyze
+// king-street-update": it parses from the first of the grammar's verbs in text, or all of text
+// if there isn't one.
+func (g *Grammar) Search(text string) (*Invocation, error) {
+	text = leadingMentionRe.ReplaceAllString(text, "")
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, &UsageError{Reason: err.Error(), Usage: g.usage()}
+	}
+	if i := g.index(tokens); i >= 0 {
+		cmd, n := g.match(tokens[i:])
+		return g.parseCommand(cmd, tokens[i+n:])
+	}
+	return g.Parse(text)
+}
+
+// Mentions reports whether one of the grammar's verbs appears anywhere in text, eg. so a mention
+// handler can tell which of several grammars a mention is for.
+func (g *Grammar) Mentions(text string) bool {
+	tokens, err := tokenize(leadingMentionRe.ReplaceAllString(text, ""))
+	return err == nil && g.index(tokens) >= 0
+}
+
+// index returns where the first of the grammar's verbs starts in tokens, or -1.
+func (g *Grammar) index(tokens []token) int {
+	for i := range tokens {
+		if cmd, _ := g.match(tokens[i:]); cmd != nil {
+			return i
+		}
+	}
+	return -1
+}
+
+// match returns the command whose verb or alias tokens start with, and how many tokens it takes
+// up.
+func (g *Grammar) match(tokens []token) (*Command, int) {
+	for _, cmd := range g.Commands {
+		for _, verb := range append([]string{cmd.Verb}, cmd.Aliases...) {
+			if n := startsWith(tokens, verb); n > 0 {
+				return cmd, n
+			}
+		}
+	}
+	return nil, 0
+}
+
+// startsWith returns how many tokens verb's words take up at the start of tokens, or 0 if they
+// don't start with it. Punctuation after a word is ignored, as in "file a bug: the map is
+// broken".
+func startsWith(tokens []token, verb string) int {
+	words := strings.Fields(verb)
+	if len(words) == 0 || len(words) > len(tokens) {
+		return 0
+	}
+	for i, word := range words {
+		if tokens[i].quoted || !strings.EqualFold(strings.TrimRight(tokens[i].text, ".,:!?"), word) {
+			return 0
+		}
+	}
+	return len(words)
+}
+
+func (g *Grammar) find(verb string) *Command {
+	for _, cmd := range g.Commands {
+		if strings.EqualFold(cmd.Verb, verb) {
+			return cmd
+		}
+		for _, alias := range cmd.Aliases {
+			if strings.EqualFold(alias, verb) {
+				return cmd
+			}
+		}
+	}
+	return nil
+}
+
+func (g *Grammar) parseCommand(cmd *Command, tokens []token) (*Invocation, error) {
+	inv := &Invocation{Command: cmd, Args: map[string]string{}, Flags: map[string]string{}}
+	usageErr := func(format string, args ...any) error {
+		return &UsageError{Reason: fmt.Sprintf(format, args...), Usage: g.Usage(cmd)}
+	}
+
+	positional := []token{}
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		var name, value string
+		var hasValue bool
+		switch {
+		case !tok.quoted && strings.HasPrefix(tok.text, "--"):
+			name, value, hasValue = strings.Cut(strings.TrimPrefix(tok.text, "--"), "=")
+			if cmd.flag(name) == nil {
+				return nil, usageErr("unknown flag --%s", name)
+			}
+		case !tok.quoted && isJoiner(tok.text) && i+1 < len(tokens) && !tokens[i+1].quoted && cmd.flag(tokens[i+1].text) != nil:
+			i++
+			name = tokens[i].text
+		default:
+			positional = append(positional, tok)
+			continue
+		}
+
+		flag := cmd.flag(name)
+		if flag.Bool {
+			if hasValue {
+				return nil, usageErr("--%s doesn't take a value", flag.Name)
+			}
+			inv.Flags[flag.Name] = "true"
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(tokens) {
+				return nil, usageErr("%s needs a value", flag.Name)
+			}
+			i++
+			value = tokens[i].text
+		}
+		value, err := check(flag.Kind, flag.Name, value)
+		if err != nil {
+			return nil, usageErr("%v", err)
+		}
+		inv.Flags[flag.Name] = value
+	}
+
+	for _, arg := range cmd.Args {
+		if len(positional) == 0 {
+			if !arg.Optional {
+				return nil, usageErr("missing <%s>", arg.Name)
+			}
+			break
+		}
+		var value string
+		if arg.Rest {
+			words := []string{}
+			for _, tok := range positional {
+				words = append(words, tok.text)
+			}
+			value = strings.Join(words, " ")
+			positional = nil
+		} else {
+			value = positional[0].text
+			positional = positional[1:]
+		}
+		value, err := check(arg.Kind, arg.Name, value)
+		if err != nil {
+			return nil, usageErr("%v", err)
+		}
+		inv.Args[arg.Name] = value
+	}
+	if len(positional) > 0 {
+		return nil, usageErr("unexpected %q", positional[0].text)
+	}
+	return inv, nil
+}
+
+func (c *Command) flag(name string) *Flag {
+	for i := range c.Flags {
+		if strings.EqualFold(c.Flags[i].Name, name) {
+			return &c.Flags[i]
+		}
+	}
+	return nil
+}
+
+// isJoiner reports whether word introduces a flag in a mention, as in "with title 'New data'
+// and draft".
+func isJoiner(word string) bool {
+	return strings.EqualFold(word, "with") || strings.EqualFold(word, "and")
+}
+
+func check(kind Kind, name, value string) (string, error) {
+	if kind != Slug {
+		return value, nil
+	}
+	slug := strings.Trim(value, "`\"'<>")
+	if !slugRe.MatchString(slug) {
+		return "", fmt.Errorf("%q isn't a valid %s", value, name)
+	}
+	return slug, nil
+}
+
+// quotePairs maps each opening quote to its closing quote. Slack turns straight quotes curly
+// on some keyboards.
+var quotePairs = map[rune]rune{
+	'"':  '"',
+	'\'': '\'',
+	'`':  '`',
+	'“':  '”',
+	'‘':  '’',
+}
+
+// tokenize splits text into words, keeping quoted runs together without their quotes. An
+// apostrophe inside a word, as in "don't", doesn't start a quote.
+func tokenize(text string) ([]token, error) {
+	tokens := []token{}
+	var b strings.Builder
+	inWord := false
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		// A quote can also open a flag's value, as in --title="New data".
+		if closing, ok := quotePairs[r]; ok && (!inWord || runes[i-1] == '=') {
+			end := -1
+			for j := i + 1; j < len(runes); j++ {
+				if runes[j] == closing {
+					end = j
+					break
+				}
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed quote %c", r)
+			}
+			if inWord {
+				b.WriteString(string(runes[i+1 : end]))
+			} else {
+				tokens = append(tokens, token{text: string(runes[i+1 : end]), quoted: true})
+			}
+			i = end
+			continue
+		}
+		if unicode.IsSpace(r) {
+			if inWord {
+				tokens = append(tokens, token{text: b.String()})
+				b.Reset()
+				inWord = false
+			}
+			continue
+		}
+		b.WriteRune(r)
+		inWord = true
+	}
+	if inWord {
+		tokens = append(tokens, token{text: b.String()})
+	}
+	return tokens, nil
+}
+
+// Usage is how cmd is invoked, eg. "/breaking on <slug> [<reason>...]".
+func (g *Grammar) Usage(cmd *Command) string {
+	parts := []string{}
+	if g.Name != "" {
+		parts = append(parts, g.Name)
+	}
+	if cmd.Verb != "" {
+		parts = append(parts, cmd.Verb)
+	}
+	for _, arg := range cmd.Args {
+		s := "<" + arg.Name + ">"
+		if arg.Rest {
+			s = "<" + arg.Name + "...>"
+		}
+		if arg.Optional {
+			s = "[" + s + "]"
+		}
+		parts = append(parts, s)
+	}
+	for _, flag := range cmd.Flags {
+		if flag.Bool {
+			parts = append(parts, "[--"+flag.Name+"]")
+		} else {
+			parts = append(parts, "[--"+flag.Name+" <"+flag.Name+">]")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// usage lists every command, eg. "/flags [list | on <flag> | off <flag>]".
+func (g *Grammar) usage() string {
+	forms := []string{}
+	for _, cmd := range g.Commands {
+		form := g.WithName("").Usage(cmd)
+		if form == "" {
+			continue
+		}
+		forms = append(forms, form)
+	}
+	text := strings.Join(forms, " | ")
+	if len(forms) > 1 || g.Default != "" {
+		text = "[" + text + "]"
+	}
+	if g.Name == "" {
+		return text
+	}
+	return g.Name + " " + text
+}
+
+// Help lists every command with what it does and its flags, as Slack mrkdwn.
+func (g *Grammar) Help() string {
+	lines := []string{}
+	for _, cmd := range g.Commands {
+		line := "`" + g.Usage(cmd) + "`"
+		if cmd.Help != "" {
+			line += " — " + cmd.Help
+		}
+		lines = append(lines, line)
+		for _, flag := range cmd.Flags {
+			if flag.Help != "" {
+				lines = append(lines, fmt.Sprintf("    `--%s` %s", flag.Name, flag.Help))
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
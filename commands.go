@@ -0,0 +1,49 @@
+package robots
+
+import (
+	"errors"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+
+	"github.com/geomodulus/robots/command"
+)
+
+// commandText is what the user typed: a slash command's text, or a mention's, including the
+// bot's mention, which command.Grammar.Parse drops.
+func commandText(env *Envelope) string {
+	switch raw := env.Raw.(type) {
+	case slack.SlashCommand:
+		return raw.Text
+	case *slackevents.AppMentionEvent:
+		return raw.Text
+	}
+	return ""
+}
+
+// parseCommand parses env's text against grammar. If the user asked for help, it returns the
+// blocks to answer with instead of an invocation.
+func parseCommand(env *Envelope, grammar *command.Grammar) (*command.Invocation, []slack.Block, error) {
+	inv, err := grammar.Parse(commandText(env))
+	return helpFor(grammar, inv, err)
+}
+
+// parseMention is parseCommand for mentions, which can ask in passing, as in "@bot can you
+// analyze king-street-update".
+func parseMention(env *Envelope, grammar *command.Grammar) (*command.Invocation, []slack.Block, error) {
+	inv, err := grammar.Search(commandText(env))
+	return helpFor(grammar, inv, err)
+}
+
+// helpFor answers command.ErrHelp with grammar's help.
+func helpFor(grammar *command.Grammar, inv *command.Invocation, err error) (*command.Invocation, []slack.Block, error) {
+	if errors.Is(err, command.ErrHelp) {
+		return nil, []slack.Block{
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, grammar.Help(), false, false), nil, nil),
+		}, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return inv, nil, nil
+}
@@ -0,0 +1,140 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/geomodulus/robots/state"
+)
+
+const (
+	// DefaultMaxExchanges is how many exchanges a conversation keeps by default.
+	DefaultMaxExchanges = 10
+	// DefaultConversationTTL is how long a quiet thread is remembered by default.
+	DefaultConversationTTL = 24 * time.Hour
+)
+
+// Exchange is one message to the bot and its reply.
+type Exchange struct {
+	UserID string    `json:"user_id"`
+	Prompt string    `json:"prompt"`
+	Reply  string    `json:"reply"`
+	At     time.Time `json:"at"`
+}
+
+type conversation struct {
+	Exchanges []Exchange `json:"exchanges"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// ConversationMemory remembers the last few exchanges in each thread, so follow-ups like
+// "make that headline shorter" have something to refer to. Set it as SlackBot.Memory and use
+// Envelope.History and Envelope.Remember from handlers.
+type ConversationMemory struct {
+	Store state.Store
+	// MaxExchanges is how many exchanges are kept per thread. Zero means DefaultMaxExchanges.
+	MaxExchanges int
+	// TTL is how long a thread is remembered after its last exchange. Zero means
+	// DefaultConversationTTL.
+	TTL time.Duration
+
+	// mu serializes updates, which read, append and write back.
+	mu sync.Mutex
+}
+
+func conversationKey(env *Envelope) string {
+	return fmt.Sprintf("conversations/%s/%s/%s", env.TeamID, env.ChannelID, env.ThreadTS)
+}
+
+// History returns the thread's exchanges, oldest first.
+func (m *ConversationMemory) History(ctx context.Context, env *Envelope) ([]Exchange, error) {
+	conv, err := m.load(ctx, env)
+	if err != nil {
+		return nil, err
+	}
+	return conv.Exchanges, nil
+}
+
+// Remember adds an exchange to the thread, dropping the oldest beyond MaxExchanges.
+func (m *ConversationMemory) Remember(ctx context.Context, env *Envelope, prompt, reply string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	conv, err := m.load(ctx, env)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	conv.Exchanges = append(conv.Exchanges, Exchange{UserID: env.UserID, Prompt: prompt, Reply: reply, At: now})
+	max := m.MaxExchanges
+	if max <= 0 {
+		max = DefaultMaxExchanges
+	}
+	if len(conv.Exchanges) > max {
+		conv.Exchanges = conv.Exchanges[len(conv.Exchanges)-max:]
+	}
+	conv.UpdatedAt = now
+
+	if err := m.Store.Put(ctx, conversationKey(env), conv); err != nil {
+		return fmt.Errorf("error saving conversation: %v", err)
+	}
+	return nil
+}
+
+// Forget clears the thread's history.
+func (m *ConversationMemory) Forget(ctx context.Context, env *Envelope) error {
+	return m.Store.Delete(ctx, conversationKey(env))
+}
+
+// Messages returns the thread's history as chat messages, to send ahead of the new prompt.
+func (m *ConversationMemory) Messages(ctx context.Context, env *Envelope) ([]openai.ChatCompletionMessage, error) {
+	history, err := m.History(ctx, env)
+	if err != nil {
+		return nil, err
+	}
+	messages := []openai.ChatCompletionMessage{}
+	for _, exchange := range history {
+		messages = append(messages,
+			openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: exchange.Prompt},
+			openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: exchange.Reply},
+		)
+	}
+	return messages, nil
+}
+
+func (m *ConversationMemory) load(ctx context.Context, env *Envelope) (*conversation, error) {
+	conv := &conversation{}
+	found, err := m.Store.Get(ctx, conversationKey(env), conv)
+	if err != nil {
+		return nil, fmt.Errorf("error loading conversation: %v", err)
+	}
+	ttl := m.TTL
+	if ttl <= 0 {
+		ttl = DefaultConversationTTL
+	}
+	if !found || time.Since(conv.UpdatedAt) > ttl {
+		return &conversation{}, nil
+	}
+	return conv, nil
+}
+
+// History returns the exchanges in the envelope's thread, or nothing if the bot has no Memory.
+func (e *Envelope) History(ctx context.Context) ([]Exchange, error) {
+	if e.bot == nil || e.bot.Memory == nil {
+		return nil, nil
+	}
+	return e.bot.Memory.History(ctx, e)
+}
+
+// Remember records an exchange in the envelope's thread. It does nothing if the bot has no
+// Memory.
+func (e *Envelope) Remember(ctx context.Context, prompt, reply string) error {
+	if e.bot == nil || e.bot.Memory == nil {
+		return nil
+	}
+	return e.bot.Memory.Remember(ctx, e, prompt, reply)
+}
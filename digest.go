@@ -0,0 +1,184 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	gh "github.com/google/go-github/v53/github"
+	"github.com/slack-go/slack"
+
+	"github.com/geomodulus/robots/audit"
+	"github.com/geomodulus/robots/github"
+	"github.com/geomodulus/robots/pubdate"
+	"github.com/geomodulus/robots/search"
+)
+
+// DigestJob posts a morning digest of the day before to Channel: pull requests opened and
+// merged, articles published, the top search queries and how many files were uploaded. Register
+// Run with a Scheduler, eg.
+//
+//	scheduler.Daily("digest", 8, 0, toronto, job.Run)
+type DigestJob struct {
+	Bot      *SlackBot
+	Articles *github.App
+	// Audit is where searches, clicks and uploads are counted from; pass the same log to
+	// search.WithAudit, search.ClickHandler and Uploader.Audit. Without it those sections are
+	// left out.
+	Audit   *audit.Daily
+	Channel string
+	// Location decides when yesterday began. Defaults to Toronto time.
+	Location *time.Location
+	// TopQueries is how many searches to list. Zero means 5.
+	TopQueries int
+}
+
+func (j *DigestJob) Run(ctx context.Context) error {
+	loc := j.Location
+	if loc == nil {
+		loc = pubdate.Toronto
+	}
+	now := time.Now().In(loc)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	from := to.AddDate(0, 0, -1)
+
+	// A failing section is noted in the digest rather than holding up the rest.
+	sections := []string{fmt.Sprintf("*Daily digest for %s*", from.Format("Monday, January 2"))}
+	if section, err := j.pullRequests(ctx, from, to); err != nil {
+		sections = append(sections, fmt.Sprintf("*Pull requests*\n:warning: %v", err))
+	} else {
+		sections = append(sections, section)
+	}
+	if section, err := j.published(ctx, pubdate.Range{From: from, To: to}); err != nil {
+		sections = append(sections, fmt.Sprintf("*Published*\n:warning: %v", err))
+	} else {
+		sections = append(sections, section)
+	}
+	if j.Audit != nil {
+		if section, err := j.searches(ctx, from, to); err != nil {
+			sections = append(sections, fmt.Sprintf("*Search*\n:warning: %v", err))
+		} else {
+			sections = append(sections, section)
+		}
+		if section, err := j.uploads(ctx, from, to); err != nil {
+			sections = append(sections, fmt.Sprintf("*Uploads*\n:warning: %v", err))
+		} else {
+			sections = append(sections, section)
+		}
+	}
+
+	blocks := []slack.Block{}
+	for _, section := range sections {
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, section, false, false), nil, nil))
+	}
+	_, _, err := j.Bot.PostMessageContext(ctx, j.Channel, slack.MsgOptionBlocks(blocks...))
+	return err
+}
+
+func (j *DigestJob) pullRequests(ctx context.Context, from, to time.Time) (string, error) {
+	activity, err := j.Articles.PullRequestActivity(ctx, from, to)
+	if err != nil {
+		return "", err
+	}
+	lines := []string{}
+	for _, pr := range activity.Opened {
+		lines = append(lines, ":new: "+prLine(pr))
+	}
+	for _, pr := range activity.Merged {
+		lines = append(lines, ":twisted_rightwards_arrows: "+prLine(pr))
+	}
+	return fitLines(fmt.Sprintf("*Pull requests* — %d opened, %d merged", len(activity.Opened), len(activity.Merged)), lines), nil
+}
+
+func prLine(pr *gh.PullRequest) string {
+	return fmt.Sprintf("<%s|#%d> %s", pr.GetHTMLURL(), pr.GetNumber(), escapeMrkdwn(pr.GetTitle()))
+}
+
+func (j *DigestJob) published(ctx context.Context, day pubdate.Range) (string, error) {
+	checkouts, err := j.Articles.ListArticles(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error listing articles: %v", err)
+	}
+	names := []string{}
+	for _, checkout := range checkouts {
+		if checkout.Article.IsLive && day.Contains(checkout.Article.PubDate) {
+			names = append(names, "• "+escapeMrkdwn(checkout.Article.Name))
+		}
+	}
+	sort.Strings(names)
+	return fitLines(fmt.Sprintf("*Published* — %d articles", len(names)), names), nil
+}
+
+func (j *DigestJob) searches(ctx context.Context, from, to time.Time) (string, error) {
+	entries, err := j.Audit.Entries(ctx, search.AuditQuery, from, to)
+	if err != nil {
+		return "", err
+	}
+	counts := map[string]int{}
+	for _, entry := range entries {
+		counts[entry.Fields["query"]]++
+	}
+	queries := []string{}
+	for query := range counts {
+		queries = append(queries, query)
+	}
+	sort.Slice(queries, func(a, b int) bool {
+		if counts[queries[a]] != counts[queries[b]] {
+			return counts[queries[a]] > counts[queries[b]]
+		}
+		return queries[a] < queries[b]
+	})
+	top := j.TopQueries
+	if top == 0 {
+		top = 5
+	}
+	if len(queries) > top {
+		queries = queries[:top]
+	}
+
+	// Clicks are only recorded with search.WithClickTracking, so without any the section reads
+	// as it did before.
+	clicks, err := j.Audit.Entries(ctx, search.AuditClick, from, to)
+	if err != nil {
+		return "", err
+	}
+	clicksByQuery := map[string]int{}
+	for _, entry := range clicks {
+		clicksByQuery[entry.Fields["query"]]++
+	}
+
+	text := fmt.Sprintf("*Search* — %d searches, %d different", len(entries), len(counts))
+	if len(clicks) > 0 {
+		text += fmt.Sprintf(", %d results clicked", len(clicks))
+	}
+	for _, query := range queries {
+		text += fmt.Sprintf("\n• _%s_ ×%d", escapeMrkdwn(query), counts[query])
+		if len(clicks) > 0 {
+			text += fmt.Sprintf(", %d clicked", clicksByQuery[query])
+		}
+	}
+	return text, nil
+}
+
+func (j *DigestJob) uploads(ctx context.Context, from, to time.Time) (string, error) {
+	entries, err := j.Audit.Entries(ctx, AuditUpload, from, to)
+	if err != nil {
+		return "", err
+	}
+	byFamily := map[string]int{}
+	for _, entry := range entries {
+		byFamily[family(entry.Fields["content_type"])]++
+	}
+	families := []string{}
+	for f, n := range byFamily {
+		families = append(families, fmt.Sprintf("%d %s", n, f))
+	}
+	sort.Strings(families)
+	text := fmt.Sprintf("*Uploads* — %d files", len(entries))
+	if len(families) > 0 {
+		text += " (" + strings.Join(families, ", ") + ")"
+	}
+	return text, nil
+}
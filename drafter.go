@@ -0,0 +1,83 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/geomodulus/robots/flags"
+	"github.com/geomodulus/robots/internal/llm"
+	"github.com/geomodulus/robots/prompts"
+)
+
+// Drafter writes first drafts of articles from a reporter's notes, eg. a voice memo transcript.
+// Drafts stick to the notes; an editor still has to check them.
+type Drafter struct {
+	OpenAI *openai.Client
+	// Prompts defaults to prompts.Default.
+	Prompts *prompts.Registry
+	// Model defaults to GPT-3.5 Turbo 16K, since notes run long.
+	Model string
+	// Flags, if set, can switch drafting off with flags.Drafting.
+	Flags *flags.Set
+}
+
+// Draft returns article HTML for notes. Without a headline, the draft starts with a suggested
+// one in an <h2>.
+func (d *Drafter) Draft(ctx context.Context, headline, notes string) (string, error) {
+	req, err := d.request(ctx, headline, notes)
+	if err != nil {
+		return "", err
+	}
+	resp, err := llm.Complete(ctx, d.OpenAI, req)
+	if err != nil {
+		return "", fmt.Errorf("error drafting article: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no completion returned")
+	}
+	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
+
+// DraftInThread is Draft, showing the draft in env's thread as it's written.
+func (d *Drafter) DraftInThread(ctx context.Context, bot *SlackBot, env *Envelope, headline, notes string) (string, error) {
+	req, err := d.request(ctx, headline, notes)
+	if err != nil {
+		return "", err
+	}
+	draft, err := bot.StreamReply(ctx, env, d.OpenAI, req, func(text string) string {
+		return "```\n" + text + "\n```"
+	})
+	if err != nil {
+		return "", fmt.Errorf("error drafting article: %w", err)
+	}
+	return strings.TrimSpace(draft), nil
+}
+
+func (d *Drafter) request(ctx context.Context, headline, notes string) (openai.ChatCompletionRequest, error) {
+	if err := d.Flags.Check(ctx, flags.Drafting); err != nil {
+		return openai.ChatCompletionRequest{}, err
+	}
+	registry := d.Prompts
+	if registry == nil {
+		registry = prompts.Default
+	}
+	prompt, err := registry.Render(ctx, prompts.Draft, map[string]any{"Headline": headline, "Notes": notes})
+	if err != nil {
+		return openai.ChatCompletionRequest{}, err
+	}
+	model := d.Model
+	if model == "" {
+		model = openai.GPT3Dot5Turbo16K
+	}
+
+	return openai.ChatCompletionRequest{
+		Model:       model,
+		Temperature: 0.3,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleUser, Content: prompt.Text},
+		},
+	}, nil
+}
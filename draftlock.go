@@ -0,0 +1,204 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	gh "github.com/google/go-github/v53/github"
+	"github.com/slack-go/slack"
+
+	"github.com/geomodulus/robots/audit"
+	"github.com/geomodulus/robots/github"
+	"github.com/geomodulus/robots/state"
+)
+
+// DraftLockTakeOverActionID is the button on a lock warning that takes the lock from its holder.
+// Forward it from the bot's HandleBlockAction to DraftLocks.HandleBlockAction.
+const DraftLockTakeOverActionID = "draft_lock_take_over"
+
+// defaultDraftLockTTL is how long a lock lasts without being renewed, so an editor who wanders
+// off doesn't block everyone else for good.
+const defaultDraftLockTTL = 30 * time.Minute
+
+// DraftLocks keeps one editor at a time editing a slug through the bot, since concurrent edits
+// race on the same PR branch. Flows call Acquire before they start editing a slug and Release
+// once they're done. Anyone else who tries is warned who holds the lock, with a button to take
+// it over. Add PreCommitHook to the articles App's PreCommitHooks too, so writes from flows that
+// don't take the lock, like corrections and collections, are refused while someone holds it.
+// Locks are advisory and the store has no compare-and-swap, so two editors starting in the same
+// instant can both get one.
+type DraftLocks struct {
+	Bot   *SlackBot
+	Store state.Store
+	// TTL defaults to 30 minutes. Each Acquire by the holder renews it.
+	TTL time.Duration
+}
+
+type draftLock struct {
+	UserID    string    `json:"user_id"`
+	ChannelID string    `json:"channel_id"`
+	ThreadTS  string    `json:"thread_ts"`
+	Acquired  time.Time `json:"acquired"`
+	Expires   time.Time `json:"expires"`
+}
+
+func draftLockKey(slug string) string {
+	return "draft-locks/" + slug
+}
+
+// HandlesAction reports whether actionID is the take over button.
+func (l *DraftLocks) HandlesAction(actionID string) bool {
+	return actionID == DraftLockTakeOverActionID
+}
+
+// Acquire locks slug for env's user, or renews their lock. If someone else holds it, a warning
+// naming them is posted in env's thread and Acquire reports false; the caller should stop there.
+func (l *DraftLocks) Acquire(ctx context.Context, env *Envelope, slug string) (bool, error) {
+	lock, err := l.load(ctx, slug)
+	if err != nil {
+		return false, err
+	}
+	if lock != nil && lock.UserID != env.UserID {
+		return false, l.Bot.ReplyTo(env, slack.MsgOptionBlocks(lockWarningBlocks(slug, lock)...))
+	}
+	acquired := time.Now()
+	if lock != nil {
+		acquired = lock.Acquired
+	}
+	return true, l.put(ctx, slug, env, acquired)
+}
+
+// Release unlocks slug if env's user holds it.
+func (l *DraftLocks) Release(ctx context.Context, env *Envelope, slug string) error {
+	lock, err := l.load(ctx, slug)
+	if err != nil || lock == nil || lock.UserID != env.UserID {
+		return err
+	}
+	if err := l.Store.Delete(ctx, draftLockKey(slug)); err != nil {
+		return fmt.Errorf("error releasing lock on %s: %v", slug, err)
+	}
+	return nil
+}
+
+// Holder returns the Slack user ID of whoever holds the lock on slug, or "" if no one does.
+func (l *DraftLocks) Holder(ctx context.Context, slug string) (string, error) {
+	lock, err := l.load(ctx, slug)
+	if err != nil || lock == nil {
+		return "", err
+	}
+	return lock.UserID, nil
+}
+
+// PreCommitHook refuses commits that touch an article someone holds the lock on, unless the
+// commit is theirs: its context's audit.Actor is the holder.
+func (l *DraftLocks) PreCommitHook() github.PreCommitHook {
+	return func(ctx context.Context, a *github.App, entries []*gh.TreeEntry) error {
+		checked := map[string]bool{}
+		for _, entry := range entries {
+			slug, ok := lockedArticleSlug(entry.GetPath())
+			if !ok || checked[slug] {
+				continue
+			}
+			checked[slug] = true
+			lock, err := l.load(ctx, slug)
+			if err != nil {
+				return err
+			}
+			if lock != nil && lock.UserID != audit.Actor(ctx) {
+				return fmt.Errorf("<@%s> is editing `%s`; try again once they're done", lock.UserID, slug)
+			}
+		}
+		return nil
+	}
+}
+
+// lockedArticleSlug returns the slug of the article a file in the articles repo belongs to,
+// archived or not.
+func lockedArticleSlug(path string) (string, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimPrefix(path, "archive/"), "articles/")
+	if !ok {
+		return "", false
+	}
+	slug, _, ok := strings.Cut(rest, "/")
+	return slug, ok
+}
+
+// HandleBlockAction hands the lock on the slug a take over button belongs to to whoever pressed
+// it, and lets the previous holder know in their thread.
+func (l *DraftLocks) HandleBlockAction(ctx context.Context, env *Envelope, action, value string, callback slack.InteractionCallback) error {
+	if action != DraftLockTakeOverActionID {
+		return fmt.Errorf("unknown action %q", action)
+	}
+	slug := value
+	previous, err := l.load(ctx, slug)
+	if err != nil {
+		return err
+	}
+	if err := l.put(ctx, slug, env, time.Now()); err != nil {
+		return err
+	}
+
+	if previous != nil && previous.UserID != env.UserID && previous.ChannelID != "" {
+		notice := fmt.Sprintf(":unlock: <@%s>, <@%s> took over editing `%s`. Changes you make through the bot now will be refused.",
+			previous.UserID, env.UserID, slug)
+		if err := l.Bot.Reply(previous.ChannelID, previous.ThreadTS, slack.MsgOptionText(notice, false)); err != nil {
+			return fmt.Errorf("error notifying previous lock holder: %v", err)
+		}
+	}
+
+	// Swap the button for the outcome so the lock can't be taken over twice from one warning.
+	blocks := []slack.Block{}
+	for _, block := range callback.Message.Blocks.BlockSet {
+		if block.BlockType() != slack.MBTAction {
+			blocks = append(blocks, block)
+		}
+	}
+	blocks = append(blocks, slack.NewContextBlock("",
+		slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf(":lock: <@%s> took over editing `%s`. Try again.", env.UserID, slug), false, false)))
+	_, _, _, err = l.Bot.UpdateMessageContext(ctx, env.ChannelID, env.MessageTS, slack.MsgOptionBlocks(blocks...))
+	return err
+}
+
+// load returns the lock on slug, or nil if there isn't one or it's expired.
+func (l *DraftLocks) load(ctx context.Context, slug string) (*draftLock, error) {
+	lock := &draftLock{}
+	found, err := l.Store.Get(ctx, draftLockKey(slug), lock)
+	if err != nil {
+		return nil, fmt.Errorf("error loading lock on %s: %v", slug, err)
+	}
+	if !found || time.Now().After(lock.Expires) {
+		return nil, nil
+	}
+	return lock, nil
+}
+
+func (l *DraftLocks) put(ctx context.Context, slug string, env *Envelope, acquired time.Time) error {
+	ttl := l.TTL
+	if ttl == 0 {
+		ttl = defaultDraftLockTTL
+	}
+	if err := l.Store.Put(ctx, draftLockKey(slug), &draftLock{
+		UserID:    env.UserID,
+		ChannelID: env.ChannelID,
+		ThreadTS:  env.ThreadTS,
+		Acquired:  acquired,
+		Expires:   time.Now().Add(ttl),
+	}); err != nil {
+		return fmt.Errorf("error saving lock on %s: %v", slug, err)
+	}
+	return nil
+}
+
+func lockWarningBlocks(slug string, lock *draftLock) []slack.Block {
+	text := fmt.Sprintf(":lock: <@%s> has been editing `%s` since <!date^%d^{time}|%s>. Editing it now would race their changes on the PR branch.",
+		lock.UserID, slug, lock.Acquired.Unix(), lock.Acquired.Format(time.Kitchen))
+	return []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+		slack.NewActionBlock("",
+			slack.NewButtonBlockElement(DraftLockTakeOverActionID, slug,
+				slack.NewTextBlockObject(slack.PlainTextType, "Take over", false, false)).WithStyle(slack.StyleDanger),
+		),
+	}
+}
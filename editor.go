@@ -0,0 +1,347 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/geomodulus/citygraph"
+	geojson "github.com/paulmach/go.geojson"
+	"github.com/slack-go/slack"
+
+	"github.com/geomodulus/robots/audit"
+	"github.com/geomodulus/robots/flags"
+	"github.com/geomodulus/robots/github"
+)
+
+// ArticleEditorCallbackID prefixes the callback IDs of the article editor's modals.
+const ArticleEditorCallbackID = "article_editor"
+
+type editorStep int
+
+const (
+	stepHeadline editorStep = iota
+	stepDek
+	stepBody
+	stepLocations
+)
+
+// editorDraftTTL is how long a draft is kept after it's opened. Closing the modal midway doesn't
+// tell the editor, so drafts abandoned that way are dropped once they're this old.
+const editorDraftTTL = 24 * time.Hour
+
+// editorSteps describes each page of the editor, in order.
+var editorSteps = []struct {
+	title     string
+	label     string
+	hint      string
+	multiline bool
+	optional  bool
+}{
+	stepHeadline: {title: "Headline", label: "Headline"},
+	stepDek:      {title: "Dek", label: "Dek", hint: "The subhead that appears under the headline.", optional: true},
+	stepBody:     {title: "Body", label: "Body", hint: "Paste the story. Blank lines separate paragraphs.", multiline: true},
+	stepLocations: {title: "Locations", label: "Locations GeoJSON", multiline: true, optional: true,
+		hint: "A FeatureCollection of the places in the story. Leave empty to add them later."},
+}
+
+// ArticleDraft is what the editor has collected so far.
+type ArticleDraft struct {
+	Headline  string
+	Dek       string
+	Body      string
+	Locations string
+
+	// env is where the editor was opened from, for Done and the lock.
+	env *Envelope
+	// slug is the article's, once it has a headline.
+	slug string
+	// opened is when the editor was opened, so abandoned drafts can be dropped.
+	opened time.Time
+}
+
+// ArticleEditor is a modal flow that walks a writer through headline, dek, body and locations,
+// then opens an article PR. Add it to SlackBot.Modals and call Open with a trigger ID from a
+// slash command or button press. Drafts are kept in memory until the PR is opened or the modal
+// is cancelled, or for a day if it's abandoned some other way. Slack caps text inputs at 3000 characters, so longer bodies need
+// finishing in the PR.
+type ArticleEditor struct {
+	Bot      *SlackBot
+	Articles *github.App
+	// Options are applied after the editor's own when opening the PR, eg. github.WithAuthors.
+	Options []github.Option
+	// Done, if set, is called once the PR is open, with the envelope the editor was opened from.
+	Done func(ctx context.Context, env *Envelope, prNum int, prURL string) error
+	// Flags, if set, can switch the editor off with flags.Drafting.
+	Flags *flags.Set
+	// BreakingNews, if set, fast-tracks the PR if its slug is in breaking-news mode.
+	BreakingNews *BreakingNews
+	// ImageCredits, if set, has the credits saved for the article's images written to its
+	// images.json.
+	ImageCredits *ImageCredits
+	// Locks, if set, holds the article from when its headline is entered until its PR is opened
+	// or the editor is cancelled, renewed at each page, so it doesn't race someone else's edits
+	// to the same slug.
+	Locks *DraftLocks
+
+	mu     sync.Mutex
+	drafts map[string]*ArticleDraft
+}
+
+// Open shows the first page of the editor.
+func (e *ArticleEditor) Open(ctx context.Context, env *Envelope, triggerID string) error {
+	if err := e.Flags.Check(ctx, flags.Drafting); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	if e.drafts == nil {
+		e.drafts = map[string]*ArticleDraft{}
+	}
+	now := time.Now()
+	for id, draft := range e.drafts {
+		if now.Sub(draft.opened) > editorDraftTTL {
+			delete(e.drafts, id)
+		}
+	}
+	draftID := citygraph.NewID().String()
+	e.drafts[draftID] = &ArticleDraft{env: env, opened: now}
+	e.mu.Unlock()
+
+	if _, err := e.Bot.OpenViewContext(ctx, triggerID, editorView(stepHeadline, draftID)); err != nil {
+		e.discard(draftID)
+		return fmt.Errorf("error opening editor: %v", err)
+	}
+	return nil
+}
+
+// HandlesView reports whether view is one of the editor's pages.
+func (e *ArticleEditor) HandlesView(view slack.View) bool {
+	return strings.HasPrefix(view.CallbackID, ArticleEditorCallbackID+":")
+}
+
+// RespondViewSubmission saves the submitted page and moves on to the next. The last page opens
+// the PR in the background and shows its link once it's ready.
+func (e *ArticleEditor) RespondViewSubmission(ctx context.Context, env *Envelope, callback slack.InteractionCallback) (*slack.ViewSubmissionResponse, error) {
+	step, err := strconv.Atoi(strings.TrimPrefix(callback.View.CallbackID, ArticleEditorCallbackID+":"))
+	if err != nil || step < 0 || step >= len(editorSteps) {
+		return nil, fmt.Errorf("unknown editor page %q", callback.View.CallbackID)
+	}
+	draftID := callback.View.PrivateMetadata
+
+	e.mu.Lock()
+	draft, ok := e.drafts[draftID]
+	e.mu.Unlock()
+	if !ok {
+		return slack.NewErrorsViewSubmissionResponse(map[string]string{
+			editorBlockID: "This draft has expired, please start again.",
+		}), nil
+	}
+
+	value := strings.TrimSpace(callback.View.State.Values[editorBlockID][editorActionID].Value)
+	switch editorStep(step) {
+	case stepHeadline:
+		draft.Headline = value
+		draft.slug = (&citygraph.Article{Name: value}).SlugTitle()
+	case stepDek:
+		draft.Dek = value
+	case stepBody:
+		draft.Body = value
+	case stepLocations:
+		if value != "" {
+			if _, err := geojson.UnmarshalFeatureCollection([]byte(value)); err != nil {
+				return slack.NewErrorsViewSubmissionResponse(map[string]string{
+					editorBlockID: fmt.Sprintf("That isn't a GeoJSON FeatureCollection: %v", err),
+				}), nil
+			}
+		}
+		draft.Locations = value
+	}
+
+	if msg, err := e.hold(ctx, draft); err != nil || msg != "" {
+		if err != nil {
+			return nil, err
+		}
+		return slack.NewErrorsViewSubmissionResponse(map[string]string{editorBlockID: msg}), nil
+	}
+
+	if next := editorStep(step + 1); int(next) < len(editorSteps) {
+		view := editorView(next, draftID)
+		return slack.NewUpdateViewSubmissionResponse(&view), nil
+	}
+
+	e.discard(draftID)
+	go e.openPullRequest(draft, callback.View.ID)
+
+	view := editorMessageView(draftID, fmt.Sprintf(":hourglass_flowing_sand: Opening a PR for *%s*…", escapeMrkdwn(draft.Headline)))
+	return slack.NewUpdateViewSubmissionResponse(&view), nil
+}
+
+// openPullRequest runs after the modal has been answered, so it can't use the request context.
+func (e *ArticleEditor) openPullRequest(draft *ArticleDraft, viewID string) {
+	ctx := context.Background()
+
+	article := &citygraph.Article{
+		ID:          citygraph.NewID().String(),
+		Name:        draft.Headline,
+		Description: draft.Dek,
+	}
+	slug := article.SlugTitle()
+	commit := &github.CommitMessage{Type: "add", Scope: "article", Summary: draft.Headline, Slug: slug}
+	// Editors opened from a slash command have no message to link to.
+	commit.ThreadURL, _ = draft.env.Permalink(ctx)
+	commitMessage, err := commit.Build()
+	if err != nil {
+		commitMessage = "Add " + slug
+	}
+	opts := []github.Option{
+		github.WithArticle(article),
+		github.WithBodyHTML(paragraphsHTML(draft.Body)),
+		github.WithCommitMessage(commitMessage),
+		github.WithPRTitle("New article: " + draft.Headline),
+	}
+	if draft.Locations != "" {
+		github.DeclareLocations(article)
+		opts = append(opts, github.WithLocations(draft.Locations))
+	}
+	if e.ImageCredits != nil {
+		credits, err := e.ImageCredits.Credits(ctx, slug)
+		if err != nil {
+			log.Printf("error loading image credits for %s: %v", slug, err)
+		} else if len(credits) > 0 {
+			opts = append(opts, github.WithImageCredits(credits))
+		}
+	}
+	// The channel's profile, eg. its desk's reviewers, applies unless Options say otherwise.
+	opts = append(draft.env.Profile.PullRequestOptions(opts...), e.Options...)
+	opts = e.BreakingNews.PullRequestOptions(ctx, draft.env, slug, opts...)
+
+	// The PR is opened as the lock holder, so DraftLocks.PreCommitHook lets it through.
+	prNum, prURL, err := e.Articles.CreateOrUpdateArticlePullRequest(audit.WithActor(ctx, draft.env.UserID), slug, opts...)
+	e.release(ctx, draft)
+	msg := fmt.Sprintf(":white_check_mark: Opened <%s|PR #%d> for *%s*.", prURL, prNum, escapeMrkdwn(draft.Headline))
+	if err != nil {
+		msg = fmt.Sprintf(":warning: Error opening PR: %v", err)
+	}
+	if _, err := e.Bot.UpdateViewContext(ctx, editorMessageView("", msg), "", "", viewID); err != nil {
+		log.Printf("error updating editor view: %v", err)
+	}
+
+	if err == nil && e.Done != nil && draft.env != nil {
+		if err := e.Done(ctx, draft.env, prNum, prURL); err != nil {
+			log.Printf("error finishing editor for PR #%d: %v", prNum, err)
+		}
+	}
+}
+
+// hold takes, or renews, the lock on the draft's slug for whoever opened the editor. If someone
+// else holds it, it returns why the editor can't go on; they've been warned in the channel,
+// with a button to take over.
+func (e *ArticleEditor) hold(ctx context.Context, draft *ArticleDraft) (string, error) {
+	if e.Locks == nil || draft.slug == "" {
+		return "", nil
+	}
+	ok, err := e.Locks.Acquire(ctx, draft.env, draft.slug)
+	if err != nil || ok {
+		return "", err
+	}
+	return fmt.Sprintf("Someone else is editing %s. Take over from the warning in the channel, or change the headline.", draft.slug), nil
+}
+
+func (e *ArticleEditor) release(ctx context.Context, draft *ArticleDraft) {
+	if e.Locks == nil || draft.slug == "" {
+		return
+	}
+	if err := e.Locks.Release(ctx, draft.env, draft.slug); err != nil {
+		log.Printf("error releasing lock on %s: %v", draft.slug, err)
+	}
+}
+
+// HandleViewClosed discards the draft of a cancelled editor and releases its lock.
+func (e *ArticleEditor) HandleViewClosed(ctx context.Context, env *Envelope, callback slack.InteractionCallback) error {
+	draftID := callback.View.PrivateMetadata
+	e.mu.Lock()
+	draft, ok := e.drafts[draftID]
+	e.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	e.discard(draftID)
+	e.release(ctx, draft)
+	return nil
+}
+
+func (e *ArticleEditor) discard(draftID string) {
+	e.mu.Lock()
+	delete(e.drafts, draftID)
+	e.mu.Unlock()
+}
+
+const (
+	editorBlockID  = "article_editor_input"
+	editorActionID = "article_editor_value"
+)
+
+func editorView(step editorStep, draftID string) slack.ModalViewRequest {
+	page := editorSteps[step]
+
+	input := slack.NewPlainTextInputBlockElement(nil, editorActionID)
+	input.Multiline = page.multiline
+	block := slack.NewInputBlock(editorBlockID, slack.NewTextBlockObject(slack.PlainTextType, page.label, false, false), nil, input)
+	block.Optional = page.optional
+	if page.hint != "" {
+		block.Hint = slack.NewTextBlockObject(slack.PlainTextType, page.hint, false, false)
+	}
+
+	submit := "Next"
+	if int(step) == len(editorSteps)-1 {
+		submit = "Open PR"
+	}
+	return slack.ModalViewRequest{
+		Type:            slack.VTModal,
+		CallbackID:      fmt.Sprintf("%s:%d", ArticleEditorCallbackID, step),
+		PrivateMetadata: draftID,
+		Title:           slack.NewTextBlockObject(slack.PlainTextType, fmt.Sprintf("%s (%d/%d)", page.title, step+1, len(editorSteps)), false, false),
+		Submit:          slack.NewTextBlockObject(slack.PlainTextType, submit, false, false),
+		Close:           slack.NewTextBlockObject(slack.PlainTextType, "Cancel", false, false),
+		Blocks:          slack.Blocks{BlockSet: []slack.Block{block}},
+		// So HandleViewClosed can release the lock.
+		NotifyOnClose: true,
+	}
+}
+
+func editorMessageView(draftID, msg string) slack.ModalViewRequest {
+	return slack.ModalViewRequest{
+		Type:            slack.VTModal,
+		CallbackID:      ArticleEditorCallbackID + ":done",
+		PrivateMetadata: draftID,
+		Title:           slack.NewTextBlockObject(slack.PlainTextType, "New article", false, false),
+		Close:           slack.NewTextBlockObject(slack.PlainTextType, "Close", false, false),
+		Blocks: slack.Blocks{BlockSet: []slack.Block{
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, msg, false, false), nil, nil),
+		}},
+	}
+}
+
+// paragraphsHTML turns pasted text into paragraphs, splitting on blank lines.
+func paragraphsHTML(text string) string {
+	var b strings.Builder
+	for _, para := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		b.WriteString("<p>" + strings.ReplaceAll(html.EscapeString(para), "\n", "<br>") + "</p>\n")
+	}
+	return b.String()
+}
+
+// escapeMrkdwn escapes the characters Slack treats as control sequences.
+func escapeMrkdwn(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
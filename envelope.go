@@ -0,0 +1,148 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+
+	"github.com/geomodulus/robots/reqid"
+)
+
+// Envelope describes where an event came from, so handlers don't each have to dig the
+// workspace, channel, thread and user out of the raw Slack types.
+type Envelope struct {
+	TeamID    string
+	ChannelID string
+	UserID    string
+	// MessageTS is the message the event is about, if any.
+	MessageTS string
+	// ThreadTS is where replies belong: the thread's root if the message is in a thread,
+	// otherwise the message itself.
+	ThreadTS string
+	// Raw is the event as received, eg. *slackevents.AppMentionEvent or slack.SlashCommand.
+	Raw any
+	// Profile is the configuration for the channel, if the bot has Profiles. It may be nil.
+	Profile *ChannelProfile
+	// RequestID identifies this event in logs and error messages. The handler's context carries
+	// it too; see package reqid.
+	RequestID string
+
+	bot       *SlackBot
+	permalink string
+}
+
+// Permalink returns a link to the event's message, looked up once and cached.
+func (e *Envelope) Permalink(ctx context.Context) (string, error) {
+	if e.permalink != "" {
+		return e.permalink, nil
+	}
+	if e.ChannelID == "" || e.MessageTS == "" {
+		return "", fmt.Errorf("event has no message to link to")
+	}
+	permalink, err := e.bot.GetPermalinkContext(ctx, &slack.PermalinkParameters{
+		Channel: e.ChannelID,
+		Ts:      e.MessageTS,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error getting permalink: %v", err)
+	}
+	e.permalink = permalink
+	return permalink, nil
+}
+
+// ReplyTo replies in the envelope's thread.
+func (b *SlackBot) ReplyTo(env *Envelope, opts ...slack.MsgOption) error {
+	return b.Reply(env.ChannelID, env.ThreadTS, opts...)
+}
+
+func (b *SlackBot) appMentionEnvelope(teamID string, ev *slackevents.AppMentionEvent) *Envelope {
+	return &Envelope{
+		TeamID:    teamID,
+		ChannelID: ev.Channel,
+		UserID:    ev.User,
+		MessageTS: ev.TimeStamp,
+		ThreadTS:  threadTS(ev.ThreadTimeStamp, ev.TimeStamp),
+		Raw:       ev,
+		Profile:   b.Profiles.For(ev.Channel),
+		RequestID: reqid.New(),
+		bot:       b,
+	}
+}
+
+func (b *SlackBot) messageEnvelope(teamID string, ev *slackevents.MessageEvent) *Envelope {
+	return &Envelope{
+		TeamID:    teamID,
+		ChannelID: ev.Channel,
+		UserID:    ev.User,
+		MessageTS: ev.TimeStamp,
+		ThreadTS:  threadTS(ev.ThreadTimeStamp, ev.TimeStamp),
+		Raw:       ev,
+		Profile:   b.Profiles.For(ev.Channel),
+		RequestID: reqid.New(),
+		bot:       b,
+	}
+}
+
+func (b *SlackBot) linkSharedEnvelope(teamID string, ev *slackevents.LinkSharedEvent) *Envelope {
+	return &Envelope{
+		TeamID:    teamID,
+		ChannelID: ev.Channel,
+		UserID:    ev.User,
+		MessageTS: ev.MessageTimeStamp,
+		ThreadTS:  threadTS(ev.ThreadTimeStamp, ev.MessageTimeStamp),
+		Raw:       ev,
+		Profile:   b.Profiles.For(ev.Channel),
+		RequestID: reqid.New(),
+		bot:       b,
+	}
+}
+
+func (b *SlackBot) appHomeEnvelope(teamID string, ev *slackevents.AppHomeOpenedEvent) *Envelope {
+	return &Envelope{
+		TeamID:    teamID,
+		ChannelID: ev.Channel,
+		UserID:    ev.User,
+		Raw:       ev,
+		RequestID: reqid.New(),
+		bot:       b,
+	}
+}
+
+func (b *SlackBot) slashCommandEnvelope(cmd slack.SlashCommand) *Envelope {
+	return &Envelope{
+		TeamID:    cmd.TeamID,
+		ChannelID: cmd.ChannelID,
+		UserID:    cmd.UserID,
+		Raw:       cmd,
+		Profile:   b.Profiles.For(cmd.ChannelID),
+		RequestID: reqid.New(),
+		bot:       b,
+	}
+}
+
+func (b *SlackBot) interactionEnvelope(callback slack.InteractionCallback) *Envelope {
+	messageTS := callback.MessageTs
+	if messageTS == "" {
+		messageTS = callback.Message.Timestamp
+	}
+	return &Envelope{
+		TeamID:    callback.Team.ID,
+		ChannelID: callback.Channel.ID,
+		UserID:    callback.User.ID,
+		MessageTS: messageTS,
+		ThreadTS:  threadTS(callback.Message.ThreadTimestamp, messageTS),
+		Raw:       callback,
+		Profile:   b.Profiles.For(callback.Channel.ID),
+		RequestID: reqid.New(),
+		bot:       b,
+	}
+}
+
+func threadTS(thread, ts string) string {
+	if thread != "" {
+		return thread
+	}
+	return ts
+}
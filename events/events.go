@@ -0,0 +1,76 @@
+// Package events lets the bots tell other systems what happened, eg. so the site rebuilds when
+// an article is published, without them polling. Subsystems Emit typed events and Webhooks
+// POSTs them, signed, to the endpoints that want them.
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Type names a kind of event. It's sent as the event's "type".
+type Type string
+
+const (
+	// ArticlePublished is emitted when an article goes live. Its data is ArticlePublishedData.
+	ArticlePublished Type = "article.published"
+	// PlaceUpdated is emitted when a change to a place is merged. Its data is PlaceUpdatedData.
+	PlaceUpdated Type = "place.updated"
+	// IndexRebuilt is emitted when the search index has been synced. Its data is
+	// IndexRebuiltData.
+	IndexRebuilt Type = "index.rebuilt"
+)
+
+// Event is something that happened, as delivered to endpoints.
+type Event struct {
+	// ID is unique per event, so endpoints can drop repeat deliveries.
+	ID   string    `json:"id"`
+	Type Type      `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data"`
+}
+
+type ArticlePublishedData struct {
+	ID      string `json:"id"`
+	Slug    string `json:"slug"`
+	Name    string `json:"name"`
+	PubDate string `json:"pub_date"`
+}
+
+type PlaceUpdatedData struct {
+	Slug string `json:"slug"`
+	Name string `json:"name,omitempty"`
+	// PRNum and PRURL are the pull request that merged the change.
+	PRNum int    `json:"pr_num"`
+	PRURL string `json:"pr_url"`
+}
+
+type IndexRebuiltData struct {
+	Indexed   int `json:"indexed"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Deleted   int `json:"deleted"`
+	Failed    int `json:"failed"`
+}
+
+// Emitter takes events. Emit must not block on delivery.
+type Emitter interface {
+	Emit(ctx context.Context, event Event)
+}
+
+// Emit sends an event of the given type to emitter, filling in its ID and time. A nil emitter
+// drops it, so subsystems can emit whether or not anything is listening.
+func Emit(ctx context.Context, emitter Emitter, typ Type, data any) {
+	if emitter == nil {
+		return
+	}
+	emitter.Emit(ctx, Event{ID: newID(), Type: typ, Time: time.Now().UTC(), Data: data})
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
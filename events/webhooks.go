@@ -0,0 +1,170 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/geomodulus/robots/internal/retry"
+)
+
+// SignatureHeader carries an event's signature, as "t=<unix time>,v1=<hex HMAC-SHA256>". The
+// HMAC is of the time, a dot and the request body, keyed with the endpoint's secret.
+const SignatureHeader = "X-Robots-Signature"
+
+// deliveryTimeout bounds one delivery, retries included.
+const deliveryTimeout = 2 * time.Minute
+
+// Endpoint is somewhere events are POSTed.
+type Endpoint struct {
+	URL string
+	// Secret signs deliveries, so the endpoint can tell they came from us.
+	Secret string
+	// Types are the events the endpoint wants. Empty means all of them.
+	Types []Type
+}
+
+func (e *Endpoint) wants(typ Type) bool {
+	if len(e.Types) == 0 {
+		return true
+	}
+	for _, t := range e.Types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhooks is an Emitter that POSTs events as JSON to its endpoints in the background, retrying
+// network errors, 429s and 5xx responses.
+type Webhooks struct {
+	Endpoints []Endpoint
+	// Client defaults to one with a 30 second timeout.
+	Client *http.Client
+
+	wg sync.WaitGroup
+}
+
+func (w *Webhooks) Emit(_ context.Context, event Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("error encoding %s event: %v", event.Type, err)
+		return
+	}
+	for _, endpoint := range w.Endpoints {
+		if !endpoint.wants(event.Type) {
+			continue
+		}
+		w.wg.Add(1)
+		go func(endpoint Endpoint) {
+			defer w.wg.Done()
+			// Delivery outlives the emitter's request, so it gets its own context.
+			ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+			defer cancel()
+			if err := w.deliver(ctx, endpoint, event, body); err != nil {
+				log.Printf("error delivering %s event %s to %s: %v", event.Type, event.ID, endpoint.URL, err)
+			}
+		}(endpoint)
+	}
+}
+
+// Wait blocks until deliveries in progress are done, eg. before shutting down.
+func (w *Webhooks) Wait() {
+	w.wg.Wait()
+}
+
+type statusError struct {
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("endpoint returned %s", e.status)
+}
+
+var deliveryPolicy = retry.Default.WithRetryable(func(err error) bool {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return retry.RetryableStatus(statusErr.code)
+	}
+	return retry.IsTransient(err)
+})
+
+func (w *Webhooks) deliver(ctx context.Context, endpoint Endpoint, event Event, body []byte) error {
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return retry.Do(ctx, deliveryPolicy, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, "POST", endpoint.URL, bytes.NewReader(body))
+		if err != nil {
+			return retry.Permanent(fmt.Errorf("http.NewRequest: %v", err))
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Robots-Event", string(event.Type))
+		req.Header.Set("X-Robots-Delivery", event.ID)
+		// Signed afresh each attempt, so retries aren't refused as stale.
+		req.Header.Set(SignatureHeader, Sign(endpoint.Secret, time.Now(), body))
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return &statusError{code: resp.StatusCode, status: resp.Status}
+		}
+		return nil
+	})
+}
+
+// Sign returns the signature header for a body sent at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", timestamp, signature(secret, timestamp, body))
+}
+
+// Verify checks a delivery's signature header, for endpoints written in Go. Signatures older
+// than tolerance are refused, so captured deliveries can't be replayed.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var timestamp, sig string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			sig = value
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || sig == "" {
+		return fmt.Errorf("malformed signature header")
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("signature is too old")
+	}
+	if !hmac.Equal([]byte(sig), []byte(signature(secret, timestamp, body))) {
+		return fmt.Errorf("signature doesn't match")
+	}
+	return nil
+}
+
+func signature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,248 @@
+// Package flags provides kill switches for the bots' major capabilities, so a misbehaving one
+// can be switched off in production without a redeploy. Defaults come from config; runtime
+// toggles are kept in a state.Store so every instance sees them.
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/geomodulus/robots/audit"
+	"github.com/geomodulus/robots/state"
+)
+
+// Flag names a capability that can be switched off.
+type Flag string
+
+// Flags checked by the bots' subsystems.
+const (
+	Drafting    Flag = "drafting"
+	AutoPublish Flag = "auto_publish"
+	Reindex     Flag = "reindex"
+)
+
+// Known lists the flags checked by the bots, for listing and validating toggles.
+var Known = []Flag{Drafting, AutoPublish, Reindex}
+
+// ErrDisabled is wrapped by the errors Check returns.
+var ErrDisabled = errors.New("switched off")
+
+// storeKey is where runtime toggles are kept.
+const storeKey = "flags/overrides"
+
+// refreshInterval is how long toggles are cached before the store is read again, so toggles
+// made on another instance take effect within it.
+var refreshInterval = 30 * time.Second
+
+// Set answers whether flags are on. Flags are on unless a default or toggle says otherwise.
+type Set struct {
+	// Store, if set, keeps runtime toggles. Without one toggles only last until restart.
+	Store state.Store
+	// Audit, if set, records every toggle.
+	Audit audit.Log
+
+	mu        sync.Mutex
+	defaults  map[Flag]bool
+	overrides map[Flag]bool
+	loaded    time.Time
+}
+
+// New returns a Set with the given defaults.
+func New(defaults map[Flag]bool) *Set {
+	s := &Set{defaults: map[Flag]bool{}}
+	for flag, on := range defaults {
+		s.defaults[flag] = on
+	}
+	return s
+}
+
+// LoadDefaults reads defaults from a JSON config, eg.
+//
+//	{"flags": {"auto_publish": false}}
+func (s *Set) LoadDefaults(config io.Reader) error {
+	var parsed struct {
+		Flags map[Flag]bool `json:"flags"`
+	}
+	if err := json.NewDecoder(config).Decode(&parsed); err != nil {
+		return fmt.Errorf("error decoding flags config: %v", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.defaults == nil {
+		s.defaults = map[Flag]bool{}
+	}
+	for flag, on := range parsed.Flags {
+		s.defaults[flag] = on
+	}
+	return nil
+}
+
+// ParseDefaults reads defaults from a comma-separated list like "reindex=off,drafting=on", as
+// set in an environment variable.
+func (s *Set) ParseDefaults(list string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.defaults == nil {
+		s.defaults = map[Flag]bool{}
+	}
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("flag %q needs a value, eg. %s=off", item, item)
+		}
+		on, err := ParseState(value)
+		if err != nil {
+			return fmt.Errorf("flag %s: %v", name, err)
+		}
+		s.defaults[Flag(strings.TrimSpace(name))] = on
+	}
+	return nil
+}
+
+// ParseState reads "on"/"off" and the usual synonyms.
+func ParseState(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "on", "true", "1", "yes", "enable", "enabled":
+		return true, nil
+	case "off", "false", "0", "no", "disable", "disabled":
+		return false, nil
+	}
+	return false, fmt.Errorf("%q isn't on or off", value)
+}
+
+// Enabled reports whether flag is on. If the store can't be read, the last toggles seen are
+// used.
+func (s *Set) Enabled(ctx context.Context, flag Flag) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh(ctx)
+	if on, ok := s.overrides[flag]; ok {
+		return on
+	}
+	if on, ok := s.defaults[flag]; ok {
+		return on
+	}
+	return true
+}
+
+// Check returns an error wrapping ErrDisabled if flag is off.
+func (s *Set) Check(ctx context.Context, flag Flag) error {
+	if !s.Enabled(ctx, flag) {
+		return fmt.Errorf("%s is %w", flag, ErrDisabled)
+	}
+	return nil
+}
+
+// Toggle turns flag on or off at runtime, overriding its default.
+func (s *Set) Toggle(ctx context.Context, flag Flag, on bool) error {
+	return s.update(ctx, func(overrides map[Flag]bool) {
+		overrides[flag] = on
+	}, "flag.toggle", map[string]string{"flag": string(flag), "on": fmt.Sprint(on)})
+}
+
+// Reset drops flag's runtime toggle, returning it to its default.
+func (s *Set) Reset(ctx context.Context, flag Flag) error {
+	return s.update(ctx, func(overrides map[Flag]bool) {
+		delete(overrides, flag)
+	}, "flag.reset", map[string]string{"flag": string(flag)})
+}
+
+// State describes a flag for listing.
+type State struct {
+	Flag Flag
+	On   bool
+	// Toggled is set if a runtime toggle overrides the default.
+	Toggled bool
+}
+
+// List returns the state of every known, defaulted or toggled flag, by name.
+func (s *Set) List(ctx context.Context) []State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh(ctx)
+
+	names := map[Flag]bool{}
+	for _, flag := range Known {
+		names[flag] = true
+	}
+	for flag := range s.defaults {
+		names[flag] = true
+	}
+	for flag := range s.overrides {
+		names[flag] = true
+	}
+
+	states := []State{}
+	for flag := range names {
+		state := State{Flag: flag, On: true}
+		if on, ok := s.defaults[flag]; ok {
+			state.On = on
+		}
+		if on, ok := s.overrides[flag]; ok {
+			state.On, state.Toggled = on, true
+		}
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Flag < states[j].Flag })
+	return states
+}
+
+func (s *Set) update(ctx context.Context, change func(map[Flag]bool), action string, fields map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Start from the store's latest so toggles from other instances aren't lost.
+	overrides := map[Flag]bool{}
+	if s.Store != nil {
+		if _, err := s.Store.Get(ctx, storeKey, &overrides); err != nil {
+			return fmt.Errorf("error loading flags: %v", err)
+		}
+	} else {
+		for flag, on := range s.overrides {
+			overrides[flag] = on
+		}
+	}
+	change(overrides)
+	if s.Store != nil {
+		if err := s.Store.Put(ctx, storeKey, overrides); err != nil {
+			return fmt.Errorf("error saving flags: %v", err)
+		}
+	}
+	s.overrides = overrides
+	s.loaded = time.Now()
+
+	if s.Audit != nil {
+		return s.Audit.Record(ctx, audit.Entry{Actor: audit.Actor(ctx), Action: action, Fields: fields})
+	}
+	return nil
+}
+
+// refresh reloads toggles from the store if they're stale. s.mu must be held.
+func (s *Set) refresh(ctx context.Context) {
+	if s.Store == nil || time.Since(s.loaded) < refreshInterval {
+		return
+	}
+	overrides := map[Flag]bool{}
+	s.loaded = time.Now()
+	if _, err := s.Store.Get(ctx, storeKey, &overrides); err != nil {
+		// Keep the last toggles seen; a store outage shouldn't flip switches.
+		log.Printf("error loading flags: %v", err)
+		return
+	}
+	s.overrides = overrides
+}
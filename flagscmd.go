@@ -0,0 +1,98 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/slack-go/slack"
+
+	"github.com/geomodulus/robots/audit"
+	"github.com/geomodulus/robots/command"
+	"github.com/geomodulus/robots/flags"
+)
+
+// FlagsCommand lets admins list and toggle feature flags from Slack:
+//
+//	/flags
+//	/flags off reindex
+//	/flags on reindex
+//	/flags reset reindex
+//
+// Call HandleSlashCommand from the bot's handler for the command it's registered as.
+type FlagsCommand struct {
+	Flags *flags.Set
+	// Admins are the Slack user IDs allowed to toggle flags. Anyone can list them.
+	Admins []string
+}
+
+var flagsGrammar = &command.Grammar{
+	Default: "list",
+	Commands: []*command.Command{
+		{Verb: "list", Help: "Show every flag and whether it's on."},
+		{Verb: "on", Args: []command.Arg{{Name: "flag"}}, Help: "Switch a flag on."},
+		{Verb: "off", Args: []command.Arg{{Name: "flag"}}, Help: "Switch a flag off."},
+		{Verb: "reset", Args: []command.Arg{{Name: "flag"}}, Help: "Go back to the flag's configured default."},
+	},
+}
+
+func (c *FlagsCommand) HandleSlashCommand(ctx context.Context, env *Envelope, cmd string) ([]slack.Block, error) {
+	inv, help, err := parseCommand(env, flagsGrammar.WithName(cmd))
+	if inv == nil {
+		return help, err
+	}
+	if inv.Verb() == "list" {
+		return c.list(ctx), nil
+	}
+	if !isAdmin(c.Admins, env.UserID) {
+		return nil, fmt.Errorf("only admins can toggle flags")
+	}
+
+	flag := flags.Flag(inv.Arg("flag"))
+	if !knownFlag(flag) {
+		return nil, fmt.Errorf("unknown flag %q", flag)
+	}
+	ctx = audit.WithActor(ctx, env.UserID)
+	if inv.Verb() == "reset" {
+		if err := c.Flags.Reset(ctx, flag); err != nil {
+			return nil, err
+		}
+	} else {
+		on, err := flags.ParseState(inv.Verb())
+		if err != nil {
+			return nil, err
+		}
+		if err := c.Flags.Toggle(ctx, flag, on); err != nil {
+			return nil, err
+		}
+	}
+	return c.list(ctx), nil
+}
+
+func (c *FlagsCommand) list(ctx context.Context) []slack.Block {
+	lines := []string{}
+	for _, state := range c.Flags.List(ctx) {
+		marker := ":large_green_circle:"
+		if !state.On {
+			marker = ":red_circle:"
+		}
+		line := fmt.Sprintf("%s `%s`", marker, state.Flag)
+		if state.Toggled {
+			line += " _(toggled)_"
+		}
+		lines = append(lines, line)
+	}
+	return []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType,
+			"*Feature flags*\n"+strings.Join(lines, "\n"), false, false), nil, nil),
+	}
+}
+
+func knownFlag(flag flags.Flag) bool {
+	for _, known := range flags.Known {
+		if known == flag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+// Package geocode turns place names, addresses and intersections into coordinates, via
+// OpenStreetMap's Nominatim.
+package geocode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/geomodulus/citygraph"
+)
+
+// DefaultEndpoint is the public Nominatim search API.
+const DefaultEndpoint = "https://nominatim.openstreetmap.org/search"
+
+// TorontoViewbox bounds searches to the city, as "west,north,east,south".
+const TorontoViewbox = "-79.64,43.86,-79.11,43.58"
+
+// ErrNotFound is returned when nothing matches a query.
+var ErrNotFound = errors.New("no match found")
+
+// Result is the best match for a query.
+type Result struct {
+	Query       string
+	DisplayName string
+	Location    citygraph.LngLat
+	// Source identifies the OSM element matched, eg. "node/123".
+	Source string
+}
+
+// Geocoder resolves free-text locations.
+type Geocoder interface {
+	Geocode(ctx context.Context, query string) (*Result, error)
+}
+
+// Client is a Geocoder backed by Nominatim. The public instance allows one request a second, so
+// requests are spaced out.
+type Client struct {
+	HTTPClient *http.Client
+	Endpoint   string
+	// Viewbox limits results to an area; empty searches everywhere.
+	Viewbox string
+	// UserAgent identifies the bot, as Nominatim's usage policy requires.
+	UserAgent string
+	// Interval is the minimum time between requests.
+	Interval time.Duration
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+func NewClient() *Client {
+	return &Client{
+		HTTPClient: &http.Client{Timeout: 15 * time.Second},
+		Endpoint:   DefaultEndpoint,
+		Viewbox:    TorontoViewbox,
+		UserAgent:  "geomodulus-robots (https://github.com/geomodulus/robots)",
+		Interval:   time.Second,
+	}
+}
+
+func (c *Client) Geocode(ctx context.Context, query string) (*Result, error) {
+	if err := c.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	params := url.Values{
+		"q":      {query},
+		"format": {"jsonv2"},
+		"limit":  {"1"},
+	}
+	if c.Viewbox != "" {
+		params.Set("viewbox", c.Viewbox)
+		params.Set("bounded", "1")
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", c.Endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("http.NewRequest: %v", err)
+	}
+	req.Header.Set("User-Agent", c.UserAgent)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("nominatim request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("nominatim returned %s: %s", resp.Status, body)
+	}
+
+	var matches []struct {
+		OSMType     string `json:"osm_type"`
+		OSMID       int64  `json:"osm_id"`
+		Lat         string `json:"lat"`
+		Lon         string `json:"lon"`
+		DisplayName string `json:"display_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&matches); err != nil {
+		return nil, fmt.Errorf("error decoding nominatim response: %v", err)
+	}
+	if len(matches) == 0 {
+		return nil, ErrNotFound
+	}
+
+	match := matches[0]
+	lat, err := strconv.ParseFloat(match.Lat, 64)
+	if err != nil {
+		return nil, fmt.Errorf("bad latitude %q: %v", match.Lat, err)
+	}
+	lng, err := strconv.ParseFloat(match.Lon, 64)
+	if err != nil {
+		return nil, fmt.Errorf("bad longitude %q: %v", match.Lon, err)
+	}
+	return &Result{
+		Query:       query,
+		DisplayName: match.DisplayName,
+		Location:    citygraph.LngLat{Lng: lng, Lat: lat},
+		Source:      fmt.Sprintf("%s/%d", match.OSMType, match.OSMID),
+	}, nil
+}
+
+// wait blocks until Interval has passed since the last request.
+func (c *Client) wait(ctx context.Context) error {
+	c.mu.Lock()
+	next := c.last.Add(c.Interval)
+	now := time.Now()
+	if next.Before(now) {
+		next = now
+	}
+	c.last = next
+	c.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Until(next)):
+		return nil
+	}
+}
@@ -0,0 +1,126 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	gh "github.com/google/go-github/v53/github"
+
+	"github.com/geomodulus/robots/a11y"
+)
+
+// AccessibilityCheckName is the name of the check run AccessibilityHook reports to.
+const AccessibilityCheckName = "Accessibility"
+
+// maxAnnotations is how many annotations GitHub takes per check run request.
+const maxAnnotations = 50
+
+// AccessibilityNotifyFunc is told about the accessibility findings for a push, keyed by
+// article.html path, eg. to post them in the Slack thread that asked for the PR.
+type AccessibilityNotifyFunc func(ctx context.Context, push *Push, findings map[string][]a11y.Finding) error
+
+// AccessibilityHook audits every article.html changed by the pushed commit, reports the findings
+// as annotations on an Accessibility check run and, if there are any, calls notify (which may be
+// nil). Findings are warnings: the check's conclusion is neutral, so they don't block merging.
+func AccessibilityHook(notify AccessibilityNotifyFunc) PushHook {
+	return func(ctx context.Context, a *App, push *Push) error {
+		paths, err := a.changedFiles(ctx, push.SHA, "article.html")
+		if err != nil {
+			return err
+		}
+		if len(paths) == 0 {
+			return nil
+		}
+
+		findings := map[string][]a11y.Finding{}
+		for _, p := range paths {
+			content, err := a.fetchFileContent(ctx, p, push.SHA)
+			if err != nil {
+				return err
+			}
+			if found := a11y.Audit(content); len(found) > 0 {
+				findings[p] = found
+			}
+		}
+
+		if err := a.ReportAccessibility(ctx, push.SHA, findings); err != nil {
+			return err
+		}
+		if notify != nil && len(findings) > 0 {
+			return notify(ctx, push, findings)
+		}
+		return nil
+	}
+}
+
+// ReportAccessibility creates a completed Accessibility check run on sha with an annotation for
+// each finding.
+func (a *App) ReportAccessibility(ctx context.Context, sha string, findings map[string][]a11y.Finding) error {
+	paths := []string{}
+	for p := range findings {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	annotations := []*gh.CheckRunAnnotation{}
+	for _, p := range paths {
+		for _, finding := range findings[p] {
+			annotations = append(annotations, &gh.CheckRunAnnotation{
+				Path:            gh.String(p),
+				StartLine:       gh.Int(finding.Line),
+				EndLine:         gh.Int(finding.Line),
+				AnnotationLevel: gh.String("warning"),
+				Title:           gh.String(string(finding.Rule)),
+				Message:         gh.String(finding.Message),
+			})
+		}
+	}
+
+	conclusion := "success"
+	title := "No accessibility problems found"
+	if len(annotations) > 0 {
+		conclusion = "neutral"
+		title = fmt.Sprintf("%d accessibility problems found", len(annotations))
+	}
+	summary := title + "."
+	first := annotations
+	if len(first) > maxAnnotations {
+		first = first[:maxAnnotations]
+	}
+	checks := a.scoped(ChecksScope)
+	run, _, err := checks.Checks.CreateCheckRun(ctx, a.Owner, a.Repo, gh.CreateCheckRunOptions{
+		Name:       AccessibilityCheckName,
+		HeadSHA:    sha,
+		Status:     gh.String("completed"),
+		Conclusion: gh.String(conclusion),
+		Output: &gh.CheckRunOutput{
+			Title:       gh.String(title),
+			Summary:     gh.String(summary),
+			Annotations: first,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error creating check run: %v", err)
+	}
+
+	// The rest go on in batches.
+	for start := maxAnnotations; start < len(annotations); start += maxAnnotations {
+		end := start + maxAnnotations
+		if end > len(annotations) {
+			end = len(annotations)
+		}
+		_, _, err := checks.Checks.UpdateCheckRun(ctx, a.Owner, a.Repo, run.GetID(), gh.UpdateCheckRunOptions{
+			Name: AccessibilityCheckName,
+			Output: &gh.CheckRunOutput{
+				Title:       gh.String(title),
+				Summary:     gh.String(summary),
+				Annotations: annotations[start:end],
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("error updating check run: %v", err)
+		}
+	}
+	return nil
+}
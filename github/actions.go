@@ -0,0 +1,221 @@
+package github
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	gh "github.com/google/go-github/v53/github"
+
+	"github.com/geomodulus/robots/reqid"
+)
+
+const (
+	// excerptLines is how much of a failing job's log is kept, ending at its first error.
+	excerptLines = 25
+	// maxLogBytes bounds how much of a job log is read looking for the error.
+	maxLogBytes = 8 << 20
+)
+
+// buildPollInterval is how often WaitForWorkflowRuns checks on a commit's runs.
+var buildPollInterval = 30 * time.Second
+
+// logTimestamp matches the timestamp Actions puts at the start of every log line.
+var logTimestamp = regexp.MustCompile(`^\d{4}-\d\d-\d\dT[\d:.]+Z `)
+
+// JobLog is an excerpt from a failed workflow job's log.
+type JobLog struct {
+	Run *gh.WorkflowRun
+	Job *gh.WorkflowJob
+	// FailedStep is the name of the first step that failed, if any.
+	FailedStep string
+	// Excerpt is the lines leading up to the first error, or the end of the log if there's no
+	// error marker.
+	Excerpt string
+}
+
+// WorkflowArtifact is an artifact uploaded by a workflow run.
+type WorkflowArtifact struct {
+	Run      *gh.WorkflowRun
+	Artifact *gh.Artifact
+	// DownloadURL is a zip of the artifact. It expires after a minute.
+	DownloadURL string
+}
+
+// BuildFailureNotifyFunc is told about the failed jobs for a push.
+type BuildFailureNotifyFunc func(ctx context.Context, push *Push, logs []*JobLog) error
+
+// BuildFailureHook waits, in the background, up to timeout for the pushed commit's workflow
+// runs to finish, and calls notify with log excerpts from any failed jobs.
+func BuildFailureHook(timeout time.Duration, notify BuildFailureNotifyFunc) PushHook {
+	return func(pushCtx context.Context, a *App, push *Push) error {
+		go func() {
+			ctx, cancel := context.WithTimeout(reqid.Detach(pushCtx), timeout)
+			defer cancel()
+
+			if _, err := a.WaitForWorkflowRuns(ctx, push.SHA); err != nil {
+				reqid.Printf(ctx, "builds for PR #%d didn't finish: %v", push.PR.GetNumber(), err)
+				return
+			}
+			logs, err := a.FetchWorkflowRunLogs(ctx, push.SHA)
+			if err != nil {
+				reqid.Printf(ctx, "error fetching build logs for PR #%d: %v", push.PR.GetNumber(), err)
+				return
+			}
+			if len(logs) == 0 {
+				return
+			}
+			if err := notify(ctx, push, logs); err != nil {
+				reqid.Printf(ctx, "error announcing build failure for PR #%d: %v", push.PR.GetNumber(), err)
+			}
+		}()
+		return nil
+	}
+}
+
+// WorkflowRuns returns the workflow runs for sha.
+func (a *App) WorkflowRuns(ctx context.Context, sha string) ([]*gh.WorkflowRun, error) {
+	runs, _, err := a.Actions.ListRepositoryWorkflowRuns(ctx, a.Owner, a.Repo, &gh.ListWorkflowRunsOptions{HeadSHA: sha})
+	if err != nil {
+		return nil, fmt.Errorf("error listing workflow runs: %v", err)
+	}
+	return runs.WorkflowRuns, nil
+}
+
+// WaitForWorkflowRuns polls until sha has workflow runs and they've all completed, or ctx is
+// done.
+func (a *App) WaitForWorkflowRuns(ctx context.Context, sha string) ([]*gh.WorkflowRun, error) {
+	for {
+		runs, err := a.WorkflowRuns(ctx, sha)
+		if err != nil {
+			return nil, err
+		}
+		done := len(runs) > 0
+		for _, run := range runs {
+			if run.GetStatus() != "completed" {
+				done = false
+			}
+		}
+		if done {
+			return runs, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(buildPollInterval):
+		}
+	}
+}
+
+// FetchWorkflowRunLogs returns log excerpts for every failed job in sha's workflow runs.
+func (a *App) FetchWorkflowRunLogs(ctx context.Context, sha string) ([]*JobLog, error) {
+	runs, err := a.WorkflowRuns(ctx, sha)
+	if err != nil {
+		return nil, err
+	}
+
+	logs := []*JobLog{}
+	for _, run := range runs {
+		if run.GetConclusion() != "failure" {
+			continue
+		}
+		jobs, _, err := a.Actions.ListWorkflowJobs(ctx, a.Owner, a.Repo, run.GetID(), &gh.ListWorkflowJobsOptions{Filter: "latest"})
+		if err != nil {
+			return nil, fmt.Errorf("error listing jobs for run %d: %v", run.GetID(), err)
+		}
+		for _, job := range jobs.Jobs {
+			if job.GetConclusion() != "failure" {
+				continue
+			}
+			excerpt, err := a.jobLogExcerpt(ctx, job.GetID())
+			if err != nil {
+				return nil, err
+			}
+			jobLog := &JobLog{Run: run, Job: job, Excerpt: excerpt}
+			for _, step := range job.Steps {
+				if step.GetConclusion() == "failure" {
+					jobLog.FailedStep = step.GetName()
+					break
+				}
+			}
+			logs = append(logs, jobLog)
+		}
+	}
+	return logs, nil
+}
+
+// FetchWorkflowRunArtifacts returns the artifacts uploaded by sha's workflow runs.
+func (a *App) FetchWorkflowRunArtifacts(ctx context.Context, sha string) ([]*WorkflowArtifact, error) {
+	runs, err := a.WorkflowRuns(ctx, sha)
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := []*WorkflowArtifact{}
+	for _, run := range runs {
+		list, _, err := a.Actions.ListWorkflowRunArtifacts(ctx, a.Owner, a.Repo, run.GetID(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("error listing artifacts for run %d: %v", run.GetID(), err)
+		}
+		for _, artifact := range list.Artifacts {
+			if artifact.GetExpired() {
+				continue
+			}
+			downloadURL, _, err := a.Actions.DownloadArtifact(ctx, a.Owner, a.Repo, artifact.GetID(), true)
+			if err != nil {
+				return nil, fmt.Errorf("error getting download URL for artifact %s: %v", artifact.GetName(), err)
+			}
+			artifacts = append(artifacts, &WorkflowArtifact{Run: run, Artifact: artifact, DownloadURL: downloadURL.String()})
+		}
+	}
+	return artifacts, nil
+}
+
+func (a *App) jobLogExcerpt(ctx context.Context, jobID int64) (string, error) {
+	logURL, _, err := a.Actions.GetWorkflowJobLogs(ctx, a.Owner, a.Repo, jobID, true)
+	if err != nil {
+		return "", fmt.Errorf("error getting log URL for job %d: %v", jobID, err)
+	}
+	// The log URL is pre-signed, so it's fetched without GitHub credentials.
+	req, err := http.NewRequestWithContext(ctx, "GET", logURL.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("http.NewRequest: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error downloading log for job %d: %v", jobID, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error downloading log for job %d: %s", jobID, resp.Status)
+	}
+	return logExcerpt(io.LimitReader(resp.Body, maxLogBytes))
+}
+
+// logExcerpt returns the lines up to and including the first "##[error]" line, or the last
+// lines of the log if there isn't one.
+func logExcerpt(r io.Reader) (string, error) {
+	lines := []string{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := logTimestamp.ReplaceAllString(scanner.Text(), "")
+		lines = append(lines, line)
+		if len(lines) > excerptLines {
+			lines = lines[1:]
+		}
+		if strings.HasPrefix(line, "##[error]") {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("error reading log: %v", err)
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,71 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	gh "github.com/google/go-github/v53/github"
+
+	"github.com/geomodulus/robots/reqid"
+)
+
+// approvalPollInterval is how often WaitForApproval checks a PR's reviews.
+var approvalPollInterval = time.Minute
+
+// ApprovalNotifyFunc is told when a PR is approved.
+type ApprovalNotifyFunc func(ctx context.Context, push *Push, review *gh.PullRequestReview) error
+
+// ApprovalHook waits, in the background, up to timeout for a PR the push opened to be approved,
+// and calls notify with the approving review. Pushes to existing PRs are ignored, so each PR is
+// only watched once.
+func ApprovalHook(timeout time.Duration, notify ApprovalNotifyFunc) PushHook {
+	return func(pushCtx context.Context, a *App, push *Push) error {
+		if !push.Created {
+			return nil
+		}
+		go func() {
+			ctx, cancel := context.WithTimeout(reqid.Detach(pushCtx), timeout)
+			defer cancel()
+
+			review, err := a.WaitForApproval(ctx, push.PR.GetNumber())
+			if err != nil {
+				reqid.Printf(ctx, "PR #%d wasn't approved: %v", push.PR.GetNumber(), err)
+				return
+			}
+			if err := notify(ctx, push, review); err != nil {
+				reqid.Printf(ctx, "error announcing approval of PR #%d: %v", push.PR.GetNumber(), err)
+			}
+		}()
+		return nil
+	}
+}
+
+// WaitForApproval polls until PR prNum has an approving review, which it returns, or ctx is
+// done. It gives up if the PR is closed first.
+func (a *App) WaitForApproval(ctx context.Context, prNum int) (*gh.PullRequestReview, error) {
+	for {
+		reviews, _, err := a.PullRequests.ListReviews(ctx, a.Owner, a.Repo, prNum, &gh.ListOptions{PerPage: 100})
+		if err != nil {
+			return nil, fmt.Errorf("error listing reviews: %v", err)
+		}
+		for _, review := range reviews {
+			if review.GetState() == "APPROVED" {
+				return review, nil
+			}
+		}
+		pr, _, err := a.PullRequests.Get(ctx, a.Owner, a.Repo, prNum)
+		if err != nil {
+			return nil, fmt.Errorf("error getting PR: %v", err)
+		}
+		if pr.GetState() == "closed" {
+			return nil, fmt.Errorf("PR #%d was closed", prNum)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(approvalPollInterval):
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	gh "github.com/google/go-github/v53/github"
+
+	"github.com/geomodulus/citygraph"
+	"github.com/geomodulus/citygraph/pb"
+)
+
+// ArchiveReferences is what still refers to an article that's about to be archived.
+type ArchiveReferences struct {
+	Slug    string
+	Article *citygraph.Article
+	// Articles are the slugs of live articles whose bodies link to it.
+	Articles []string
+	// RelatedBy are the slugs of live articles that list it in related.
+	RelatedBy []string
+	// Collections are the slugs of the collections it's part of. Archiving it without taking it
+	// out of them makes ValidateCollections fail from then on.
+	Collections []string
+	// Places are the slugs of places that list it in related_articles.
+	Places []string
+	// GraphEdges are the edges into the article's vertex in the citygraph store.
+	GraphEdges []*pb.Edge
+}
+
+// Empty reports whether nothing refers to the article, so it can be archived as is.
+func (r *ArchiveReferences) Empty() bool {
+	return len(r.Articles) == 0 && len(r.RelatedBy) == 0 && len(r.Collections) == 0 &&
+		len(r.Places) == 0 && len(r.GraphEdges) == 0
+}
+
+// Report lists the references as Markdown.
+func (r *ArchiveReferences) Report() string {
+	if r.Empty() {
+		return fmt.Sprintf("Nothing refers to **%s**.\n", r.Article.Name)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "**%s** is still referred to by:\n", r.Article.Name)
+	for _, slug := range r.Articles {
+		fmt.Fprintf(&b, "- Article `%s` links to it\n", slug)
+	}
+	for _, slug := range r.RelatedBy {
+		fmt.Fprintf(&b, "- Article `%s` lists it in related\n", slug)
+	}
+	for _, slug := range r.Collections {
+		fmt.Fprintf(&b, "- Collection `%s` includes it\n", slug)
+	}
+	for _, slug := range r.Places {
+		fmt.Fprintf(&b, "- Place `%s` lists it in related_articles\n", slug)
+	}
+	for _, edge := range r.GraphEdges {
+		fmt.Fprintf(&b, "- Graph edge `%s` from vertex `%s`\n", edge.GetKey().GetT().GetValue(), uuidString(edge.GetKey().GetOutboundId()))
+	}
+	return b.String()
+}
+
+// CheckArchiveReferences finds what refers to the article at slug before it's archived: live
+// articles linking to it or listing it as related, collections it's part of, places listing it
+// as related, and, if graph isn't nil, edges into its vertex in the citygraph store. places may
+// be nil to skip them.
+func CheckArchiveReferences(ctx context.Context, articles, places *App, graph citygraph.GraphClient, slug string) (*ArchiveReferences, error) {
+	checkout, err := articles.FetchArticle(ctx, slug)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching article: %w", err)
+	}
+	refs := &ArchiveReferences{
+		Slug:        slug,
+		Article:     checkout.Article,
+		Articles:    []string{},
+		RelatedBy:   []string{},
+		Collections: []string{},
+		Places:      []string{},
+	}
+
+	links, err := articles.ArticleLinkGraph(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error building link graph: %w", err)
+	}
+	refs.Articles = append(refs.Articles, links.Inbound[slug]...)
+
+	live, err := articles.ListArticles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing articles: %w", err)
+	}
+	for _, other := range live {
+		if other.Slug == slug {
+			continue
+		}
+		for _, id := range other.Article.Related {
+			if id == checkout.Article.ID {
+				refs.RelatedBy = append(refs.RelatedBy, other.Slug)
+				break
+			}
+		}
+	}
+	sort.Strings(refs.RelatedBy)
+
+	collections, err := articles.FetchCollections(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching collections: %w", err)
+	}
+	for _, collection := range collections {
+		for _, member := range collection.Articles {
+			if member == slug {
+				refs.Collections = append(refs.Collections, collection.Slug)
+				break
+			}
+		}
+	}
+	sort.Strings(refs.Collections)
+
+	if places != nil {
+		allPlaces, err := places.ListPlaces(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing places: %w", err)
+		}
+		for _, place := range allPlaces {
+			for _, id := range place.RelatedArticles {
+				if id == checkout.Article.ID {
+					refs.Places = append(refs.Places, place.Slug)
+					break
+				}
+			}
+		}
+		sort.Strings(refs.Places)
+	}
+
+	if graph != nil {
+		vertex, err := checkout.Article.VertexQuery()
+		if err != nil {
+			return nil, fmt.Errorf("error building vertex query: %v", err)
+		}
+		refs.GraphEdges, err = graph.GetEdges(ctx, citygraph.NewPipeEdgeQuery(vertex, pb.EdgeDirection_INBOUND, nil))
+		if err != nil {
+			return nil, fmt.Errorf("error getting graph edges: %v", err)
+		}
+	}
+	return refs, nil
+}
+
+// RewriteArchivedLinks points the links in each of refs.Articles at archivePath, where the article
+// lives once it's archived. Pass WithPRNum to commit to the archiving PR, so the links and the
+// move land together. It returns how many links were rewritten.
+func (a *App) RewriteArchivedLinks(ctx context.Context, refs *ArchiveReferences, archivePath string, opts ...Option) (int, *gh.PullRequest, error) {
+	params := Params{
+		PRTitle: "Update links to archived article: " + refs.Article.Name,
+		PRBody:  "Points links to `" + refs.Slug + "` at " + archivePath + ".",
+	}
+	for _, opt := range opts {
+		opt(&params)
+	}
+	slugID, err := refs.Article.SlugID()
+	if err != nil {
+		return 0, nil, fmt.Errorf("error getting slug id: %v", err)
+	}
+
+	rewritten := 0
+	treeEntries := []*gh.TreeEntry{}
+	for _, slug := range refs.Articles {
+		checkout, err := a.FetchArticle(ctx, slug)
+		if err != nil {
+			return 0, nil, fmt.Errorf("error fetching %s: %w", slug, err)
+		}
+		body, n := rewriteArticleLinks(checkout.BodyHTML, slugID, archivePath)
+		if n == 0 {
+			continue
+		}
+		entry, err := articleBodyHTML("articles/"+slug, body)
+		if err != nil {
+			return 0, nil, fmt.Errorf("error creating tree entry for %s: %w", slug, err)
+		}
+		treeEntries = append(treeEntries, entry)
+		rewritten += n
+	}
+	if len(treeEntries) == 0 {
+		return 0, nil, nil
+	}
+
+	pr, _, err := a.commitToPullRequest(ctx, treeEntries, params)
+	if err != nil {
+		return 0, nil, err
+	}
+	return rewritten, pr, nil
+}
+
+var hrefAttr = regexp.MustCompile(`(href\s*=\s*)("[^"]*"|'[^']*')`)
+
+// rewriteArticleLinks replaces the href of every link to the article with slugID by newPath,
+// keeping any #fragment. The rest of the HTML is left as written.
+func rewriteArticleLinks(body, slugID, newPath string) (string, int) {
+	n := 0
+	out := hrefAttr.ReplaceAllStringFunc(body, func(attr string) string {
+		parts := hrefAttr.FindStringSubmatch(attr)
+		quote := parts[2][:1]
+		href := parts[2][1 : len(parts[2])-1]
+		if id, ok := linkedSlugID(href); !ok || id != slugID {
+			return attr
+		}
+		n++
+		target := newPath
+		if i := strings.Index(href, "#"); i >= 0 {
+			target += href[i:]
+		}
+		return parts[1] + quote + target + quote
+	})
+	return out, n
+}
+
+func uuidString(id *pb.Uuid) string {
+	b := id.GetValue()
+	if len(b) != 16 {
+		return fmt.Sprintf("%x", b)
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
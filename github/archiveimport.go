@@ -0,0 +1,237 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	gh "github.com/google/go-github/v53/github"
+
+	"github.com/geomodulus/citygraph"
+)
+
+// defaultImportBatchSize is how many articles ImportArchivedArticles commits at a time, to keep
+// each tree request well under GitHub's limits.
+const defaultImportBatchSize = 25
+
+// ImportedArticle is an article from another CMS, ready to be added to the archive.
+type ImportedArticle struct {
+	// Slug is the article's preferred slug. It's suffixed with -2, -3, … if already taken.
+	Slug string
+	// SourceID identifies the article in the CMS it came from, eg. "wordpress:123". It's kept
+	// in article.json, so a later run of the same import knows to skip the article.
+	SourceID string
+	Article  *citygraph.Article
+	BodyHTML string
+}
+
+// ImportArchivedArticles adds articles under archive/articles/ in one PR, committing batchSize
+// articles at a time so a large import doesn't have to fit in a single tree. An article already
+// imported, on main or on an open import PR, is skipped rather than duplicated, and new batches
+// go to the open PR, so an import that failed partway through can be run again. Articles are
+// matched by SourceID, or without one by slug and name. It returns the PR's number and URL, and
+// the slugs the articles were committed under, in order, with "" for skipped articles.
+func (a *App) ImportArchivedArticles(ctx context.Context, articles []*ImportedArticle, batchSize int, opts ...Option) (int, string, []string, error) {
+	if len(articles) == 0 {
+		return 0, "", nil, fmt.Errorf("no articles to import")
+	}
+	if batchSize <= 0 {
+		batchSize = defaultImportBatchSize
+	}
+
+	live, err := a.ListArticles(ctx)
+	if err != nil {
+		return 0, "", nil, fmt.Errorf("error listing articles: %w", err)
+	}
+	state, err := a.archiveImportState(ctx)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	taken := map[string]bool{}
+	for _, checkout := range live {
+		taken[checkout.Slug] = true
+	}
+	archivedNames := map[string]string{}
+	for _, checkout := range state.archived {
+		taken[checkout.Slug] = true
+		archivedNames[checkout.Slug] = checkout.Article.Name
+	}
+
+	params := Params{
+		PRTitle: fmt.Sprintf("Import %d archived articles", len(articles)),
+	}
+	for _, opt := range opts {
+		opt(&params)
+	}
+	basePRTitle := params.PRTitle
+	if params.PRNum == 0 {
+		params.PRNum = state.prNum
+	}
+
+	slugs := make([]string, len(articles))
+	var activePR *gh.PullRequest
+	for start := 0; start < len(articles); start += batchSize {
+		end := start + batchSize
+		if end > len(articles) {
+			end = len(articles)
+		}
+
+		treeEntries := []*gh.TreeEntry{}
+		for i, imported := range articles[start:end] {
+			if imported.SourceID != "" && state.imported[imported.SourceID] {
+				continue
+			}
+			if name, ok := archivedNames[imported.Slug]; ok && imported.SourceID == "" && name == imported.Article.Name {
+				continue
+			}
+			slug := uniqueSlug(imported.Slug, taken)
+			taken[slug] = true
+			slugs[start+i] = slug
+
+			articleParams := params
+			articleParams.Article = imported.Article
+			articleParams.Article.Slug = slug
+			articleParams.BodyHTML = imported.BodyHTML
+			articleParams.ImportedFrom = imported.SourceID
+			articleParams.ArticleJS = articleScaffolds[StandardStory].js
+			entries, err := treeEntriesFromParams("archive/articles/"+slug, articleParams)
+			if err != nil {
+				return 0, "", nil, fmt.Errorf("error creating tree entries for %s: %w", slug, err)
+			}
+			treeEntries = append(treeEntries, entries...)
+		}
+		if len(treeEntries) == 0 {
+			continue
+		}
+
+		batchParams := params
+		if batchParams.PRBody == "" {
+			batchParams.PRBody = importedArticlesPRBody(articles)
+		}
+		// The PR is named after its first commit, so only later batches say which they are.
+		if activePR != nil {
+			batchParams.PRNum = activePR.GetNumber()
+		}
+		if batchParams.PRNum != 0 {
+			batchParams.PRTitle = fmt.Sprintf("%s (%d–%d of %d)", basePRTitle, start+1, end, len(articles))
+		}
+		var created bool
+		activePR, created, err = a.commitToPullRequest(ctx, treeEntries, batchParams)
+		if err != nil {
+			return 0, "", nil, fmt.Errorf("error committing articles %d–%d: %w", start+1, end, err)
+		}
+		if created {
+			reviewers := params.Reviewers
+			if len(reviewers) == 0 {
+				reviewers = DefaultReviewers
+			}
+			if _, _, err := a.PullRequests.RequestReviewers(ctx, a.Owner, a.Repo, activePR.GetNumber(), gh.ReviewersRequest{
+				Reviewers: reviewers,
+			}); err != nil {
+				return 0, "", nil, fmt.Errorf("error requesting reviewers: %v", err)
+			}
+		}
+	}
+	if activePR == nil {
+		return 0, "", slugs, fmt.Errorf("every article is already archived")
+	}
+	return activePR.GetNumber(), activePR.GetHTMLURL(), slugs, nil
+}
+
+// ImportedSources returns the SourceIDs of the articles already imported into the archive, on
+// main or on an open import PR, so an import can leave them out before preparing them.
+func (a *App) ImportedSources(ctx context.Context) (map[string]bool, error) {
+	state, err := a.archiveImportState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return state.imported, nil
+}
+
+// archiveImport is what's already in the archive: the articles on main and on the open import
+// PR, if there is one.
+type archiveImport struct {
+	archived []*ArticleCheckout
+	// imported holds the SourceIDs of the imported articles.
+	imported map[string]bool
+	// prNum is the open import PR, or 0.
+	prNum int
+}
+
+func (a *App) archiveImportState(ctx context.Context) (*archiveImport, error) {
+	archived, err := a.ListArchivedArticles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing archived articles: %w", err)
+	}
+	state := &archiveImport{archived: archived, imported: map[string]bool{}}
+	onMain := map[string]bool{}
+	for _, checkout := range archived {
+		onMain[checkout.Slug] = true
+	}
+
+	// An open PR adding imported articles to the archive is an import that didn't finish. Its
+	// articles count as archived, and new batches go to it.
+	byDir, err := a.OpenPullRequestsByDir(ctx, "archive/articles")
+	if err != nil {
+		return nil, err
+	}
+	prs := []*gh.PullRequest{}
+	changed := map[int]map[string]bool{}
+	for slug, dirPRs := range byDir {
+		for _, pr := range dirPRs {
+			if changed[pr.GetNumber()] == nil {
+				changed[pr.GetNumber()] = map[string]bool{}
+				prs = append(prs, pr)
+			}
+			changed[pr.GetNumber()][slug] = true
+		}
+	}
+	sort.Slice(prs, func(i, j int) bool {
+		return prs[i].GetCreatedAt().After(prs[j].GetCreatedAt().Time)
+	})
+	for _, pr := range prs {
+		onBranch, err := a.listArticlesAt(ctx, "archive/articles", pr.GetHead().GetSHA())
+		if err != nil {
+			return nil, fmt.Errorf("error listing archived articles on PR #%d: %w", pr.GetNumber(), err)
+		}
+		isImport := false
+		for _, checkout := range onBranch {
+			if changed[pr.GetNumber()][checkout.Slug] && checkout.ImportedFrom != "" {
+				isImport = true
+				break
+			}
+		}
+		if !isImport {
+			continue
+		}
+		for _, checkout := range onBranch {
+			if !onMain[checkout.Slug] {
+				state.archived = append(state.archived, checkout)
+			}
+		}
+		state.prNum = pr.GetNumber()
+		break
+	}
+	for _, checkout := range state.archived {
+		if checkout.ImportedFrom != "" {
+			state.imported[checkout.ImportedFrom] = true
+		}
+	}
+	return state, nil
+}
+
+// importedArticlesPRListLimit keeps the PR body of a large import under GitHub's size limit.
+const importedArticlesPRListLimit = 200
+
+func importedArticlesPRBody(articles []*ImportedArticle) string {
+	lines := []string{fmt.Sprintf("Imports %d articles into `archive/articles/`:", len(articles)), ""}
+	for i, imported := range articles {
+		if i == importedArticlesPRListLimit {
+			lines = append(lines, fmt.Sprintf("- …and %d more", len(articles)-i))
+			break
+		}
+		lines = append(lines, "- "+imported.Article.Name)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,51 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geomodulus/citygraph"
+
+	"github.com/geomodulus/robots/jscheck"
+)
+
+// ArticleJSError is returned when article.js refers to datasets that article.json doesn't
+// declare. Findings lists everything jscheck found, including the warnings
+// that don't block the commit, so the caller can show them all.
+type ArticleJSError struct {
+	Findings []jscheck.Finding
+}
+
+func (e *ArticleJSError) Error() string {
+	problems := []string{}
+	for _, finding := range e.Findings {
+		if finding.Breaks() {
+			problems = append(problems, finding.String())
+		}
+	}
+	return fmt.Sprintf("article.js would break the map: %s", strings.Join(problems, "; "))
+}
+
+// CheckArticleScript runs jscheck on an article's script against its declared datasets. A
+// teaser.geojson, if the article has a teaser, counts as declared.
+func CheckArticleScript(article *citygraph.Article, js string, hasTeaser bool) []jscheck.Finding {
+	datasets := append([]*citygraph.GeoJSONDataset{}, article.GeoJSONDatasets...)
+	if hasTeaser || article.Teaser != nil {
+		// The site draws the teaser itself, so it's never unused.
+		datasets = append(datasets, &citygraph.GeoJSONDataset{Name: "teaser", Render: "teaser"})
+	}
+	return jscheck.Check(js, datasets, jscheck.DefaultModuleAPI)
+}
+
+func guardArticleJS(params Params) error {
+	if !params.CheckArticleJS || params.SkipOptionalChecks || params.Article == nil || params.ArticleJS == "" {
+		return nil
+	}
+	findings := CheckArticleScript(params.Article, params.ArticleJS, params.TeaserGeoJSON != "")
+	for _, finding := range findings {
+		if finding.Breaks() {
+			return &ArticleJSError{Findings: findings}
+		}
+	}
+	return nil
+}
@@ -4,12 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"strings"
 
 	gh "github.com/google/go-github/v53/github"
 	"github.com/paulmach/go.geojson"
 
 	"github.com/geomodulus/citygraph"
+	"github.com/geomodulus/robots/flags"
 	"github.com/geomodulus/robots/prettier"
+	"github.com/geomodulus/robots/pubdate"
 )
 
 // ArticleCheckout contains the contents of an article read directly from Github.
@@ -19,6 +23,36 @@ type ArticleCheckout struct {
 	BodyHTML           string
 	JavascriptFunction string
 	LocationsGeoJSON   *geojson.FeatureCollection
+	Corrections        []*Correction
+	// RelatedPlaces holds the IDs of places featured in the article.
+	RelatedPlaces []string
+	// Series is the article's place in a collection, if it's in one.
+	Series *SeriesPart
+	// ImageCredits is the article's images.json, if it has one.
+	ImageCredits []*ImageCredit
+	// ImportedFrom identifies an archived article in the CMS it was imported from.
+	ImportedFrom string
+	// ArticleJSON is article.json as it's written on main. Pass it to WithBaseArticleJSON when
+	// updating the article so the diff only shows what changed.
+	ArticleJSON string
+}
+
+// CurrentArticle returns an article's article.json as it is on the open PR prNum, or on main if
+// there isn't one.
+func (a *App) CurrentArticle(ctx context.Context, slug string, prNum int) (*citygraph.Article, error) {
+	jsonPath := "articles/" + slug + "/article.json"
+	content, ok, err := a.currentFile(ctx, jsonPath, prNum)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%s doesn't exist", jsonPath)
+	}
+	article := &citygraph.Article{}
+	if err := json.Unmarshal([]byte(content), &articleJSON{Article: article}); err != nil {
+		return nil, fmt.Errorf("error unmarshaling %s: %v", jsonPath, err)
+	}
+	return article, nil
 }
 
 func (a *App) FetchArticle(ctx context.Context, slug string) (*ArticleCheckout, error) {
@@ -44,11 +78,17 @@ func (a *App) FetchArticle(ctx context.Context, slug string) (*ArticleCheckout,
 		return nil, fmt.Errorf("error decoding file content: %v", err)
 	}
 	article := &citygraph.Article{}
-	if err := json.Unmarshal([]byte(content), &article); err != nil {
+	stored := articleJSON{Article: article}
+	if err := json.Unmarshal([]byte(content), &stored); err != nil {
 		return nil, fmt.Errorf("error unmarshaling article: %v", err)
 	}
 	// Update article via new method? here
 	res.Article = article
+	res.Corrections = stored.Corrections
+	res.RelatedPlaces = stored.RelatedPlaces
+	res.Series = stored.Series
+	res.ImportedFrom = stored.ImportedFrom
+	res.ArticleJSON = content
 
 	htmlPath := "articles/" + slug + "/article.html"
 	htmlFile, _, _, err := a.Repositories.GetContents(ctx, a.Owner, a.Repo, htmlPath, &gh.RepositoryContentGetOptions{Ref: branchCommitSHA})
@@ -72,6 +112,22 @@ func (a *App) FetchArticle(ctx context.Context, slug string) (*ArticleCheckout,
 	}
 	res.JavascriptFunction = jsContent
 
+	// images.json is optional; older articles don't have one.
+	imagesPath := "articles/" + slug + "/images.json"
+	imagesFile, _, resp, err := a.Repositories.GetContents(ctx, a.Owner, a.Repo, imagesPath, &gh.RepositoryContentGetOptions{Ref: branchCommitSHA})
+	if err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
+		return nil, fmt.Errorf("error getting file content: %v", err)
+	}
+	if err == nil {
+		imagesContent, err := imagesFile.GetContent()
+		if err != nil {
+			return nil, fmt.Errorf("error decoding file content: %v", err)
+		}
+		if err := json.Unmarshal([]byte(imagesContent), &res.ImageCredits); err != nil {
+			return nil, fmt.Errorf("error unmarshaling image credits: %v", err)
+		}
+	}
+
 	for _, dataset := range article.GeoJSONDatasets {
 		if dataset.Name != "locations" {
 			continue
@@ -94,105 +150,101 @@ func (a *App) FetchArticle(ctx context.Context, slug string) (*ArticleCheckout,
 	return res, nil
 }
 
-func (a *App) CreateOrUpdateArticlePullRequest(ctx context.Context, slug string, opts ...Option) (int, string, error) {
-	var (
-		prBranchRef *gh.Reference
-		activePR    *gh.PullRequest
-		err         error
-	)
+// ListArticles reads every article.json under articles/ on the main branch. Bodies, scripts and
+// datasets aren't fetched.
+func (a *App) ListArticles(ctx context.Context) ([]*ArticleCheckout, error) {
+	return a.listArticles(ctx, "articles")
+}
 
-	params := Params{
-		PRBody: "This PR was created dynamically.",
-	}
-	for _, opt := range opts {
-		opt(&params)
-	}
+// ListArchivedArticles is ListArticles for archive/articles/.
+func (a *App) ListArchivedArticles(ctx context.Context) ([]*ArticleCheckout, error) {
+	return a.listArticles(ctx, "archive/articles")
+}
 
-	var maybeArchive string
-	if params.InArchive {
-		maybeArchive = "archive/"
+func (a *App) listArticles(ctx context.Context, dir string) ([]*ArticleCheckout, error) {
+	sha, err := a.mainSHA(ctx)
+	if err != nil {
+		return nil, err
 	}
+	return a.listArticlesAt(ctx, dir, sha)
+}
 
-	articlePath := maybeArchive + "articles/" + slug
+// listArticlesAt reads every article.json directly under dir as of commit sha.
+func (a *App) listArticlesAt(ctx context.Context, dir, sha string) ([]*ArticleCheckout, error) {
+	tree, _, err := a.Git.GetTree(ctx, a.Owner, a.Repo, sha, true)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tree: %v", err)
+	}
 
-	if params.PRNum == 0 {
-		// No PR exists, create one
-		prBranchRef, err = a.newBranchRef(ctx)
-		if err != nil {
-			return 0, "", fmt.Errorf("error creating new branch: %v", err)
+	articles := []*ArticleCheckout{}
+	for _, entry := range tree.Entries {
+		rest, ok := strings.CutPrefix(entry.GetPath(), dir+"/")
+		parts := strings.Split(rest, "/")
+		if !ok || len(parts) != 2 || parts[1] != "article.json" {
+			continue
 		}
-	} else {
-		// PR exists, check if it's been merged
-		pr, _, err := a.PullRequests.Get(ctx, a.Owner, a.Repo, params.PRNum)
+		content, _, err := a.Git.GetBlobRaw(ctx, a.Owner, a.Repo, entry.GetSHA())
 		if err != nil {
-			return 0, "", fmt.Errorf("error getting PR: %v", err)
+			return nil, fmt.Errorf("error getting %s: %v", entry.GetPath(), err)
 		}
-		if *pr.State == "closed" {
-			// Prior PR has been closed so, create a new one.
-			prBranchRef, err = a.newBranchRef(ctx)
-			if err != nil {
-				return 0, "", fmt.Errorf("error creating new branch: %v", err)
-			}
-		} else {
-			// PR still active, needs to be updated.
-			prBranchRef, _, err = a.Git.GetRef(ctx, a.Owner, a.Repo, "refs/heads/"+pr.GetHead().GetRef())
-			if err != nil {
-				return 0, "", err
-			}
-			activePR = pr
+		article := &citygraph.Article{}
+		stored := articleJSON{Article: article}
+		if err := json.Unmarshal(content, &stored); err != nil {
+			return nil, fmt.Errorf("error unmarshaling %s: %v", entry.GetPath(), err)
 		}
+		articles = append(articles, &ArticleCheckout{
+			Slug:          parts[0],
+			Article:       article,
+			Corrections:   stored.Corrections,
+			RelatedPlaces: stored.RelatedPlaces,
+			Series:        stored.Series,
+			ImportedFrom:  stored.ImportedFrom,
+			ArticleJSON:   string(content),
+		})
 	}
+	return articles, nil
+}
 
-	treeEntries, err := treeEntriesFromParams(articlePath, params)
-	if err != nil {
-		return 0, "", fmt.Errorf("error creating tree entries: %w", err)
+func (a *App) CreateOrUpdateArticlePullRequest(ctx context.Context, slug string, opts ...Option) (int, string, error) {
+	params := articleParams(opts)
+
+	var maybeArchive string
+	if params.InArchive {
+		maybeArchive = "archive/"
 	}
 
-	// Commit the changes.
-	baseSHA := prBranchRef.GetObject().GetSHA()
-	tree, _, err := a.Git.CreateTree(ctx, a.Owner, a.Repo, baseSHA, treeEntries)
-	if err != nil {
-		return 0, "", fmt.Errorf("error creating tree: %v", err)
+	articlePath := maybeArchive + "articles/" + slug
+	if err := a.carryOverArticle(ctx, articlePath, params.PRNum, &params); err != nil {
+		return 0, "", err
 	}
-	parentCommit, _, err := a.Git.GetCommit(ctx, a.Owner, a.Repo, baseSHA)
+
+	// The PR body says which formatter ran, so it's rendered once the files are.
+	fallbacks := prettier.Fallbacks()
+	treeEntries, err := treeEntriesFromParams(articlePath, params)
 	if err != nil {
-		return 0, "", fmt.Errorf("error getting commit: %v", err)
+		return 0, "", fmt.Errorf("error creating tree entries: %w", err)
 	}
-	commit, _, err := a.Git.CreateCommit(ctx, a.Owner, a.Repo, &gh.Commit{
-		Message: gh.String(params.PRTitle),
-		Tree:    tree,
-		Parents: []*gh.Commit{parentCommit},
-	})
-	if err != nil {
-		return 0, "", fmt.Errorf("error creating commit: %v", err)
+	if params.PRBody == "" {
+		body, err := a.renderPRBody(slug, params, prettier.Fallbacks() != fallbacks)
+		if err != nil {
+			return 0, "", err
+		}
+		params.PRBody = body
 	}
 
-	// Add commit to the new branch.
-	prBranchRef.Object.SHA = commit.SHA
-
-	_, _, err = a.Git.UpdateRef(ctx, a.Owner, a.Repo, prBranchRef, false)
+	activePR, created, err := a.commitToPullRequest(ctx, treeEntries, params)
 	if err != nil {
-		return 0, "", fmt.Errorf("error updating reference: %v", err)
+		return 0, "", err
 	}
 
-	if activePR == nil {
-		// Create a pull request
-		newPR := &gh.NewPullRequest{
-			Title:               gh.String(params.PRTitle),
-			Head:                gh.String(prBranchRef.GetRef()),
-			Base:                gh.String("main"),
-			Body:                gh.String(params.PRBody),
-			MaintainerCanModify: gh.Bool(true),
-		}
-
-		activePR, err = a.createPRWithRetry(ctx, newPR, 10)
-		if err != nil {
-			return 0, "", fmt.Errorf("error creating PR: %v", err)
+	if created {
+		// Add reviewers to the pull request
+		reviewers := params.Reviewers
+		if len(reviewers) == 0 {
+			reviewers = DefaultReviewers
 		}
-
-		// Add a reviewer to the pull request
 		_, _, err = a.PullRequests.RequestReviewers(ctx, a.Owner, a.Repo, activePR.GetNumber(), gh.ReviewersRequest{
-			Reviewers: []string{"chrisdinn"},
+			Reviewers: reviewers,
 		})
 		if err != nil {
 			return 0, "", fmt.Errorf("error requesting reviewers: %v", err)
@@ -202,11 +254,13 @@ func (a *App) CreateOrUpdateArticlePullRequest(ctx context.Context, slug string,
 	return activePR.GetNumber(), activePR.GetHTMLURL(), nil
 }
 
+// CreateArticleCommit publishes the article by committing it straight to main, unless
+// flags.AutoPublish is off.
 func (a *App) CreateArticleCommit(ctx context.Context, slug string, opts ...Option) (string, error) {
-	params := Params{}
-	for _, opt := range opts {
-		opt(&params)
+	if err := a.Flags.Check(ctx, flags.AutoPublish); err != nil {
+		return "", fmt.Errorf("not publishing %s: %w", slug, err)
 	}
+	params := articleParams(opts)
 
 	var maybeArchive string
 	if params.InArchive {
@@ -216,25 +270,32 @@ func (a *App) CreateArticleCommit(ctx context.Context, slug string, opts ...Opti
 	articlePath := maybeArchive + "articles/" + slug
 
 	// Step 1: Get the latest commit of the branch
-	ref, _, err := a.Git.GetRef(ctx, a.Owner, a.Repo, "refs/heads/main")
+	committer := a.scoped(CommitScope)
+	ref, _, err := committer.Git.GetRef(ctx, a.Owner, a.Repo, "refs/heads/main")
 	if err != nil {
 		panic(err)
 	}
 
 	// Step 2: Create a tree with the new article
+	if err := a.carryOverArticle(ctx, articlePath, 0, &params); err != nil {
+		return "", err
+	}
 	treeEntries, err := treeEntriesFromParams(articlePath, params)
 	if err != nil {
 		return "", fmt.Errorf("error creating tree entries: %w", err)
 	}
+	if err := a.runPreCommitHooks(ctx, treeEntries); err != nil {
+		return "", err
+	}
 	baseSHA := ref.GetObject().GetSHA()
-	tree, _, err := a.Git.CreateTree(ctx, a.Owner, a.Repo, baseSHA, treeEntries)
+	tree, _, err := committer.Git.CreateTree(ctx, a.Owner, a.Repo, baseSHA, treeEntries)
 	if err != nil {
 		return "", fmt.Errorf("error creating tree: %v", err)
 	}
 
 	// Step 3: Create the commit.
 	parent := []*gh.Commit{{SHA: ref.Object.SHA}}
-	commit, _, err := a.Git.CreateCommit(ctx, a.Owner, a.Repo, &gh.Commit{
+	commit, _, err := committer.Git.CreateCommit(ctx, a.Owner, a.Repo, &gh.Commit{
 		Message: gh.String(params.CommitMessage),
 		Tree:    tree,
 		Parents: parent,
@@ -245,7 +306,7 @@ func (a *App) CreateArticleCommit(ctx context.Context, slug string, opts ...Opti
 
 	// Step 4: Update the reference
 	ref.Object.SHA = commit.SHA
-	_, _, err = a.Git.UpdateRef(ctx, a.Owner, a.Repo, ref, false)
+	_, _, err = committer.Git.UpdateRef(ctx, a.Owner, a.Repo, ref, false)
 	if err != nil {
 		return "", fmt.Errorf("error updating reference: %v", err)
 	}
@@ -253,71 +314,174 @@ func (a *App) CreateArticleCommit(ctx context.Context, slug string, opts ...Opti
 	return *commit.URL, nil
 }
 
+// articleParams applies opts over the checks every article PR and commit makes: images in the
+// body must be credited, and article.js mustn't refer to datasets the article doesn't declare.
+func articleParams(opts []Option) Params {
+	params := Params{RequireImageCredits: true, CheckArticleJS: true}
+	for _, opt := range opts {
+		opt(&params)
+	}
+	return params
+}
+
 func treeEntriesFromParams(path string, params Params) ([]*gh.TreeEntry, error) {
-	treeEntries := []*gh.TreeEntry{}
+	if err := guardBodyHTML(params); err != nil {
+		return nil, err
+	}
+	if err := guardImageCredits(params); err != nil {
+		return nil, err
+	}
+	if err := guardArticleJS(params); err != nil {
+		return nil, err
+	}
+
+	// Each file is formatted by its own prettier run, so they're built concurrently.
+	builders := []entryBuilder{}
 
 	if params.Article != nil {
-		entry, err := articleTreeEntry(path, params.Article)
-		if err != nil {
-			return nil, fmt.Errorf("error creating article tree entry: %w", err)
-		}
-		treeEntries = append(treeEntries, entry)
+		builders = append(builders, one(func() (*gh.TreeEntry, error) {
+			entry, err := articleTreeEntry(path, params)
+			if err != nil {
+				return nil, fmt.Errorf("error creating article tree entry: %w", err)
+			}
+			return entry, nil
+		}))
 	}
 
 	if params.BodyHTML != "" {
 		// articles.html
-		htmlTreeEntry, err := articleBodyHTML(path, params.BodyHTML)
-		if err != nil {
-			return nil, fmt.Errorf("error creating article body html tree entry: %w", err)
-		}
-		treeEntries = append(treeEntries, htmlTreeEntry)
+		builders = append(builders, one(func() (*gh.TreeEntry, error) {
+			htmlTreeEntry, err := articleBodyHTML(path, params.BodyHTML)
+			if err != nil {
+				return nil, fmt.Errorf("error creating article body html tree entry: %w", err)
+			}
+			return htmlTreeEntry, nil
+		}))
 	}
 
 	if params.ArticleJS != "" {
 		// articles.js
-		jsTreeEntry, err := articleJS(path, params.ArticleJS)
-		if err != nil {
-			return nil, fmt.Errorf("error creating article js tree entry: %w", err)
-		}
-		treeEntries = append(treeEntries, jsTreeEntry)
+		builders = append(builders, one(func() (*gh.TreeEntry, error) {
+			jsTreeEntry, err := articleJS(path, params.ArticleJS)
+			if err != nil {
+				return nil, fmt.Errorf("error creating article js tree entry: %w", err)
+			}
+			return jsTreeEntry, nil
+		}))
+	}
+
+	if params.ImageCredits != nil {
+		// images.json
+		builders = append(builders, one(func() (*gh.TreeEntry, error) {
+			entry, err := articleImagesJSON(path, params.ImageCredits)
+			if err != nil {
+				return nil, fmt.Errorf("error creating article images tree entry: %w", err)
+			}
+			return entry, nil
+		}))
 	}
 
 	if params.TeaserGeoJSON != "" {
 		// teaser.geojson
-		entry, err := articleTeaserGeoJSON(path, params.TeaserGeoJSON)
-		if err != nil {
-			return nil, fmt.Errorf("error creating article teaser geojson tree entry: %w", err)
-		}
-		treeEntries = append(treeEntries, entry)
+		builders = append(builders, one(func() (*gh.TreeEntry, error) {
+			entry, err := articleTeaserGeoJSON(path, params.TeaserGeoJSON)
+			if err != nil {
+				return nil, fmt.Errorf("error creating article teaser geojson tree entry: %w", err)
+			}
+			return entry, nil
+		}))
 	}
 
 	if params.TeaserJS != "" {
 		// teaser.js
-		entry, err := articleTeaserJS(path, params.TeaserJS)
-		if err != nil {
-			return nil, fmt.Errorf("error creating article teaser js tree entry: %w", err)
-		}
-		treeEntries = append(treeEntries, entry)
+		builders = append(builders, one(func() (*gh.TreeEntry, error) {
+			entry, err := articleTeaserJS(path, params.TeaserJS)
+			if err != nil {
+				return nil, fmt.Errorf("error creating article teaser js tree entry: %w", err)
+			}
+			return entry, nil
+		}))
 	}
 
-	if (params.Article != nil) && (params.Locations != "") {
-		// locations.geojson
-		if len(params.Article.GeoJSONDatasets) > 0 && params.Article.GeoJSONDatasets[0].Name == "locations" {
+	if params.Locations != "" {
+		// locations.geojson, which the site only loads if article.json declares it.
+		if !declaresLocations(params.Article) {
+			return nil, fmt.Errorf(`locations need an article declaring a "locations" dataset`)
+		}
+		builders = append(builders, func() ([]*gh.TreeEntry, error) {
 			entries, err := articleGeoJSONDatasets(path, params.Locations)
 			if err != nil {
 				return nil, fmt.Errorf("error creating article geojson datasets tree entries: %w", err)
 			}
-			treeEntries = append(treeEntries, entries...)
+			return entries, nil
+		})
+	}
+
+	entries, err := buildEntries(builders)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("nothing to commit")
+	}
+	return entries, nil
+}
+
+// DeclareLocations adds a "locations" dataset to article unless it has one, so WithLocations
+// has somewhere to go.
+func DeclareLocations(article *citygraph.Article) {
+	if declaresLocations(article) {
+		return
+	}
+	article.GeoJSONDatasets = append([]*citygraph.GeoJSONDataset{{
+		ID:   citygraph.NewID().String(),
+		Name: "locations",
+	}}, article.GeoJSONDatasets...)
+}
+
+func declaresLocations(article *citygraph.Article) bool {
+	if article == nil {
+		return false
+	}
+	for _, dataset := range article.GeoJSONDatasets {
+		if dataset.Name == "locations" {
+			return true
 		}
 	}
+	return false
+}
 
-	return treeEntries, nil
+// articleJSON is the on-disk shape of article.json: the citygraph article plus fields only the
+// content repo cares about.
+type articleJSON struct {
+	*citygraph.Article
+	Corrections   []*Correction `json:"corrections,omitempty"`
+	RelatedPlaces []string      `json:"related_places,omitempty"`
+	Series        *SeriesPart   `json:"series,omitempty"`
+	ImportedFrom  string        `json:"imported_from,omitempty"`
 }
 
-func articleTreeEntry(path string, article *citygraph.Article) (*gh.TreeEntry, error) {
+func articleTreeEntry(path string, params Params) (*gh.TreeEntry, error) {
+	article := params.Article
+	if article.PubDate != "" {
+		pubDate, err := pubdate.Normalize(article.PubDate)
+		if err != nil {
+			return nil, err
+		}
+		normalized := *article
+		normalized.PubDate = pubDate
+		article = &normalized
+	}
+
 	// articles.json
 	jsonPath := path + "/article.json"
-	jsonFileContent, err := json.MarshalIndent(article, "", "  ")
+	jsonFileContent, err := marshalStable(articleJSON{
+		Article:       article,
+		Corrections:   params.Corrections,
+		RelatedPlaces: params.RelatedPlaces,
+		Series:        params.Series,
+		ImportedFrom:  params.ImportedFrom,
+	}, params.BaseArticleJSON)
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling json: %w", err)
 	}
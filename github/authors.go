@@ -0,0 +1,164 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	gh "github.com/google/go-github/v53/github"
+
+	"github.com/geomodulus/citygraph"
+	"github.com/geomodulus/robots/prettier"
+)
+
+// authorsPath is the location of the authors registry in the content repo.
+const authorsPath = "authors.json"
+
+// Author is an entry in the authors registry. Article bylines reference authors by Name.
+type Author struct {
+	Name        string `json:"name"`
+	Bio         string `json:"bio,omitempty"`
+	HeadshotURL string `json:"headshot_url,omitempty"`
+	// Twitter is the author's Twitter username.
+	Twitter string `json:"twitter,omitempty"`
+	// Instagram is the author's Instagram username.
+	Instagram string `json:"instagram,omitempty"`
+	Website   string `json:"website,omitempty"`
+}
+
+func WithAuthors(authors []*Author) Option {
+	return func(params *Params) {
+		params.Authors = authors
+	}
+}
+
+// FetchAuthors reads the authors registry from the main branch.
+func (a *App) FetchAuthors(ctx context.Context) ([]*Author, error) {
+	sha, err := a.mainSHA(ctx)
+	if err != nil {
+		return nil, err
+	}
+	content, err := a.fetchFileContent(ctx, authorsPath, sha)
+	if err != nil {
+		return nil, err
+	}
+	authors := []*Author{}
+	if err := json.Unmarshal([]byte(content), &authors); err != nil {
+		return nil, fmt.Errorf("error unmarshaling authors: %v", err)
+	}
+	return authors, nil
+}
+
+// FindAuthor returns the author with the given name, ignoring case, or nil.
+func FindAuthor(authors []*Author, name string) *Author {
+	for _, author := range authors {
+		if strings.EqualFold(strings.TrimSpace(author.Name), strings.TrimSpace(name)) {
+			return author
+		}
+	}
+	return nil
+}
+
+// UpsertAuthor replaces the author with the same name or appends a new one.
+func UpsertAuthor(authors []*Author, author *Author) []*Author {
+	for i, existing := range authors {
+		if strings.EqualFold(strings.TrimSpace(existing.Name), strings.TrimSpace(author.Name)) {
+			authors[i] = author
+			return authors
+		}
+	}
+	return append(authors, author)
+}
+
+// ValidateAuthors checks that every byline in the article refers to a registered author.
+func ValidateAuthors(article *citygraph.Article, authors []*Author) error {
+	unknown := []string{}
+	for _, name := range article.Authors {
+		if FindAuthor(authors, name) == nil {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown authors: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// ValidateArticleAuthors checks the article's bylines against the registry on main.
+func (a *App) ValidateArticleAuthors(ctx context.Context, article *citygraph.Article) error {
+	authors, err := a.FetchAuthors(ctx)
+	if err != nil {
+		return fmt.Errorf("error fetching authors: %w", err)
+	}
+	return ValidateAuthors(article, authors)
+}
+
+// CreateOrUpdateAuthorsPullRequest opens, or updates, a PR replacing the authors registry with
+// the authors passed via WithAuthors.
+func (a *App) CreateOrUpdateAuthorsPullRequest(ctx context.Context, opts ...Option) (int, string, error) {
+	params := Params{
+		PRBody: "This PR was created dynamically.",
+	}
+	for _, opt := range opts {
+		opt(&params)
+	}
+
+	entry, err := authorsTreeEntry(params.Authors)
+	if err != nil {
+		return 0, "", err
+	}
+
+	activePR, _, err := a.commitToPullRequest(ctx, []*gh.TreeEntry{entry}, params)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return activePR.GetNumber(), activePR.GetHTMLURL(), nil
+}
+
+// CreateAuthorsCommit commits the authors passed via WithAuthors directly to main.
+func (a *App) CreateAuthorsCommit(ctx context.Context, opts ...Option) (string, error) {
+	params := Params{}
+	for _, opt := range opts {
+		opt(&params)
+	}
+
+	entry, err := authorsTreeEntry(params.Authors)
+	if err != nil {
+		return "", err
+	}
+
+	commit, err := a.commitToMain(ctx, []*gh.TreeEntry{entry}, params.CommitMessage)
+	if err != nil {
+		return "", err
+	}
+	return commit.GetURL(), nil
+}
+
+func authorsTreeEntry(authors []*Author) (*gh.TreeEntry, error) {
+	if len(authors) == 0 {
+		return nil, fmt.Errorf("no authors provided")
+	}
+	for _, author := range authors {
+		if strings.TrimSpace(author.Name) == "" {
+			return nil, fmt.Errorf("author missing name")
+		}
+	}
+
+	jsonFileContent, err := json.MarshalIndent(authors, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling json: %w", err)
+	}
+	prettyJSONFileContent, err := prettier.Format(string(jsonFileContent), authorsPath)
+	if err != nil {
+		return nil, fmt.Errorf("error formatting json: %w", err)
+	}
+
+	return &gh.TreeEntry{
+		Path:    gh.String(authorsPath),
+		Mode:    gh.String("100644"),
+		Type:    gh.String("blob"),
+		Content: gh.String(prettyJSONFileContent),
+	}, nil
+}
@@ -0,0 +1,49 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"path"
+)
+
+// metadataFiles are the files MetadataSnapshot copies, wherever they are in the repo.
+var metadataFiles = map[string]bool{
+	"article.json": true,
+	"poi.json":     true,
+}
+
+// MetadataSnapshot is every article.json and poi.json on main as of one commit, byte for byte,
+// including archived articles and closed places.
+type MetadataSnapshot struct {
+	SHA string
+	// Files maps each file's path in the repo to its content.
+	Files map[string][]byte
+}
+
+// SnapshotMetadata copies the article and place metadata on main, for backups.
+func (a *App) SnapshotMetadata(ctx context.Context) (*MetadataSnapshot, error) {
+	sha, err := a.mainSHA(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tree, _, err := a.Git.GetTree(ctx, a.Owner, a.Repo, sha, true)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tree: %v", err)
+	}
+	if tree.GetTruncated() {
+		return nil, fmt.Errorf("tree of %s is too large to list in one request", sha)
+	}
+
+	snapshot := &MetadataSnapshot{SHA: sha, Files: map[string][]byte{}}
+	for _, entry := range tree.Entries {
+		if entry.GetType() != "blob" || !metadataFiles[path.Base(entry.GetPath())] {
+			continue
+		}
+		content, _, err := a.Git.GetBlobRaw(ctx, a.Owner, a.Repo, entry.GetSHA())
+		if err != nil {
+			return nil, fmt.Errorf("error getting %s: %v", entry.GetPath(), err)
+		}
+		snapshot.Files[entry.GetPath()] = content
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,123 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	gh "github.com/google/go-github/v53/github"
+
+	"github.com/geomodulus/citygraph"
+)
+
+// currentFile returns a file as it is on the open PR prNum, or on main if there isn't one. It
+// reports false if the file doesn't exist yet.
+func (a *App) currentFile(ctx context.Context, path string, prNum int) (string, bool, error) {
+	ref := "main"
+	if prNum != 0 {
+		pr, _, err := a.PullRequests.Get(ctx, a.Owner, a.Repo, prNum)
+		if err != nil {
+			return "", false, fmt.Errorf("error getting PR: %v", err)
+		}
+		if pr.GetState() == "open" {
+			ref = pr.GetHead().GetSHA()
+		}
+	}
+	return a.fileAt(ctx, path, ref)
+}
+
+// fileAt returns a file as it is at ref, reporting false if it doesn't exist there.
+func (a *App) fileAt(ctx context.Context, path, ref string) (string, bool, error) {
+	file, _, resp, err := a.Repositories.GetContents(ctx, a.Owner, a.Repo, path, &gh.RepositoryContentGetOptions{Ref: ref})
+	if err != nil && resp != nil && resp.StatusCode == http.StatusNotFound {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("error getting %s: %v", path, err)
+	}
+	content, err := file.GetContent()
+	if err != nil {
+		return "", false, fmt.Errorf("error decoding %s: %v", path, err)
+	}
+	return content, true, nil
+}
+
+// carryOverArticle fills in the article.json fields params leave unset from the article as it
+// is on the open PR prNum, or main, so an update that doesn't pass them keeps them: its
+// corrections, related places, series and import source, and its image credits. Pass an empty
+// slice to clear one.
+func (a *App) carryOverArticle(ctx context.Context, articlePath string, prNum int, params *Params) error {
+	if err := a.carryOverImageCredits(ctx, articlePath, prNum, params); err != nil {
+		return err
+	}
+	if params.Article == nil {
+		return nil
+	}
+	content, ok, err := a.currentFile(ctx, articlePath+"/article.json", prNum)
+	if err != nil || !ok {
+		return err
+	}
+	stored := articleJSON{Article: &citygraph.Article{}}
+	if err := json.Unmarshal([]byte(content), &stored); err != nil {
+		return fmt.Errorf("error unmarshaling %s/article.json: %v", articlePath, err)
+	}
+	if params.Corrections == nil {
+		params.Corrections = stored.Corrections
+	}
+	if params.RelatedPlaces == nil {
+		params.RelatedPlaces = stored.RelatedPlaces
+	}
+	if params.Series == nil {
+		params.Series = stored.Series
+	}
+	if params.ImportedFrom == "" {
+		params.ImportedFrom = stored.ImportedFrom
+	}
+	if params.BaseArticleJSON == "" {
+		params.BaseArticleJSON = content
+	}
+	return nil
+}
+
+// carryOverImageCredits keeps the article's images.json when params don't replace it, so its
+// body's images are checked against the credits it already has.
+func (a *App) carryOverImageCredits(ctx context.Context, articlePath string, prNum int, params *Params) error {
+	if params.ImageCredits != nil || params.BodyHTML == "" {
+		return nil
+	}
+	content, ok, err := a.currentFile(ctx, articlePath+"/images.json", prNum)
+	if err != nil || !ok {
+		return err
+	}
+	credits := []*ImageCredit{}
+	if err := json.Unmarshal([]byte(content), &credits); err != nil {
+		return fmt.Errorf("error unmarshaling %s/images.json: %v", articlePath, err)
+	}
+	params.ImageCredits = credits
+	return nil
+}
+
+// carryOverPlace is carryOverArticle for poi.json: it keeps the place's related articles and
+// attributes. OpenStreetMap enrichment then merges over the attributes kept, so hand-set ones
+// and their provenance survive.
+func (a *App) carryOverPlace(ctx context.Context, placePath string, prNum int, params *Params) error {
+	if params.Place == nil {
+		return nil
+	}
+	content, ok, err := a.currentFile(ctx, placePath+"/poi.json", prNum)
+	if err != nil || !ok {
+		return err
+	}
+	stored := placeJSON{Place: &citygraph.Place{}}
+	if err := json.Unmarshal([]byte(content), &stored); err != nil {
+		return fmt.Errorf("error unmarshaling %s/poi.json: %v", placePath, err)
+	}
+	if params.RelatedArticles == nil {
+		params.RelatedArticles = stored.RelatedArticles
+	}
+	if params.Attributes == nil {
+		params.Attributes = stored.Attributes
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	gh "github.com/google/go-github/v53/github"
+)
+
+// ChangelogEntry is one content change.
+type ChangelogEntry struct {
+	*CommitMessage
+	SHA  string
+	Time time.Time
+	URL  string
+}
+
+// Changelog is the content changes made to main over a period, for the newsletter.
+type Changelog struct {
+	From, To time.Time
+	Entries  []*ChangelogEntry
+}
+
+// Changelog lists the commits to main in [from, to) that follow the commit message format.
+// Others, like merge commits, are left out, as are chores.
+func (a *App) Changelog(ctx context.Context, from, to time.Time) (*Changelog, error) {
+	changelog := &Changelog{From: from, To: to}
+	opts := &gh.CommitsListOptions{
+		SHA:         "main",
+		Since:       from,
+		Until:       to,
+		ListOptions: gh.ListOptions{PerPage: 100},
+	}
+	for {
+		commits, resp, err := a.Repositories.ListCommits(ctx, a.Owner, a.Repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("error listing commits: %v", err)
+		}
+		for _, commit := range commits {
+			msg, err := ParseCommitMessage(commit.GetCommit().GetMessage())
+			if err != nil || msg.Type == "chore" {
+				continue
+			}
+			changelog.Entries = append(changelog.Entries, &ChangelogEntry{
+				CommitMessage: msg,
+				SHA:           commit.GetSHA(),
+				Time:          commit.GetCommit().GetCommitter().GetDate().Time,
+				URL:           commit.GetHTMLURL(),
+			})
+		}
+		if resp.NextPage == 0 {
+			return changelog, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// changelogHeadings are the section headings for each commit type.
+var changelogHeadings = map[string]string{
+	"add":     "New",
+	"update":  "Updated",
+	"correct": "Corrected",
+	"archive": "Archived",
+	"remove":  "Removed",
+}
+
+// Markdown formats the changelog grouped by type, newest first within each group. Repeated
+// changes to the same slug are listed once.
+func (c *Changelog) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Changes %s – %s\n", c.From.Format("Jan 2"), c.To.AddDate(0, 0, -1).Format("Jan 2"))
+	if len(c.Entries) == 0 {
+		b.WriteString("\nNo content changes.\n")
+		return b.String()
+	}
+	for _, typ := range CommitTypes {
+		seen := map[string]bool{}
+		lines := []string{}
+		for _, entry := range c.Entries {
+			if entry.Type != typ {
+				continue
+			}
+			key := entry.Scope + "/" + entry.Slug
+			if entry.Slug != "" && seen[key] {
+				continue
+			}
+			seen[key] = true
+			line := "- " + entry.Summary
+			if entry.Scope != "" {
+				line = fmt.Sprintf("- **%s:** %s", entry.Scope, entry.Summary)
+			}
+			if entry.URL != "" {
+				line += fmt.Sprintf(" ([%.7s](%s))", entry.SHA, entry.URL)
+			}
+			lines = append(lines, line)
+		}
+		if len(lines) > 0 {
+			fmt.Fprintf(&b, "\n### %s\n\n%s\n", changelogHeadings[typ], strings.Join(lines, "\n"))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,272 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	gh "github.com/google/go-github/v53/github"
+
+	"github.com/geomodulus/robots/prettier"
+)
+
+// collectionsPath is the location of the collections registry in the content repo.
+const collectionsPath = "collections.json"
+
+// Collection is an article series, eg. a multi-part investigation, with its articles in reading
+// order.
+type Collection struct {
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Articles are the slugs of the articles in the series, in order.
+	Articles []string `json:"articles"`
+}
+
+// SeriesPart is written to the article.json of each article in a collection as "series", so the
+// site can show "Part 2 of 3" with links to the parts either side.
+type SeriesPart struct {
+	Collection string      `json:"collection"`
+	Name       string      `json:"name"`
+	Part       int         `json:"part"`
+	Of         int         `json:"of"`
+	Previous   *SeriesLink `json:"previous,omitempty"`
+	Next       *SeriesLink `json:"next,omitempty"`
+}
+
+// SeriesLink points at another part of a series.
+type SeriesLink struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+	Path string `json:"path,omitempty"`
+}
+
+// FetchCollections reads the collections registry from the main branch. A repo without one has
+// no collections.
+func (a *App) FetchCollections(ctx context.Context) ([]*Collection, error) {
+	sha, err := a.mainSHA(ctx)
+	if err != nil {
+		return nil, err
+	}
+	file, _, resp, err := a.Repositories.GetContents(ctx, a.Owner, a.Repo, collectionsPath, &gh.RepositoryContentGetOptions{Ref: sha})
+	if err != nil && resp != nil && resp.StatusCode == http.StatusNotFound {
+		return []*Collection{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting file content: %v", err)
+	}
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, fmt.Errorf("error decoding file content: %v", err)
+	}
+	collections := []*Collection{}
+	if err := json.Unmarshal([]byte(content), &collections); err != nil {
+		return nil, fmt.Errorf("error unmarshaling collections: %v", err)
+	}
+	return collections, nil
+}
+
+// FindCollection returns the collection with the given slug, or nil.
+func FindCollection(collections []*Collection, slug string) *Collection {
+	for _, collection := range collections {
+		if collection.Slug == slug {
+			return collection
+		}
+	}
+	return nil
+}
+
+// UpsertCollection replaces the collection with the same slug or appends a new one.
+func UpsertCollection(collections []*Collection, collection *Collection) []*Collection {
+	for i, existing := range collections {
+		if existing.Slug == collection.Slug {
+			collections[i] = collection
+			return collections
+		}
+	}
+	return append(collections, collection)
+}
+
+// RemoveCollection drops the collection with the given slug, if there is one.
+func RemoveCollection(collections []*Collection, slug string) []*Collection {
+	out := []*Collection{}
+	for _, collection := range collections {
+		if collection.Slug != slug {
+			out = append(out, collection)
+		}
+	}
+	return out
+}
+
+// ValidateCollections checks every collection has a slug and a name, and that its articles
+// exist and appear once. An article can only be in one collection, since its article.json has
+// room for one series.
+func ValidateCollections(collections []*Collection, articles []*ArticleCheckout) error {
+	exists := map[string]bool{}
+	for _, checkout := range articles {
+		exists[checkout.Slug] = true
+	}
+
+	problems := []string{}
+	slugs := map[string]bool{}
+	memberOf := map[string]string{}
+	for _, collection := range collections {
+		if strings.TrimSpace(collection.Slug) == "" {
+			problems = append(problems, "collection missing slug")
+			continue
+		}
+		if slugs[collection.Slug] {
+			problems = append(problems, fmt.Sprintf("collection %s is defined twice", collection.Slug))
+		}
+		slugs[collection.Slug] = true
+		if strings.TrimSpace(collection.Name) == "" {
+			problems = append(problems, fmt.Sprintf("collection %s missing name", collection.Slug))
+		}
+		if len(collection.Articles) == 0 {
+			problems = append(problems, fmt.Sprintf("collection %s has no articles", collection.Slug))
+		}
+		for _, slug := range collection.Articles {
+			switch other, ok := memberOf[slug]; {
+			case !exists[slug]:
+				problems = append(problems, fmt.Sprintf("collection %s: no article %s", collection.Slug, slug))
+			case ok && other == collection.Slug:
+				problems = append(problems, fmt.Sprintf("collection %s lists %s twice", collection.Slug, slug))
+			case ok:
+				problems = append(problems, fmt.Sprintf("%s is in both %s and %s", slug, other, collection.Slug))
+			}
+			memberOf[slug] = collection.Slug
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid collections: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// SeriesParts returns the series part of every article in collections, by slug.
+func SeriesParts(collections []*Collection, articles []*ArticleCheckout) map[string]*SeriesPart {
+	bySlug := map[string]*ArticleCheckout{}
+	for _, checkout := range articles {
+		bySlug[checkout.Slug] = checkout
+	}
+	link := func(slug string) *SeriesLink {
+		l := &SeriesLink{Slug: slug}
+		if checkout, ok := bySlug[slug]; ok {
+			l.Name = checkout.Article.Name
+			l.Path, _ = checkout.Article.Path()
+		}
+		return l
+	}
+
+	parts := map[string]*SeriesPart{}
+	for _, collection := range collections {
+		for i, slug := range collection.Articles {
+			part := &SeriesPart{
+				Collection: collection.Slug,
+				Name:       collection.Name,
+				Part:       i + 1,
+				Of:         len(collection.Articles),
+			}
+			if i > 0 {
+				part.Previous = link(collection.Articles[i-1])
+			}
+			if i < len(collection.Articles)-1 {
+				part.Next = link(collection.Articles[i+1])
+			}
+			parts[slug] = part
+		}
+	}
+	return parts
+}
+
+// CreateOrUpdateCollectionsPullRequest opens, or updates, a PR replacing the collections
+// registry with collections and updating the series part in the article.json of every article
+// whose part changed, including articles that have left a collection.
+func (a *App) CreateOrUpdateCollectionsPullRequest(ctx context.Context, collections []*Collection, opts ...Option) (int, string, error) {
+	params := Params{
+		PRTitle: "Update collections",
+		PRBody:  "This PR was created dynamically.",
+	}
+	for _, opt := range opts {
+		opt(&params)
+	}
+
+	treeEntries, err := a.collectionsTreeEntries(ctx, collections, params)
+	if err != nil {
+		return 0, "", err
+	}
+	activePR, _, err := a.commitToPullRequest(ctx, treeEntries, params)
+	if err != nil {
+		return 0, "", err
+	}
+	return activePR.GetNumber(), activePR.GetHTMLURL(), nil
+}
+
+// CreateCollectionsCommit is CreateOrUpdateCollectionsPullRequest committed directly to main.
+func (a *App) CreateCollectionsCommit(ctx context.Context, collections []*Collection, opts ...Option) (string, error) {
+	params := Params{
+		CommitMessage: "Update collections",
+	}
+	for _, opt := range opts {
+		opt(&params)
+	}
+
+	treeEntries, err := a.collectionsTreeEntries(ctx, collections, params)
+	if err != nil {
+		return "", err
+	}
+	commit, err := a.commitToMain(ctx, treeEntries, params.CommitMessage)
+	if err != nil {
+		return "", err
+	}
+	return commit.GetURL(), nil
+}
+
+func (a *App) collectionsTreeEntries(ctx context.Context, collections []*Collection, params Params) ([]*gh.TreeEntry, error) {
+	articles, err := a.ListArticles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing articles: %w", err)
+	}
+	if err := ValidateCollections(collections, articles); err != nil {
+		return nil, err
+	}
+
+	jsonFileContent, err := json.MarshalIndent(collections, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling collections: %v", err)
+	}
+	prettyJSON, err := prettier.Format(string(jsonFileContent), collectionsPath)
+	if err != nil {
+		return nil, fmt.Errorf("error formatting collections: %w", err)
+	}
+	treeEntries := []*gh.TreeEntry{{
+		Path:    gh.String(collectionsPath),
+		Mode:    gh.String("100644"),
+		Type:    gh.String("blob"),
+		Content: gh.String(prettyJSON),
+	}}
+
+	parts := SeriesParts(collections, articles)
+	for _, checkout := range articles {
+		part := parts[checkout.Slug]
+		if reflect.DeepEqual(part, checkout.Series) {
+			continue
+		}
+		articleParams := params
+		articleParams.Article = checkout.Article
+		articleParams.BaseArticleJSON = checkout.ArticleJSON
+		articleParams.Corrections = checkout.Corrections
+		articleParams.RelatedPlaces = checkout.RelatedPlaces
+		articleParams.Series = part
+		articleParams.ImportedFrom = checkout.ImportedFrom
+		entries, err := treeEntriesFromParams("articles/"+checkout.Slug, articleParams)
+		if err != nil {
+			return nil, fmt.Errorf("error creating tree entries for %s: %w", checkout.Slug, err)
+		}
+		treeEntries = append(treeEntries, entries...)
+	}
+	return treeEntries, nil
+}
@@ -0,0 +1,128 @@
+package github
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Commit types for content changes, in the order changelogs list them.
+var CommitTypes = []string{"add", "update", "correct", "archive", "remove", "chore"}
+
+// Trailers added to commit messages.
+const (
+	SlugTrailer   = "Slug"
+	ThreadTrailer = "Slack-Thread"
+)
+
+// maxSummaryLength, in characters, keeps subjects readable in git log and GitHub's UI.
+const maxSummaryLength = 72
+
+var (
+	subjectRe = regexp.MustCompile(`^([a-z]+)(?:\(([a-z0-9_-]+)\))?: (.+)$`)
+	scopeRe   = regexp.MustCompile(`^[a-z0-9_-]+$`)
+)
+
+// CommitMessage is a commit message in the content repos' format:
+//
+//	add(article): Bike lanes are coming to Bloor
+//
+//	Optional body.
+//
+//	Slug: bike-lanes-bloor
+//	Slack-Thread: https://geomodulus.slack.com/archives/C0123/p1690000000000000
+type CommitMessage struct {
+	// Type is one of CommitTypes.
+	Type string
+	// Scope is what changed, eg. "article", "place" or "authors". Optional.
+	Scope   string
+	Summary string
+	Body    string
+	// Slug and ThreadURL become trailers, tying the commit to its content and conversation.
+	Slug      string
+	ThreadURL string
+}
+
+// Validate checks the message follows the format.
+func (m *CommitMessage) Validate() error {
+	if !validCommitType(m.Type) {
+		return fmt.Errorf("commit type %q isn't one of %s", m.Type, strings.Join(CommitTypes, ", "))
+	}
+	if m.Scope != "" && !scopeRe.MatchString(m.Scope) {
+		return fmt.Errorf("commit scope %q should be lowercase letters, digits, - or _", m.Scope)
+	}
+	summary := strings.TrimSpace(m.Summary)
+	if summary == "" {
+		return fmt.Errorf("commit summary is empty")
+	}
+	if strings.Contains(summary, "\n") {
+		return fmt.Errorf("commit summary should be one line")
+	}
+	return nil
+}
+
+// Build validates the message and formats it, shortening a long summary.
+func (m *CommitMessage) Build() (string, error) {
+	if err := m.Validate(); err != nil {
+		return "", err
+	}
+	subject := m.Type
+	if m.Scope != "" {
+		subject += "(" + m.Scope + ")"
+	}
+	summary := strings.TrimSpace(m.Summary)
+	if runes, room := []rune(summary), maxSummaryLength-len(subject)-2; len(runes) > room && room > 1 {
+		summary = strings.TrimSpace(string(runes[:room-1])) + "…"
+	}
+	msg := subject + ": " + summary
+
+	if body := strings.TrimSpace(m.Body); body != "" {
+		msg += "\n\n" + body
+	}
+	trailers := []string{}
+	if m.Slug != "" {
+		trailers = append(trailers, SlugTrailer+": "+m.Slug)
+	}
+	if m.ThreadURL != "" {
+		trailers = append(trailers, ThreadTrailer+": "+m.ThreadURL)
+	}
+	if len(trailers) > 0 {
+		msg += "\n\n" + strings.Join(trailers, "\n")
+	}
+	return msg, nil
+}
+
+// ParseCommitMessage reads a message in the format back, eg. for changelogs. Messages that
+// don't follow it, like merge commits, return an error.
+func ParseCommitMessage(msg string) (*CommitMessage, error) {
+	subject, rest, _ := strings.Cut(strings.TrimSpace(msg), "\n")
+	match := subjectRe.FindStringSubmatch(strings.TrimSpace(subject))
+	if match == nil || !validCommitType(match[1]) {
+		return nil, fmt.Errorf("%q isn't a conventional commit subject", subject)
+	}
+	m := &CommitMessage{Type: match[1], Scope: match[2], Summary: match[3]}
+
+	body := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(rest), "\n") {
+		key, value, ok := strings.Cut(line, ": ")
+		switch {
+		case ok && key == SlugTrailer:
+			m.Slug = strings.TrimSpace(value)
+		case ok && key == ThreadTrailer:
+			m.ThreadURL = strings.TrimSpace(value)
+		default:
+			body = append(body, line)
+		}
+	}
+	m.Body = strings.TrimSpace(strings.Join(body, "\n"))
+	return m, nil
+}
+
+func validCommitType(typ string) bool {
+	for _, t := range CommitTypes {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
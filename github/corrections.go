@@ -0,0 +1,82 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// CorrectionLabel is applied to every PR opened by CreateCorrectionPullRequest.
+const CorrectionLabel = "correction"
+
+// Correction is an editor's note recorded in article.json and shown at the end of article.html.
+type Correction struct {
+	Date string `json:"date"`
+	Note string `json:"note"`
+}
+
+// CorrectionHTML renders the standard correction block appended to article bodies.
+func CorrectionHTML(c *Correction) string {
+	date := c.Date
+	if t, err := time.Parse("2006-01-02", c.Date); err == nil {
+		date = t.Format("January 2, 2006")
+	}
+	return fmt.Sprintf(
+		`<aside class="correction"><p><strong>Correction, %s:</strong> %s</p></aside>`,
+		html.EscapeString(date),
+		html.EscapeString(c.Note),
+	)
+}
+
+// CreateCorrectionPullRequest appends a correction note to the article's body, records it in
+// article.json and opens a PR labeled "correction". Options can override the PR title and body
+// or point at an existing PR with WithPRNum.
+func (a *App) CreateCorrectionPullRequest(ctx context.Context, slug, note string, opts ...Option) (int, string, error) {
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return 0, "", fmt.Errorf("correction note is empty")
+	}
+
+	checkout, err := a.FetchArticle(ctx, slug)
+	if err != nil {
+		return 0, "", fmt.Errorf("error fetching article: %w", err)
+	}
+
+	correction := &Correction{
+		Date: time.Now().Format("2006-01-02"),
+		Note: note,
+	}
+
+	params := Params{
+		PRTitle: "Correction: " + checkout.Article.Name,
+		PRBody:  "Correction to `" + slug + "`:\n\n> " + note,
+	}
+	for _, opt := range opts {
+		opt(&params)
+	}
+	params.Article = checkout.Article
+	params.BaseArticleJSON = checkout.ArticleJSON
+	params.Corrections = append(checkout.Corrections, correction)
+	params.RelatedPlaces = checkout.RelatedPlaces
+	params.Series = checkout.Series
+	params.ImportedFrom = checkout.ImportedFrom
+	params.BodyHTML = strings.TrimRight(checkout.BodyHTML, "\n") + "\n" + CorrectionHTML(correction) + "\n"
+
+	treeEntries, err := treeEntriesFromParams("articles/"+checkout.Slug, params)
+	if err != nil {
+		return 0, "", fmt.Errorf("error creating tree entries: %w", err)
+	}
+
+	activePR, _, err := a.commitToPullRequest(ctx, treeEntries, params)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if _, _, err := a.scoped(PullRequestScope).Issues.AddLabelsToIssue(ctx, a.Owner, a.Repo, activePR.GetNumber(), []string{CorrectionLabel}); err != nil {
+		return 0, "", fmt.Errorf("error labeling PR: %v", err)
+	}
+
+	return activePR.GetNumber(), activePR.GetHTMLURL(), nil
+}
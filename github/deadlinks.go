@@ -0,0 +1,99 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// maxLinkChecks is how many external links are checked at once.
+const maxLinkChecks = 4
+
+// DeadLink is a link in an article body that doesn't lead anywhere.
+type DeadLink struct {
+	Href   string
+	Reason string
+}
+
+// CheckLinks finds the dead links in an article body. Links to other articles must point at a
+// live article on main; other http(s) links must resolve, and are only checked if httpClient
+// isn't nil. Each href is reported once, in the order it first appears.
+func (a *App) CheckLinks(ctx context.Context, body string, httpClient *http.Client) ([]*DeadLink, error) {
+	var live map[string]bool
+	external := []string{}
+	dead := map[string]string{}
+	order := []string{}
+	seen := map[string]bool{}
+	for _, href := range hrefs([]byte(body)) {
+		href = strings.TrimSpace(href)
+		if seen[href] {
+			continue
+		}
+		seen[href] = true
+		order = append(order, href)
+
+		if slugID, ok := linkedSlugID(href); ok {
+			if live == nil {
+				var err error
+				if live, err = a.liveSlugIDs(ctx); err != nil {
+					return nil, err
+				}
+			}
+			if !live[slugID] {
+				dead[href] = "no live article with this ID"
+			}
+			continue
+		}
+		if parsed, err := url.Parse(href); err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") {
+			external = append(external, href)
+		}
+	}
+
+	if httpClient != nil {
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, maxLinkChecks)
+		for _, href := range external {
+			href := href
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer func() { <-sem; wg.Done() }()
+				if reason := checkWebsite(ctx, httpClient, href); reason != "" {
+					mu.Lock()
+					dead[href] = reason
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	out := []*DeadLink{}
+	for _, href := range order {
+		if reason, ok := dead[href]; ok {
+			out = append(out, &DeadLink{Href: href, Reason: reason})
+		}
+	}
+	return out, nil
+}
+
+// liveSlugIDs returns the slug IDs of the live articles on main.
+func (a *App) liveSlugIDs(ctx context.Context) (map[string]bool, error) {
+	articles, err := a.ListArticles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	live := map[string]bool{}
+	for _, checkout := range articles {
+		if !checkout.Article.IsLive {
+			continue
+		}
+		if id, err := checkout.Article.SlugID(); err == nil {
+			live[id] = true
+		}
+	}
+	return live, nil
+}
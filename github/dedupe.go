@@ -0,0 +1,259 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	gh "github.com/google/go-github/v53/github"
+
+	"github.com/geomodulus/robots/osm"
+)
+
+// DuplicateRadius is how close, in metres, two similarly named places must be to be considered
+// duplicates.
+const DuplicateRadius = 75.0
+
+// FindDuplicatePlaces groups places under active_places/ that look like the same business:
+// names that match once punctuation and case are ignored (or one contains the other), within
+// DuplicateRadius of each other. Each group has at least two places.
+func (a *App) FindDuplicatePlaces(ctx context.Context) ([][]*PlaceCheckout, error) {
+	places, err := a.ListPlaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return DuplicatePlaces(places), nil
+}
+
+// DuplicatePlaces groups the duplicates among places. See FindDuplicatePlaces.
+func DuplicatePlaces(places []*PlaceCheckout) [][]*PlaceCheckout {
+	// Union-find over place indexes.
+	parent := make([]int, len(places))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := 0; i < len(places); i++ {
+		for j := i + 1; j < len(places); j++ {
+			if isDuplicatePlace(places[i], places[j]) {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	groups := map[int][]*PlaceCheckout{}
+	order := []int{}
+	for i, place := range places {
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			order = append(order, root)
+		}
+		groups[root] = append(groups[root], place)
+	}
+
+	res := [][]*PlaceCheckout{}
+	for _, root := range order {
+		if len(groups[root]) > 1 {
+			res = append(res, groups[root])
+		}
+	}
+	return res
+}
+
+func isDuplicatePlace(a, b *PlaceCheckout) bool {
+	nameA, nameB := normalizeName(a.Place.Name), normalizeName(b.Place.Name)
+	if nameA == "" || nameB == "" {
+		return false
+	}
+	if nameA != nameB && !strings.Contains(nameA, nameB) && !strings.Contains(nameB, nameA) {
+		return false
+	}
+	return distanceMeters(a.Place.Location, b.Place.Location) <= DuplicateRadius
+}
+
+// MergePlacesResult describes the PR opened by MergePlaces.
+type MergePlacesResult struct {
+	PRNum int
+	PRURL string
+	// Replaced maps each removed place ID to the ID of the place it was merged into.
+	Replaced map[string]string
+	// RelatedArticles lists the articles that referenced any of the merged places.
+	RelatedArticles []string
+}
+
+// MergePlaces folds the duplicate places into keep and opens a PR that rewrites keep's poi.json
+// and deletes the duplicates. Fields empty on keep are filled from the duplicates; related
+// articles and attributes are combined. Use ReplaceRelatedPlaces on the articles repo with the
+// result's Replaced IDs to update references.
+func (a *App) MergePlaces(ctx context.Context, keep string, duplicates []string, opts ...Option) (*MergePlacesResult, error) {
+	if len(duplicates) == 0 {
+		return nil, fmt.Errorf("no duplicates to merge")
+	}
+
+	target, err := a.FetchPlace(ctx, keep)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s: %w", keep, err)
+	}
+
+	sha, err := a.mainSHA(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &MergePlacesResult{
+		Replaced: map[string]string{},
+	}
+	treeEntries := []*gh.TreeEntry{}
+	for _, slug := range duplicates {
+		if slug == keep {
+			continue
+		}
+		dup, err := a.FetchPlace(ctx, slug)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching %s: %w", slug, err)
+		}
+		mergePlaceInto(target, dup)
+		res.Replaced[dup.Place.ID] = target.Place.ID
+
+		deletes, err := a.deleteDirEntries(ctx, "active_places/"+slug, sha)
+		if err != nil {
+			return nil, err
+		}
+		treeEntries = append(treeEntries, deletes...)
+	}
+	res.RelatedArticles = target.RelatedArticles
+
+	params := Params{
+		PRTitle: "Merge duplicate places into " + target.Place.Name,
+		PRBody:  "Merges `" + strings.Join(duplicates, "`, `") + "` into `" + keep + "`.",
+	}
+	for _, opt := range opts {
+		opt(&params)
+	}
+	params.Place = target.Place
+	params.RelatedArticles = target.RelatedArticles
+	params.Attributes = target.Attributes
+
+	entries, err := placeTreeEntriesFromParams("active_places/"+keep, params)
+	if err != nil {
+		return nil, fmt.Errorf("error creating tree entries: %w", err)
+	}
+	treeEntries = append(treeEntries, entries...)
+
+	activePR, _, err := a.commitToPullRequest(ctx, treeEntries, params)
+	if err != nil {
+		return nil, err
+	}
+	res.PRNum = activePR.GetNumber()
+	res.PRURL = activePR.GetHTMLURL()
+	return res, nil
+}
+
+// ReplaceRelatedPlaces rewrites related_places in every article that references one of the
+// replaced place IDs, in a single PR. It returns 0 and an empty URL if nothing references them.
+func (a *App) ReplaceRelatedPlaces(ctx context.Context, replaced map[string]string, opts ...Option) (int, string, error) {
+	articles, err := a.ListArticles(ctx)
+	if err != nil {
+		return 0, "", err
+	}
+
+	params := Params{
+		PRTitle: "Update related places after merge",
+		PRBody:  "This PR was created dynamically.",
+	}
+	for _, opt := range opts {
+		opt(&params)
+	}
+
+	treeEntries := []*gh.TreeEntry{}
+	for _, checkout := range articles {
+		changed := false
+		related := []string{}
+		for _, id := range checkout.RelatedPlaces {
+			if newID, ok := replaced[id]; ok {
+				id = newID
+				changed = true
+			}
+			related = appendMissing(related, id)
+		}
+		if !changed {
+			continue
+		}
+
+		articleParams := params
+		articleParams.Article = checkout.Article
+		articleParams.BaseArticleJSON = checkout.ArticleJSON
+		articleParams.Corrections = checkout.Corrections
+		articleParams.Series = checkout.Series
+		articleParams.ImportedFrom = checkout.ImportedFrom
+		articleParams.RelatedPlaces = related
+		entries, err := treeEntriesFromParams("articles/"+checkout.Slug, articleParams)
+		if err != nil {
+			return 0, "", fmt.Errorf("error creating tree entries for %s: %w", checkout.Slug, err)
+		}
+		treeEntries = append(treeEntries, entries...)
+	}
+	if len(treeEntries) == 0 {
+		return 0, "", nil
+	}
+
+	activePR, _, err := a.commitToPullRequest(ctx, treeEntries, params)
+	if err != nil {
+		return 0, "", err
+	}
+	return activePR.GetNumber(), activePR.GetHTMLURL(), nil
+}
+
+// mergePlaceInto copies everything dup knows that target doesn't.
+func mergePlaceInto(target, dup *PlaceCheckout) {
+	t, d := target.Place, dup.Place
+	fill := func(dst *string, src string) {
+		if *dst == "" {
+			*dst = src
+		}
+	}
+	fill(&t.Desc, d.Desc)
+	fill(&t.PhoneNumber, d.PhoneNumber)
+	fill(&t.Address, d.Address)
+	fill(&t.Established, d.Established)
+	fill(&t.Instagram, d.Instagram)
+	fill(&t.Twitter, d.Twitter)
+	fill(&t.URL, d.URL)
+	fill(&t.ShortName, d.ShortName)
+	fill(&t.Type, d.Type)
+	fill(&t.Sprite, d.Sprite)
+	if t.Restaurant == nil {
+		t.Restaurant = d.Restaurant
+	}
+	if t.Bar == nil {
+		t.Bar = d.Bar
+	}
+	if t.Cafe == nil {
+		t.Cafe = d.Cafe
+	}
+	if !d.AddedAt.IsZero() && (t.AddedAt.IsZero() || d.AddedAt.Before(t.AddedAt)) {
+		t.AddedAt = d.AddedAt
+	}
+	t.Images = append(t.Images, d.Images...)
+	t.Locations = append(t.Locations, d.Locations...)
+
+	target.RelatedArticles = appendMissing(target.RelatedArticles, dup.RelatedArticles...)
+	// Attributes already on target take precedence over the duplicate's.
+	attrs := map[string]*osm.Attribute{}
+	for k, v := range dup.Attributes {
+		attrs[k] = v
+	}
+	for k, v := range target.Attributes {
+		attrs[k] = v
+	}
+	if len(attrs) > 0 {
+		target.Attributes = attrs
+	}
+}
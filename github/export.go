@@ -0,0 +1,367 @@
+package github
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/geomodulus/citygraph"
+	"github.com/geomodulus/robots/reqid"
+)
+
+// MediaFetcher downloads one of an article's images or datasets for ExportArticle.
+type MediaFetcher func(ctx context.Context, mediaURL string) (io.ReadCloser, error)
+
+type exportParams struct {
+	fetch MediaFetcher
+}
+
+// ExportOption configures ExportArticle.
+type ExportOption func(*exportParams)
+
+// WithMediaFetcher downloads images and datasets with fetch instead of a plain GET, eg. straight
+// from the media bucket with Uploader.Download.
+func WithMediaFetcher(fetch MediaFetcher) ExportOption {
+	return func(params *exportParams) {
+		params.fetch = fetch
+	}
+}
+
+// exportFile is one of the repo's files for the article, as it goes in an export.
+type exportFile struct {
+	name    string
+	content string
+}
+
+// exportManifest is manifest.json in an export: what the bundle is and what it couldn't include.
+type exportManifest struct {
+	Slug    string `json:"slug"`
+	Name    string `json:"name"`
+	PubDate string `json:"pub_date,omitempty"`
+	// Media maps each image's original URL to its file in the bundle.
+	Media map[string]string `json:"media"`
+	// MissingMedia are images that couldn't be downloaded, which index.html still links to.
+	MissingMedia []string `json:"missing_media,omitempty"`
+	// Datasets maps each GeoJSON dataset's name, and "teaser" if there's a teaser.geojson, to
+	// its file in the bundle.
+	Datasets map[string]string `json:"datasets,omitempty"`
+	// MissingDatasets are datasets that couldn't be found or downloaded, which index.html
+	// leaves off its map.
+	MissingDatasets []string `json:"missing_datasets,omitempty"`
+}
+
+// ExportArticle writes the article at slug to w as a zip, for archiving or sharing with
+// partners. It holds the repo's files for the article as they are on main, its images under
+// media/, its GeoJSON datasets, with those hosted elsewhere downloaded under datasets/, and
+// index.html, a page that shows the article without the site, pointing at the downloaded images.
+// The page draws the datasets on a map and runs article.js. Images and datasets that can't be
+// downloaded are listed in manifest.json; images are left pointing at the web.
+func (a *App) ExportArticle(ctx context.Context, slug string, w io.Writer, opts ...ExportOption) error {
+	params := exportParams{fetch: httpMediaFetcher}
+	for _, opt := range opts {
+		opt(&params)
+	}
+
+	checkout, err := a.FetchArticle(ctx, slug)
+	if err != nil {
+		return fmt.Errorf("error fetching article: %w", err)
+	}
+
+	zw := zip.NewWriter(w)
+	add := func(name string, content []byte) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return fmt.Errorf("error adding %s: %v", name, err)
+		}
+		if _, err := f.Write(content); err != nil {
+			return fmt.Errorf("error writing %s: %v", name, err)
+		}
+		return nil
+	}
+
+	files := []exportFile{
+		{"article.json", checkout.ArticleJSON},
+		{"article.html", checkout.BodyHTML},
+		{"article.js", checkout.JavascriptFunction},
+	}
+	if len(checkout.ImageCredits) > 0 {
+		credits, err := json.MarshalIndent(checkout.ImageCredits, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshaling image credits: %v", err)
+		}
+		files = append(files, exportFile{"images.json", string(credits)})
+	}
+	for _, file := range files {
+		if err := add(file.name, []byte(file.content)); err != nil {
+			return err
+		}
+	}
+
+	manifest := &exportManifest{
+		Slug:     checkout.Slug,
+		Name:     checkout.Article.Name,
+		PubDate:  checkout.Article.PubDate,
+		Media:    map[string]string{},
+		Datasets: map[string]string{},
+	}
+	sources := imageSources(checkout.BodyHTML)
+	if checkout.Article.FeatureImage != "" {
+		sources = append([]string{checkout.Article.FeatureImage}, sources...)
+	}
+	for _, src := range sources {
+		if _, ok := manifest.Media[src]; ok {
+			continue
+		}
+		name := fmt.Sprintf("media/%02d-%s", len(manifest.Media)+1, mediaFileName(src))
+		content, err := fetchMedia(ctx, params.fetch, src)
+		if err != nil {
+			reqid.Printf(ctx, "error exporting %s from %s: %v", src, slug, err)
+			manifest.MissingMedia = append(manifest.MissingMedia, src)
+			continue
+		}
+		if err := add(name, content); err != nil {
+			return err
+		}
+		manifest.Media[src] = name
+	}
+
+	datasets, err := a.exportDatasets(ctx, checkout, params.fetch, add, manifest)
+	if err != nil {
+		return err
+	}
+
+	page, err := exportPage(checkout, manifest.Media, datasets)
+	if err != nil {
+		return err
+	}
+	if err := add("index.html", page); err != nil {
+		return err
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling manifest: %v", err)
+	}
+	if err := add("manifest.json", manifestJSON); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("error finishing zip: %v", err)
+	}
+	return nil
+}
+
+// exportDatasets adds the article's datasets and teaser.geojson to the bundle, returning each
+// one's GeoJSON by name for index.html.
+func (a *App) exportDatasets(ctx context.Context, checkout *ArticleCheckout, fetch MediaFetcher, add func(string, []byte) error, manifest *exportManifest) (map[string]json.RawMessage, error) {
+	dir := "articles/" + checkout.Slug + "/"
+	datasets := map[string]json.RawMessage{}
+	for _, dataset := range checkout.Article.GeoJSONDatasets {
+		name, content, err := a.exportDataset(ctx, dir, dataset, fetch)
+		if err == nil && !json.Valid(content) {
+			err = fmt.Errorf("not JSON")
+		}
+		if err != nil {
+			reqid.Printf(ctx, "error exporting dataset %q from %s: %v", dataset.Name, checkout.Slug, err)
+			manifest.MissingDatasets = append(manifest.MissingDatasets, dataset.Name)
+			continue
+		}
+		if err := add(name, content); err != nil {
+			return nil, err
+		}
+		manifest.Datasets[dataset.Name] = name
+		datasets[dataset.Name] = content
+	}
+
+	teaser, ok, err := a.fileAt(ctx, dir+"teaser.geojson", "main")
+	if err != nil {
+		return nil, err
+	}
+	if ok && json.Valid([]byte(teaser)) {
+		if err := add("teaser.geojson", []byte(teaser)); err != nil {
+			return nil, err
+		}
+		manifest.Datasets["teaser"] = "teaser.geojson"
+		datasets["teaser"] = json.RawMessage(teaser)
+	}
+	return datasets, nil
+}
+
+// exportDataset returns a dataset's file name in the bundle and its content. Datasets with a web
+// URL are downloaded; the rest are read from the article's folder, by their URL if it's a file
+// name, or else as <name>.geojson.
+func (a *App) exportDataset(ctx context.Context, dir string, dataset *citygraph.GeoJSONDataset, fetch MediaFetcher) (string, []byte, error) {
+	if u, err := url.Parse(dataset.URL); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		content, err := fetchMedia(ctx, fetch, dataset.URL)
+		return "datasets/" + path.Base(dataset.Name) + ".geojson", content, err
+	}
+	name := strings.TrimPrefix(dataset.URL, "./")
+	if name == "" {
+		name = dataset.Name + ".geojson"
+	}
+	content, ok, err := a.fileAt(ctx, dir+name, "main")
+	if err != nil {
+		return "", nil, err
+	}
+	if !ok {
+		return "", nil, fmt.Errorf("%s isn't in the repo", name)
+	}
+	return name, []byte(content), nil
+}
+
+// maxExportMediaSize bounds each image in an export, so one huge file can't balloon it.
+const maxExportMediaSize = 50 << 20
+
+func fetchMedia(ctx context.Context, fetch MediaFetcher, src string) ([]byte, error) {
+	body, err := fetch(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	content, err := io.ReadAll(io.LimitReader(body, maxExportMediaSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading: %v", err)
+	}
+	if len(content) > maxExportMediaSize {
+		return nil, fmt.Errorf("over %d bytes", maxExportMediaSize)
+	}
+	return content, nil
+}
+
+func httpMediaFetcher(ctx context.Context, mediaURL string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mediaURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("error downloading: %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// mediaFileName is the last part of src's path, or "image" if it hasn't got one.
+func mediaFileName(src string) string {
+	name := src
+	if u, err := url.Parse(src); err == nil {
+		name = u.Path
+	}
+	name = path.Base(name)
+	if name == "." || name == "/" || name == "" {
+		return "image"
+	}
+	return name
+}
+
+var exportPageTemplate = template.Must(template.New("export").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Name}}</title>
+<style>
+body { max-width: 40em; margin: 2em auto; padding: 0 1em; font: 18px/1.5 Georgia, serif; color: #222; }
+img { max-width: 100%; height: auto; }
+figcaption, .byline, .credits { font: 14px/1.4 sans-serif; color: #555; }
+</style>
+</head>
+<body>
+<article>
+<h1>{{.Name}}</h1>
+{{if .Description}}<h2>{{.Description}}</h2>
+{{end}}<p class="byline">{{if .Authors}}By {{.Authors}}{{end}}{{if and .Authors .PubDate}} · {{end}}{{.PubDate}}</p>
+{{if .FeatureImage}}<img src="{{.FeatureImage}}" alt="">
+{{end}}{{.Body}}
+</article>
+{{if .HasMap}}<div id="map" style="height: 400px; margin: 1em 0;"></div>
+{{end}}{{if .Credits}}<section class="credits">
+<h3>Image credits</h3>
+<ul>
+{{range .Credits}}<li>{{.Credit}} ({{.License}}){{if .Source}}, <a href="{{.Source}}">source</a>{{end}}</li>
+{{end}}</ul>
+</section>
+{{end}}{{if .URL}}<p class="credits">Originally published at <a href="{{.URL}}">{{.URL}}</a>.</p>
+{{end}}<script>
+// The datasets article.js asks for, as the site provides them.
+var datasets = {{.Datasets}};
+function getDataset(name) { return datasets[name]; }
+var dataset = getDataset;
+function loadDataset(name) { return Promise.resolve(datasets[name]); }
+</script>
+{{if .HasMap}}<link rel="stylesheet" href="https://unpkg.com/leaflet@1.9.4/dist/leaflet.css">
+<script src="https://unpkg.com/leaflet@1.9.4/dist/leaflet.js"></script>
+<script>
+var map = L.map("map");
+L.tileLayer("https://tile.openstreetmap.org/{z}/{x}/{y}.png", {
+  attribution: "&copy; OpenStreetMap contributors"
+}).addTo(map);
+var bounds = L.latLngBounds([]);
+Object.keys(datasets).forEach(function (name) {
+  var layer = L.geoJSON(datasets[name]).addTo(map);
+  if (layer.getBounds().isValid()) {
+    bounds.extend(layer.getBounds());
+  }
+});
+if (bounds.isValid()) {
+  map.fitBounds(bounds);
+} else {
+  map.setView([43.6532, -79.3832], 12);
+}
+</script>
+{{end}}<script src="article.js"></script>
+</body>
+</html>
+`))
+
+// exportPage renders index.html with each image pointed at its file in media, and datasets
+// drawn on its map.
+func exportPage(checkout *ArticleCheckout, media map[string]string, datasets map[string]json.RawMessage) ([]byte, error) {
+	body := checkout.BodyHTML
+	for src, name := range media {
+		body = strings.ReplaceAll(body, `"`+html.EscapeString(src)+`"`, `"`+name+`"`)
+		body = strings.ReplaceAll(body, `"`+src+`"`, `"`+name+`"`)
+	}
+	featureImage := checkout.Article.FeatureImage
+	if name, ok := media[featureImage]; ok {
+		featureImage = name
+	}
+	var pageURL string
+	if p, err := checkout.Article.Path(); err == nil {
+		pageURL = "https://www.torontoverse.com" + p
+	}
+
+	// Marshaling escapes <, > and &, so the datasets can't close the script they're in.
+	datasetsJSON, err := json.Marshal(datasets)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling datasets: %v", err)
+	}
+
+	var b strings.Builder
+	if err := exportPageTemplate.Execute(&b, map[string]any{
+		"Name":         checkout.Article.Name,
+		"Description":  checkout.Article.Description,
+		"Authors":      strings.Join(checkout.Article.Authors, ", "),
+		"PubDate":      checkout.Article.PubDate,
+		"FeatureImage": featureImage,
+		// The body comes from the repo, where it's been through review, so it's trusted.
+		"Body":     template.HTML(body),
+		"Credits":  checkout.ImageCredits,
+		"URL":      pageURL,
+		"Datasets": template.JS(datasetsJSON),
+		"HasMap":   len(datasets) > 0,
+	}); err != nil {
+		return nil, fmt.Errorf("error rendering index.html: %v", err)
+	}
+	return []byte(b.String()), nil
+}
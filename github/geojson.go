@@ -0,0 +1,118 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	gh "github.com/google/go-github/v53/github"
+
+	"github.com/geomodulus/robots/prettier"
+)
+
+// DefaultCoordinatePrecision is how many decimal places coordinates in committed GeoJSON keep,
+// unless App.CoordinatePrecision says otherwise. Six is about 10cm, finer than any map we draw.
+const DefaultCoordinatePrecision = 6
+
+var (
+	// coordinatesRe matches the start of a GeoJSON coordinates or bbox array.
+	coordinatesRe = regexp.MustCompile(`"(?:coordinates|bbox)"\s*:\s*\[`)
+	decimalRe     = regexp.MustCompile(`-?\d+\.(\d+)(?:[eE][+-]?\d+)?`)
+)
+
+// NormalizeCoordinates rounds every coordinate in a GeoJSON document to precision decimal places.
+// Everything else, including the document's formatting and numbers in properties, is left as it
+// is, so the only change in a diff is the coordinates themselves.
+func NormalizeCoordinates(content string, precision int) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range coordinatesRe.FindAllStringIndex(content, -1) {
+		start := loc[1] - 1
+		if start < last {
+			continue
+		}
+		end := arrayEnd(content, start)
+		if end < 0 {
+			continue
+		}
+		b.WriteString(content[last:start])
+		b.WriteString(decimalRe.ReplaceAllStringFunc(content[start:end], func(num string) string {
+			return roundCoordinate(num, precision)
+		}))
+		last = end
+	}
+	if last == 0 {
+		return content
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
+// arrayEnd returns the index just past the bracket closing the array opened at start, or -1 if
+// the array holds anything but numbers and arrays.
+func arrayEnd(content string, start int) int {
+	depth := 0
+	for i := start; i < len(content); i++ {
+		switch content[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case '"', '{':
+			return -1
+		}
+	}
+	return -1
+}
+
+func roundCoordinate(num string, precision int) string {
+	match := decimalRe.FindStringSubmatch(num)
+	if len(match[1]) <= precision && !strings.ContainsAny(num, "eE") {
+		return num
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return num
+	}
+	pow := math.Pow10(precision)
+	rounded := math.Round(f*pow) / pow
+	if rounded == 0 {
+		// Not "-0".
+		rounded = 0
+	}
+	return strconv.FormatFloat(rounded, 'f', -1, 64)
+}
+
+// normalizeGeoJSONHook rounds the coordinates of every .geojson file in a commit to the App's
+// CoordinatePrecision. Files it changes are formatted again, since shorter coordinates can fit on
+// fewer lines than prettier gave them.
+func normalizeGeoJSONHook(ctx context.Context, a *App, entries []*gh.TreeEntry) error {
+	precision := a.CoordinatePrecision
+	if precision < 0 {
+		return nil
+	}
+	if precision == 0 {
+		precision = DefaultCoordinatePrecision
+	}
+	for _, entry := range entries {
+		if entry.Content == nil || !strings.HasSuffix(entry.GetPath(), ".geojson") {
+			continue
+		}
+		normalized := NormalizeCoordinates(entry.GetContent(), precision)
+		if normalized == entry.GetContent() {
+			continue
+		}
+		pretty, err := prettier.Format(normalized, entry.GetPath())
+		if err != nil {
+			return fmt.Errorf("error formatting %s: %v", entry.GetPath(), err)
+		}
+		entry.Content = gh.String(pretty)
+	}
+	return nil
+}
@@ -2,13 +2,20 @@ package github
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"math"
+	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	gh "github.com/google/go-github/v53/github"
 
 	"github.com/geomodulus/citygraph"
+	"github.com/geomodulus/robots/flags"
+	"github.com/geomodulus/robots/htmlguard"
+	"github.com/geomodulus/robots/internal/retry"
+	"github.com/geomodulus/robots/osm"
 )
 
 // NewClient returns a new GitHub client for the given installation ID.
@@ -19,6 +26,54 @@ type App struct {
 	InstallationID int64
 	Owner          string
 	Repo           string
+
+	// PushHooks run after every push to a PR branch.
+	PushHooks []PushHook
+	// PreCommitHooks run on the files of every commit before it's made, after GeoJSON
+	// coordinates are rounded to CoordinatePrecision.
+	PreCommitHooks []PreCommitHook
+	// CoordinatePrecision is how many decimal places committed GeoJSON coordinates keep.
+	// Defaults to DefaultCoordinatePrecision; negative leaves them as they are.
+	CoordinatePrecision int
+	// Flags, if set, can stop CreateArticleCommit publishing straight to main with
+	// flags.AutoPublish. Article PRs still open.
+	Flags *flags.Set
+	// PRBodyTemplate renders the body of new article and place PRs. Defaults to
+	// DefaultPRBodyTemplate.
+	PRBodyTemplate *template.Template
+	// ScopeTokens makes writes, and history reads, with tokens limited to what each needs, as
+	// WithScope does. Other reads keep using the App's client.
+	ScopeTokens bool
+	// AppClient is authenticated as the App itself, eg. with ghinstallation.NewAppsTransport,
+	// to mint scoped tokens. Defaults to the App's client, which must then be authenticated as
+	// the App, leaving only scoped calls working.
+	AppClient *gh.Client
+
+	// state is kept behind a pointer, so WithScope can copy the rest of the App as is.
+	state *appState
+}
+
+// appState is what an App learns about its tokens as it runs.
+type appState struct {
+	tokenMu        sync.Mutex
+	tokenExpiresAt time.Time
+	// scopedTokens are the tokens WithScope copies authenticate with, by TokenScope.key.
+	scopedMu     sync.Mutex
+	scopedTokens map[string]*scopedToken
+	// scopedApps are the copies scoped hands out, by TokenScope.key.
+	scopedApps map[string]*App
+}
+
+// stateMu guards creating Apps' state.
+var stateMu sync.Mutex
+
+func (a *App) tokenState() *appState {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	if a.state == nil {
+		a.state = &appState{}
+	}
+	return a.state
 }
 
 // CreateGithubInstallationToken creates a new GitHub installation token.
@@ -27,9 +82,22 @@ func (a *App) CreateInstallationToken(ctx context.Context) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("CreateInstallationToken: %v", err)
 	}
+	state := a.tokenState()
+	state.tokenMu.Lock()
+	state.tokenExpiresAt = token.GetExpiresAt().Time
+	state.tokenMu.Unlock()
 	return token.GetToken(), nil
 }
 
+// InstallationTokenExpiry returns when the last token from CreateInstallationToken expires, or
+// the zero time if none has been created.
+func (a *App) InstallationTokenExpiry() time.Time {
+	state := a.tokenState()
+	state.tokenMu.Lock()
+	defer state.tokenMu.Unlock()
+	return state.tokenExpiresAt
+}
+
 type Params struct {
 	InArchive     bool
 	Article       *citygraph.Article
@@ -43,8 +111,48 @@ type Params struct {
 	PRNum         int
 	TeaserGeoJSON string
 	TeaserJS      string
+	Authors       []*Author
+	Corrections   []*Correction
+	// RelatedPlaces is written to article.json, RelatedArticles to poi.json. Left unset, they
+	// and the other article.json fields beside the article are kept as they are.
+	RelatedPlaces   []string
+	RelatedArticles []string
+	// Series is written to article.json for articles in a collection.
+	Series *SeriesPart
+	// Attributes is written to poi.json, merged with OpenStreetMap data when OSM is set. Left
+	// unset, the place's existing attributes are kept.
+	Attributes map[string]*osm.Attribute
+	OSM        *osm.Client
+	// PushHooks run after this call's push, following the App's hooks.
+	PushHooks []PushHook
+	// HTMLGuard, if set, rejects BodyHTML it doesn't allow before anything is formatted or
+	// committed.
+	HTMLGuard *htmlguard.Policy
+	// Reviewers are requested on new article pull requests. Defaults to DefaultReviewers.
+	Reviewers []string
+	// ImageCredits is written to images.json. With RequireImageCredits, every image in BodyHTML
+	// must be credited in it. Article PRs and commits require credits unless SkipOptionalChecks
+	// is set.
+	ImageCredits        []*ImageCredit
+	RequireImageCredits bool
+	// CheckArticleJS refuses an ArticleJS that refers to datasets Article doesn't declare.
+	// Article PRs and commits check unless SkipOptionalChecks is set.
+	CheckArticleJS bool
+	// SkipOptionalChecks waives checks that guard quality rather than safety, like
+	// RequireImageCredits. HTMLGuard still applies.
+	SkipOptionalChecks bool
+	// BaseArticleJSON is the article.json being replaced, so unchanged fields are written as
+	// they were.
+	BaseArticleJSON string
+	// ImportedFrom identifies an archived article in the CMS it was imported from. Without it,
+	// an update keeps the article's.
+	ImportedFrom string
 }
 
+// DefaultReviewers are requested on new article pull requests unless WithReviewers says
+// otherwise.
+var DefaultReviewers = []string{"chrisdinn"}
+
 type Option func(*Params)
 
 func InArchive(inArchive bool) Option {
@@ -107,6 +215,84 @@ func WithPRBody(prBody string) Option {
 	}
 }
 
+// WithCorrections sets the corrections recorded in article.json. Without it, an update keeps
+// the article's existing corrections.
+func WithCorrections(corrections []*Correction) Option {
+	return func(params *Params) {
+		params.Corrections = corrections
+	}
+}
+
+// WithReviewers sets the GitHub users requested to review a new pull request.
+func WithReviewers(reviewers ...string) Option {
+	return func(params *Params) {
+		params.Reviewers = reviewers
+	}
+}
+
+func WithRelatedPlaces(ids []string) Option {
+	return func(params *Params) {
+		params.RelatedPlaces = ids
+	}
+}
+
+// WithSeries sets the series part recorded in article.json. Without it, an update keeps the
+// article's existing part.
+func WithSeries(part *SeriesPart) Option {
+	return func(params *Params) {
+		params.Series = part
+	}
+}
+
+func WithRelatedArticles(ids []string) Option {
+	return func(params *Params) {
+		params.RelatedArticles = ids
+	}
+}
+
+func WithAttributes(attrs map[string]*osm.Attribute) Option {
+	return func(params *Params) {
+		params.Attributes = attrs
+	}
+}
+
+// WithOSMEnrichment looks places up in OpenStreetMap before they're committed and merges in
+// opening hours, cuisine, accessibility and website details.
+func WithOSMEnrichment(client *osm.Client) Option {
+	return func(params *Params) {
+		params.OSM = client
+	}
+}
+
+func WithPushHook(hook PushHook) Option {
+	return func(params *Params) {
+		params.PushHooks = append(params.PushHooks, hook)
+	}
+}
+
+// WithHTMLGuard checks BodyHTML against policy, eg. htmlguard.DefaultPolicy for bot-generated
+// articles.
+func WithHTMLGuard(policy *htmlguard.Policy) Option {
+	return func(params *Params) {
+		params.HTMLGuard = policy
+	}
+}
+
+// SkipOptionalChecks waives the image credits and article.js checks article PRs and commits
+// require, eg. for breaking news, where fixes can follow in a later commit.
+func SkipOptionalChecks() Option {
+	return func(params *Params) {
+		params.SkipOptionalChecks = true
+	}
+}
+
+func guardBodyHTML(params Params) error {
+	if params.HTMLGuard == nil || params.BodyHTML == "" {
+		return nil
+	}
+	return params.HTMLGuard.Validate(params.BodyHTML)
+}
+
 func WithTeaserGeoJSON(geojson string) Option {
 	return func(params *Params) {
 		params.TeaserGeoJSON = geojson
@@ -140,31 +326,259 @@ func (a *App) newBranchRef(ctx context.Context) (*gh.Reference, error) {
 	return newBranchRef, nil
 }
 
-func (a *App) createPRWithRetry(ctx context.Context, newPR *gh.NewPullRequest, maxRetries int) (*gh.PullRequest, error) {
-	baseDelay := float64(2) // base delay in seconds
-	maxDelay := float64(30) // maximum delay in seconds
+// pullRequestBranch returns the branch ref for params.PRNum if that PR is still open, along with
+// the PR itself, or a fresh branch off main when there's no open PR.
+func (a *App) pullRequestBranch(ctx context.Context, prNum int) (*gh.Reference, *gh.PullRequest, error) {
+	if prNum == 0 {
+		// No PR exists, create one
+		ref, err := a.newBranchRef(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating new branch: %v", err)
+		}
+		return ref, nil, nil
+	}
 
-	for i := 0; i < maxRetries; i++ {
-		pr, _, err := a.PullRequests.Create(ctx, a.Owner, a.Repo, newPR)
+	// PR exists, check if it's been merged
+	pr, _, err := a.PullRequests.Get(ctx, a.Owner, a.Repo, prNum)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting PR: %v", err)
+	}
+	if pr.GetState() == "closed" {
+		// Prior PR has been closed so, create a new one.
+		ref, err := a.newBranchRef(ctx)
 		if err != nil {
-			githubError, ok := err.(*gh.ErrorResponse)
-			if ok {
-				for _, err := range githubError.Errors {
-					if err.Code == "custom" && err.Message == "No commits between main and "+*newPR.Head {
-						delay := math.Min(baseDelay*math.Pow(2, float64(i)), maxDelay) // calculate delay
-						fmt.Printf("PR creation failed. Retrying after %.2f seconds...\n", delay)
-						time.Sleep(time.Duration(delay) * time.Second) // wait before retrying
-						break
-					}
+			return nil, nil, fmt.Errorf("error creating new branch: %v", err)
+		}
+		return ref, nil, nil
+	}
+
+	// PR still active, needs to be updated.
+	ref, _, err := a.Git.GetRef(ctx, a.Owner, a.Repo, "refs/heads/"+pr.GetHead().GetRef())
+	if err != nil {
+		return nil, nil, err
+	}
+	return ref, pr, nil
+}
+
+// commitToPullRequest commits treeEntries to the branch of the open PR params.PRNum, or to a new
+// branch and PR if there isn't one. The returned bool reports whether the PR was newly created.
+func (a *App) commitToPullRequest(ctx context.Context, treeEntries []*gh.TreeEntry, params Params) (*gh.PullRequest, bool, error) {
+	publisher := a.scoped(PublishScope)
+	prBranchRef, activePR, err := publisher.pullRequestBranch(ctx, params.PRNum)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Commit the changes.
+	if err := a.runPreCommitHooks(ctx, treeEntries); err != nil {
+		return nil, false, err
+	}
+	baseSHA := prBranchRef.GetObject().GetSHA()
+	tree, _, err := publisher.Git.CreateTree(ctx, a.Owner, a.Repo, baseSHA, treeEntries)
+	if err != nil {
+		return nil, false, fmt.Errorf("error creating tree: %v", err)
+	}
+	parentCommit, _, err := publisher.Git.GetCommit(ctx, a.Owner, a.Repo, baseSHA)
+	if err != nil {
+		return nil, false, fmt.Errorf("error getting commit: %v", err)
+	}
+	// The commit message is what Changelog reads, so the PR title is only a fallback.
+	message := params.CommitMessage
+	if message == "" {
+		message = params.PRTitle
+	}
+	commit, _, err := publisher.Git.CreateCommit(ctx, a.Owner, a.Repo, &gh.Commit{
+		Message: gh.String(message),
+		Tree:    tree,
+		Parents: []*gh.Commit{parentCommit},
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("error creating commit: %v", err)
+	}
+
+	// Add commit to the branch.
+	prBranchRef.Object.SHA = commit.SHA
+
+	_, _, err = publisher.Git.UpdateRef(ctx, a.Owner, a.Repo, prBranchRef, false)
+	if err != nil {
+		return nil, false, fmt.Errorf("error updating reference: %v", err)
+	}
+
+	if activePR != nil {
+		a.runPushHooks(ctx, &Push{PR: activePR, SHA: commit.GetSHA()}, params)
+		return activePR, false, nil
+	}
+
+	// Create a pull request
+	newPR := &gh.NewPullRequest{
+		Title:               gh.String(params.PRTitle),
+		Head:                gh.String(prBranchRef.GetRef()),
+		Base:                gh.String("main"),
+		Body:                gh.String(params.PRBody),
+		MaintainerCanModify: gh.Bool(true),
+	}
+
+	activePR, err = publisher.createPRWithRetry(ctx, newPR, 10)
+	if err != nil {
+		return nil, false, fmt.Errorf("error creating PR: %v", err)
+	}
+	a.runPushHooks(ctx, &Push{PR: activePR, SHA: commit.GetSHA(), Created: true}, params)
+	return activePR, true, nil
+}
+
+// commitToMain commits treeEntries directly to main.
+func (a *App) commitToMain(ctx context.Context, treeEntries []*gh.TreeEntry, message string) (*gh.Commit, error) {
+	committer := a.scoped(CommitScope)
+	ref, _, err := committer.Git.GetRef(ctx, a.Owner, a.Repo, "refs/heads/main")
+	if err != nil {
+		return nil, fmt.Errorf("error getting reference: %v", err)
+	}
+
+	if err := a.runPreCommitHooks(ctx, treeEntries); err != nil {
+		return nil, err
+	}
+	baseSHA := ref.GetObject().GetSHA()
+	tree, _, err := committer.Git.CreateTree(ctx, a.Owner, a.Repo, baseSHA, treeEntries)
+	if err != nil {
+		return nil, fmt.Errorf("error creating tree: %v", err)
+	}
+
+	commit, _, err := committer.Git.CreateCommit(ctx, a.Owner, a.Repo, &gh.Commit{
+		Message: gh.String(message),
+		Tree:    tree,
+		Parents: []*gh.Commit{{SHA: ref.Object.SHA}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating commit: %v", err)
+	}
+
+	ref.Object.SHA = commit.SHA
+	_, _, err = committer.Git.UpdateRef(ctx, a.Owner, a.Repo, ref, false)
+	if err != nil {
+		return nil, fmt.Errorf("error updating reference: %v", err)
+	}
+	return commit, nil
+}
+
+// fetchFileContent reads the file at path as of the given commit.
+func (a *App) fetchFileContent(ctx context.Context, path, sha string) (string, error) {
+	file, _, _, err := a.Repositories.GetContents(ctx, a.Owner, a.Repo, path, &gh.RepositoryContentGetOptions{Ref: sha})
+	if err != nil {
+		return "", fmt.Errorf("error getting file content: %v", err)
+	}
+	content, err := file.GetContent()
+	if err != nil {
+		return "", fmt.Errorf("error decoding file content: %v", err)
+	}
+	return content, nil
+}
+
+// deleteDirEntries returns tree entries deleting every file under dir as of the given commit.
+func (a *App) deleteDirEntries(ctx context.Context, dir, sha string) ([]*gh.TreeEntry, error) {
+	tree, _, err := a.Git.GetTree(ctx, a.Owner, a.Repo, sha, true)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tree: %v", err)
+	}
+	entries := []*gh.TreeEntry{}
+	for _, entry := range tree.Entries {
+		if entry.GetType() != "blob" || !strings.HasPrefix(entry.GetPath(), dir+"/") {
+			continue
+		}
+		// A nil SHA and Content deletes the file.
+		entries = append(entries, &gh.TreeEntry{
+			Path: gh.String(entry.GetPath()),
+			Mode: gh.String(entry.GetMode()),
+			Type: gh.String("blob"),
+		})
+	}
+	return entries, nil
+}
+
+// mainSHA returns the head commit of the main branch.
+func (a *App) mainSHA(ctx context.Context) (string, error) {
+	ref, _, err := a.Git.GetRef(ctx, a.Owner, a.Repo, "refs/heads/main")
+	if err != nil {
+		return "", fmt.Errorf("error getting reference: %v", err)
+	}
+	return ref.GetObject().GetSHA(), nil
+}
+
+func (a *App) createPRWithRetry(ctx context.Context, newPR *gh.NewPullRequest, maxRetries int) (*gh.PullRequest, error) {
+	// GitHub sometimes hasn't caught up with the branch we just pushed and reports it as empty.
+	noCommits := "No commits between main and " + newPR.GetHead()
+	policy := retryPolicy
+	policy.MaxAttempts = maxRetries
+	policy.BaseDelay = 2 * time.Second
+	policy.Retryable = func(err error) bool {
+		if ghErr, ok := err.(*gh.ErrorResponse); ok {
+			for _, e := range ghErr.Errors {
+				if e.Code == "custom" && e.Message == noCommits {
+					return true
 				}
-			} else {
-				return nil, err
 			}
-		} else {
-			return pr, nil
+		}
+		return isRetryable(err)
+	}
+
+	attempt := 0
+	pr, err := retry.DoValue(ctx, policy, func(ctx context.Context) (*gh.PullRequest, error) {
+		attempt++
+		// An earlier attempt may have opened the PR even though its response was lost, so adopt
+		// that one rather than opening a duplicate.
+		if attempt > 1 {
+			existing, err := a.openPRForHead(ctx, newPR.GetHead())
+			if err != nil || existing != nil {
+				return existing, err
+			}
+		}
+		pr, _, err := a.PullRequests.Create(ctx, a.Owner, a.Repo, newPR)
+		if err != nil && prAlreadyExists(err) {
+			existing, lookupErr := a.openPRForHead(ctx, newPR.GetHead())
+			if lookupErr != nil {
+				return nil, lookupErr
+			}
+			if existing != nil {
+				return existing, nil
+			}
+		}
+		return pr, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create PR: %v", err)
+	}
+	return pr, nil
+}
+
+// openPRForHead returns the open PR from head, eg. "refs/heads/update-article-123", or nil if
+// there isn't one.
+func (a *App) openPRForHead(ctx context.Context, head string) (*gh.PullRequest, error) {
+	branch := strings.TrimPrefix(head, "refs/heads/")
+	prs, _, err := a.PullRequests.List(ctx, a.Owner, a.Repo, &gh.PullRequestListOptions{
+		State: "open",
+		Head:  a.Owner + ":" + branch,
+		Base:  "main",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing pull requests for %s: %w", branch, err)
+	}
+	if len(prs) == 0 {
+		return nil, nil
+	}
+	return prs[0], nil
+}
+
+// prAlreadyExists reports whether err is GitHub refusing a second PR from the same branch.
+func prAlreadyExists(err error) bool {
+	var ghErr *gh.ErrorResponse
+	if !errors.As(err, &ghErr) {
+		return false
+	}
+	for _, e := range ghErr.Errors {
+		if strings.HasPrefix(e.Message, "A pull request already exists") {
+			return true
 		}
 	}
-	return nil, fmt.Errorf("unable to create PR after %d attempts", maxRetries)
+	return false
 }
 
 func removeQuotes(s string) string {
@@ -0,0 +1,150 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	gh "github.com/google/go-github/v53/github"
+)
+
+// ArticleRevision is a commit to main that changed an article.
+type ArticleRevision struct {
+	SHA     string
+	Subject string
+	Author  string
+	Time    time.Time
+	URL     string
+}
+
+// ArticleHistory returns up to limit of the commits to main that changed the article, newest
+// first.
+func (a *App) ArticleHistory(ctx context.Context, slug string, limit int) ([]*ArticleRevision, error) {
+	commits, _, err := a.scoped(ReadScope).Repositories.ListCommits(ctx, a.Owner, a.Repo, &gh.CommitsListOptions{
+		SHA:         "main",
+		Path:        "articles/" + slug,
+		ListOptions: gh.ListOptions{PerPage: limit},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing commits: %v", err)
+	}
+	revisions := []*ArticleRevision{}
+	for _, commit := range commits {
+		subject, _, _ := strings.Cut(commit.GetCommit().GetMessage(), "\n")
+		author := commit.GetAuthor().GetLogin()
+		if author == "" {
+			author = commit.GetCommit().GetAuthor().GetName()
+		}
+		revisions = append(revisions, &ArticleRevision{
+			SHA:     commit.GetSHA(),
+			Subject: subject,
+			Author:  author,
+			Time:    commit.GetCommit().GetCommitter().GetDate().Time,
+			URL:     commit.GetHTMLURL(),
+		})
+		if len(revisions) == limit {
+			break
+		}
+	}
+	return revisions, nil
+}
+
+// ArticleFileDiff is how one of an article's files changed between two commits.
+type ArticleFileDiff struct {
+	Path string
+	// Status is "added", "removed", "modified" or "renamed".
+	Status    string
+	Additions int
+	Deletions int
+	Patch     string
+}
+
+// DiffArticle compares the article's files at base and head.
+func (a *App) DiffArticle(ctx context.Context, slug, base, head string) ([]*ArticleFileDiff, error) {
+	comparison, _, err := a.scoped(ReadScope).Repositories.CompareCommits(ctx, a.Owner, a.Repo, base, head, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error comparing commits: %v", err)
+	}
+	dir := "articles/" + slug + "/"
+	diffs := []*ArticleFileDiff{}
+	for _, file := range comparison.Files {
+		if !strings.HasPrefix(file.GetFilename(), dir) {
+			continue
+		}
+		diffs = append(diffs, &ArticleFileDiff{
+			Path:      file.GetFilename(),
+			Status:    file.GetStatus(),
+			Additions: file.GetAdditions(),
+			Deletions: file.GetDeletions(),
+			Patch:     file.GetPatch(),
+		})
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Path < diffs[j].Path })
+	return diffs, nil
+}
+
+// FileAt returns the file at path as it was at ref, eg. a commit from ArticleHistory,
+// reporting false if it didn't exist there.
+func (a *App) FileAt(ctx context.Context, path, ref string) (string, bool, error) {
+	return a.scoped(ReadScope).fileAt(ctx, path, ref)
+}
+
+// RevertArticleTo commits the article's files as they were at sha directly to main, removing
+// any added since, and returns the new commit's SHA. Nothing else in the repo changes.
+func (a *App) RevertArticleTo(ctx context.Context, slug, sha string, opts ...Option) (string, error) {
+	params := Params{
+		CommitMessage: fmt.Sprintf("update(article): Roll back %s to %.7s\n\n%s: %s", slug, sha, SlugTrailer, slug),
+	}
+	for _, opt := range opts {
+		opt(&params)
+	}
+
+	dir := "articles/" + slug
+	reader := a.scoped(ReadScope)
+	mainSHA, err := reader.mainSHA(ctx)
+	if err != nil {
+		return "", err
+	}
+	entries := map[string]*gh.TreeEntry{}
+	deletions, err := reader.deleteDirEntries(ctx, dir, mainSHA)
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range deletions {
+		entries[entry.GetPath()] = entry
+	}
+
+	tree, _, err := reader.Git.GetTree(ctx, a.Owner, a.Repo, sha, true)
+	if err != nil {
+		return "", fmt.Errorf("error getting tree: %v", err)
+	}
+	restored := 0
+	for _, entry := range tree.Entries {
+		if entry.GetType() != "blob" || !strings.HasPrefix(entry.GetPath(), dir+"/") {
+			continue
+		}
+		// Pointing at the old blob restores its content without downloading it.
+		entries[entry.GetPath()] = &gh.TreeEntry{
+			Path: gh.String(entry.GetPath()),
+			Mode: gh.String(entry.GetMode()),
+			Type: gh.String("blob"),
+			SHA:  gh.String(entry.GetSHA()),
+		}
+		restored++
+	}
+	if restored == 0 {
+		return "", fmt.Errorf("article %q didn't exist at %.7s", slug, sha)
+	}
+
+	treeEntries := []*gh.TreeEntry{}
+	for _, entry := range entries {
+		treeEntries = append(treeEntries, entry)
+	}
+	commit, err := a.commitToMain(ctx, treeEntries, params.CommitMessage)
+	if err != nil {
+		return "", err
+	}
+	return commit.GetSHA(), nil
+}
@@ -0,0 +1,46 @@
+package github
+
+import (
+	"context"
+	"fmt"
+
+	gh "github.com/google/go-github/v53/github"
+
+	"github.com/geomodulus/robots/reqid"
+)
+
+// Push describes a commit pushed to a PR branch.
+type Push struct {
+	PR  *gh.PullRequest
+	SHA string
+	// Created is set when the push opened the PR.
+	Created bool
+}
+
+// PushHook is called after a commit is pushed to a PR branch. Errors are logged; the push has
+// already happened so they don't fail the call that triggered it.
+type PushHook func(ctx context.Context, a *App, push *Push) error
+
+func (a *App) runPushHooks(ctx context.Context, push *Push, params Params) {
+	hooks := append(append([]PushHook{}, a.PushHooks...), params.PushHooks...)
+	for _, hook := range hooks {
+		if err := hook(ctx, a, push); err != nil {
+			reqid.Printf(ctx, "push hook for PR #%d: %v", push.PR.GetNumber(), err)
+		}
+	}
+}
+
+// PreCommitHook checks or rewrites the files of a commit before it's made, eg. to normalize
+// them. Hooks edit entries in place; an error stops the commit.
+type PreCommitHook func(ctx context.Context, a *App, entries []*gh.TreeEntry) error
+
+// runPreCommitHooks normalizes GeoJSON coordinates, then runs the App's hooks.
+func (a *App) runPreCommitHooks(ctx context.Context, entries []*gh.TreeEntry) error {
+	hooks := append([]PreCommitHook{normalizeGeoJSONHook}, a.PreCommitHooks...)
+	for _, hook := range hooks {
+		if err := hook(ctx, a, entries); err != nil {
+			return fmt.Errorf("pre-commit hook: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	gh "github.com/google/go-github/v53/github"
+	"golang.org/x/net/html"
+
+	"github.com/geomodulus/robots/prettier"
+)
+
+// ImageCredit records who made one of an article's images and how we may use it. An article's
+// credits are kept in its images.json.
+type ImageCredit struct {
+	URL     string `json:"url"`
+	Credit  string `json:"credit"`
+	License string `json:"license"`
+	// Source is where the image came from, eg. the original's page. Optional.
+	Source string `json:"source,omitempty"`
+}
+
+// Licenses are the licenses editors choose from.
+var Licenses = []string{
+	"Staff",
+	"Used with permission",
+	"Handout",
+	"CC BY 4.0",
+	"CC BY-SA 4.0",
+	"CC0",
+	"Public domain",
+}
+
+// MissingCreditsError is returned when an article's body has images with no credit or license.
+// CreateOrUpdateArticlePullRequest and CreateArticleCommit check every body they commit.
+type MissingCreditsError struct {
+	Images []string
+}
+
+func (e *MissingCreditsError) Error() string {
+	return fmt.Sprintf("%d images need a credit and license before this can go to GitHub: %s",
+		len(e.Images), strings.Join(e.Images, ", "))
+}
+
+// CheckImageCredits returns a *MissingCreditsError listing the images in bodyHTML that don't
+// have both a credit and a license in credits.
+func CheckImageCredits(bodyHTML string, credits []*ImageCredit) error {
+	credited := map[string]bool{}
+	for _, credit := range credits {
+		if strings.TrimSpace(credit.Credit) != "" && strings.TrimSpace(credit.License) != "" {
+			credited[credit.URL] = true
+		}
+	}
+	missing := []string{}
+	seen := map[string]bool{}
+	for _, src := range imageSources(bodyHTML) {
+		if !credited[src] && !seen[src] {
+			missing = append(missing, src)
+		}
+		seen[src] = true
+	}
+	if len(missing) > 0 {
+		return &MissingCreditsError{Images: missing}
+	}
+	return nil
+}
+
+// WithImageCredits writes credits to the article's images.json. Without it, an update keeps
+// the article's existing credits.
+func WithImageCredits(credits []*ImageCredit) Option {
+	return func(params *Params) {
+		params.ImageCredits = credits
+	}
+}
+
+func guardImageCredits(params Params) error {
+	if !params.RequireImageCredits || params.SkipOptionalChecks || params.BodyHTML == "" {
+		return nil
+	}
+	return CheckImageCredits(params.BodyHTML, params.ImageCredits)
+}
+
+func articleImagesJSON(path string, credits []*ImageCredit) (*gh.TreeEntry, error) {
+	jsonPath := path + "/images.json"
+	content, err := json.MarshalIndent(credits, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling image credits: %w", err)
+	}
+	pretty, err := prettier.Format(string(content), jsonPath)
+	if err != nil {
+		return nil, fmt.Errorf("error formatting image credits: %w", err)
+	}
+	return &gh.TreeEntry{
+		Path:    gh.String(jsonPath),
+		Mode:    gh.String("100644"),
+		Type:    gh.String("blob"),
+		Content: gh.String(pretty),
+	}, nil
+}
+
+// imageSources returns the src of every <img> in an HTML fragment.
+func imageSources(body string) []string {
+	out := []string{}
+	tokenizer := html.NewTokenizer(strings.NewReader(body))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return out
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			if token.Data != "img" {
+				continue
+			}
+			for _, attr := range token.Attr {
+				if attr.Key == "src" && strings.TrimSpace(attr.Val) != "" {
+					out = append(out, strings.TrimSpace(attr.Val))
+				}
+			}
+		}
+	}
+}
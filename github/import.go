@@ -0,0 +1,229 @@
+package github
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	gh "github.com/google/go-github/v53/github"
+	"github.com/paulmach/go.geojson"
+
+	"github.com/geomodulus/citygraph"
+)
+
+// ImportFormat identifies the encoding of a place import file.
+type ImportFormat string
+
+const (
+	// ImportCSV expects a header row. Recognized columns are name, short_name, description,
+	// street_address (or address), phone_number (or phone), url (or website), type, instagram,
+	// twitter, lng (or longitude) and lat (or latitude).
+	ImportCSV ImportFormat = "csv"
+	// ImportGeoJSON expects a feature collection of points, with the same keys as ImportCSV
+	// read from feature properties.
+	ImportGeoJSON ImportFormat = "geojson"
+)
+
+// ImportPlaces parses places from r and opens a single PR adding a poi.json and a stub body.html
+// for each of them under active_places/.
+func (a *App) ImportPlaces(ctx context.Context, r io.Reader, format ImportFormat, opts ...Option) (int, string, error) {
+	var (
+		places []*citygraph.Place
+		err    error
+	)
+	switch format {
+	case ImportCSV:
+		places, err = parsePlacesCSV(r)
+	case ImportGeoJSON:
+		places, err = parsePlacesGeoJSON(r)
+	default:
+		return 0, "", fmt.Errorf("unknown import format %q", format)
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	if len(places) == 0 {
+		return 0, "", fmt.Errorf("no places found in import")
+	}
+
+	existing, err := a.ListPlaces(ctx)
+	if err != nil {
+		return 0, "", fmt.Errorf("error listing existing places: %w", err)
+	}
+	taken := map[string]bool{}
+	for _, place := range existing {
+		taken[place.Slug] = true
+	}
+
+	params := Params{
+		PRTitle: fmt.Sprintf("Import %d places", len(places)),
+	}
+	for _, opt := range opts {
+		opt(&params)
+	}
+
+	treeEntries := []*gh.TreeEntry{}
+	slugs := []string{}
+	for _, place := range places {
+		slug := uniqueSlug(place.SlugTitle(), taken)
+		taken[slug] = true
+		slugs = append(slugs, slug)
+
+		placeParams := params
+		placeParams.Place = place
+		placeParams.BodyHTML = placeBodyStub(place)
+		if err := enrichPlace(ctx, &placeParams); err != nil {
+			return 0, "", err
+		}
+		entries, err := placeTreeEntriesFromParams("active_places/"+slug, placeParams)
+		if err != nil {
+			return 0, "", fmt.Errorf("error creating tree entries for %s: %w", slug, err)
+		}
+		treeEntries = append(treeEntries, entries...)
+	}
+
+	if params.PRBody == "" {
+		params.PRBody = "Imported places:\n\n- `" + strings.Join(slugs, "`\n- `") + "`"
+	}
+
+	activePR, _, err := a.commitToPullRequest(ctx, treeEntries, params)
+	if err != nil {
+		return 0, "", err
+	}
+	return activePR.GetNumber(), activePR.GetHTMLURL(), nil
+}
+
+func parsePlacesCSV(r io.Reader) ([]*citygraph.Place, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading csv header: %v", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	places := []*citygraph.Place{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading csv: %v", err)
+		}
+		get := func(keys ...string) string {
+			for _, key := range keys {
+				if i, ok := columns[key]; ok && i < len(record) {
+					if v := strings.TrimSpace(record[i]); v != "" {
+						return v
+					}
+				}
+			}
+			return ""
+		}
+		place, err := newImportedPlace(get)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		places = append(places, place)
+	}
+	return places, nil
+}
+
+func parsePlacesGeoJSON(r io.Reader) ([]*citygraph.Place, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading geojson: %v", err)
+	}
+	fc, err := geojson.UnmarshalFeatureCollection(b)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshaling geojson: %v", err)
+	}
+
+	places := []*citygraph.Place{}
+	for i, feature := range fc.Features {
+		if feature.Geometry == nil || !feature.Geometry.IsPoint() || len(feature.Geometry.Point) < 2 {
+			return nil, fmt.Errorf("feature %d: geometry must be a point", i)
+		}
+		get := func(keys ...string) string {
+			for _, key := range keys {
+				switch key {
+				case "lng", "longitude":
+					return strconv.FormatFloat(feature.Geometry.Point[0], 'f', -1, 64)
+				case "lat", "latitude":
+					return strconv.FormatFloat(feature.Geometry.Point[1], 'f', -1, 64)
+				}
+				if v, err := feature.PropertyString(key); err == nil && strings.TrimSpace(v) != "" {
+					return strings.TrimSpace(v)
+				}
+			}
+			return ""
+		}
+		place, err := newImportedPlace(get)
+		if err != nil {
+			return nil, fmt.Errorf("feature %d: %w", i, err)
+		}
+		places = append(places, place)
+	}
+	return places, nil
+}
+
+// newImportedPlace builds a place from a field lookup shared by the CSV and GeoJSON parsers.
+func newImportedPlace(get func(keys ...string) string) (*citygraph.Place, error) {
+	name := get("name")
+	if name == "" {
+		return nil, fmt.Errorf("missing name")
+	}
+	lng, err := strconv.ParseFloat(get("lng", "longitude"), 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid longitude: %v", name, err)
+	}
+	lat, err := strconv.ParseFloat(get("lat", "latitude"), 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid latitude: %v", name, err)
+	}
+
+	return &citygraph.Place{
+		ID:          citygraph.NewID().String(),
+		Name:        name,
+		ShortName:   get("short_name"),
+		Desc:        get("description"),
+		AddedAt:     time.Now(),
+		PhoneNumber: get("phone_number", "phone"),
+		Address:     get("street_address", "address"),
+		URL:         get("url", "website"),
+		Type:        get("type"),
+		Instagram:   strings.TrimPrefix(get("instagram"), "@"),
+		Twitter:     strings.TrimPrefix(get("twitter"), "@"),
+		Location:    citygraph.LngLat{Lng: lng, Lat: lat},
+	}, nil
+}
+
+func placeBodyStub(place *citygraph.Place) string {
+	if place.Desc == "" {
+		return "<p></p>\n"
+	}
+	return "<p>" + html.EscapeString(place.Desc) + "</p>\n"
+}
+
+// uniqueSlug appends -2, -3, … to slug until it isn't taken.
+func uniqueSlug(slug string, taken map[string]bool) string {
+	if !taken[slug] {
+		return slug
+	}
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s-%d", slug, i)
+		if !taken[candidate] {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package github
+
+import (
+	"context"
+	"fmt"
+
+	gh "github.com/google/go-github/v53/github"
+)
+
+// CreateIssue files an issue in the repo with the given labels, which may be empty, and returns
+// its number and URL. Labels that don't exist yet are created by GitHub.
+func (a *App) CreateIssue(ctx context.Context, title, body string, labels []string) (int, string, error) {
+	req := &gh.IssueRequest{
+		Title: gh.String(title),
+		Body:  gh.String(body),
+	}
+	if len(labels) > 0 {
+		req.Labels = &labels
+	}
+	issue, _, err := a.scoped(IssueScope).Issues.Create(ctx, a.Owner, a.Repo, req)
+	if err != nil {
+		return 0, "", fmt.Errorf("error creating issue: %v", err)
+	}
+	return issue.GetNumber(), issue.GetHTMLURL(), nil
+}
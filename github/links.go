@@ -0,0 +1,232 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	gh "github.com/google/go-github/v53/github"
+	"golang.org/x/net/html"
+
+	"github.com/geomodulus/citygraph"
+	"github.com/geomodulus/robots/pubdate"
+)
+
+// LinkGraph is who links to whom among the live articles on main, read from their article.html.
+type LinkGraph struct {
+	// Articles are the live articles by slug.
+	Articles map[string]*citygraph.Article
+	// Outbound and Inbound hold the slugs each article links to and is linked from. Self-links
+	// and links to articles that aren't live are left out.
+	Outbound map[string][]string
+	Inbound  map[string][]string
+}
+
+// ArticleLinkGraph reads every live article's body on main and builds the graph of links between
+// them. Links are recognised by their /articles/<slug id>/ path, relative or on torontoverse.com.
+func (a *App) ArticleLinkGraph(ctx context.Context) (*LinkGraph, error) {
+	sha, err := a.mainSHA(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := a.articleTreeEntries(ctx, sha)
+	if err != nil {
+		return nil, err
+	}
+
+	articles := map[string]*citygraph.Article{}
+	bodySHAs := map[string]string{}
+	for _, entry := range entries {
+		rest, ok := strings.CutPrefix(entry.GetPath(), "articles/")
+		parts := strings.Split(rest, "/")
+		if !ok || len(parts) != 2 {
+			continue
+		}
+		switch parts[1] {
+		case "article.html":
+			bodySHAs[parts[0]] = entry.GetSHA()
+		case "article.json":
+			content, _, err := a.Git.GetBlobRaw(ctx, a.Owner, a.Repo, entry.GetSHA())
+			if err != nil {
+				return nil, fmt.Errorf("error getting %s: %v", entry.GetPath(), err)
+			}
+			article := &citygraph.Article{}
+			if err := json.Unmarshal(content, &articleJSON{Article: article}); err != nil {
+				return nil, fmt.Errorf("error unmarshaling %s: %v", entry.GetPath(), err)
+			}
+			if article.IsLive {
+				articles[parts[0]] = article
+			}
+		}
+	}
+
+	bySlugID := map[string]string{}
+	for slug, article := range articles {
+		if id, err := article.SlugID(); err == nil {
+			bySlugID[id] = slug
+		}
+	}
+
+	graph := &LinkGraph{
+		Articles: articles,
+		Outbound: map[string][]string{},
+		Inbound:  map[string][]string{},
+	}
+	for slug := range articles {
+		blobSHA, ok := bodySHAs[slug]
+		if !ok {
+			continue
+		}
+		content, _, err := a.Git.GetBlobRaw(ctx, a.Owner, a.Repo, blobSHA)
+		if err != nil {
+			return nil, fmt.Errorf("error getting articles/%s/article.html: %v", slug, err)
+		}
+		seen := map[string]bool{}
+		for _, href := range hrefs(content) {
+			slugID, ok := linkedSlugID(href)
+			target := bySlugID[slugID]
+			if !ok || target == "" || target == slug || seen[target] {
+				continue
+			}
+			seen[target] = true
+			graph.Outbound[slug] = append(graph.Outbound[slug], target)
+			graph.Inbound[target] = append(graph.Inbound[target], slug)
+		}
+	}
+	for _, links := range []map[string][]string{graph.Outbound, graph.Inbound} {
+		for _, slugs := range links {
+			sort.Strings(slugs)
+		}
+	}
+	return graph, nil
+}
+
+// articleTreeEntries returns the tree entries under articles/ at sha, with full paths. GitHub
+// truncates recursive trees of large repos, so then each article's directory is read on its own
+// rather than some silently going missing.
+func (a *App) articleTreeEntries(ctx context.Context, sha string) ([]*gh.TreeEntry, error) {
+	tree, _, err := a.Git.GetTree(ctx, a.Owner, a.Repo, sha, true)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tree: %v", err)
+	}
+	if !tree.GetTruncated() {
+		return tree.Entries, nil
+	}
+
+	root, _, err := a.Git.GetTree(ctx, a.Owner, a.Repo, sha, false)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tree: %v", err)
+	}
+	articlesSHA := ""
+	for _, entry := range root.Entries {
+		if entry.GetPath() == "articles" && entry.GetType() == "tree" {
+			articlesSHA = entry.GetSHA()
+		}
+	}
+	if articlesSHA == "" {
+		return nil, nil
+	}
+	dirs, _, err := a.Git.GetTree(ctx, a.Owner, a.Repo, articlesSHA, false)
+	if err != nil {
+		return nil, fmt.Errorf("error getting articles tree: %v", err)
+	}
+	if dirs.GetTruncated() {
+		return nil, fmt.Errorf("articles tree is too large to list")
+	}
+	entries := []*gh.TreeEntry{}
+	for _, dir := range dirs.Entries {
+		if dir.GetType() != "tree" {
+			continue
+		}
+		files, _, err := a.Git.GetTree(ctx, a.Owner, a.Repo, dir.GetSHA(), false)
+		if err != nil {
+			return nil, fmt.Errorf("error getting tree of articles/%s: %v", dir.GetPath(), err)
+		}
+		for _, file := range files.Entries {
+			file.Path = gh.String("articles/" + dir.GetPath() + "/" + file.GetPath())
+			entries = append(entries, file)
+		}
+	}
+	return entries, nil
+}
+
+// Orphans are the live articles no other article links to, oldest first, since those have had
+// longest to pick up links and haven't.
+func (g *LinkGraph) Orphans() []string {
+	orphans := []string{}
+	for slug := range g.Articles {
+		if len(g.Inbound[slug]) == 0 {
+			orphans = append(orphans, slug)
+		}
+	}
+	// PubDates are written in a few layouts, so they're compared as times. Unparseable ones sort
+	// first, as the zero time.
+	pubDates := map[string]time.Time{}
+	for _, slug := range orphans {
+		pubDates[slug], _ = pubdate.Parse(g.Articles[slug].PubDate)
+	}
+	sort.Slice(orphans, func(i, j int) bool {
+		a, b := pubDates[orphans[i]], pubDates[orphans[j]]
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return orphans[i] < orphans[j]
+	})
+	return orphans
+}
+
+// Links reports whether from already links to to.
+func (g *LinkGraph) Links(from, to string) bool {
+	for _, slug := range g.Outbound[from] {
+		if slug == to {
+			return true
+		}
+	}
+	return false
+}
+
+// hrefs returns the href of every <a> in an HTML fragment.
+func hrefs(content []byte) []string {
+	out := []string{}
+	tokenizer := html.NewTokenizer(bytes.NewReader(content))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return out
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			if string(name) != "a" {
+				continue
+			}
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = tokenizer.TagAttr()
+				if string(key) == "href" {
+					out = append(out, string(val))
+				}
+			}
+		}
+	}
+}
+
+// linkedSlugID pulls the slug ID out of an article link, either a relative /articles/<slug id>/…
+// path or the same on torontoverse.com.
+func linkedSlugID(href string) (string, bool) {
+	parsed, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return "", false
+	}
+	if host := strings.TrimPrefix(parsed.Hostname(), "www."); host != "" && host != "torontoverse.com" {
+		return "", false
+	}
+	parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "articles" || parts[1] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
@@ -0,0 +1,133 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// mapPreviewMarker prefixes map preview lines in PR bodies so they can be replaced on later
+// pushes.
+const mapPreviewMarker = "**Map preview"
+
+// MapPreviewRenderer renders the features in a locations.geojson at path to an image and returns
+// its URL.
+type MapPreviewRenderer func(ctx context.Context, push *Push, path string, fc *geojson.FeatureCollection) (string, error)
+
+// MapPreviewNotifyFunc is told about the preview images for a push, keyed by locations.geojson
+// path, eg. to post them in the Slack thread that asked for the PR.
+type MapPreviewNotifyFunc func(ctx context.Context, push *Push, images map[string]string) error
+
+// MapPreviewHook renders a preview of every locations.geojson changed by the pushed commit, adds
+// the images to the PR body and calls notify (which may be nil). Pushes that don't touch
+// locations do nothing.
+func MapPreviewHook(render MapPreviewRenderer, notify MapPreviewNotifyFunc) PushHook {
+	return func(ctx context.Context, a *App, push *Push) error {
+		paths, err := a.ChangedLocations(ctx, push.SHA)
+		if err != nil {
+			return err
+		}
+		if len(paths) == 0 {
+			return nil
+		}
+
+		images := map[string]string{}
+		for _, p := range paths {
+			content, err := a.fetchFileContent(ctx, p, push.SHA)
+			if err != nil {
+				return err
+			}
+			fc, err := geojson.UnmarshalFeatureCollection([]byte(content))
+			if err != nil {
+				return fmt.Errorf("error parsing %s: %v", p, err)
+			}
+			if len(fc.Features) == 0 {
+				continue
+			}
+			imageURL, err := render(ctx, push, p, fc)
+			if err != nil {
+				return fmt.Errorf("error rendering %s: %v", p, err)
+			}
+			if imageURL != "" {
+				images[p] = imageURL
+			}
+		}
+		if len(images) == 0 {
+			return nil
+		}
+
+		if err := a.SetPRMapPreviews(ctx, push.PR.GetNumber(), images); err != nil {
+			return err
+		}
+		if notify != nil {
+			return notify(ctx, push, images)
+		}
+		return nil
+	}
+}
+
+// ChangedLocations returns the paths of the locations.geojson files added or modified by the
+// commit sha.
+func (a *App) ChangedLocations(ctx context.Context, sha string) ([]string, error) {
+	return a.changedFiles(ctx, sha, "locations.geojson")
+}
+
+// changedFiles returns the paths of the files named name added or modified by the commit sha.
+func (a *App) changedFiles(ctx context.Context, sha, name string) ([]string, error) {
+	commit, _, err := a.Repositories.GetCommit(ctx, a.Owner, a.Repo, sha, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error getting commit: %v", err)
+	}
+	paths := []string{}
+	for _, file := range commit.Files {
+		if path.Base(file.GetFilename()) == name && file.GetStatus() != "removed" {
+			paths = append(paths, file.GetFilename())
+		}
+	}
+	return paths, nil
+}
+
+// SetPRMapPreviews adds, or replaces, map preview images in the PR body, keyed by the
+// locations.geojson they show.
+func (a *App) SetPRMapPreviews(ctx context.Context, prNum int, images map[string]string) error {
+	return a.editPRBody(ctx, prNum, func(body string) (string, bool) {
+		// Keep previews of files this push didn't touch.
+		previews := []string{}
+		lines := []string{}
+		for _, line := range strings.Split(body, "\n") {
+			if !strings.HasPrefix(line, mapPreviewMarker) {
+				lines = append(lines, line)
+				continue
+			}
+			if p := mapPreviewPath(line); p == "" || images[p] == "" {
+				previews = append(previews, line)
+			}
+		}
+		paths := []string{}
+		for p := range images {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		for _, p := range paths {
+			previews = append(previews, fmt.Sprintf("%s (`%s`):** ![Map of %s](%s)", mapPreviewMarker, p, path.Dir(p), images[p]))
+		}
+		return strings.Join(previews, "\n") + "\n\n" + strings.TrimLeft(strings.Join(lines, "\n"), "\n"), true
+	})
+}
+
+// mapPreviewPath returns the path in a map preview line, or "" if there isn't one.
+func mapPreviewPath(line string) string {
+	start := strings.Index(line, "`")
+	if start < 0 {
+		return ""
+	}
+	end := strings.Index(line[start+1:], "`")
+	if end < 0 {
+		return ""
+	}
+	return line[start+1 : start+1+end]
+}
@@ -0,0 +1,55 @@
+package github
+
+import (
+	"sync"
+
+	gh "github.com/google/go-github/v53/github"
+)
+
+// maxParallelFormats bounds how many files are formatted at once. Each is a prettier process,
+// so this caps the node processes a single PR build starts.
+const maxParallelFormats = 4
+
+// entryBuilder formats and returns the tree entries for one or more files.
+type entryBuilder func() ([]*gh.TreeEntry, error)
+
+// buildEntries runs builders concurrently, up to maxParallelFormats at a time, and returns
+// their entries in the order the builders were given. The first builder to fail, in that order,
+// decides the error.
+func buildEntries(builders []entryBuilder) ([]*gh.TreeEntry, error) {
+	results := make([][]*gh.TreeEntry, len(builders))
+	errs := make([]error, len(builders))
+
+	sem := make(chan struct{}, maxParallelFormats)
+	var wg sync.WaitGroup
+	for i, build := range builders {
+		wg.Add(1)
+		go func(i int, build entryBuilder) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i], errs[i] = build()
+		}(i, build)
+	}
+	wg.Wait()
+
+	treeEntries := []*gh.TreeEntry{}
+	for i := range builders {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		treeEntries = append(treeEntries, results[i]...)
+	}
+	return treeEntries, nil
+}
+
+// one adapts a builder of a single entry.
+func one(build func() (*gh.TreeEntry, error)) entryBuilder {
+	return func() ([]*gh.TreeEntry, error) {
+		entry, err := build()
+		if err != nil {
+			return nil, err
+		}
+		return []*gh.TreeEntry{entry}, nil
+	}
+}
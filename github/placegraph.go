@@ -0,0 +1,129 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/geomodulus/citygraph"
+	gh "github.com/google/go-github/v53/github"
+	geojson "github.com/paulmach/go.geojson"
+)
+
+// WritePlaceToGraph upserts checkout's place into the citygraph store: its vertex, if it doesn't
+// have one yet, every field of its poi.json, its body, and its location as a GeoJSON point
+// feature, which is what the live map draws.
+func WritePlaceToGraph(ctx context.Context, graph citygraph.GraphClient, checkout *PlaceCheckout) error {
+	place := checkout.Place
+	id, err := place.UUID()
+	if err != nil {
+		return fmt.Errorf("error parsing place ID %q: %v", place.ID, err)
+	}
+	q, err := place.VertexQuery()
+	if err != nil {
+		return fmt.Errorf("error building vertex query: %v", err)
+	}
+	vertices, err := graph.GetVertices(ctx, q)
+	if err != nil {
+		return fmt.Errorf("error getting place vertex: %v", err)
+	}
+	if len(vertices) == 0 {
+		if err := graph.CreateVertex(ctx, citygraph.UUID(id), &citygraph.PlaceType); err != nil {
+			return fmt.Errorf("error creating place vertex: %v", err)
+		}
+	}
+
+	// Every field of poi.json is written under its own name, so the map has all the place has.
+	content, err := json.Marshal(placeJSON{
+		Place:           place,
+		RelatedArticles: checkout.RelatedArticles,
+		Attributes:      checkout.Attributes,
+	})
+	if err != nil {
+		return fmt.Errorf("error marshaling %s: %v", checkout.Slug, err)
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return fmt.Errorf("error unmarshaling %s: %v", checkout.Slug, err)
+	}
+	properties := map[string]any{}
+	for name, value := range fields {
+		properties[name] = value
+	}
+	// Fields left out when empty are written anyway, so clearing one clears it on the map.
+	properties["status"] = place.Status
+	properties["images"] = place.Images
+	properties["related_articles"] = checkout.RelatedArticles
+	properties["attributes"] = checkout.Attributes
+
+	properties[citygraph.PropertyNameDisplayName] = place.Name
+	properties["slug"] = checkout.Slug
+	properties["slug_title"] = place.SlugTitle()
+	properties[citygraph.PropertyNameBodyText] = checkout.BodyHTML
+	properties[citygraph.PropertyNameGeoJSONFeature] = placeFeature(checkout)
+
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := graph.SetVertexProperties(ctx, q, name, properties[name]); err != nil {
+			return fmt.Errorf("error setting %s of %s: %v", name, checkout.Slug, err)
+		}
+	}
+	return nil
+}
+
+// RemovePlaceFromGraph deletes a place's vertex from the citygraph store, so the live map stops
+// drawing it.
+func RemovePlaceFromGraph(ctx context.Context, graph citygraph.GraphClient, place *citygraph.Place) error {
+	q, err := place.VertexQuery()
+	if err != nil {
+		return fmt.Errorf("error building vertex query: %v", err)
+	}
+	if err := graph.DeleteVertices(ctx, q); err != nil {
+		return fmt.Errorf("error deleting place vertex: %v", err)
+	}
+	return nil
+}
+
+// RemovedPlace reports whether the merged pr deleted or moved the place at slug. If it did, the
+// place is returned as it was before the merge; if it's still on main, RemovedPlace returns nil.
+func (a *App) RemovedPlace(ctx context.Context, slug string, pr *gh.PullRequest) (*citygraph.Place, error) {
+	jsonPath := "active_places/" + slug + "/poi.json"
+	_, ok, err := a.currentFile(ctx, jsonPath, 0)
+	if err != nil || ok {
+		return nil, err
+	}
+	before, err := a.beforeMerge(ctx, pr)
+	if err != nil {
+		return nil, err
+	}
+	content, err := a.fetchFileContent(ctx, jsonPath, before)
+	if err != nil {
+		return nil, err
+	}
+	place := &citygraph.Place{}
+	if err := json.Unmarshal([]byte(content), &placeJSON{Place: place}); err != nil {
+		return nil, fmt.Errorf("error unmarshaling %s: %v", jsonPath, err)
+	}
+	return place, nil
+}
+
+// placeFeature is the point the map draws for a place, with what its popup and marker need.
+func placeFeature(checkout *PlaceCheckout) *geojson.Feature {
+	place := checkout.Place
+	feature := geojson.NewPointFeature([]float64{place.Location.Lng, place.Location.Lat})
+	feature.ID = place.ID
+	feature.SetProperty("slug", checkout.Slug)
+	feature.SetProperty("name", place.Name)
+	feature.SetProperty("type", place.Type)
+	feature.SetProperty("sprite", place.Sprite)
+	feature.SetProperty("address", place.Address)
+	if place.Status != "" {
+		feature.SetProperty("status", place.Status)
+	}
+	return feature
+}
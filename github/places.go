@@ -4,10 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	gh "github.com/google/go-github/v53/github"
 
 	"github.com/geomodulus/citygraph"
+	"github.com/geomodulus/robots/osm"
 	"github.com/geomodulus/robots/prettier"
 )
 
@@ -16,6 +19,18 @@ type PlaceCheckout struct {
 	Slug     string
 	Place    *citygraph.Place
 	BodyHTML string
+	// RelatedArticles holds the IDs of articles that feature this place.
+	RelatedArticles []string
+	// Attributes holds extra place details, such as opening hours, and where each came from.
+	Attributes map[string]*osm.Attribute
+}
+
+// placeJSON is the on-disk shape of poi.json: the citygraph place plus fields only the content
+// repo cares about.
+type placeJSON struct {
+	*citygraph.Place
+	RelatedArticles []string                  `json:"related_articles,omitempty"`
+	Attributes      map[string]*osm.Attribute `json:"attributes,omitempty"`
 }
 
 func (a *App) FetchPlace(ctx context.Context, slug string) (*PlaceCheckout, error) {
@@ -41,10 +56,13 @@ func (a *App) FetchPlace(ctx context.Context, slug string) (*PlaceCheckout, erro
 		return nil, fmt.Errorf("error decoding file content: %v", err)
 	}
 	place := &citygraph.Place{}
-	if err := json.Unmarshal([]byte(content), &place); err != nil {
+	stored := placeJSON{Place: place}
+	if err := json.Unmarshal([]byte(content), &stored); err != nil {
 		return nil, fmt.Errorf("error unmarshaling place: %v", err)
 	}
 	res.Place = place
+	res.RelatedArticles = stored.RelatedArticles
+	res.Attributes = stored.Attributes
 
 	htmlPath := "active_places/" + slug + "/body.html"
 	htmlFile, _, _, err := a.Repositories.GetContents(ctx, a.Owner, a.Repo, htmlPath, &gh.RepositoryContentGetOptions{Ref: branchCommitSHA})
@@ -60,128 +78,145 @@ func (a *App) FetchPlace(ctx context.Context, slug string) (*PlaceCheckout, erro
 	return res, nil
 }
 
-func (a *App) CreateOrUpdatePlacePullRequest(ctx context.Context, slug string, opts ...Option) (int, string, error) {
-	var (
-		prBranchRef *gh.Reference
-		activePR    *gh.PullRequest
-		err         error
-	)
-
-	params := Params{
-		PRBody: "This PR was created dynamically.",
+// ListPlaces reads every poi.json under active_places/ on the main branch. Bodies aren't
+// fetched.
+func (a *App) ListPlaces(ctx context.Context) ([]*PlaceCheckout, error) {
+	sha, err := a.mainSHA(ctx)
+	if err != nil {
+		return nil, err
 	}
-	for _, opt := range opts {
-		opt(&params)
+	tree, _, err := a.Git.GetTree(ctx, a.Owner, a.Repo, sha, true)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tree: %v", err)
 	}
 
-	if params.PRNum == 0 {
-		// No PR exists, create one
-		prBranchRef, err = a.newBranchRef(ctx)
-		if err != nil {
-			return 0, "", fmt.Errorf("error creating new branch: %v", err)
+	places := []*PlaceCheckout{}
+	for _, entry := range tree.Entries {
+		parts := strings.Split(entry.GetPath(), "/")
+		if len(parts) != 3 || parts[0] != "active_places" || parts[2] != "poi.json" {
+			continue
 		}
-	} else {
-		// PR exists, check if it's been merged
-		pr, _, err := a.PullRequests.Get(ctx, a.Owner, a.Repo, params.PRNum)
+		content, _, err := a.Git.GetBlobRaw(ctx, a.Owner, a.Repo, entry.GetSHA())
 		if err != nil {
-			return 0, "", fmt.Errorf("error getting PR: %v", err)
+			return nil, fmt.Errorf("error getting %s: %v", entry.GetPath(), err)
 		}
-		if *pr.State == "closed" {
-			// Prior PR has been closed so, create a new one.
-			prBranchRef, err = a.newBranchRef(ctx)
-			if err != nil {
-				return 0, "", fmt.Errorf("error creating new branch: %v", err)
-			}
-		} else {
-			// PR still active, needs to be updated.
-			prBranchRef, _, err = a.Git.GetRef(ctx, a.Owner, a.Repo, "refs/heads/"+pr.GetHead().GetRef())
-			if err != nil {
-				return 0, "", err
-			}
-			activePR = pr
+		place := &citygraph.Place{}
+		stored := placeJSON{Place: place}
+		if err := json.Unmarshal(content, &stored); err != nil {
+			return nil, fmt.Errorf("error unmarshaling %s: %v", entry.GetPath(), err)
 		}
-	}
+		places = append(places, &PlaceCheckout{
+			Slug:            parts[1],
+			Place:           place,
+			RelatedArticles: stored.RelatedArticles,
+			Attributes:      stored.Attributes,
+		})
+	}
+	return places, nil
+}
 
-	treeEntries := []*gh.TreeEntry{}
+func (a *App) CreateOrUpdatePlacePullRequest(ctx context.Context, slug string, opts ...Option) (int, string, error) {
+	params := Params{}
+	for _, opt := range opts {
+		opt(&params)
+	}
+	if err := a.carryOverPlace(ctx, "active_places/"+slug, params.PRNum, &params); err != nil {
+		return 0, "", err
+	}
 
-	if params.Place != nil {
-		// articles.json
-		jsonPath := "active_places/" + slug + "/poi.json"
-		jsonFileContent, err := json.MarshalIndent(params.Place, "", "  ")
-		if err != nil {
-			return 0, "", fmt.Errorf("error marshaling json: %v", err)
-		}
-		prettyJSONFileContent, err := prettier.Format(string(jsonFileContent), jsonPath)
-		if err != nil {
-			return 0, "", fmt.Errorf("error formatting json: %v", err)
-		}
-		jsonTreeEntry := &gh.TreeEntry{
-			Path:    gh.String(jsonPath),
-			Mode:    gh.String("100644"),
-			Type:    gh.String("blob"),
-			Content: gh.String(string(prettyJSONFileContent)),
-		}
-		treeEntries = append(treeEntries, jsonTreeEntry)
+	if err := enrichPlace(ctx, &params); err != nil {
+		return 0, "", err
 	}
 
-	if params.BodyHTML != "" {
-		// articles.html
-		htmlPath := "active_places/" + slug + "/body.html"
-		prettyBody, err := prettier.Format(params.BodyHTML, htmlPath)
+	fallbacks := prettier.Fallbacks()
+	treeEntries, err := placeTreeEntriesFromParams("active_places/"+slug, params)
+	if err != nil {
+		return 0, "", fmt.Errorf("error creating tree entries: %w", err)
+	}
+	if params.PRBody == "" {
+		body, err := a.renderPRBody(slug, params, prettier.Fallbacks() != fallbacks)
 		if err != nil {
-			return 0, "", fmt.Errorf("error formatting html: %v\n\noffending html:\n%s", err, params.BodyHTML)
+			return 0, "", err
 		}
-		htmlTreeEntry := &gh.TreeEntry{
-			Path:    gh.String(htmlPath),
-			Mode:    gh.String("100644"),
-			Type:    gh.String("blob"),
-			Content: gh.String(prettyBody),
-		}
-		treeEntries = append(treeEntries, htmlTreeEntry)
+		params.PRBody = body
 	}
 
-	// Commit the changes.
-	baseSHA := prBranchRef.GetObject().GetSHA()
-	tree, _, err := a.Git.CreateTree(ctx, a.Owner, a.Repo, baseSHA, treeEntries)
+	activePR, _, err := a.commitToPullRequest(ctx, treeEntries, params)
 	if err != nil {
-		return 0, "", fmt.Errorf("error creating tree: %v", err)
+		return 0, "", err
 	}
-	parentCommit, _, err := a.Git.GetCommit(ctx, a.Owner, a.Repo, baseSHA)
-	if err != nil {
-		return 0, "", fmt.Errorf("error getting commit: %v", err)
+
+	return activePR.GetNumber(), activePR.GetHTMLURL(), nil
+}
+
+// enrichPlace merges OSM attributes into params when enrichment was requested with
+// WithOSMEnrichment. A place that can't be found in OSM is left as is.
+func enrichPlace(ctx context.Context, params *Params) error {
+	if params.OSM == nil || params.Place == nil {
+		return nil
 	}
-	commit, _, err := a.Git.CreateCommit(ctx, a.Owner, a.Repo, &gh.Commit{
-		Message: gh.String(params.PRTitle),
-		Tree:    tree,
-		Parents: []*gh.Commit{parentCommit},
-	})
+	el, err := params.OSM.FindPlace(ctx, params.Place, DefaultMatchRadius)
 	if err != nil {
-		return 0, "", fmt.Errorf("error creating commit: %v", err)
+		return fmt.Errorf("error looking up %q in OpenStreetMap: %w", params.Place.Name, err)
 	}
+	if el == nil {
+		return nil
+	}
+	params.Attributes = osm.Merge(params.Attributes, osm.Attributes(el, time.Now()))
+	if params.Place.URL == "" && params.Attributes["website"] != nil {
+		params.Place.URL = params.Attributes["website"].Value
+	}
+	return nil
+}
 
-	// Add commit to the new branch.
-	prBranchRef.Object.SHA = commit.SHA
-
-	_, _, err = a.Git.UpdateRef(ctx, a.Owner, a.Repo, prBranchRef, false)
-	if err != nil {
-		return 0, "", fmt.Errorf("error updating reference: %v", err)
+func placeTreeEntriesFromParams(path string, params Params) ([]*gh.TreeEntry, error) {
+	if err := guardBodyHTML(params); err != nil {
+		return nil, err
 	}
 
-	if activePR == nil {
-		// Create a pull request
-		newPR := &gh.NewPullRequest{
-			Title:               gh.String(params.PRTitle),
-			Head:                gh.String(prBranchRef.GetRef()),
-			Base:                gh.String("main"),
-			Body:                gh.String(params.PRBody),
-			MaintainerCanModify: gh.Bool(true),
-		}
+	builders := []entryBuilder{}
 
-		activePR, err = a.createPRWithRetry(ctx, newPR, 10)
-		if err != nil {
-			return 0, "", fmt.Errorf("error creating PR: %v", err)
-		}
+	if params.Place != nil {
+		// poi.json
+		builders = append(builders, one(func() (*gh.TreeEntry, error) {
+			jsonPath := path + "/poi.json"
+			jsonFileContent, err := json.MarshalIndent(placeJSON{
+				Place:           params.Place,
+				RelatedArticles: params.RelatedArticles,
+				Attributes:      params.Attributes,
+			}, "", "  ")
+			if err != nil {
+				return nil, fmt.Errorf("error marshaling json: %v", err)
+			}
+			prettyJSONFileContent, err := prettier.Format(string(jsonFileContent), jsonPath)
+			if err != nil {
+				return nil, fmt.Errorf("error formatting json: %v", err)
+			}
+			return &gh.TreeEntry{
+				Path:    gh.String(jsonPath),
+				Mode:    gh.String("100644"),
+				Type:    gh.String("blob"),
+				Content: gh.String(string(prettyJSONFileContent)),
+			}, nil
+		}))
 	}
 
-	return activePR.GetNumber(), activePR.GetHTMLURL(), nil
+	if params.BodyHTML != "" {
+		// body.html
+		builders = append(builders, one(func() (*gh.TreeEntry, error) {
+			htmlPath := path + "/body.html"
+			prettyBody, err := prettier.Format(params.BodyHTML, htmlPath)
+			if err != nil {
+				return nil, fmt.Errorf("error formatting html: %v\n\noffending html:\n%s", err, params.BodyHTML)
+			}
+			return &gh.TreeEntry{
+				Path:    gh.String(htmlPath),
+				Mode:    gh.String("100644"),
+				Type:    gh.String("blob"),
+				Content: gh.String(prettyBody),
+			}, nil
+		}))
+	}
+
+	return buildEntries(builders)
 }
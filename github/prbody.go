@@ -0,0 +1,195 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	gh "github.com/google/go-github/v53/github"
+
+	"github.com/geomodulus/robots/reqid"
+)
+
+// Markers around the validation section of a templated PR body, so ValidationHook can replace
+// it on each push.
+const (
+	validationStart = "<!-- robots:validation -->"
+	validationEnd   = "<!-- /robots:validation -->"
+)
+
+// PRBodyData is what a PR body template is rendered with.
+type PRBodyData struct {
+	// Kind is "Article" or "Place".
+	Kind string
+	Slug string
+	// Summary and Details come from the commit message, or the PR title if there isn't one.
+	Summary string
+	Details string
+	// Checks are the validations run before the commit, eg. the HTML guard.
+	Checks    []string
+	Checklist []string
+	// ValidationStart and ValidationEnd must surround the validation section for ValidationHook
+	// to fill it in.
+	ValidationStart string
+	ValidationEnd   string
+	// PreviewMarker starts the preview line, which PreviewHook fills in.
+	PreviewMarker string
+}
+
+// DefaultPRBodyTemplate is used for new article and place PRs unless the App has its own
+// PRBodyTemplate or the call passes WithPRBody.
+var DefaultPRBodyTemplate = template.Must(template.New("pr_body").Parse(
+	`**{{.Kind}}:** ` + "`{{.Slug}}`" + `
+
+{{.Summary}}
+{{- with .Details}}
+
+{{.}}
+{{- end}}
+
+{{.PreviewMarker}}_waiting for deployment_
+
+### Validation
+{{range .Checks}}
+- :white_check_mark: {{.}}
+{{- end}}
+{{.ValidationStart}}
+_Waiting for builds._
+{{.ValidationEnd}}
+
+### Editor checklist
+{{range .Checklist}}
+- [ ] {{.}}
+{{- end}}
+`))
+
+// Default editor checklists, by kind.
+var (
+	ArticleChecklist = []string{
+		"Headline and dek read well and fit",
+		"Names, numbers and quotes checked against sources",
+		"Images have alt text and credits",
+		"Map and locations look right in the preview",
+		"Categories and authors are set",
+	}
+	PlaceChecklist = []string{
+		"Name, address and phone number are correct",
+		"Marker is in the right spot in the preview",
+		"Hours, status and links are current",
+	}
+)
+
+// renderPRBody fills in the App's PR body template for a new PR on slug. fellBack says the files
+// were formatted without prettier.
+func (a *App) renderPRBody(slug string, params Params, fellBack bool) (string, error) {
+	data := PRBodyData{
+		Kind:            "Article",
+		Slug:            slug,
+		Summary:         params.PRTitle,
+		Checklist:       ArticleChecklist,
+		ValidationStart: validationStart,
+		ValidationEnd:   validationEnd,
+		PreviewMarker:   previewMarker,
+	}
+	if params.Place != nil && params.Article == nil {
+		data.Kind = "Place"
+		data.Checklist = PlaceChecklist
+	}
+	if msg, err := ParseCommitMessage(params.CommitMessage); err == nil {
+		data.Summary = msg.Summary
+		data.Details = msg.Body
+	} else if params.CommitMessage != "" {
+		data.Summary = params.CommitMessage
+	}
+	if params.HTMLGuard != nil && params.BodyHTML != "" {
+		data.Checks = append(data.Checks, "HTML allowed by the guard")
+	}
+	if (params.BodyHTML != "" || params.ArticleJS != "") && fellBack {
+		data.Checks = append(data.Checks, "Formatted with the fallback formatter, since prettier was unavailable")
+	} else if params.BodyHTML != "" || params.ArticleJS != "" {
+		data.Checks = append(data.Checks, "Formatted with prettier")
+	}
+
+	tmpl := a.PRBodyTemplate
+	if tmpl == nil {
+		tmpl = DefaultPRBodyTemplate
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("error rendering PR body: %v", err)
+	}
+	return b.String(), nil
+}
+
+// ValidationHook waits, in the background, up to timeout for the pushed commit's workflow runs,
+// then writes their results into the validation section of the PR body. PRs whose body wasn't
+// templated are left alone.
+func ValidationHook(timeout time.Duration) PushHook {
+	return func(pushCtx context.Context, a *App, push *Push) error {
+		go func() {
+			ctx, cancel := context.WithTimeout(reqid.Detach(pushCtx), timeout)
+			defer cancel()
+
+			runs, err := a.WaitForWorkflowRuns(ctx, push.SHA)
+			if err != nil {
+				reqid.Printf(ctx, "builds for PR #%d didn't finish: %v", push.PR.GetNumber(), err)
+				return
+			}
+			lines := []string{}
+			for _, run := range runs {
+				icon := ":white_check_mark:"
+				if run.GetConclusion() != "success" {
+					icon = ":x:"
+				}
+				lines = append(lines, fmt.Sprintf("- %s [%s](%s): %s", icon, run.GetName(), run.GetHTMLURL(), run.GetConclusion()))
+			}
+			section := fmt.Sprintf("Builds for %.7s:\n%s", push.SHA, strings.Join(lines, "\n"))
+			if err := a.SetPRValidation(ctx, push.PR.GetNumber(), section); err != nil {
+				reqid.Printf(ctx, "error adding validation to PR #%d: %v", push.PR.GetNumber(), err)
+			}
+		}()
+		return nil
+	}
+}
+
+// SetPRValidation replaces the validation section of a templated PR body with section.
+func (a *App) SetPRValidation(ctx context.Context, prNum int, section string) error {
+	return a.editPRBody(ctx, prNum, func(body string) (string, bool) {
+		before, rest, ok := strings.Cut(body, validationStart)
+		_, after, ok2 := strings.Cut(rest, validationEnd)
+		if !ok || !ok2 {
+			return "", false
+		}
+		return before + validationStart + "\n" + section + "\n" + validationEnd + after, true
+	})
+}
+
+// prBodyLocks holds a mutex per PR, by owner/repo#number, so the hooks that fill in sections of
+// a PR body in the background don't overwrite each other's edits.
+var prBodyLocks sync.Map
+
+// editPRBody replaces a PR's body with what edit makes of it, one edit per PR at a time. edit
+// returns false to leave the body alone.
+func (a *App) editPRBody(ctx context.Context, prNum int, edit func(body string) (string, bool)) error {
+	lock, _ := prBodyLocks.LoadOrStore(fmt.Sprintf("%s/%s#%d", a.Owner, a.Repo, prNum), &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	defer mu.Unlock()
+
+	pr, _, err := a.PullRequests.Get(ctx, a.Owner, a.Repo, prNum)
+	if err != nil {
+		return fmt.Errorf("error getting PR: %v", err)
+	}
+	body, ok := edit(pr.GetBody())
+	if !ok {
+		return nil
+	}
+	if _, _, err := a.scoped(PullRequestScope).PullRequests.Edit(ctx, a.Owner, a.Repo, prNum, &gh.PullRequest{Body: gh.String(body)}); err != nil {
+		return fmt.Errorf("error editing PR: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	gh "github.com/google/go-github/v53/github"
+
+	"github.com/geomodulus/robots/reqid"
+)
+
+// previewMarker prefixes the preview line in PR bodies so it can be replaced on later pushes.
+const previewMarker = "**Preview:** "
+
+// previewPollInterval is how often WaitForPreviewURL checks for a deployment.
+var previewPollInterval = 15 * time.Second
+
+// PreviewNotifyFunc is told about a preview deployment once it's ready, eg. to post it in the
+// Slack thread that asked for the PR.
+type PreviewNotifyFunc func(ctx context.Context, push *Push, previewURL string) error
+
+// PreviewHook waits, in the background, up to timeout for the pushed commit's preview
+// deployment, then adds its URL to the PR body and calls notify (which may be nil).
+func PreviewHook(timeout time.Duration, notify PreviewNotifyFunc) PushHook {
+	return func(pushCtx context.Context, a *App, push *Push) error {
+		go func() {
+			// The request that pushed has usually finished long before the preview is ready.
+			ctx, cancel := context.WithTimeout(reqid.Detach(pushCtx), timeout)
+			defer cancel()
+
+			previewURL, err := a.WaitForPreviewURL(ctx, push.SHA)
+			if err != nil {
+				reqid.Printf(ctx, "no preview for PR #%d: %v", push.PR.GetNumber(), err)
+				return
+			}
+			if err := a.SetPRPreviewURL(ctx, push.PR.GetNumber(), previewURL); err != nil {
+				reqid.Printf(ctx, "error adding preview to PR #%d: %v", push.PR.GetNumber(), err)
+			}
+			if notify != nil {
+				if err := notify(ctx, push, previewURL); err != nil {
+					reqid.Printf(ctx, "error announcing preview for PR #%d: %v", push.PR.GetNumber(), err)
+				}
+			}
+		}()
+		return nil
+	}
+}
+
+// WaitForPreviewURL polls until a preview deployment for sha succeeds, or ctx is done.
+func (a *App) WaitForPreviewURL(ctx context.Context, sha string) (string, error) {
+	for {
+		previewURL, err := a.PreviewURL(ctx, sha)
+		if err != nil {
+			return "", err
+		}
+		if previewURL != "" {
+			return previewURL, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(previewPollInterval):
+		}
+	}
+}
+
+// PreviewURL returns the URL of a successful preview deployment for sha, or "" if there isn't
+// one yet. Both the deployments API and commit statuses (as used by hosts like Netlify) are
+// checked.
+func (a *App) PreviewURL(ctx context.Context, sha string) (string, error) {
+	deployments, _, err := a.Repositories.ListDeployments(ctx, a.Owner, a.Repo, &gh.DeploymentsListOptions{SHA: sha})
+	if err != nil {
+		return "", fmt.Errorf("error listing deployments: %v", err)
+	}
+	for _, deployment := range deployments {
+		statuses, _, err := a.Repositories.ListDeploymentStatuses(ctx, a.Owner, a.Repo, deployment.GetID(), nil)
+		if err != nil {
+			return "", fmt.Errorf("error listing deployment statuses: %v", err)
+		}
+		for _, status := range statuses {
+			if status.GetState() == "success" && status.GetEnvironmentURL() != "" {
+				return status.GetEnvironmentURL(), nil
+			}
+		}
+	}
+
+	combined, _, err := a.Repositories.GetCombinedStatus(ctx, a.Owner, a.Repo, sha, nil)
+	if err != nil {
+		return "", fmt.Errorf("error getting commit status: %v", err)
+	}
+	for _, status := range combined.Statuses {
+		name := strings.ToLower(status.GetContext())
+		if status.GetState() == "success" && status.GetTargetURL() != "" &&
+			(strings.Contains(name, "preview") || strings.Contains(name, "deploy")) {
+			return status.GetTargetURL(), nil
+		}
+	}
+	return "", nil
+}
+
+// SetPRPreviewURL replaces the preview line in the PR body, or adds one at the top if there
+// isn't one.
+func (a *App) SetPRPreviewURL(ctx context.Context, prNum int, previewURL string) error {
+	return a.editPRBody(ctx, prNum, func(body string) (string, bool) {
+		lines := strings.Split(body, "\n")
+		replaced := false
+		for i, line := range lines {
+			if strings.HasPrefix(line, previewMarker) {
+				lines[i] = previewMarker + previewURL
+				replaced = true
+			}
+		}
+		body = strings.Join(lines, "\n")
+		if !replaced {
+			body = previewMarker + previewURL + "\n\n" + strings.TrimLeft(body, "\n")
+		}
+		return body, true
+	})
+}
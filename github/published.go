@@ -0,0 +1,52 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	gh "github.com/google/go-github/v53/github"
+
+	"github.com/geomodulus/citygraph"
+)
+
+// PublishedArticle reports whether the merged pr published the article at slug: it's live on
+// main and wasn't before the merge. If it did, the article is returned as it is on main;
+// otherwise PublishedArticle returns nil.
+func (a *App) PublishedArticle(ctx context.Context, slug string, pr *gh.PullRequest) (*citygraph.Article, error) {
+	jsonPath := "articles/" + slug + "/article.json"
+	article, err := a.articleAt(ctx, jsonPath, "main")
+	if err != nil || article == nil || !isPublished(article) {
+		return nil, err
+	}
+	before, err := a.beforeMerge(ctx, pr)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := a.articleAt(ctx, jsonPath, before)
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil && isPublished(previous) {
+		return nil, nil
+	}
+	return article, nil
+}
+
+// articleAt returns the article.json at jsonPath as of ref, or nil if there isn't one.
+func (a *App) articleAt(ctx context.Context, jsonPath, ref string) (*citygraph.Article, error) {
+	content, ok, err := a.fileAt(ctx, jsonPath, ref)
+	if err != nil || !ok {
+		return nil, err
+	}
+	article := &citygraph.Article{}
+	if err := json.Unmarshal([]byte(content), &articleJSON{Article: article}); err != nil {
+		return nil, fmt.Errorf("error unmarshaling %s: %v", jsonPath, err)
+	}
+	return article, nil
+}
+
+// isPublished reports whether an article is live on the site.
+func isPublished(article *citygraph.Article) bool {
+	return article.IsLive && article.PubDate != ""
+}
@@ -0,0 +1,114 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	gh "github.com/google/go-github/v53/github"
+)
+
+// PullRequestActivity is what happened to the repo's pull requests over a period.
+type PullRequestActivity struct {
+	Opened []*gh.PullRequest
+	Merged []*gh.PullRequest
+}
+
+// PullRequestActivity lists the pull requests opened or merged in [from, to).
+func (a *App) PullRequestActivity(ctx context.Context, from, to time.Time) (*PullRequestActivity, error) {
+	activity := &PullRequestActivity{}
+	opts := &gh.PullRequestListOptions{
+		State:       "all",
+		Sort:        "updated",
+		Direction:   "desc",
+		ListOptions: gh.ListOptions{PerPage: 100},
+	}
+	within := func(t gh.Timestamp) bool {
+		return !t.IsZero() && !t.Before(from) && t.Before(to)
+	}
+	for {
+		prs, resp, err := a.PullRequests.List(ctx, a.Owner, a.Repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("error listing pull requests: %v", err)
+		}
+		for _, pr := range prs {
+			// Sorted by last update, so once one predates the period, the rest do too.
+			if pr.GetUpdatedAt().Before(from) {
+				return activity, nil
+			}
+			if within(pr.GetCreatedAt()) {
+				activity.Opened = append(activity.Opened, pr)
+			}
+			if within(pr.GetMergedAt()) {
+				activity.Merged = append(activity.Merged, pr)
+			}
+		}
+		if resp.NextPage == 0 {
+			return activity, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// beforeMerge returns the commit main was at before the merged pr, the first parent of its merge
+// commit.
+func (a *App) beforeMerge(ctx context.Context, pr *gh.PullRequest) (string, error) {
+	merge, _, err := a.Git.GetCommit(ctx, a.Owner, a.Repo, pr.GetMergeCommitSHA())
+	if err != nil {
+		return "", fmt.Errorf("error getting merge commit of PR #%d: %v", pr.GetNumber(), err)
+	}
+	if len(merge.Parents) == 0 {
+		return "", fmt.Errorf("merge commit of PR #%d has no parent", pr.GetNumber())
+	}
+	return merge.Parents[0].GetSHA(), nil
+}
+
+// OpenPullRequestsByDir maps each directory under dir, eg. a place's slug under
+// "active_places", to the open pull requests changing it, newest first.
+func (a *App) OpenPullRequestsByDir(ctx context.Context, dir string) (map[string][]*gh.PullRequest, error) {
+	byDir := map[string][]*gh.PullRequest{}
+	opts := &gh.PullRequestListOptions{State: "open", ListOptions: gh.ListOptions{PerPage: 100}}
+	for {
+		prs, resp, err := a.PullRequests.List(ctx, a.Owner, a.Repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("error listing pull requests: %v", err)
+		}
+		for _, pr := range prs {
+			names, err := a.ChangedDirs(ctx, pr.GetNumber(), dir)
+			if err != nil {
+				return nil, err
+			}
+			for _, name := range names {
+				byDir[name] = append(byDir[name], pr)
+			}
+		}
+		if resp.NextPage == 0 {
+			return byDir, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// ChangedDirs lists the directories under dir, eg. places' slugs under "active_places", that PR
+// prNum changes, in the order its files are listed.
+func (a *App) ChangedDirs(ctx context.Context, prNum int, dir string) ([]string, error) {
+	files, _, err := a.PullRequests.ListFiles(ctx, a.Owner, a.Repo, prNum, &gh.ListOptions{PerPage: 100})
+	if err != nil {
+		return nil, fmt.Errorf("error listing files of PR #%d: %v", prNum, err)
+	}
+	names := []string{}
+	seen := map[string]bool{}
+	for _, file := range files {
+		rest, ok := strings.CutPrefix(file.GetFilename(), dir+"/")
+		if !ok {
+			continue
+		}
+		name, _, _ := strings.Cut(rest, "/")
+		if !seen[name] {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+	return names, nil
+}
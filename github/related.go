@@ -0,0 +1,219 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+
+	gh "github.com/google/go-github/v53/github"
+	"github.com/paulmach/go.geojson"
+
+	"github.com/geomodulus/citygraph"
+	"github.com/geomodulus/robots/prettier"
+)
+
+// DefaultMatchRadius is how close, in metres, a location must be to a place to match it.
+const DefaultMatchRadius = 40.0
+
+// RelatedPlacesResult describes the PRs opened by LinkRelatedPlaces.
+type RelatedPlacesResult struct {
+	Matches []*PlaceCheckout
+
+	ArticlePRNum int
+	ArticlePRURL string
+	PlacesPRNum  int
+	PlacesPRURL  string
+}
+
+// MatchPlaces returns the places that correspond to features in the collection, either by name
+// or because a point feature sits within radius metres of the place.
+func MatchPlaces(fc *geojson.FeatureCollection, places []*PlaceCheckout, radius float64) []*PlaceCheckout {
+	matches := []*PlaceCheckout{}
+	if fc == nil {
+		return matches
+	}
+
+	seen := map[string]bool{}
+	for _, feature := range fc.Features {
+		name := normalizeName(featureName(feature))
+
+		for _, place := range places {
+			if seen[place.Place.ID] {
+				continue
+			}
+			matched := name != "" && (name == normalizeName(place.Place.Name) || name == normalizeName(place.Place.ShortName))
+			if !matched && feature.Geometry != nil && feature.Geometry.IsPoint() && len(feature.Geometry.Point) >= 2 {
+				loc := citygraph.LngLat{Lng: feature.Geometry.Point[0], Lat: feature.Geometry.Point[1]}
+				matched = distanceMeters(loc, place.Place.Location) <= radius
+			}
+			if matched {
+				seen[place.Place.ID] = true
+				matches = append(matches, place)
+			}
+		}
+	}
+	return matches
+}
+
+// LinkRelatedPlaces matches the article's locations.geojson against every place in the places
+// repo, then opens one PR in each repo: one recording the places in article.json's
+// related_places, and one recording the article in each place's related_articles.
+func LinkRelatedPlaces(ctx context.Context, articles, places *App, slug string) (*RelatedPlacesResult, error) {
+	checkout, err := articles.FetchArticle(ctx, slug)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching article: %w", err)
+	}
+	if checkout.LocationsGeoJSON == nil {
+		return nil, fmt.Errorf("article %q has no locations dataset", slug)
+	}
+
+	allPlaces, err := places.ListPlaces(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing places: %w", err)
+	}
+
+	res := &RelatedPlacesResult{
+		Matches: MatchPlaces(checkout.LocationsGeoJSON, allPlaces, DefaultMatchRadius),
+	}
+	if len(res.Matches) == 0 {
+		return res, nil
+	}
+
+	placeIDs := []string{}
+	for _, place := range res.Matches {
+		placeIDs = append(placeIDs, place.Place.ID)
+	}
+
+	res.ArticlePRNum, res.ArticlePRURL, err = articles.SetArticleRelatedPlaces(ctx, checkout, placeIDs,
+		WithPRTitle("Link related places: "+checkout.Article.Name),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error updating article: %w", err)
+	}
+
+	res.PlacesPRNum, res.PlacesPRURL, err = places.AddRelatedArticle(ctx, checkout.Article.ID, res.Matches,
+		WithPRTitle("Link article: "+checkout.Article.Name),
+		WithPRBody("Links places featured in "+res.ArticlePRURL),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error updating places: %w", err)
+	}
+
+	return res, nil
+}
+
+// SetArticleRelatedPlaces adds placeIDs to the article's related_places and opens a PR.
+func (a *App) SetArticleRelatedPlaces(ctx context.Context, checkout *ArticleCheckout, placeIDs []string, opts ...Option) (int, string, error) {
+	params := Params{
+		PRTitle: "Link related places: " + checkout.Article.Name,
+	}
+	for _, opt := range opts {
+		opt(&params)
+	}
+	params.Article = checkout.Article
+	params.BaseArticleJSON = checkout.ArticleJSON
+	params.Corrections = checkout.Corrections
+	params.Series = checkout.Series
+	params.ImportedFrom = checkout.ImportedFrom
+	params.RelatedPlaces = appendMissing(checkout.RelatedPlaces, placeIDs...)
+
+	fallbacks := prettier.Fallbacks()
+	treeEntries, err := treeEntriesFromParams("articles/"+checkout.Slug, params)
+	if err != nil {
+		return 0, "", fmt.Errorf("error creating tree entries: %w", err)
+	}
+	if params.PRBody == "" {
+		body, err := a.renderPRBody(checkout.Slug, params, prettier.Fallbacks() != fallbacks)
+		if err != nil {
+			return 0, "", err
+		}
+		params.PRBody = body
+	}
+
+	activePR, _, err := a.commitToPullRequest(ctx, treeEntries, params)
+	if err != nil {
+		return 0, "", err
+	}
+	return activePR.GetNumber(), activePR.GetHTMLURL(), nil
+}
+
+// AddRelatedArticle records articleID in each place's related_articles in a single PR.
+func (a *App) AddRelatedArticle(ctx context.Context, articleID string, places []*PlaceCheckout, opts ...Option) (int, string, error) {
+	params := Params{
+		PRBody: "This PR was created dynamically.",
+	}
+	for _, opt := range opts {
+		opt(&params)
+	}
+
+	treeEntries := []*gh.TreeEntry{}
+	for _, place := range places {
+		placeParams := params
+		placeParams.Place = place.Place
+		placeParams.RelatedArticles = appendMissing(place.RelatedArticles, articleID)
+		placeParams.Attributes = place.Attributes
+
+		entries, err := placeTreeEntriesFromParams("active_places/"+place.Slug, placeParams)
+		if err != nil {
+			return 0, "", fmt.Errorf("error creating tree entries for %s: %w", place.Slug, err)
+		}
+		treeEntries = append(treeEntries, entries...)
+	}
+
+	activePR, _, err := a.commitToPullRequest(ctx, treeEntries, params)
+	if err != nil {
+		return 0, "", err
+	}
+	return activePR.GetNumber(), activePR.GetHTMLURL(), nil
+}
+
+func featureName(feature *geojson.Feature) string {
+	for _, key := range []string{"name", "title"} {
+		if name, err := feature.PropertyString(key); err == nil && name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// normalizeName lowercases a name and drops everything but letters and digits, so "Joe's Bar"
+// and "joes bar" compare equal.
+func normalizeName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// distanceMeters is the haversine distance between two points.
+func distanceMeters(a, b citygraph.LngLat) float64 {
+	const earthRadius = 6371000.0
+	lat1 := a.Lat * math.Pi / 180
+	lat2 := b.Lat * math.Pi / 180
+	dLat := (b.Lat - a.Lat) * math.Pi / 180
+	dLng := (b.Lng - a.Lng) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+func appendMissing(list []string, values ...string) []string {
+	for _, v := range values {
+		found := false
+		for _, existing := range list {
+			if existing == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,60 @@
+package github
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	gh "github.com/google/go-github/v53/github"
+
+	"github.com/geomodulus/robots/internal/retry"
+)
+
+// maxRateLimitWait is the longest we'll wait out a rate limit before giving up.
+const maxRateLimitWait = time.Minute
+
+// retryPolicy is used for GitHub calls that are retried as a unit, like PR creation.
+var retryPolicy = retry.Policy{
+	MaxAttempts: retry.Default.MaxAttempts,
+	BaseDelay:   retry.Default.BaseDelay,
+	MaxDelay:    retry.Default.MaxDelay,
+	Retryable:   isRetryable,
+	RetryAfter:  retryAfter,
+}
+
+// NewRetryTransport wraps base (http.DefaultTransport if nil) so that requests made by a GitHub
+// client built on it are retried on network errors, rate limits and 5xx responses, eg.
+//
+//	itr, _ := ghinstallation.New(github.NewRetryTransport(nil), appID, installationID, key)
+//	app := &github.App{Client: gh.NewClient(&http.Client{Transport: itr}), ...}
+func NewRetryTransport(base http.RoundTripper) http.RoundTripper {
+	return retry.NewTransport(base)
+}
+
+// isRetryable reports whether a GitHub API error is worth retrying: short rate limits, server
+// errors and network hiccups.
+func isRetryable(err error) bool {
+	var rateErr *gh.RateLimitError
+	var abuseErr *gh.AbuseRateLimitError
+	if errors.As(err, &rateErr) || errors.As(err, &abuseErr) {
+		return retryAfter(err) <= maxRateLimitWait
+	}
+	var respErr *gh.ErrorResponse
+	if errors.As(err, &respErr) && respErr.Response != nil {
+		return retry.RetryableStatus(respErr.Response.StatusCode)
+	}
+	return retry.IsTransient(err)
+}
+
+// retryAfter returns the wait GitHub asked for when rate limiting, if any.
+func retryAfter(err error) time.Duration {
+	var rateErr *gh.RateLimitError
+	if errors.As(err, &rateErr) {
+		return time.Until(rateErr.Rate.Reset.Time)
+	}
+	var abuseErr *gh.AbuseRateLimitError
+	if errors.As(err, &abuseErr) {
+		return abuseErr.GetRetryAfter()
+	}
+	return 0
+}
@@ -0,0 +1,289 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/geomodulus/citygraph"
+)
+
+// ArticleTemplate names a starter layout for a new article.
+type ArticleTemplate string
+
+const (
+	StandardStory ArticleTemplate = "standard"
+	PhotoEssay    ArticleTemplate = "photo_essay"
+	DataExplainer ArticleTemplate = "data_explainer"
+	// LiveMap is a story told around its map, so it starts with an empty locations dataset.
+	LiveMap ArticleTemplate = "live_map"
+)
+
+// Article formats, which decide the site's layout.
+const (
+	flexFormat = "content-flex"
+	// mapFormat lays the article out around its map.
+	mapFormat = "content-map"
+)
+
+// articleScaffold is the starter content for a template.
+type articleScaffold struct {
+	format   string
+	bodyHTML string
+	js       string
+	// locations adds an empty locations.geojson dataset.
+	locations  bool
+	categories []string
+}
+
+var articleScaffolds = map[ArticleTemplate]articleScaffold{
+	StandardStory: {
+		format: flexFormat,
+		bodyHTML: `<p>Lede: who, what, where and when, in a sentence or two.</p>
+<p>Nut graf: why this matters to Torontonians.</p>
+<p>Supporting detail, quotes and context.</p>`,
+		js: `console.debug("article.js");`,
+	},
+	PhotoEssay: {
+		format: flexFormat,
+		bodyHTML: `<p>Introduce the essay: what we're looking at and why.</p>
+<figure>
+  <img src="" alt="" />
+  <figcaption>Caption. <span class="credit">Photo: Photographer</span></figcaption>
+</figure>
+<figure>
+  <img src="" alt="" />
+  <figcaption>Caption. <span class="credit">Photo: Photographer</span></figcaption>
+</figure>
+<p>Closing thought.</p>`,
+		js: `console.debug("article.js");`,
+	},
+	DataExplainer: {
+		format: flexFormat,
+		bodyHTML: `<p>The finding, in one sentence with the key number.</p>
+<h3>What the data shows</h3>
+<p>Walk through the chart or map.</p>
+<div class="chart" id="chart"></div>
+<h3>How we did this</h3>
+<p>Sources, date ranges and caveats.</p>`,
+		js: `// Draw the chart into #chart once the data is in place.
+console.debug("article.js");`,
+		locations:  true,
+		categories: []string{"Data"},
+	},
+	LiveMap: {
+		format: mapFormat,
+		bodyHTML: `<p>What the map shows and when it was last updated.</p>
+<p>How to read it: what the markers and colours mean.</p>`,
+		js: `// Markers come from locations.geojson; style them here.
+console.debug("article.js");`,
+		locations: true,
+	},
+}
+
+// ArticleTemplates lists the templates ScaffoldArticle knows.
+func ArticleTemplates() []ArticleTemplate {
+	templates := []ArticleTemplate{}
+	for template := range articleScaffolds {
+		templates = append(templates, template)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i] < templates[j] })
+	return templates
+}
+
+// ScaffoldArticle opens a PR with a new article laid out for template: article.json, a starter
+// article.html and article.js, and for map-led templates an empty locations dataset. Pass
+// WithArticle to fill in the headline, authors and so on; its ID and format are set if missing.
+// Other opts are applied as usual and can replace the starter files.
+func (a *App) ScaffoldArticle(ctx context.Context, slug string, template ArticleTemplate, opts ...Option) (int, string, error) {
+	scaffold, ok := articleScaffolds[template]
+	if !ok {
+		return 0, "", fmt.Errorf("unknown article template %q", template)
+	}
+
+	params := Params{}
+	for _, opt := range opts {
+		opt(&params)
+	}
+	article := params.Article
+	if article == nil {
+		article = &citygraph.Article{Name: slugTitle(slug)}
+	}
+	if article.ID == "" {
+		article.ID = citygraph.NewID().String()
+	}
+	if article.Format == "" {
+		article.Format = scaffold.format
+	}
+	if len(article.Categories) == 0 {
+		article.Categories = scaffold.categories
+	}
+
+	commitMessage, err := (&CommitMessage{
+		Type:    "add",
+		Scope:   "article",
+		Summary: fmt.Sprintf("Scaffold %s as %s", slug, template),
+		Slug:    slug,
+	}).Build()
+	if err != nil {
+		return 0, "", err
+	}
+	scaffoldOpts := []Option{
+		WithBodyHTML(scaffold.bodyHTML),
+		WithArticleJS(scaffold.js),
+		WithCommitMessage(commitMessage),
+		WithPRTitle("New article: " + article.Name),
+	}
+	if scaffold.locations && len(article.GeoJSONDatasets) == 0 {
+		DeclareLocations(article)
+		scaffoldOpts = append(scaffoldOpts, WithLocations(`{"type": "FeatureCollection", "features": []}`))
+	}
+	// The caller's options follow the scaffold's, so they win.
+	opts = append(append(scaffoldOpts, opts...), WithArticle(article))
+	return a.CreateOrUpdateArticlePullRequest(ctx, slug, opts...)
+}
+
+// slugTitle turns a slug into a working title, eg. "bike-lanes-bloor" to "Bike lanes bloor".
+func slugTitle(slug string) string {
+	title := strings.ReplaceAll(slug, "-", " ")
+	if title == "" {
+		return title
+	}
+	return strings.ToUpper(title[:1]) + title[1:]
+}
+
+// PlaceCategory names a kind of place, which decides the fields and body a new place starts with.
+type PlaceCategory string
+
+const (
+	RestaurantPlace PlaceCategory = "restaurant"
+	CafePlace       PlaceCategory = "cafe"
+	BarPlace        PlaceCategory = "bar"
+	ParkPlace       PlaceCategory = "park"
+	// VenuePlace is anywhere with a programme: theatres, galleries, music halls and the like.
+	VenuePlace PlaceCategory = "venue"
+)
+
+// placeScaffold is the starter content for a category.
+type placeScaffold struct {
+	typ      string
+	sprite   string
+	bodyHTML string
+	// fill sets the category's own fields, like Restaurant, on a new place.
+	fill func(*citygraph.Place)
+}
+
+var placeScaffolds = map[PlaceCategory]placeScaffold{
+	RestaurantPlace: {
+		typ:    "Restaurant",
+		sprite: "restaurant",
+		bodyHTML: `<p>What they serve, and what to order.</p>
+<p>The room: how it looks, how loud it gets, whether to book.</p>
+<p>Price range and hours.</p>`,
+		fill: func(p *citygraph.Place) {
+			if p.Restaurant == nil {
+				p.Restaurant = &citygraph.Restaurant{}
+			}
+		},
+	},
+	CafePlace: {
+		typ:    "Cafe",
+		sprite: "cafe",
+		bodyHTML: `<p>The coffee, and what else is worth getting.</p>
+<p>Seating, wifi and whether it's a place to work.</p>`,
+		fill: func(p *citygraph.Place) {
+			if p.Cafe == nil {
+				p.Cafe = &citygraph.Cafe{}
+			}
+		},
+	},
+	BarPlace: {
+		typ:    "Bar",
+		sprite: "bar",
+		bodyHTML: `<p>What's on tap and behind the bar.</p>
+<p>The crowd, the music and when it gets busy.</p>`,
+		fill: func(p *citygraph.Place) {
+			if p.Bar == nil {
+				p.Bar = &citygraph.Bar{}
+			}
+		},
+	},
+	ParkPlace: {
+		typ:    "Park",
+		sprite: "park",
+		bodyHTML: `<p>What's here: trails, fields, playgrounds, washrooms.</p>
+<p>How to get there, and the best entrance to use.</p>
+<p>Seasonal notes: rinks, splash pads, off-leash hours.</p>`,
+	},
+	VenuePlace: {
+		typ:    "Venue",
+		sprite: "theatre",
+		bodyHTML: `<p>What goes on here, and who it's for.</p>
+<p>Capacity, accessibility and how to get tickets.</p>`,
+	},
+}
+
+// PlaceCategories lists the categories ScaffoldPlace knows.
+func PlaceCategories() []PlaceCategory {
+	categories := []PlaceCategory{}
+	for category := range placeScaffolds {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i] < categories[j] })
+	return categories
+}
+
+// ScaffoldPlace opens a PR with a new place page for category: poi.json with the category's
+// type, sprite and fields, and a starter body.html. Pass WithPlace to fill in the name, address
+// and so on; fields it leaves empty get the category's defaults. Other opts are applied as usual
+// and can replace the starter body.
+func (a *App) ScaffoldPlace(ctx context.Context, slug string, category PlaceCategory, opts ...Option) (int, string, error) {
+	scaffold, ok := placeScaffolds[category]
+	if !ok {
+		return 0, "", fmt.Errorf("unknown place category %q", category)
+	}
+
+	params := Params{}
+	for _, opt := range opts {
+		opt(&params)
+	}
+	place := params.Place
+	if place == nil {
+		place = &citygraph.Place{Name: slugTitle(slug)}
+	}
+	if place.ID == "" {
+		place.ID = citygraph.NewID().String()
+	}
+	if place.AddedAt.IsZero() {
+		place.AddedAt = time.Now()
+	}
+	if place.Type == "" {
+		place.Type = scaffold.typ
+	}
+	if place.Sprite == "" {
+		place.Sprite = scaffold.sprite
+	}
+	if scaffold.fill != nil {
+		scaffold.fill(place)
+	}
+
+	commitMessage, err := (&CommitMessage{
+		Type:    "add",
+		Scope:   "place",
+		Summary: fmt.Sprintf("Scaffold %s as %s", slug, category),
+		Slug:    slug,
+	}).Build()
+	if err != nil {
+		return 0, "", err
+	}
+	scaffoldOpts := []Option{
+		WithBodyHTML(scaffold.bodyHTML),
+		WithCommitMessage(commitMessage),
+		WithPRTitle("New place: " + place.Name),
+	}
+	// The caller's options follow the scaffold's, so they win.
+	opts = append(append(scaffoldOpts, opts...), WithPlace(place))
+	return a.CreateOrUpdatePlacePullRequest(ctx, slug, opts...)
+}
@@ -0,0 +1,140 @@
+package github
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// WithBaseArticleJSON gives the article.json being replaced, usually the checkout's ArticleJSON,
+// so the new one keeps its key order and the formatting of values that haven't changed. Without
+// it, keys are written in the order of the Article struct.
+func WithBaseArticleJSON(base string) Option {
+	return func(params *Params) {
+		params.BaseArticleJSON = base
+	}
+}
+
+// marshalStable encodes v as indented JSON that differs from base only where the values do:
+// keys base has come first and in its order, values equal to base's are copied from it as
+// written, and HTML in strings isn't escaped. base may be empty.
+func marshalStable(v any, base string) ([]byte, error) {
+	var encoded bytes.Buffer
+	encoder := json.NewEncoder(&encoded)
+	// Headlines hold HTML; escaping it would rewrite every < and > as \u003c and \u003e.
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	var baseRaw json.RawMessage
+	if base != "" && json.Valid([]byte(base)) {
+		baseRaw = bytes.TrimSpace([]byte(base))
+	}
+	if err := writeStable(&out, bytes.TrimSpace(encoded.Bytes()), baseRaw, ""); err != nil {
+		return nil, err
+	}
+	out.WriteString("\n")
+	return out.Bytes(), nil
+}
+
+func writeStable(out *bytes.Buffer, raw, base json.RawMessage, indent string) error {
+	if base != nil && jsonEqual(raw, base) {
+		return json.Indent(out, base, indent, "  ")
+	}
+	if !isObject(raw) || !isObject(base) {
+		return json.Indent(out, raw, indent, "  ")
+	}
+
+	fields, err := objectFields(raw)
+	if err != nil {
+		return err
+	}
+	baseFields, err := objectFields(base)
+	if err != nil {
+		return err
+	}
+	baseValues := map[string]json.RawMessage{}
+	order := []string{}
+	for _, field := range baseFields {
+		baseValues[field.key] = field.value
+		order = append(order, field.key)
+	}
+	values := map[string]json.RawMessage{}
+	for _, field := range fields {
+		if _, ok := baseValues[field.key]; !ok {
+			order = append(order, field.key)
+		}
+		values[field.key] = field.value
+	}
+
+	inner := indent + "  "
+	out.WriteString("{")
+	first := true
+	for _, key := range order {
+		value, ok := values[key]
+		if !ok {
+			// Dropped, eg. an omitempty field that's now empty.
+			continue
+		}
+		if !first {
+			out.WriteString(",")
+		}
+		first = false
+		name, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "\n%s%s: ", inner, name)
+		if err := writeStable(out, value, baseValues[key], inner); err != nil {
+			return err
+		}
+	}
+	if !first {
+		out.WriteString("\n" + indent)
+	}
+	out.WriteString("}")
+	return nil
+}
+
+type jsonField struct {
+	key   string
+	value json.RawMessage
+}
+
+// objectFields returns a JSON object's fields in the order they're written.
+func objectFields(raw json.RawMessage) ([]jsonField, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	fields := []jsonField{}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		fields = append(fields, jsonField{key: key.(string), value: value})
+	}
+	return fields, nil
+}
+
+func isObject(raw json.RawMessage) bool {
+	return len(raw) > 0 && raw[0] == '{'
+}
+
+// jsonEqual reports whether a and b encode the same value, ignoring key order, whitespace and
+// how numbers and strings are written.
+func jsonEqual(a, b json.RawMessage) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
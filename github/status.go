@@ -0,0 +1,121 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	gh "github.com/google/go-github/v53/github"
+
+	"github.com/geomodulus/robots/osm"
+)
+
+// ClosedStatus is the poi.json status for places that have shut down.
+const ClosedStatus = "Closed"
+
+// PlaceStatusFinding flags a place that may need an editor's attention.
+type PlaceStatusFinding struct {
+	Place  *PlaceCheckout
+	Reason string
+	// LikelyClosed is set when the evidence suggests the business has shut down, rather than
+	// just a broken website.
+	LikelyClosed bool
+}
+
+// CheckPlaceStatus checks each open place's website and, when osmClient is set, whether
+// OpenStreetMap mappers have marked it closed. Places already marked closed are skipped.
+func CheckPlaceStatus(ctx context.Context, places []*PlaceCheckout, httpClient *http.Client, osmClient *osm.Client) []*PlaceStatusFinding {
+	findings := []*PlaceStatusFinding{}
+	for _, place := range places {
+		if place.Place.Status == ClosedStatus {
+			continue
+		}
+
+		if place.Place.URL != "" {
+			if reason := checkWebsite(ctx, httpClient, place.Place.URL); reason != "" {
+				findings = append(findings, &PlaceStatusFinding{
+					Place:  place,
+					Reason: reason,
+				})
+			}
+		}
+
+		if osmClient != nil {
+			el, err := osmClient.FindPlace(ctx, place.Place, DefaultMatchRadius)
+			if err != nil {
+				// OSM is best-effort; Overpass rate limits are common.
+				continue
+			}
+			if el != nil && el.LikelyClosed() {
+				findings = append(findings, &PlaceStatusFinding{
+					Place:        place,
+					Reason:       "marked closed in OpenStreetMap (" + el.Ref() + ")",
+					LikelyClosed: true,
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// checkWebsite returns a reason the site looks dead, or "" if it resolves.
+func checkWebsite(ctx context.Context, client *http.Client, siteURL string) string {
+	if !strings.HasPrefix(siteURL, "http://") && !strings.HasPrefix(siteURL, "https://") {
+		siteURL = "https://" + siteURL
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", siteURL, nil)
+	if err != nil {
+		return fmt.Sprintf("invalid website URL: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Sprintf("website unreachable: %v", err)
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound, http.StatusGone:
+		return "website returned " + resp.Status
+	}
+	if resp.StatusCode >= 500 {
+		return "website returned " + resp.Status
+	}
+	return ""
+}
+
+// MarkPlacesClosed opens a single PR setting the status of each place to Closed.
+func (a *App) MarkPlacesClosed(ctx context.Context, slugs []string, opts ...Option) (int, string, error) {
+	params := Params{
+		PRTitle: fmt.Sprintf("Mark %d places closed", len(slugs)),
+		PRBody:  "Marks closed:\n\n- `" + strings.Join(slugs, "`\n- `") + "`",
+	}
+	for _, opt := range opts {
+		opt(&params)
+	}
+
+	treeEntries := []*gh.TreeEntry{}
+	for _, slug := range slugs {
+		place, err := a.FetchPlace(ctx, slug)
+		if err != nil {
+			return 0, "", fmt.Errorf("error fetching %s: %w", slug, err)
+		}
+		place.Place.Status = ClosedStatus
+
+		placeParams := params
+		placeParams.Place = place.Place
+		placeParams.RelatedArticles = place.RelatedArticles
+		placeParams.Attributes = place.Attributes
+		entries, err := placeTreeEntriesFromParams("active_places/"+slug, placeParams)
+		if err != nil {
+			return 0, "", fmt.Errorf("error creating tree entries for %s: %w", slug, err)
+		}
+		treeEntries = append(treeEntries, entries...)
+	}
+
+	activePR, _, err := a.commitToPullRequest(ctx, treeEntries, params)
+	if err != nil {
+		return 0, "", err
+	}
+	return activePR.GetNumber(), activePR.GetHTMLURL(), nil
+}
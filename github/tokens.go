@@ -0,0 +1,177 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	gh "github.com/google/go-github/v53/github"
+)
+
+// tokenRefreshMargin is how long before expiry a scoped token is replaced.
+const tokenRefreshMargin = 5 * time.Minute
+
+// TokenScope is what an installation token may touch: the repositories it's good for and the
+// permissions it has on them. A token can't have more than the installation was granted.
+type TokenScope struct {
+	// Repositories are repo names in the App's owner. Empty means the App's Repo.
+	Repositories []string
+	Permissions  gh.InstallationPermissions
+}
+
+// Scopes for the App's own operations, each with only the permissions that operation needs.
+var (
+	// ReadScope reads articles, places and history.
+	ReadScope = TokenScope{Permissions: gh.InstallationPermissions{
+		Contents:     gh.String("read"),
+		PullRequests: gh.String("read"),
+	}}
+	// CommitScope commits to branches, including main.
+	CommitScope = TokenScope{Permissions: gh.InstallationPermissions{
+		Contents: gh.String("write"),
+	}}
+	// PullRequestScope opens and updates pull requests. Contents is read-only, so a PR can be
+	// described but its branch can't be pushed to.
+	PullRequestScope = TokenScope{Permissions: gh.InstallationPermissions{
+		Contents:     gh.String("read"),
+		PullRequests: gh.String("write"),
+	}}
+	// PublishScope commits to a PR branch and opens its pull request, as
+	// CreateOrUpdateArticlePullRequest and CreateOrUpdatePlacePullRequest do.
+	PublishScope = TokenScope{Permissions: gh.InstallationPermissions{
+		Contents:     gh.String("write"),
+		PullRequests: gh.String("write"),
+	}}
+	// ChecksScope reports check runs, like the accessibility audit.
+	ChecksScope = TokenScope{Permissions: gh.InstallationPermissions{
+		Checks:   gh.String("write"),
+		Contents: gh.String("read"),
+	}}
+	// IssueScope files issues, as CreateIssue does.
+	IssueScope = TokenScope{Permissions: gh.InstallationPermissions{
+		Issues: gh.String("write"),
+	}}
+)
+
+// key identifies a scope for caching its token.
+func (s TokenScope) key(defaultRepo string) string {
+	repos := append([]string{}, s.Repositories...)
+	if len(repos) == 0 {
+		repos = []string{defaultRepo}
+	}
+	sort.Strings(repos)
+	// Struct fields marshal in a fixed order, so equal permissions give equal keys.
+	perms, _ := json.Marshal(s.Permissions)
+	return strings.Join(repos, ",") + "|" + string(perms)
+}
+
+type scopedToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// CreateScopedInstallationToken creates an installation token limited to scope. Unlike
+// CreateInstallationToken, it doesn't change InstallationTokenExpiry.
+func (a *App) CreateScopedInstallationToken(ctx context.Context, scope TokenScope) (string, time.Time, error) {
+	repos := scope.Repositories
+	if len(repos) == 0 {
+		repos = []string{a.Repo}
+	}
+	permissions := scope.Permissions
+	minter := a.AppClient
+	if minter == nil {
+		minter = a.Client
+	}
+	token, _, err := minter.Apps.CreateInstallationToken(ctx, a.InstallationID, &gh.InstallationTokenOptions{
+		Repositories: repos,
+		Permissions:  &permissions,
+	})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("CreateInstallationToken(%s): %v", scope.key(a.Repo), err)
+	}
+	return token.GetToken(), token.GetExpiresAt().Time, nil
+}
+
+// scopedToken returns a cached token for scope, minting a new one when it's close to expiry.
+func (a *App) scopedToken(ctx context.Context, scope TokenScope) (string, error) {
+	key := scope.key(a.Repo)
+	state := a.tokenState()
+	state.scopedMu.Lock()
+	defer state.scopedMu.Unlock()
+	if cached, ok := state.scopedTokens[key]; ok && time.Until(cached.expiresAt) > tokenRefreshMargin {
+		return cached.token, nil
+	}
+	token, expiresAt, err := a.CreateScopedInstallationToken(ctx, scope)
+	if err != nil {
+		return "", err
+	}
+	if state.scopedTokens == nil {
+		state.scopedTokens = map[string]*scopedToken{}
+	}
+	state.scopedTokens[key] = &scopedToken{token: token, expiresAt: expiresAt}
+	return token, nil
+}
+
+// WithScope returns a copy of the App whose API calls are made with a token limited to scope,
+// eg. for rollbacks, which only ever commit:
+//
+//	rollbacks := app.WithScope(github.CommitScope)
+//	rollbacks.RevertArticleTo(ctx, slug, sha)
+//
+// Tokens are minted on first use and replaced before they expire, with AppClient. Calls outside
+// scope fail with GitHub's 403, so give each subsystem its own copy rather than sharing the App.
+func (a *App) WithScope(scope TokenScope) *App {
+	scoped := *a
+	// The copy starts with no tokens of its own; its client gets them from a.
+	scoped.state = nil
+	scoped.ScopeTokens = false
+	scoped.Client = gh.NewClient(&http.Client{Transport: &scopedTransport{
+		app:   a,
+		scope: scope,
+		base:  NewRetryTransport(nil),
+	}})
+	return &scoped
+}
+
+// scopedTransport authenticates requests with the parent App's token for scope.
+type scopedTransport struct {
+	app   *App
+	scope TokenScope
+	base  http.RoundTripper
+}
+
+func (t *scopedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.app.scopedToken(req.Context(), t.scope)
+	if err != nil {
+		return nil, err
+	}
+	// RoundTrippers mustn't modify the request they're given.
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "token "+token)
+	return t.base.RoundTrip(req)
+}
+
+// scoped returns the App an operation needing only scope should call GitHub with: a copy
+// limited to scope if ScopeTokens is set, otherwise the App itself. Copies are kept for reuse.
+func (a *App) scoped(scope TokenScope) *App {
+	if !a.ScopeTokens {
+		return a
+	}
+	key := scope.key(a.Repo)
+	state := a.tokenState()
+	state.scopedMu.Lock()
+	defer state.scopedMu.Unlock()
+	if scoped, ok := state.scopedApps[key]; ok {
+		return scoped
+	}
+	if state.scopedApps == nil {
+		state.scopedApps = map[string]*App{}
+	}
+	scoped := a.WithScope(scope)
+	state.scopedApps[key] = scoped
+	return scoped
+}
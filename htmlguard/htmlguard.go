@@ -0,0 +1,202 @@
+// Package htmlguard checks bot-generated article HTML against the tags, attributes, classes and
+// embeds the site actually uses, so nothing unexpected reaches prettier or GitHub. It also
+// sanitizes uploaded SVGs.
+package htmlguard
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"golang.org/x/net/html"
+)
+
+// Policy is an allowlist for article HTML.
+type Policy struct {
+	// Tags maps each allowed element to its allowed attributes. class is checked against
+	// Classes, and src on iframes against EmbedHosts.
+	Tags map[string][]string
+	// Classes are the class names allowed on any element.
+	Classes []string
+	// EmbedHosts are the hosts iframes may load from.
+	EmbedHosts []string
+}
+
+// DefaultPolicy covers the markup used in articles.
+var DefaultPolicy = &Policy{
+	Tags: map[string][]string{
+		"p": {"class"}, "br": nil, "hr": nil,
+		"h2": {"id", "class"}, "h3": {"id", "class"}, "h4": {"id", "class"},
+		"strong": nil, "em": nil, "b": nil, "i": nil, "u": nil, "s": nil, "sub": nil, "sup": nil,
+		"blockquote": {"class", "cite"}, "cite": nil, "q": nil,
+		"ul": {"class"}, "ol": {"class", "start"}, "li": nil,
+		"a":      {"href", "title", "target", "rel"},
+		"figure": {"class"}, "figcaption": {"class"},
+		"img":   {"src", "alt", "width", "height", "loading", "class"},
+		"aside": {"class"}, "span": {"class"}, "div": {"class"},
+		"table": {"class"}, "thead": nil, "tbody": nil, "tr": nil, "th": {"scope"}, "td": nil,
+		"iframe": {"src", "width", "height", "title", "allow", "allowfullscreen", "frameborder", "loading"},
+	},
+	Classes: []string{
+		"correction", "pull-quote", "caption", "credit", "full-width", "inline", "embed", "note",
+	},
+	EmbedHosts: []string{
+		"www.youtube.com", "www.youtube-nocookie.com", "player.vimeo.com", "open.spotify.com",
+	},
+}
+
+// Violation is something in the HTML the policy doesn't allow.
+type Violation struct {
+	Tag    string
+	Reason string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("<%s>: %s", v.Tag, v.Reason)
+}
+
+// ViolationError is returned by Validate when the HTML breaks the policy.
+type ViolationError struct {
+	Violations []Violation
+}
+
+func (e *ViolationError) Error() string {
+	reasons := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		reasons[i] = v.String()
+	}
+	return "HTML not allowed: " + strings.Join(reasons, "; ")
+}
+
+// Validate returns a *ViolationError listing everything in body the policy doesn't allow.
+func (p *Policy) Validate(body string) error {
+	violations, err := p.Check(body)
+	if err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return &ViolationError{Violations: violations}
+	}
+	return nil
+}
+
+// Check lists everything in body the policy doesn't allow.
+func (p *Policy) Check(body string) ([]Violation, error) {
+	nodes, err := html.ParseFragment(strings.NewReader(body), &html.Node{Type: html.ElementNode, Data: "body"})
+	if err != nil {
+		return nil, fmt.Errorf("error parsing HTML: %v", err)
+	}
+
+	violations := []Violation{}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			violations = append(violations, p.checkElement(n)...)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	for _, n := range nodes {
+		walk(n)
+	}
+	return violations, nil
+}
+
+func (p *Policy) checkElement(n *html.Node) []Violation {
+	allowedAttrs, ok := p.Tags[n.Data]
+	if !ok {
+		return []Violation{{Tag: n.Data, Reason: "element not allowed"}}
+	}
+
+	violations := []Violation{}
+	for _, attr := range n.Attr {
+		switch {
+		case strings.HasPrefix(attr.Key, "on"):
+			violations = append(violations, Violation{n.Data, fmt.Sprintf("event handler %s not allowed", attr.Key)})
+		case !contains(allowedAttrs, attr.Key):
+			violations = append(violations, Violation{n.Data, fmt.Sprintf("attribute %s not allowed", attr.Key)})
+		case attr.Key == "class":
+			for _, class := range strings.Fields(attr.Val) {
+				if !contains(p.Classes, class) {
+					violations = append(violations, Violation{n.Data, fmt.Sprintf("class %q not allowed", class)})
+				}
+			}
+		case attr.Key == "href" || attr.Key == "src" || attr.Key == "cite":
+			u, err := url.Parse(strings.TrimSpace(attr.Val))
+			if err != nil || (u.Scheme != "" && u.Scheme != "https" && u.Scheme != "http" && u.Scheme != "mailto") {
+				violations = append(violations, Violation{n.Data, fmt.Sprintf("%s %q not allowed", attr.Key, attr.Val)})
+			} else if n.Data == "iframe" && attr.Key == "src" && !contains(p.EmbedHosts, u.Hostname()) {
+				violations = append(violations, Violation{n.Data, fmt.Sprintf("embeds from %q not allowed", u.Hostname())})
+			}
+		}
+	}
+	return violations
+}
+
+// Sanitize strips everything the policy doesn't allow, for HTML that should be salvaged rather
+// than rejected.
+func (p *Policy) Sanitize(body string) string {
+	return p.bluemonday().Sanitize(body)
+}
+
+func (p *Policy) bluemonday() *bluemonday.Policy {
+	bm := bluemonday.NewPolicy()
+	bm.AllowStandardURLs()
+	bm.RequireNoFollowOnLinks(false)
+
+	tags := make([]string, 0, len(p.Tags))
+	for tag := range p.Tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		bm.AllowElements(tag)
+		for _, attr := range p.Tags[tag] {
+			switch attr {
+			case "class":
+				bm.AllowAttrs("class").Matching(classPattern(p.Classes)).OnElements(tag)
+			case "src":
+				if tag == "iframe" {
+					bm.AllowAttrs("src").Matching(embedPattern(p.EmbedHosts)).OnElements(tag)
+					continue
+				}
+				bm.AllowAttrs(attr).OnElements(tag)
+			default:
+				bm.AllowAttrs(attr).OnElements(tag)
+			}
+		}
+	}
+	return bm
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// classPattern matches class attributes made only of allowed classes.
+func classPattern(classes []string) *regexp.Regexp {
+	quoted := make([]string, len(classes))
+	for i, class := range classes {
+		quoted[i] = regexp.QuoteMeta(class)
+	}
+	alt := "(?:" + strings.Join(quoted, "|") + ")"
+	return regexp.MustCompile(`^\s*` + alt + `(?:\s+` + alt + `)*\s*$`)
+}
+
+// embedPattern matches https URLs on the allowed hosts.
+func embedPattern(hosts []string) *regexp.Regexp {
+	quoted := make([]string, len(hosts))
+	for i, host := range hosts {
+		quoted[i] = regexp.QuoteMeta(host)
+	}
+	return regexp.MustCompile(`^https://(?:` + strings.Join(quoted, "|") + `)/`)
+}
@@ -0,0 +1,135 @@
+package htmlguard
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// svgDropped are elements removed from SVGs along with everything inside them.
+var svgDropped = map[string]bool{
+	"script": true, "foreignobject": true, "iframe": true, "embed": true, "object": true,
+	"handler": true, "listener": true,
+}
+
+// svgAnimations can rewrite another element's attributes, eg. set an href to javascript:.
+var svgAnimations = map[string]bool{"set": true, "animate": true, "animatetransform": true, "animatemotion": true}
+
+// SanitizeSVG strips an SVG of anything that could run script when it's opened from our media
+// domain: script and foreignObject elements, event handler attributes, links outside the
+// document, animations of links and styles that import or script. DTDs are dropped too, since
+// their entities can blow up parsers.
+func SanitizeSVG(r io.Reader) ([]byte, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = true
+
+	var out bytes.Buffer
+	// skip counts how deep we are inside a dropped element.
+	skip := 0
+	sawSVG := false
+	inStyle := false
+	for {
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing SVG: %v", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			if skip > 0 || svgDropped[name] || (svgAnimations[name] && animatesLink(t)) {
+				skip++
+				continue
+			}
+			if !sawSVG && name != "svg" {
+				return nil, fmt.Errorf("not an SVG: root element is <%s>", t.Name.Local)
+			}
+			sawSVG = true
+			inStyle = name == "style"
+			out.WriteString("<" + qualifiedName(t.Name))
+			for _, attr := range t.Attr {
+				if !svgAttrAllowed(attr) {
+					continue
+				}
+				out.WriteString(" " + qualifiedName(attr.Name) + `="`)
+				xml.EscapeText(&out, []byte(attr.Value))
+				out.WriteString(`"`)
+			}
+			out.WriteString(">")
+
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			inStyle = false
+			out.WriteString("</" + qualifiedName(t.Name) + ">")
+
+		case xml.CharData:
+			if skip > 0 {
+				continue
+			}
+			if inStyle && unsafeStyle(string(t)) {
+				continue
+			}
+			xml.EscapeText(&out, t)
+
+		case xml.ProcInst:
+			if t.Target == "xml" && !sawSVG {
+				out.WriteString("<?xml " + string(t.Inst) + "?>")
+			}
+
+		// Comments and directives (DOCTYPEs and their entities) are dropped.
+		case xml.Comment, xml.Directive:
+		}
+	}
+	if !sawSVG {
+		return nil, fmt.Errorf("not an SVG: no <svg> element")
+	}
+	return out.Bytes(), nil
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+func svgAttrAllowed(attr xml.Attr) bool {
+	name := strings.ToLower(attr.Name.Local)
+	value := strings.ToLower(strings.TrimSpace(attr.Value))
+	switch {
+	case strings.HasPrefix(name, "on"):
+		return false
+	case name == "href" || name == "src":
+		// Only links within the document, and embedded raster images.
+		return strings.HasPrefix(value, "#") || strings.HasPrefix(value, "data:image/png") ||
+			strings.HasPrefix(value, "data:image/jpeg") || strings.HasPrefix(value, "data:image/gif")
+	case name == "style":
+		return !unsafeStyle(value)
+	}
+	return !strings.Contains(value, "javascript:")
+}
+
+func animatesLink(t xml.StartElement) bool {
+	for _, attr := range t.Attr {
+		if strings.ToLower(attr.Name.Local) == "attributename" {
+			target := strings.ToLower(attr.Value)
+			return target == "href" || strings.HasSuffix(target, ":href") || strings.HasPrefix(target, "on")
+		}
+	}
+	return false
+}
+
+func unsafeStyle(css string) bool {
+	css = strings.ToLower(css)
+	return strings.Contains(css, "@import") || strings.Contains(css, "javascript:") ||
+		strings.Contains(css, "expression(") || strings.Contains(css, "url(http") || strings.Contains(css, "url(//")
+}
@@ -0,0 +1,186 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/slack-go/slack"
+
+	"github.com/geomodulus/robots/github"
+	"github.com/geomodulus/robots/state"
+)
+
+const (
+	// ImageCreditActionID is the button on an upload that asks for its credit. Forward it from
+	// the bot's HandleBlockAction to ImageCredits.HandleBlockAction.
+	ImageCreditActionID = "image_credit"
+	// ImageCreditsCallbackID is the callback ID of the credit modal.
+	ImageCreditsCallbackID = "image_credits"
+)
+
+const (
+	imageCreditBlockID  = "image_credit_credit"
+	imageLicenseBlockID = "image_credit_license"
+	imageSourceBlockID  = "image_credit_source"
+	imageCreditValueID  = "value"
+)
+
+// ImageCredits asks for an image's credit and license as soon as it's uploaded, and keeps them
+// until the article's PR is opened. Add it to SlackBot.Modals, store images with UploadImage,
+// which asks for the credit, and set ArticleEditor.ImageCredits so the credits go in the PR.
+// Article PRs are refused while any image in the body is uncredited.
+type ImageCredits struct {
+	Bot   *SlackBot
+	Store state.Store
+	// BreakingNews, if set, lets uploads to slugs in breaking-news mode go ahead uncredited.
+	// The button to credit them is still posted.
+	BreakingNews *BreakingNews
+}
+
+// imageTypes are the uploads UploadImage accepts.
+var imageTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp", "image/avif", "image/heic", svgType}
+
+func imageCreditsKey(slug string) string {
+	return "image-credits/" + slug
+}
+
+// UploadImage stores an image shared in Slack for slug's article, showing its progress in env's
+// thread, and then asks for its credit. It returns the image's URL, for the article's body.
+func (c *ImageCredits) UploadImage(ctx context.Context, env *Envelope, uploader *Uploader, slug string, file slack.File) (string, error) {
+	imageURL, err := uploader.Upload(ctx, slug, file.URLPrivateDownload,
+		uploader.SlackAuth(), AllowTypes(imageTypes...), WithProgress(c.Bot.UploadProgress(ctx, env, file.Name)))
+	if err != nil {
+		return "", fmt.Errorf("error uploading %s: %w", file.Name, err)
+	}
+	if err := c.Prompt(ctx, env, slug, imageURL); err != nil {
+		return imageURL, fmt.Errorf("error asking for credit: %w", err)
+	}
+	return imageURL, nil
+}
+
+// Prompt posts the uploaded image in env's thread with a button to add its credit.
+func (c *ImageCredits) Prompt(ctx context.Context, env *Envelope, slug, imageURL string) error {
+	text := fmt.Sprintf(":frame_with_picture: Who should <%s|this image> in `%s` be credited to?", imageURL, slug)
+	if c.BreakingNews.Approves(ctx, env, slug, "image credit: "+imageURL) {
+		text = fmt.Sprintf(":rotating_light: `%s` is breaking news, so <%s|this image> can go in without a credit. Add one when there's time.", slug, imageURL)
+	}
+	return c.Bot.ReplyTo(env, slack.MsgOptionBlocks(
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+		slack.NewActionBlock("",
+			slack.NewButtonBlockElement(ImageCreditActionID, slug+" "+imageURL,
+				slack.NewTextBlockObject(slack.PlainTextType, "Add credit", false, false)).WithStyle(slack.StylePrimary),
+		),
+	))
+}
+
+// HandlesAction reports whether actionID is the credit button.
+func (c *ImageCredits) HandlesAction(actionID string) bool {
+	return actionID == ImageCreditActionID
+}
+
+// HandleBlockAction opens the credit modal for the image a button belongs to.
+func (c *ImageCredits) HandleBlockAction(ctx context.Context, env *Envelope, action, value string, callback slack.InteractionCallback) error {
+	if action != ImageCreditActionID {
+		return fmt.Errorf("unknown action %q", action)
+	}
+	// The prompt is remembered too, so it can be marked done once the credit is saved.
+	metadata := strings.Join([]string{value, env.ChannelID, env.MessageTS}, " ")
+	if _, err := c.Bot.OpenViewContext(ctx, callback.TriggerID, imageCreditView(metadata)); err != nil {
+		return fmt.Errorf("error opening credit form: %v", err)
+	}
+	return nil
+}
+
+// HandlesView reports whether view is the credit modal.
+func (c *ImageCredits) HandlesView(view slack.View) bool {
+	return view.CallbackID == ImageCreditsCallbackID
+}
+
+// RespondViewSubmission saves the credit and closes the modal.
+func (c *ImageCredits) RespondViewSubmission(ctx context.Context, env *Envelope, callback slack.InteractionCallback) (*slack.ViewSubmissionResponse, error) {
+	parts := strings.Fields(callback.View.PrivateMetadata)
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("malformed credit form %q", callback.View.PrivateMetadata)
+	}
+	slug, imageURL, channelID, promptTS := parts[0], parts[1], parts[2], parts[3]
+
+	values := callback.View.State.Values
+	credit := &github.ImageCredit{
+		URL:     imageURL,
+		Credit:  strings.TrimSpace(values[imageCreditBlockID][imageCreditValueID].Value),
+		License: values[imageLicenseBlockID][imageCreditValueID].SelectedOption.Value,
+		Source:  strings.TrimSpace(values[imageSourceBlockID][imageCreditValueID].Value),
+	}
+	if credit.Credit == "" {
+		return slack.NewErrorsViewSubmissionResponse(map[string]string{
+			imageCreditBlockID: "Who took or made the image, eg. \"Jane Doe/Torontoverse\".",
+		}), nil
+	}
+
+	credits, err := c.Credits(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	replaced := false
+	for i, existing := range credits {
+		if existing.URL == imageURL {
+			credits[i] = credit
+			replaced = true
+		}
+	}
+	if !replaced {
+		credits = append(credits, credit)
+	}
+	if err := c.Store.Put(ctx, imageCreditsKey(slug), credits); err != nil {
+		return nil, fmt.Errorf("error saving image credit: %v", err)
+	}
+
+	text := fmt.Sprintf(":white_check_mark: <%s|Image> in `%s` credited to *%s* (%s) by <@%s>.",
+		imageURL, slug, escapeMrkdwn(credit.Credit), credit.License, env.UserID)
+	if _, _, _, err := c.Bot.UpdateMessageContext(ctx, channelID, promptTS, slack.MsgOptionBlocks(
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+	)); err != nil {
+		return nil, fmt.Errorf("error updating credit prompt: %v", err)
+	}
+	return slack.NewClearViewSubmissionResponse(), nil
+}
+
+// Credits returns the credits saved for the article so far.
+func (c *ImageCredits) Credits(ctx context.Context, slug string) ([]*github.ImageCredit, error) {
+	credits := []*github.ImageCredit{}
+	if _, err := c.Store.Get(ctx, imageCreditsKey(slug), &credits); err != nil {
+		return nil, fmt.Errorf("error loading image credits: %v", err)
+	}
+	return credits, nil
+}
+
+func imageCreditView(metadata string) slack.ModalViewRequest {
+	credit := slack.NewInputBlock(imageCreditBlockID,
+		slack.NewTextBlockObject(slack.PlainTextType, "Credit", false, false), nil,
+		slack.NewPlainTextInputBlockElement(nil, imageCreditValueID))
+
+	options := []*slack.OptionBlockObject{}
+	for _, license := range github.Licenses {
+		options = append(options, slack.NewOptionBlockObject(license, slack.NewTextBlockObject(slack.PlainTextType, license, false, false), nil))
+	}
+	license := slack.NewInputBlock(imageLicenseBlockID,
+		slack.NewTextBlockObject(slack.PlainTextType, "License", false, false), nil,
+		slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, nil, imageCreditValueID, options...))
+
+	source := slack.NewInputBlock(imageSourceBlockID,
+		slack.NewTextBlockObject(slack.PlainTextType, "Source", false, false), nil,
+		slack.NewPlainTextInputBlockElement(nil, imageCreditValueID))
+	source.Optional = true
+	source.Hint = slack.NewTextBlockObject(slack.PlainTextType, "Where the image came from, if it wasn't shot for us.", false, false)
+
+	return slack.ModalViewRequest{
+		Type:            slack.VTModal,
+		CallbackID:      ImageCreditsCallbackID,
+		PrivateMetadata: metadata,
+		Title:           slack.NewTextBlockObject(slack.PlainTextType, "Image credit", false, false),
+		Submit:          slack.NewTextBlockObject(slack.PlainTextType, "Save", false, false),
+		Close:           slack.NewTextBlockObject(slack.PlainTextType, "Cancel", false, false),
+		Blocks:          slack.Blocks{BlockSet: []slack.Block{credit, license, source}},
+	}
+}
@@ -0,0 +1,203 @@
+// Package breaker provides circuit breakers for optional dependencies, so when one is down calls
+// fail fast and callers can degrade instead of every interaction waiting on timeouts.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrOpen is wrapped by the errors returned while a breaker is open.
+var ErrOpen = errors.New("circuit open")
+
+// Defaults for New.
+const (
+	DefaultThreshold = 5
+	DefaultCooldown  = 30 * time.Second
+)
+
+// State is a breaker's state.
+type State int
+
+const (
+	// Closed lets calls through.
+	Closed State = iota
+	// Open fails calls fast until the dependency recovers.
+	Open
+	// HalfOpen lets one trial call through to see if the dependency has recovered.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// Breaker opens after Threshold consecutive failures. While open it probes the dependency every
+// Cooldown, with Probe if set or otherwise by letting the next call through, and closes once a
+// probe succeeds.
+type Breaker struct {
+	Name      string
+	Threshold int
+	Cooldown  time.Duration
+	// Probe, if set, checks the dependency in the background while the breaker is open.
+	Probe func(ctx context.Context) error
+	// IsFailure decides which errors count against the dependency. By default they all do,
+	// except context cancellation.
+	IsFailure func(error) bool
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	lastErr  error
+	probing  bool
+}
+
+func New(name string) *Breaker {
+	return &Breaker{Name: name, Threshold: DefaultThreshold, Cooldown: DefaultCooldown}
+}
+
+// Do runs fn unless the breaker is open, recording its outcome. A nil breaker always runs fn.
+func (b *Breaker) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if b == nil {
+		return fn(ctx)
+	}
+	if err := b.allow(); err != nil {
+		return err
+	}
+	err := fn(ctx)
+	b.record(err)
+	return err
+}
+
+// DoValue is Do for calls that return a value.
+func DoValue[T any](ctx context.Context, b *Breaker, fn func(ctx context.Context) (T, error)) (T, error) {
+	var value T
+	err := b.Do(ctx, func(ctx context.Context) error {
+		var err error
+		value, err = fn(ctx)
+		return err
+	})
+	return value, err
+}
+
+// State returns the breaker's state.
+func (b *Breaker) State() State {
+	if b == nil {
+		return Closed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Available reports whether calls are being let through.
+func (b *Breaker) Available() bool {
+	return b.State() != Open
+}
+
+func (b *Breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case Open:
+		if b.Probe == nil && time.Since(b.openedAt) >= b.cooldown() {
+			// Let this call through as the probe.
+			b.state = HalfOpen
+			return nil
+		}
+		return fmt.Errorf("%s unavailable since %s (%v): %w", b.Name, b.openedAt.Format(time.Kitchen), b.lastErr, ErrOpen)
+	case HalfOpen:
+		// A trial is already in flight.
+		return fmt.Errorf("%s unavailable: %w", b.Name, ErrOpen)
+	}
+	return nil
+}
+
+func (b *Breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil || !b.isFailure(err) {
+		if b.state != Closed {
+			log.Printf("%s recovered", b.Name)
+		}
+		b.state = Closed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	b.lastErr = err
+	if b.state == HalfOpen || b.failures >= b.threshold() {
+		b.open()
+	}
+}
+
+// open trips the breaker. b.mu must be held.
+func (b *Breaker) open() {
+	if b.state != Open {
+		log.Printf("%s unavailable, failing fast: %v", b.Name, b.lastErr)
+	}
+	b.state = Open
+	b.openedAt = time.Now()
+	if b.Probe != nil && !b.probing {
+		b.probing = true
+		go b.probe()
+	}
+}
+
+func (b *Breaker) probe() {
+	for {
+		time.Sleep(b.cooldown())
+		ctx, cancel := context.WithTimeout(context.Background(), b.cooldown())
+		err := b.Probe(ctx)
+		cancel()
+
+		b.mu.Lock()
+		if err == nil {
+			log.Printf("%s recovered", b.Name)
+			b.state = Closed
+			b.failures = 0
+			b.probing = false
+			b.mu.Unlock()
+			return
+		}
+		b.lastErr = err
+		b.mu.Unlock()
+	}
+}
+
+func (b *Breaker) isFailure(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if b.IsFailure != nil {
+		return b.IsFailure(err)
+	}
+	return true
+}
+
+func (b *Breaker) threshold() int {
+	if b.Threshold <= 0 {
+		return DefaultThreshold
+	}
+	return b.Threshold
+}
+
+func (b *Breaker) cooldown() time.Duration {
+	if b.Cooldown <= 0 {
+		return DefaultCooldown
+	}
+	return b.Cooldown
+}
@@ -0,0 +1,93 @@
+// Package cache is a small in-memory cache for lookups that are slow or billed, like embeddings
+// and Slack user names.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// TTL is a small LRU cache whose entries also expire after a fixed TTL. A nil cache never
+// hits.
+type TTL[V any] struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type entry[V any] struct {
+	key     string
+	value   V
+	expires time.Time
+}
+
+// New returns a cache of up to size entries that each live for ttl, or nil if either is zero.
+func New[V any](size int, ttl time.Duration) *TTL[V] {
+	if size <= 0 || ttl <= 0 {
+		return nil
+	}
+	return &TTL[V]{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+// Get returns the value for key, if it's cached and hasn't expired.
+func (c *TTL[V]) Get(key string) (V, bool) {
+	var zero V
+	if c == nil {
+		return zero, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return zero, false
+	}
+	entry := el.Value.(*entry[V])
+	if time.Now().After(entry.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	return entry.value, true
+}
+
+// Put caches value for key, evicting the least recently used entry if the cache is full.
+func (c *TTL[V]) Put(key string, value V) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		el.Value = &entry[V]{key: key, value: value, expires: time.Now().Add(c.ttl)}
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&entry[V]{key: key, value: value, expires: time.Now().Add(c.ttl)})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry[V]).key)
+	}
+}
+
+// Clear drops every entry, eg. after reindexing.
+func (c *TTL[V]) Clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = map[string]*list.Element{}
+}
@@ -0,0 +1,68 @@
+// Package llm runs the bots' OpenAI chat completions behind one circuit breaker, so while OpenAI
+// is down every feature that writes with it fails fast and can say so, instead of each request
+// waiting on timeouts.
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/geomodulus/robots/internal/breaker"
+	"github.com/geomodulus/robots/internal/retry"
+)
+
+// ErrUnavailable is wrapped by errors from completions refused while OpenAI is down.
+var ErrUnavailable = errors.New("OpenAI is unavailable")
+
+// UnavailableMessage is a user-facing explanation for ErrUnavailable.
+const UnavailableMessage = "Writing is temporarily unavailable while OpenAI is down. Please try again in a few minutes."
+
+// chatBreaker is shared by every completion, since they all depend on the same service.
+var chatBreaker = &breaker.Breaker{
+	Name:      "OpenAI chat",
+	Threshold: breaker.DefaultThreshold,
+	Cooldown:  breaker.DefaultCooldown,
+	IsFailure: IsOutage,
+}
+
+// Complete runs a chat completion unless OpenAI is known to be down.
+func Complete(ctx context.Context, client *openai.Client, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	resp, err := breaker.DoValue(ctx, chatBreaker, func(ctx context.Context) (openai.ChatCompletionResponse, error) {
+		return client.CreateChatCompletion(ctx, req)
+	})
+	return resp, unavailable(err)
+}
+
+// Stream starts a streamed chat completion unless OpenAI is known to be down. Only starting the
+// stream counts toward the breaker.
+func Stream(ctx context.Context, client *openai.Client, req openai.ChatCompletionRequest) (*openai.ChatCompletionStream, error) {
+	stream, err := breaker.DoValue(ctx, chatBreaker, func(ctx context.Context) (*openai.ChatCompletionStream, error) {
+		return client.CreateChatCompletionStream(ctx, req)
+	})
+	return stream, unavailable(err)
+}
+
+// IsOutage reports whether an OpenAI error suggests the service is down, rather than something
+// wrong with the request.
+func IsOutage(err error) bool {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return retry.RetryableStatus(apiErr.HTTPStatusCode)
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return retry.RetryableStatus(reqErr.HTTPStatusCode)
+	}
+	return retry.IsTransient(err)
+}
+
+// unavailable marks errors from the open breaker with ErrUnavailable.
+func unavailable(err error) error {
+	if errors.Is(err, breaker.ErrOpen) {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return err
+}
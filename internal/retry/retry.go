@@ -0,0 +1,151 @@
+// Package retry retries flaky calls with jittered exponential backoff.
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// Policy controls how many times, and how patiently, a call is retried.
+type Policy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// Retryable reports whether an error is worth another attempt. Nil retries every error not
+	// marked Permanent.
+	Retryable func(error) bool
+	// RetryAfter, if set, can return a server-requested delay for an error, which is used in
+	// place of the backoff when positive.
+	RetryAfter func(error) time.Duration
+}
+
+// Default retries transient network errors a handful of times over roughly half a minute.
+var Default = Policy{
+	MaxAttempts: 5,
+	BaseDelay:   time.Second,
+	MaxDelay:    30 * time.Second,
+	Retryable:   IsTransient,
+}
+
+// WithRetryable returns a copy of the policy using the given predicate.
+func (p Policy) WithRetryable(retryable func(error) bool) Policy {
+	p.Retryable = retryable
+	return p
+}
+
+// Delay is the backoff before the given retry (0 is the first retry), with full jitter.
+func (p Policy) Delay(retry int) time.Duration {
+	ceiling := math.Min(float64(p.BaseDelay)*math.Pow(2, float64(retry)), float64(p.MaxDelay))
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying, whatever the policy's predicate says.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Do calls fn until it succeeds, returns an error the policy won't retry, runs out of attempts
+// or ctx is done. The last error is returned, unwrapped from Permanent.
+func Do(ctx context.Context, p Policy, fn func(ctx context.Context) error) error {
+	_, err := DoValue(ctx, p, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
+
+// DoValue is Do for calls that return a value.
+func DoValue[T any](ctx context.Context, p Policy, fn func(ctx context.Context) (T, error)) (T, error) {
+	attempts := p.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var (
+		res T
+		err error
+	)
+	for i := 0; i < attempts; i++ {
+		res, err = fn(ctx)
+		if err == nil {
+			return res, nil
+		}
+
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			return res, perm.err
+		}
+		if p.Retryable != nil && !p.Retryable(err) {
+			return res, err
+		}
+		if i == attempts-1 {
+			break
+		}
+
+		delay := p.Delay(i)
+		if p.RetryAfter != nil {
+			if after := p.RetryAfter(err); after > 0 {
+				delay = after
+			}
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return res, err
+		case <-timer.C:
+		}
+	}
+	return res, err
+}
+
+// IsTransient reports whether err looks like a network hiccup: timeouts, resets, refused or
+// truncated connections.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// RetryableStatus reports whether an HTTP status code is worth retrying: 408, 429 and 5xx
+// other than 501.
+func RetryableStatus(code int) bool {
+	switch {
+	case code == http.StatusRequestTimeout, code == http.StatusTooManyRequests:
+		return true
+	case code == http.StatusNotImplemented:
+		return false
+	case code >= 500:
+		return true
+	}
+	return false
+}
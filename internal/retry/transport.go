@@ -0,0 +1,113 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Transport is an http.RoundTripper that retries requests failing with transient errors or
+// retryable status codes, honouring Retry-After. Requests with a body are only retried when they
+// can be replayed (GetBody is set, as it is for requests built from byte slices and strings).
+type Transport struct {
+	Base   http.RoundTripper
+	Policy Policy
+}
+
+// NewTransport wraps base, or http.DefaultTransport if nil, with the Default policy.
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{Base: base, Policy: Default}
+}
+
+// statusError carries a retryable response between attempts. The last one is handed back to the
+// caller as an ordinary response rather than an error.
+type statusError struct {
+	resp  *http.Response
+	after time.Duration
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("retryable response: %s", e.resp.Status)
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	policy := t.Policy
+	predicate := policy.Retryable
+	policy.Retryable = func(err error) bool {
+		if !replayable {
+			return false
+		}
+		var se *statusError
+		if errors.As(err, &se) {
+			return true
+		}
+		return predicate == nil || predicate(err)
+	}
+	policy.RetryAfter = func(err error) time.Duration {
+		var se *statusError
+		if errors.As(err, &se) {
+			return se.after
+		}
+		return 0
+	}
+
+	var pending *http.Response
+	first := true
+	resp, err := DoValue(req.Context(), policy, func(ctx context.Context) (*http.Response, error) {
+		if pending != nil {
+			drain(pending)
+			pending = nil
+		}
+		attempt := req
+		if !first && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, Permanent(err)
+			}
+			attempt = req.Clone(ctx)
+			attempt.Body = body
+		}
+		first = false
+
+		resp, err := base.RoundTrip(attempt)
+		if err != nil {
+			return nil, err
+		}
+		if RetryableStatus(resp.StatusCode) {
+			pending = resp
+			return nil, &statusError{resp: resp, after: parseRetryAfter(resp)}
+		}
+		return resp, nil
+	})
+
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.resp, nil
+	}
+	return resp, err
+}
+
+// drain discards a response that's about to be retried so its connection can be reused.
+func drain(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds.
+func parseRetryAfter(resp *http.Response) time.Duration {
+	secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
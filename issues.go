@@ -0,0 +1,136 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+
+	"github.com/geomodulus/robots/command"
+	"github.com/geomodulus/robots/github"
+)
+
+// maxIssueTitleLength keeps titles readable in GitHub's issue list.
+const maxIssueTitleLength = 80
+
+var issueGrammar = &command.Grammar{
+	Name: "@bot",
+	Commands: []*command.Command{{
+		Verb: "file a bug",
+		Args: []command.Arg{{Name: "about", Optional: true, Rest: true}},
+		Help: "Open a GitHub issue labelled bug, about the thread it's asked in if <about> is left off.",
+	}, {
+		Verb: "file an issue",
+		Args: []command.Arg{{Name: "about", Optional: true, Rest: true}},
+		Help: "Open a GitHub issue, about the thread it's asked in if <about> is left off.",
+	}},
+}
+
+// IssueFiler turns "@bot file a bug about X" into a GitHub issue linking back to the Slack
+// thread, and replies with a link to it. Check HandlesMention from the bot's app mention handler
+// and pass matching mentions to HandleAppMention. Other handlers, eg. for validation failures,
+// can call File directly.
+type IssueFiler struct {
+	Bot  *SlackBot
+	Repo *github.App
+	// Labels are added to every issue. "bug" is added to bugs.
+	Labels []string
+}
+
+// HandlesMention reports whether a mention asks for an issue.
+func (f *IssueFiler) HandlesMention(text string) bool {
+	return issueGrammar.Mentions(text)
+}
+
+func (f *IssueFiler) HandleAppMention(ctx context.Context, env *Envelope, ev *slackevents.AppMentionEvent) error {
+	inv, help, err := parseMention(env, issueGrammar)
+	if err != nil {
+		return err
+	}
+	if inv == nil {
+		return f.Bot.ReplyTo(env, slack.MsgOptionBlocks(help...))
+	}
+	about := strings.TrimSpace(userMentionRe.ReplaceAllString(dropPreposition(inv.Arg("about")), ""))
+
+	// In a thread, the root message is usually what the issue is about.
+	details := ""
+	if ev.ThreadTimeStamp != "" {
+		msgs, err := f.Bot.ThreadMessages(ctx, env.ChannelID, env.ThreadTS)
+		if err != nil {
+			return err
+		}
+		if len(msgs) > 0 && msgs[0].Timestamp != env.MessageTS {
+			details = msgs[0].Text
+		}
+	}
+	if about == "" {
+		about = firstLine(details)
+	}
+	if about == "" {
+		return fmt.Errorf("usage: file a bug about <what's wrong>, or ask in the thread it's about")
+	}
+
+	labels := []string{}
+	if inv.Verb() == "file a bug" {
+		labels = append(labels, "bug")
+	}
+	_, _, err = f.File(ctx, env, about, details, labels...)
+	return err
+}
+
+// File opens an issue titled title, with details and a link to env's message in the body, and
+// replies in env's thread with a link to the issue.
+func (f *IssueFiler) File(ctx context.Context, env *Envelope, title, details string, labels ...string) (int, string, error) {
+	var body strings.Builder
+	if details != "" {
+		fmt.Fprintf(&body, "%s\n\n", quoteMarkdown(details))
+	}
+	reporter := env.UserID
+	if name, err := f.Bot.UserName(ctx, env.UserID); err == nil && name != "" {
+		reporter = name
+	}
+	fmt.Fprintf(&body, "Filed from Slack by %s", reporter)
+	if permalink, err := env.Permalink(ctx); err == nil {
+		fmt.Fprintf(&body, ": %s", permalink)
+	}
+	body.WriteString("\n")
+
+	num, url, err := f.Repo.CreateIssue(ctx, truncateTitle(title), body.String(), append(append([]string{}, f.Labels...), labels...))
+	if err != nil {
+		return 0, "", err
+	}
+	err = f.Bot.ReplyTo(env, slack.MsgOptionText(fmt.Sprintf(":memo: Filed <%s|#%d: %s>", url, num, escapeMrkdwn(truncateTitle(title))), false))
+	return num, url, err
+}
+
+// dropPreposition drops the word leading into what an issue is about, as in "file a bug about
+// the map".
+func dropPreposition(about string) string {
+	word, rest, _ := strings.Cut(about, " ")
+	switch strings.ToLower(word) {
+	case "about", "for", "on", "re", "re:":
+		return rest
+	}
+	return about
+}
+
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return strings.TrimSpace(line)
+}
+
+func truncateTitle(title string) string {
+	title = strings.Join(strings.Fields(title), " ")
+	if len([]rune(title)) <= maxIssueTitleLength {
+		return title
+	}
+	return string([]rune(title)[:maxIssueTitleLength-1]) + "…"
+}
+
+// quoteMarkdown block-quotes text, so Slack formatting in it doesn't run into the rest of the
+// issue.
+func quoteMarkdown(text string) string {
+	return "> " + strings.ReplaceAll(strings.TrimSpace(text), "\n", "\n> ")
+}
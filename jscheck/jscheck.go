@@ -0,0 +1,263 @@
+// Package jscheck cross-checks an article's article.js against the datasets its article.json
+// declares, so interactive maps don't break silently: it finds references to datasets that don't
+// exist, datasets nothing refers to, and code that never runs.
+package jscheck
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/geomodulus/citygraph"
+)
+
+// Rule names a check.
+type Rule string
+
+const (
+	// MissingDataset is a reference to a dataset article.json doesn't declare, which fails when
+	// the map loads.
+	MissingDataset Rule = "missing-dataset"
+	// MissingProperty is a property of a dataset object, eg. datasets.wards, that article.json
+	// doesn't declare. The object might be some other datasets, like a Chart.js chart's, so it
+	// doesn't count as breaking the page.
+	MissingProperty Rule = "missing-property"
+	// UnusedDataset is a declared dataset nothing refers to.
+	UnusedDataset Rule = "unused-dataset"
+	// UnusedFunction is a function that's declared but never called.
+	UnusedFunction Rule = "unused-function"
+	// UnreachableCode follows a return or throw in the same block.
+	UnreachableCode Rule = "unreachable-code"
+)
+
+// Finding is one problem in the script.
+type Finding struct {
+	Rule Rule
+	// Line is where the problem is, counting from 1, or 0 for problems with article.json.
+	Line    int
+	Message string
+}
+
+func (f Finding) String() string {
+	if f.Line == 0 {
+		return f.Message
+	}
+	return fmt.Sprintf("line %d: %s", f.Line, f.Message)
+}
+
+// Breaks reports whether the finding breaks the page rather than just being untidy.
+func (f Finding) Breaks() bool {
+	return f.Rule == MissingDataset
+}
+
+// ModuleAPI is how scripts on the site refer to an article's datasets, besides by file name, eg.
+// "locations.geojson".
+type ModuleAPI struct {
+	// DatasetFuncs take a dataset's name as their first argument, eg. loadDataset("locations").
+	DatasetFuncs []string
+	// DatasetObjects are indexed by dataset name, eg. datasets.locations or
+	// datasets["locations"].
+	DatasetObjects []string
+}
+
+// DefaultModuleAPI is the site's module API.
+var DefaultModuleAPI = ModuleAPI{
+	DatasetFuncs:   []string{"loadDataset", "getDataset", "dataset"},
+	DatasetObjects: []string{"datasets"},
+}
+
+var (
+	identRe = `[A-Za-z_$][\w$]*`
+	// functionDeclRe matches "function name(" and "const name = (...) =>" or "= function".
+	functionDeclRe = regexp.MustCompile(`(?m)^\s*(?:async\s+)?function\s*\*?\s*(` + identRe + `)\s*\(|^\s*(?:const|let|var)\s+(` + identRe + `)\s*=\s*(?:async\s+)?(?:function\b|\([^()]*\)\s*=>|` + identRe + `\s*=>)`)
+	terminatorRe   = regexp.MustCompile(`^\s*(?:return|throw)\b`)
+)
+
+// Check lists the problems in js, the body of article.js, given the datasets its article.json
+// declares. Datasets the site draws itself, those with Render set, count as used.
+func Check(js string, datasets []*citygraph.GeoJSONDataset, api ModuleAPI) []Finding {
+	src := lex(js)
+	findings := []Finding{}
+
+	declared := map[string]*citygraph.GeoJSONDataset{}
+	for _, dataset := range datasets {
+		declared[dataset.Name] = dataset
+	}
+	used := map[string]bool{}
+	reported := map[string]bool{}
+	for _, ref := range datasetRefs(src, datasets, api) {
+		if _, ok := declared[ref.name]; ok {
+			used[ref.name] = true
+			continue
+		}
+		if !reported[ref.name] {
+			rule := MissingDataset
+			if ref.property {
+				rule = MissingProperty
+			}
+			findings = append(findings, Finding{
+				Rule:    rule,
+				Line:    ref.line,
+				Message: fmt.Sprintf("dataset %q isn't declared in article.json", ref.name),
+			})
+			reported[ref.name] = true
+		}
+	}
+	for _, dataset := range datasets {
+		if !used[dataset.Name] && dataset.Render == "" {
+			findings = append(findings, Finding{
+				Rule:    UnusedDataset,
+				Message: fmt.Sprintf("dataset %q is declared in article.json but never used", dataset.Name),
+			})
+		}
+	}
+
+	findings = append(findings, unusedFunctions(src)...)
+	findings = append(findings, unreachableCode(src)...)
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Line < findings[j].Line
+	})
+	return findings
+}
+
+type datasetRef struct {
+	name string
+	line int
+	// property is set for references like datasets.wards, which aren't certain to be datasets.
+	property bool
+}
+
+// builtinMembers are Array and Object members, which aren't datasets when a script calls
+// datasets.forEach or reads datasets.length.
+var builtinMembers = map[string]bool{
+	"length": true, "push": true, "pop": true, "shift": true, "unshift": true, "slice": true,
+	"splice": true, "concat": true, "forEach": true, "map": true, "filter": true, "reduce": true,
+	"find": true, "findIndex": true, "some": true, "every": true, "includes": true, "indexOf": true,
+	"join": true, "sort": true, "reverse": true, "flat": true, "flatMap": true, "keys": true,
+	"values": true, "entries": true, "at": true, "hasOwnProperty": true, "toString": true,
+	"constructor": true,
+}
+
+func datasetRefs(src *source, datasets []*citygraph.GeoJSONDataset, api ModuleAPI) []datasetRef {
+	refs := []datasetRef{}
+
+	// Calls and indexes whose argument is a string literal.
+	for _, fn := range api.DatasetFuncs {
+		re := regexp.MustCompile(`\b` + regexp.QuoteMeta(fn) + `\s*\(\s*`)
+		for _, loc := range re.FindAllStringIndex(src.code, -1) {
+			if lit, ok := src.literalAt(loc[1]); ok {
+				refs = append(refs, datasetRef{name: lit.value, line: lit.line})
+			}
+		}
+	}
+	for _, obj := range api.DatasetObjects {
+		// Not a property of something else, like chart.data.datasets.
+		re := regexp.MustCompile(`(?:^|[^\w$.])(` + regexp.QuoteMeta(obj) + `)\s*(?:\.\s*(` + identRe + `)|\[\s*)`)
+		for _, match := range re.FindAllStringSubmatchIndex(src.code, -1) {
+			if match[4] >= 0 {
+				name := src.code[match[4]:match[5]]
+				if !builtinMembers[name] {
+					refs = append(refs, datasetRef{name: name, line: src.lineAt(match[2]), property: true})
+				}
+			} else if lit, ok := src.literalAt(match[1]); ok {
+				refs = append(refs, datasetRef{name: lit.value, line: lit.line})
+			}
+		}
+	}
+
+	// File names and URLs.
+	byURL := map[string]string{}
+	for _, dataset := range datasets {
+		if dataset.URL != "" {
+			byURL[dataset.URL] = dataset.Name
+		}
+	}
+	for _, lit := range src.literals {
+		if name, ok := byURL[lit.value]; ok {
+			refs = append(refs, datasetRef{name: name, line: lit.line})
+			continue
+		}
+		// Only the article's own files, named on their own. Paths like /data/wards.geojson and
+		// URLs elsewhere are someone else's data.
+		if strings.HasSuffix(lit.value, ".geojson") && !strings.Contains(strings.TrimPrefix(lit.value, "./"), "/") {
+			refs = append(refs, datasetRef{name: strings.TrimSuffix(path.Base(lit.value), ".geojson"), line: lit.line})
+		}
+	}
+	return refs
+}
+
+// unusedFunctions finds named functions whose names appear nowhere but their declaration.
+func unusedFunctions(src *source) []Finding {
+	findings := []Finding{}
+	for _, match := range functionDeclRe.FindAllStringSubmatchIndex(src.code, -1) {
+		start, end := match[2], match[3]
+		if start < 0 {
+			start, end = match[4], match[5]
+		}
+		name := src.code[start:end]
+		uses := regexp.MustCompile(`(?:^|[^\w$.])`+regexp.QuoteMeta(name)+`\b`).FindAllStringIndex(src.code, -1)
+		if len(uses) <= 1 {
+			findings = append(findings, Finding{
+				Rule:    UnusedFunction,
+				Line:    src.lineAt(start),
+				Message: fmt.Sprintf("function %s is never called", name),
+			})
+		}
+	}
+	return findings
+}
+
+// unreachableCode finds statements after a return or throw in the same block. It reads the
+// script line by line, as prettier leaves it, so a statement is a line and its continuations.
+func unreachableCode(src *source) []Finding {
+	findings := []Finding{}
+	lines := strings.Split(src.code, "\n")
+	for i := 0; i < len(lines); i++ {
+		if !terminatorRe.MatchString(lines[i]) {
+			continue
+		}
+		indent := indentOf(lines[i])
+		// Skip the rest of the returned expression.
+		depth := bracketDepth(lines[i])
+		j := i + 1
+		for ; j < len(lines) && depth > 0; j++ {
+			depth += bracketDepth(lines[j])
+		}
+		for ; j < len(lines) && strings.TrimSpace(lines[j]) == ""; j++ {
+		}
+		if j == len(lines) {
+			continue
+		}
+		next := strings.TrimSpace(lines[j])
+		if indentOf(lines[j]) != indent || strings.HasPrefix(next, "}") || strings.HasPrefix(next, ")") ||
+			strings.HasPrefix(next, "case ") || strings.HasPrefix(next, "default:") || strings.HasPrefix(next, "function") {
+			continue
+		}
+		findings = append(findings, Finding{
+			Rule:    UnreachableCode,
+			Line:    j + 1,
+			Message: fmt.Sprintf("unreachable: follows the %s on line %d", strings.Fields(lines[i])[0], i+1),
+		})
+		i = j
+	}
+	return findings
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+func bracketDepth(line string) int {
+	depth := 0
+	for _, r := range line {
+		switch r {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		}
+	}
+	return depth
+}
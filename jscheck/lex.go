@@ -0,0 +1,148 @@
+package jscheck
+
+import "strings"
+
+// source is a script with its comments removed and the text of its string literals blanked, so
+// searches of code don't match inside them. Offsets and lines are the same as the original's.
+type source struct {
+	code     string
+	literals []literal
+	// lineStarts are the offsets at which each line starts.
+	lineStarts []int
+}
+
+// literal is a string literal without interpolation.
+type literal struct {
+	value string
+	// start is the offset of the opening quote.
+	start int
+	line  int
+}
+
+// literalAt returns the literal whose opening quote is at offset.
+func (s *source) literalAt(offset int) (literal, bool) {
+	for _, lit := range s.literals {
+		if lit.start == offset {
+			return lit, true
+		}
+	}
+	return literal{}, false
+}
+
+// lineAt returns the line offset is on, counting from 1.
+func (s *source) lineAt(offset int) int {
+	line := 0
+	for line < len(s.lineStarts) && s.lineStarts[line] <= offset {
+		line++
+	}
+	return line
+}
+
+// lex strips js's comments and blanks its strings. Regular expression literals aren't
+// recognized, so a quote inside one can throw it off; article scripts rarely have them.
+func lex(js string) *source {
+	src := &source{lineStarts: []int{0}}
+	for i, c := range js {
+		if c == '\n' {
+			src.lineStarts = append(src.lineStarts, i+1)
+		}
+	}
+
+	code := []byte(js)
+	blank := func(from, to int) {
+		for k := from; k < to && k < len(code); k++ {
+			if code[k] != '\n' {
+				code[k] = ' '
+			}
+		}
+	}
+
+	// templateDepth holds, for each ${ we're inside, how many braces are open within it.
+	templateDepth := []int{}
+	for i := 0; i < len(js); i++ {
+		switch c := js[i]; {
+		case c == '/' && i+1 < len(js) && js[i+1] == '/':
+			end := strings.IndexByte(js[i:], '\n')
+			if end < 0 {
+				end = len(js) - i
+			}
+			blank(i, i+end)
+			i += end - 1
+		case c == '/' && i+1 < len(js) && js[i+1] == '*':
+			end := strings.Index(js[i+2:], "*/")
+			if end < 0 {
+				end = len(js) - i - 2
+			}
+			blank(i, i+end+4)
+			i += end + 3
+		case c == '\'' || c == '"':
+			end := closingQuote(js, i+1, c)
+			src.literals = append(src.literals, literal{value: unescape(js[i+1 : end]), start: i, line: src.lineAt(i)})
+			blank(i+1, end)
+			i = end
+		case c == '`' || (c == '}' && len(templateDepth) > 0 && templateDepth[len(templateDepth)-1] == 0):
+			if c == '}' {
+				templateDepth = templateDepth[:len(templateDepth)-1]
+			}
+			// Read template text up to its end or the next interpolation.
+			start := i
+			j := i + 1
+			for j < len(js) && js[j] != '`' && !(js[j] == '$' && j+1 < len(js) && js[j+1] == '{') {
+				if js[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if c == '`' && (j >= len(js) || js[j] == '`') {
+				src.literals = append(src.literals, literal{value: unescape(js[i+1 : min(j, len(js))]), start: start, line: src.lineAt(i)})
+			}
+			blank(i+1, j)
+			if j < len(js) && js[j] == '$' {
+				templateDepth = append(templateDepth, 0)
+				j++
+			}
+			i = j
+		case c == '{' && len(templateDepth) > 0:
+			templateDepth[len(templateDepth)-1]++
+		case c == '}' && len(templateDepth) > 0:
+			templateDepth[len(templateDepth)-1]--
+		}
+	}
+	src.code = string(code)
+	return src
+}
+
+// closingQuote returns the offset of the quote ending a string that starts at from, or the end of
+// the line if it isn't closed.
+func closingQuote(js string, from int, quote byte) int {
+	for i := from; i < len(js); i++ {
+		switch js[i] {
+		case '\\':
+			i++
+		case quote, '\n':
+			return i
+		}
+	}
+	return len(js)
+}
+
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,399 @@
+package robots
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/geomodulus/citygraph"
+	"github.com/slack-go/slack"
+
+	"github.com/geomodulus/robots/state"
+)
+
+// JSONExpandActionID is the button under a JSON preview that posts the whole file. Forward it
+// from the bot's HandleBlockAction to JSONPreviews.HandleBlockAction.
+const JSONExpandActionID = "json_expand"
+
+const (
+	// jsonPreviewItems is how many elements of an array a preview shows.
+	jsonPreviewItems = 3
+	// jsonPreviewLines is how many lines of a preview are shown before it's cut off.
+	jsonPreviewLines = 30
+	// defaultJSONPreviewTTL is how long a file can be expanded by default.
+	defaultJSONPreviewTTL = 7 * 24 * time.Hour
+)
+
+// jsonPreviewIndexKey lists the files kept for previews with when they expire, so expired ones
+// can be deleted.
+const jsonPreviewIndexKey = "json-previews/index"
+
+// JSONPreviews shows JSON and GeoJSON files in Slack as short, readable previews instead of a
+// wall of coordinates: long arrays are cut down, the rest is indented, and an "Expand" button
+// posts the whole file as a snippet, which Slack highlights. Files are kept in Store until
+// they're expanded or expire.
+type JSONPreviews struct {
+	Bot   *SlackBot
+	Store state.Store
+	// TTL is how long a file can be expanded. Defaults to a week.
+	TTL time.Duration
+
+	// mu serializes updates to the index, which read, change and write it back.
+	mu sync.Mutex
+}
+
+type jsonPreviewFile struct {
+	Name    string    `json:"name"`
+	Content string    `json:"content"`
+	Expires time.Time `json:"expires"`
+}
+
+func jsonPreviewKey(id string) string {
+	return "json-previews/" + id
+}
+
+// isJSONFile reports whether name is a JSON or GeoJSON file.
+func isJSONFile(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	return ext == ".json" || ext == ".geojson"
+}
+
+// hasButton reports whether block is an actions block with a button for one of actionIDs. A
+// message that swaps its buttons for an outcome uses it to keep any preview's expand button.
+func hasButton(block slack.Block, actionIDs ...string) bool {
+	actions, ok := block.(*slack.ActionBlock)
+	if !ok || actions.Elements == nil {
+		return false
+	}
+	for _, element := range actions.Elements.ElementSet {
+		button, ok := element.(*slack.ButtonBlockElement)
+		if !ok {
+			continue
+		}
+		for _, id := range actionIDs {
+			if button.ActionID == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HandlesAction reports whether actionID is the expand button.
+func (p *JSONPreviews) HandlesAction(actionID string) bool {
+	return actionID == JSONExpandActionID
+}
+
+// Blocks returns a preview of the JSON file name, eg. "locations.geojson", for a message. The
+// preview is a summary line, the trimmed file, and an expand button if anything was left out.
+func (p *JSONPreviews) Blocks(ctx context.Context, name string, content []byte) ([]slack.Block, error) {
+	preview, summary, trimmed, err := previewJSON(content)
+	if err != nil {
+		return nil, fmt.Errorf("error previewing %s: %v", name, err)
+	}
+	blocks := []slack.Block{
+		slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType,
+			fmt.Sprintf("*%s* · %s · %s", escapeMrkdwn(name), summary, formatBytes(int64(len(content)))), false, false)),
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, "```"+preview+"```", false, false), nil, nil),
+	}
+	if !trimmed {
+		return blocks, nil
+	}
+
+	id := citygraph.NewID().String()
+	ttl := p.TTL
+	if ttl == 0 {
+		ttl = defaultJSONPreviewTTL
+	}
+	expires := time.Now().Add(ttl)
+	if err := p.Store.Put(ctx, jsonPreviewKey(id), &jsonPreviewFile{Name: name, Content: string(content), Expires: expires}); err != nil {
+		return nil, fmt.Errorf("error saving %s: %v", name, err)
+	}
+	if err := p.index(ctx, id, expires); err != nil {
+		return nil, err
+	}
+	blocks = append(blocks, slack.NewActionBlock("",
+		slack.NewButtonBlockElement(JSONExpandActionID, id,
+			slack.NewTextBlockObject(slack.PlainTextType, "Expand", false, false)),
+	))
+	return blocks, nil
+}
+
+// HandleBlockAction posts the whole file an expand button belongs to in the message's thread.
+func (p *JSONPreviews) HandleBlockAction(ctx context.Context, env *Envelope, action, value string, callback slack.InteractionCallback) error {
+	if action != JSONExpandActionID {
+		return fmt.Errorf("unknown action %q", action)
+	}
+	file := &jsonPreviewFile{}
+	found, err := p.Store.Get(ctx, jsonPreviewKey(value), file)
+	if err != nil {
+		return fmt.Errorf("error loading file: %v", err)
+	}
+	if !found {
+		return fmt.Errorf("that file has already been posted, or has expired")
+	}
+	if time.Now().After(file.Expires) {
+		return fmt.Errorf("that file has expired")
+	}
+
+	threadTS := callback.Message.ThreadTimestamp
+	if threadTS == "" {
+		threadTS = env.MessageTS
+	}
+	if _, err := p.Bot.UploadFileV2Context(ctx, slack.UploadFileV2Parameters{
+		Content:         file.Content,
+		FileSize:        len(file.Content),
+		Filename:        file.Name,
+		Title:           file.Name,
+		Channel:         env.ChannelID,
+		ThreadTimestamp: threadTS,
+	}); err != nil {
+		return fmt.Errorf("error uploading %s: %v", file.Name, err)
+	}
+	if err := p.Store.Delete(ctx, jsonPreviewKey(value)); err != nil {
+		return fmt.Errorf("error deleting file: %v", err)
+	}
+
+	// Swap the button for a note so the file isn't posted twice. Other buttons on the message
+	// belong to other components, so only this one goes.
+	blocks := []slack.Block{}
+	for _, block := range callback.Message.Blocks.BlockSet {
+		if actions, ok := block.(*slack.ActionBlock); ok && hasAction(actions, JSONExpandActionID, value) {
+			blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject(slack.MarkdownType,
+				fmt.Sprintf(":page_facing_up: <@%s> posted the full %s in the thread.", env.UserID, escapeMrkdwn(file.Name)), false, false)))
+			continue
+		}
+		blocks = append(blocks, block)
+	}
+	_, _, _, err = p.Bot.UpdateMessageContext(ctx, env.ChannelID, env.MessageTS, slack.MsgOptionBlocks(blocks...))
+	return err
+}
+
+// index adds a stored file to the index, deleting the files that have expired.
+func (p *JSONPreviews) index(ctx context.Context, id string, expires time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	index := map[string]time.Time{}
+	if _, err := p.Store.Get(ctx, jsonPreviewIndexKey, &index); err != nil {
+		return fmt.Errorf("error loading preview index: %v", err)
+	}
+	now := time.Now()
+	for stored, at := range index {
+		if now.After(at) {
+			if err := p.Store.Delete(ctx, jsonPreviewKey(stored)); err != nil {
+				return fmt.Errorf("error deleting expired preview: %v", err)
+			}
+			delete(index, stored)
+		}
+	}
+	index[id] = expires
+	if err := p.Store.Put(ctx, jsonPreviewIndexKey, index); err != nil {
+		return fmt.Errorf("error saving preview index: %v", err)
+	}
+	return nil
+}
+
+func hasAction(block *slack.ActionBlock, actionID, value string) bool {
+	for _, element := range block.Elements.ElementSet {
+		if button, ok := element.(*slack.ButtonBlockElement); ok && button.ActionID == actionID && button.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// previewJSON returns an indented copy of content with long arrays cut down and, if needed, the
+// end cut off, along with a one-line summary and whether anything was left out.
+func previewJSON(content []byte) (string, string, bool, error) {
+	var value any
+	if err := json.Unmarshal(content, &value); err != nil {
+		return "", "", false, err
+	}
+	summary := summarizeJSON(value)
+
+	var b strings.Builder
+	trimmed := false
+	if err := writeJSONPreview(&b, bytes.TrimSpace(content), "", &trimmed); err != nil {
+		return "", "", false, err
+	}
+	preview := b.String()
+
+	lines := strings.Split(preview, "\n")
+	if len(lines) > jsonPreviewLines {
+		lines = append(lines[:jsonPreviewLines], fmt.Sprintf("… %d more lines", len(lines)-jsonPreviewLines))
+		preview = strings.Join(lines, "\n")
+		trimmed = true
+	}
+	// Leave room in the section for the fences.
+	if len(preview) > maxSectionLength-10 {
+		cut := strings.LastIndex(preview[:maxSectionLength-10], "\n")
+		if cut < 0 {
+			cut = maxSectionLength - 10
+			for cut > 0 && !utf8.RuneStart(preview[cut]) {
+				cut--
+			}
+		}
+		preview = preview[:cut] + "\n…"
+		trimmed = true
+	}
+	return preview, summary, trimmed, nil
+}
+
+// writeJSONPreview writes raw indented, in its original key order. Arrays are cut to their
+// first few elements, with a note saying how many more there were, and arrays of numbers, like
+// coordinates, are kept on one line. It sets trimmed if it cut anything.
+func writeJSONPreview(b *strings.Builder, raw json.RawMessage, indent string, trimmed *bool) error {
+	if len(raw) == 0 || (raw[0] != '{' && raw[0] != '[') {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, raw); err != nil {
+			return err
+		}
+		b.Write(compact.Bytes())
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	inner := indent + "  "
+
+	if raw[0] == '{' {
+		b.WriteString("{")
+		first := true
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			var value json.RawMessage
+			if err := decoder.Decode(&value); err != nil {
+				return err
+			}
+			if !first {
+				b.WriteString(",")
+			}
+			first = false
+			name, _ := json.Marshal(key)
+			fmt.Fprintf(b, "\n%s%s: ", inner, name)
+			if err := writeJSONPreview(b, value, inner, trimmed); err != nil {
+				return err
+			}
+		}
+		if !first {
+			b.WriteString("\n" + indent)
+		}
+		b.WriteString("}")
+		return nil
+	}
+
+	elements := []json.RawMessage{}
+	for decoder.More() {
+		var element json.RawMessage
+		if err := decoder.Decode(&element); err != nil {
+			return err
+		}
+		elements = append(elements, element)
+	}
+	if len(elements) == 0 {
+		b.WriteString("[]")
+		return nil
+	}
+	// A coordinate pair or bounding box reads best whole, on one line.
+	if allNumbers(elements) {
+		shown := elements
+		if len(shown) > 2*jsonPreviewItems {
+			shown = shown[:2*jsonPreviewItems]
+			*trimmed = true
+		}
+		parts := []string{}
+		for _, element := range shown {
+			parts = append(parts, string(element))
+		}
+		if len(shown) < len(elements) {
+			parts = append(parts, fmt.Sprintf("… %d more", len(elements)-len(shown)))
+		}
+		b.WriteString("[" + strings.Join(parts, ", ") + "]")
+		return nil
+	}
+	shown := elements
+	if len(shown) > jsonPreviewItems {
+		shown = shown[:jsonPreviewItems]
+		*trimmed = true
+	}
+	b.WriteString("[")
+	for i, element := range shown {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n" + inner)
+		if err := writeJSONPreview(b, element, inner, trimmed); err != nil {
+			return err
+		}
+	}
+	if len(shown) < len(elements) {
+		fmt.Fprintf(b, ",\n%s… %d more", inner, len(elements)-len(shown))
+	}
+	b.WriteString("\n" + indent + "]")
+	return nil
+}
+
+func allNumbers(elements []json.RawMessage) bool {
+	for _, element := range elements {
+		if len(element) == 0 || !(element[0] == '-' || (element[0] >= '0' && element[0] <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+// summarizeJSON describes a value in a few words: a GeoJSON feature collection's features by
+// geometry, an array's length or an object's keys.
+func summarizeJSON(value any) string {
+	switch v := value.(type) {
+	case map[string]any:
+		if v["type"] == "FeatureCollection" {
+			return summarizeFeatures(v["features"])
+		}
+		if v["type"] == "Feature" {
+			return "GeoJSON feature"
+		}
+		return fmt.Sprintf("object with %d keys", len(v))
+	case []any:
+		return fmt.Sprintf("array of %d", len(v))
+	}
+	return "value"
+}
+
+func summarizeFeatures(features any) string {
+	list, _ := features.([]any)
+	counts := map[string]int{}
+	order := []string{}
+	for _, feature := range list {
+		object, _ := feature.(map[string]any)
+		geometry, _ := object["geometry"].(map[string]any)
+		kind, _ := geometry["type"].(string)
+		if kind == "" {
+			kind = "no geometry"
+		}
+		if counts[kind] == 0 {
+			order = append(order, kind)
+		}
+		counts[kind]++
+	}
+	parts := []string{}
+	for _, kind := range order {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[kind], kind))
+	}
+	summary := fmt.Sprintf("%d features", len(list))
+	if len(parts) > 0 {
+		summary += ": " + strings.Join(parts, ", ")
+	}
+	return summary
+}
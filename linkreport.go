@@ -0,0 +1,105 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/geomodulus/robots/github"
+	"github.com/geomodulus/robots/search"
+)
+
+// LinkReportJob posts a report of orphan articles, the live articles no other article links to,
+// to Channel, with suggestions of related articles that could link to each. Register Run with a
+// Scheduler, eg.
+//
+//	scheduler.Weekly("link-report", time.Monday, 10, 0, toronto, job.Run)
+type LinkReportJob struct {
+	Bot      *SlackBot
+	Articles *github.App
+	// Search finds related articles to suggest links from. Without it orphans are only listed.
+	Search  *search.Client
+	Channel string
+	// MaxOrphans is how many orphans to list. Zero means 20.
+	MaxOrphans int
+	// Suggestions is how many links to suggest for each orphan. Zero means 3.
+	Suggestions int
+}
+
+func (j *LinkReportJob) Run(ctx context.Context) error {
+	report, err := j.Report(ctx)
+	if err != nil {
+		return err
+	}
+	env := &Envelope{ChannelID: j.Channel, bot: j.Bot}
+	_, err = j.Bot.PostLong(ctx, env, "Orphan articles", report, OutputAuto)
+	return err
+}
+
+// Report builds the report as Markdown.
+func (j *LinkReportJob) Report(ctx context.Context) (string, error) {
+	graph, err := j.Articles.ArticleLinkGraph(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error building link graph: %v", err)
+	}
+	orphans := graph.Orphans()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d of %d live articles have no links from other articles.\n", len(orphans), len(graph.Articles))
+	max := j.MaxOrphans
+	if max == 0 {
+		max = 20
+	}
+	if len(orphans) > max {
+		fmt.Fprintf(&b, "The oldest %d:\n", max)
+		orphans = orphans[:max]
+	}
+	for _, slug := range orphans {
+		article := graph.Articles[slug]
+		fmt.Fprintf(&b, "\n**%s** (`%s`, %s)\n", article.Name, slug, article.PubDate)
+		suggestions, err := j.suggest(graph, slug)
+		if err != nil {
+			fmt.Fprintf(&b, "- Couldn't find related articles: %v\n", err)
+			continue
+		}
+		for _, from := range suggestions {
+			fmt.Fprintf(&b, "- Link from **%s** (`%s`)\n", graph.Articles[from].Name, from)
+		}
+	}
+	return b.String(), nil
+}
+
+// suggest returns live articles related to the orphan that don't link to it yet, most related
+// first.
+func (j *LinkReportJob) suggest(graph *github.LinkGraph, orphan string) ([]string, error) {
+	if j.Search == nil {
+		return nil, nil
+	}
+	n := j.Suggestions
+	if n == 0 {
+		n = 3
+	}
+	article := graph.Articles[orphan]
+	// Ask for a few extra, since the orphan itself and articles already linking to it come back too.
+	results, err := j.Search.RunQuery(article.Name+"\n"+article.Description, search.WithLimit(n+2))
+	if err != nil {
+		return nil, err
+	}
+	// Results are matched by ID, since the slug in the index isn't always the directory name.
+	byID := map[string]string{}
+	for slug, a := range graph.Articles {
+		byID[a.ID] = slug
+	}
+	out := []string{}
+	for _, result := range results {
+		if len(out) == n {
+			break
+		}
+		from := byID[result.ID]
+		if from == "" || from == orphan || graph.Links(from, orphan) {
+			continue
+		}
+		out = append(out, from)
+	}
+	return out, nil
+}
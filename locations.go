@@ -0,0 +1,204 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/geomodulus/citygraph"
+	"github.com/slack-go/slack"
+
+	"github.com/geomodulus/robots/audit"
+	"github.com/geomodulus/robots/github"
+	"github.com/geomodulus/robots/locations"
+	"github.com/geomodulus/robots/mapbox"
+	"github.com/geomodulus/robots/state"
+)
+
+// Action IDs of the buttons on a locations proposal. Forward them from the bot's
+// HandleBlockAction to LocationsReview.HandleBlockAction.
+const (
+	LocationsAttachActionID  = "locations_attach"
+	LocationsDiscardActionID = "locations_discard"
+)
+
+// LocationsReview proposes locations for a draft article in Slack and, once an editor confirms,
+// commits them to the article's PR as locations.geojson.
+type LocationsReview struct {
+	Bot       *SlackBot
+	Articles  *github.App
+	Extractor *locations.Extractor
+	// Store keeps proposals between posting and confirmation.
+	Store state.Store
+	// MapboxToken, if set, adds a map of the proposed locations.
+	MapboxToken string
+	// Locks, if set, holds the article for whoever a proposal is posted for until it's attached
+	// or discarded, so no one else's edits race it.
+	Locks *DraftLocks
+	// BreakingNews, if set, fast-tracks the PRs of slugs in breaking-news mode.
+	BreakingNews *BreakingNews
+	// Previews, if set, shows the locations.geojson that would be attached.
+	Previews *JSONPreviews
+}
+
+type locationsProposal struct {
+	Slug     string `json:"slug"`
+	PRNum    int    `json:"pr_num"`
+	GeoJSON  string `json:"geojson"`
+	Features int    `json:"features"`
+}
+
+func locationsProposalKey(id string) string {
+	return "locations/" + id
+}
+
+// HandlesAction reports whether actionID is one of the proposal buttons.
+func (r *LocationsReview) HandlesAction(actionID string) bool {
+	return actionID == LocationsAttachActionID || actionID == LocationsDiscardActionID
+}
+
+// Propose extracts locations from bodyHTML and posts them in env's thread, with buttons to
+// attach them to PR prNum or discard them.
+func (r *LocationsReview) Propose(ctx context.Context, env *Envelope, slug string, prNum int, bodyHTML string) error {
+	proposal, err := r.Extractor.Extract(ctx, bodyHTML)
+	if err != nil {
+		return err
+	}
+	if len(proposal.Mentions) == 0 {
+		return r.Bot.ReplyTo(env, slack.MsgOptionText("I couldn't find any locations in the article.", false))
+	}
+
+	lines := []string{}
+	for _, mention := range proposal.Mentions {
+		if mention.Result == nil {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf(":round_pushpin: *%s* — %s", escapeMrkdwn(mention.Name), escapeMrkdwn(mention.Result.DisplayName)))
+	}
+	for _, mention := range proposal.Unresolved() {
+		lines = append(lines, fmt.Sprintf(":grey_question: *%s* — couldn't find `%s`", escapeMrkdwn(mention.Name), escapeMrkdwn(mention.Query)))
+	}
+	text := fitLines(fmt.Sprintf("*Proposed locations for `%s`*", slug), lines)
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+	}
+
+	if len(proposal.Features.Features) == 0 {
+		blocks = append(blocks, slack.NewContextBlock("",
+			slack.NewTextBlockObject(slack.MarkdownType, "None of them could be placed on the map, so there's nothing to attach.", false, false)))
+		return r.Bot.ReplyTo(env, slack.MsgOptionBlocks(blocks...))
+	}
+
+	if r.Locks != nil {
+		if ok, err := r.Locks.Acquire(ctx, env, slug); !ok || err != nil {
+			return err
+		}
+	}
+
+	if r.MapboxToken != "" {
+		if mapURL := mapbox.StaticMapURL(r.MapboxToken, proposal.Features, 600, 300); mapURL != "" {
+			blocks = append(blocks, slack.NewImageBlock(mapURL, "Map of proposed locations", "", nil))
+		}
+	}
+
+	geoJSON, err := proposal.Features.MarshalJSON()
+	if err != nil {
+		return fmt.Errorf("error encoding locations: %v", err)
+	}
+	id := citygraph.NewID().String()
+	if err := r.Store.Put(ctx, locationsProposalKey(id), &locationsProposal{
+		Slug:     slug,
+		PRNum:    prNum,
+		GeoJSON:  string(geoJSON),
+		Features: len(proposal.Features.Features),
+	}); err != nil {
+		return fmt.Errorf("error saving locations proposal: %v", err)
+	}
+	if r.Previews != nil {
+		preview, err := r.Previews.Blocks(ctx, "locations.geojson", geoJSON)
+		if err != nil {
+			return err
+		}
+		blocks = append(blocks, preview...)
+	}
+
+	blocks = append(blocks, slack.NewActionBlock("",
+		slack.NewButtonBlockElement(LocationsAttachActionID, id,
+			slack.NewTextBlockObject(slack.PlainTextType, "Attach to PR", false, false)).WithStyle(slack.StylePrimary),
+		slack.NewButtonBlockElement(LocationsDiscardActionID, id,
+			slack.NewTextBlockObject(slack.PlainTextType, "Discard", false, false)),
+	))
+	return r.Bot.ReplyTo(env, slack.MsgOptionBlocks(blocks...))
+}
+
+// HandleBlockAction attaches or discards the proposal a button belongs to.
+func (r *LocationsReview) HandleBlockAction(ctx context.Context, env *Envelope, action, value string, callback slack.InteractionCallback) error {
+	proposal := &locationsProposal{}
+	found, err := r.Store.Get(ctx, locationsProposalKey(value), proposal)
+	if err != nil {
+		return fmt.Errorf("error loading locations proposal: %v", err)
+	}
+	if !found {
+		return fmt.Errorf("that proposal has already been handled")
+	}
+
+	var outcome string
+	switch action {
+	case LocationsAttachActionID:
+		if r.Locks != nil {
+			if ok, err := r.Locks.Acquire(ctx, env, proposal.Slug); !ok || err != nil {
+				return err
+			}
+		}
+		// locations.geojson is only written for an article declaring it, so declare it on the
+		// article as the PR has it.
+		article, err := r.Articles.CurrentArticle(ctx, proposal.Slug, proposal.PRNum)
+		if err != nil {
+			return fmt.Errorf("error loading article: %v", err)
+		}
+		github.DeclareLocations(article)
+		commit := &github.CommitMessage{Type: "update", Scope: "locations", Summary: "Add locations for " + proposal.Slug, Slug: proposal.Slug}
+		commit.ThreadURL, _ = env.Permalink(ctx)
+		commitMessage, err := commit.Build()
+		if err != nil {
+			return err
+		}
+		// The commit is made as the lock holder, so DraftLocks.PreCommitHook lets it through.
+		_, prURL, err := r.Articles.CreateOrUpdateArticlePullRequest(audit.WithActor(ctx, env.UserID), proposal.Slug,
+			r.BreakingNews.PullRequestOptions(ctx, env, proposal.Slug,
+				github.WithArticle(article),
+				github.WithLocations(proposal.GeoJSON),
+				github.WithPRNum(proposal.PRNum),
+				github.WithCommitMessage(commitMessage),
+			)...,
+		)
+		if err != nil {
+			return fmt.Errorf("error attaching locations: %v", err)
+		}
+		outcome = fmt.Sprintf(":white_check_mark: <@%s> attached %d locations to <%s|the PR>.", env.UserID, proposal.Features, prURL)
+	case LocationsDiscardActionID:
+		outcome = fmt.Sprintf(":wastebasket: <@%s> discarded these locations.", env.UserID)
+	default:
+		return fmt.Errorf("unknown action %q", action)
+	}
+
+	if err := r.Store.Delete(ctx, locationsProposalKey(value)); err != nil {
+		return fmt.Errorf("error deleting locations proposal: %v", err)
+	}
+	if r.Locks != nil {
+		if err := r.Locks.Release(ctx, env, proposal.Slug); err != nil {
+			return err
+		}
+	}
+
+	// Swap the buttons for the outcome so the proposal can't be handled twice.
+	blocks := []slack.Block{}
+	for _, block := range callback.Message.Blocks.BlockSet {
+		if !hasButton(block, LocationsAttachActionID, LocationsDiscardActionID) {
+			blocks = append(blocks, block)
+		}
+	}
+	blocks = append(blocks, slack.NewContextBlock("",
+		slack.NewTextBlockObject(slack.MarkdownType, outcome, false, false)))
+	_, _, _, err = r.Bot.UpdateMessageContext(ctx, env.ChannelID, env.MessageTS, slack.MsgOptionBlocks(blocks...))
+	return err
+}
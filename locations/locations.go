@@ -0,0 +1,161 @@
+// Package locations proposes an article's locations.geojson by asking an LLM which places the
+// body mentions and geocoding them.
+package locations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	geojson "github.com/paulmach/go.geojson"
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/geomodulus/robots/geocode"
+	"github.com/geomodulus/robots/internal/llm"
+	"github.com/geomodulus/robots/prompts"
+)
+
+// maxMentions bounds how many places are geocoded per article, since Nominatim only allows a
+// request a second.
+const maxMentions = 25
+
+// Mention is a place the article refers to.
+type Mention struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+	// Kind is "place", "address" or "intersection".
+	Kind string `json:"kind"`
+	// Result is where the mention was geocoded to, or nil if it couldn't be found.
+	Result *geocode.Result `json:"-"`
+}
+
+// Proposal is the extracted mentions and a feature collection of the ones that were found.
+type Proposal struct {
+	Mentions []*Mention
+	Features *geojson.FeatureCollection
+}
+
+// Unresolved returns the mentions that couldn't be geocoded.
+func (p *Proposal) Unresolved() []*Mention {
+	unresolved := []*Mention{}
+	for _, mention := range p.Mentions {
+		if mention.Result == nil {
+			unresolved = append(unresolved, mention)
+		}
+	}
+	return unresolved
+}
+
+// Extractor finds the places in article bodies.
+type Extractor struct {
+	OpenAI   *openai.Client
+	Geocoder geocode.Geocoder
+	// Prompts defaults to prompts.Default.
+	Prompts *prompts.Registry
+	// Model defaults to GPT-3.5 Turbo.
+	Model string
+}
+
+// Extract proposes locations for bodyHTML. Mentions that can't be geocoded are kept in the
+// proposal, without a feature, so they can be shown to the editor.
+func (e *Extractor) Extract(ctx context.Context, bodyHTML string) (*Proposal, error) {
+	mentions, err := e.mentions(ctx, plainText(bodyHTML))
+	if err != nil {
+		return nil, err
+	}
+
+	fc := geojson.NewFeatureCollection()
+	for _, mention := range mentions {
+		result, err := e.Geocoder.Geocode(ctx, mention.Query)
+		if errors.Is(err, geocode.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("error geocoding %q: %v", mention.Query, err)
+		}
+		mention.Result = result
+
+		feature := geojson.NewPointFeature([]float64{result.Location.Lng, result.Location.Lat})
+		feature.SetProperty("name", mention.Name)
+		feature.SetProperty("address", result.DisplayName)
+		feature.SetProperty("kind", mention.Kind)
+		feature.SetProperty("source", result.Source)
+		fc.AddFeature(feature)
+	}
+	return &Proposal{Mentions: mentions, Features: fc}, nil
+}
+
+func (e *Extractor) mentions(ctx context.Context, text string) ([]*Mention, error) {
+	registry := e.Prompts
+	if registry == nil {
+		registry = prompts.Default
+	}
+	prompt, err := registry.Render(ctx, prompts.ExtractLocations, map[string]any{"Text": text})
+	if err != nil {
+		return nil, err
+	}
+	model := e.Model
+	if model == "" {
+		model = openai.GPT3Dot5Turbo
+	}
+
+	resp, err := llm.Complete(ctx, e.OpenAI, openai.ChatCompletionRequest{
+		Model:       model,
+		Temperature: 0,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleUser, Content: prompt.Text},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error extracting locations: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no completion returned")
+	}
+
+	var mentions []*Mention
+	if err := json.Unmarshal([]byte(stripCodeFence(resp.Choices[0].Message.Content)), &mentions); err != nil {
+		return nil, fmt.Errorf("error parsing extracted locations: %v", err)
+	}
+
+	// Drop blanks and repeats; the model often lists a place each time it's mentioned.
+	seen := map[string]bool{}
+	unique := []*Mention{}
+	for _, mention := range mentions {
+		if mention.Query == "" {
+			mention.Query = mention.Name
+		}
+		key := strings.ToLower(strings.TrimSpace(mention.Query))
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, mention)
+		if len(unique) == maxMentions {
+			break
+		}
+	}
+	return unique, nil
+}
+
+func plainText(bodyHTML string) string {
+	// Keep paragraphs apart so sentences don't run together.
+	text := strings.ReplaceAll(bodyHTML, "</p>", "</p>\n\n")
+	return strings.TrimSpace(html.UnescapeString(bluemonday.StrictPolicy().Sanitize(text)))
+}
+
+// stripCodeFence removes the ```json fence models like to wrap JSON in.
+func stripCodeFence(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	s = strings.TrimPrefix(s, "```")
+	if i := strings.Index(s, "\n"); i >= 0 {
+		s = s[i+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "```"))
+}
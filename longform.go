@@ -0,0 +1,241 @@
+package robots
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/slack-go/slack"
+)
+
+// OutputMode is how PostLong presents content.
+type OutputMode int
+
+const (
+	// OutputAuto picks a mode by length: a message if it fits, otherwise a canvas if the bot
+	// has a Token, otherwise a file.
+	OutputAuto OutputMode = iota
+	OutputMessage
+	// OutputFile uploads the content as a Markdown file, which Slack shows as a snippet.
+	OutputFile
+	OutputCanvas
+)
+
+// maxSectionLength is the most text a section block holds.
+const maxSectionLength = 3000
+
+// PostLong posts Markdown content to env's thread in a form that fits its length, eg. a full
+// draft review or style report. It returns the canvas ID if one was created, so later runs can
+// UpdateCanvas instead of posting again.
+func (b *SlackBot) PostLong(ctx context.Context, env *Envelope, title, markdown string, mode OutputMode) (string, error) {
+	if mode == OutputAuto {
+		switch {
+		case len(markdown) <= maxSectionLength:
+			mode = OutputMessage
+		case b.Token != "":
+			mode = OutputCanvas
+		default:
+			mode = OutputFile
+		}
+	}
+
+	switch mode {
+	case OutputMessage:
+		return "", b.ReplyTo(env, slack.MsgOptionBlocks(longMessageBlocks(title, markdown)...))
+
+	case OutputFile:
+		_, err := b.UploadFileV2Context(ctx, slack.UploadFileV2Parameters{
+			Content:         markdown,
+			FileSize:        len(markdown),
+			Filename:        fileName(title) + ".md",
+			Title:           title,
+			Channel:         env.ChannelID,
+			ThreadTimestamp: env.ThreadTS,
+		})
+		if err != nil {
+			return "", fmt.Errorf("error uploading %s: %v", title, err)
+		}
+		return "", nil
+
+	case OutputCanvas:
+		canvasID, err := b.CreateCanvas(ctx, title, markdown, env.ChannelID)
+		if err != nil {
+			return "", err
+		}
+		return canvasID, b.ReplyTo(env, slack.MsgOptionText(
+			fmt.Sprintf(":page_facing_up: *%s* is in <https://slack.com/app_redirect?channel=%s&canvas_id=%s|a canvas>.", title, env.ChannelID, canvasID),
+			false,
+		))
+	}
+	return "", fmt.Errorf("unknown output mode %d", mode)
+}
+
+// CreateCanvas creates a standalone canvas from Markdown and lets members of channelIDs read it.
+// It needs the bot's Token and the canvases:write scope.
+func (b *SlackBot) CreateCanvas(ctx context.Context, title, markdown string, channelIDs ...string) (string, error) {
+	var created struct {
+		CanvasID string `json:"canvas_id"`
+	}
+	if err := b.callAPI(ctx, "canvases.create", map[string]any{
+		"title":            title,
+		"document_content": canvasContent(markdown),
+	}, &created); err != nil {
+		return "", fmt.Errorf("error creating canvas: %v", err)
+	}
+
+	if len(channelIDs) > 0 {
+		if err := b.callAPI(ctx, "canvases.access.set", map[string]any{
+			"canvas_id":    created.CanvasID,
+			"access_level": "read",
+			"channel_ids":  channelIDs,
+		}, nil); err != nil {
+			return created.CanvasID, fmt.Errorf("error sharing canvas: %v", err)
+		}
+	}
+	return created.CanvasID, nil
+}
+
+// UpdateCanvas replaces a canvas's content.
+func (b *SlackBot) UpdateCanvas(ctx context.Context, canvasID, markdown string) error {
+	if err := b.callAPI(ctx, "canvases.edit", map[string]any{
+		"canvas_id": canvasID,
+		"changes": []map[string]any{{
+			"operation":        "replace",
+			"document_content": canvasContent(markdown),
+		}},
+	}, nil); err != nil {
+		return fmt.Errorf("error updating canvas: %v", err)
+	}
+	return nil
+}
+
+func canvasContent(markdown string) map[string]string {
+	return map[string]string{"type": "markdown", "markdown": markdown}
+}
+
+// callAPI calls a Slack Web API method that slack-go doesn't wrap, decoding the response into
+// out if it's not nil.
+func (b *SlackBot) callAPI(ctx context.Context, method string, body, out any) error {
+	if b.Token == "" {
+		return fmt.Errorf("%s needs the bot's Token", method)
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("error encoding request: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", slack.APIURL+method, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("http.NewRequest: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+b.Token)
+
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %v", method, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return slack.StatusCodeError{Code: resp.StatusCode, Status: resp.Status}
+	}
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return fmt.Errorf("error decoding %s response: %v", method, err)
+	}
+	var status slack.SlackResponse
+	if err := json.Unmarshal(raw, &status); err != nil {
+		return fmt.Errorf("error decoding %s response: %v", method, err)
+	}
+	if err := status.Err(); err != nil {
+		return err
+	}
+	if out != nil {
+		return json.Unmarshal(raw, out)
+	}
+	return nil
+}
+
+// longMessageBlocks splits text across section blocks at line breaks, and splits any line too
+// long for a section on its own.
+func longMessageBlocks(title, markdown string) []slack.Block {
+	// Header text is capped at 150 characters.
+	if runes := []rune(title); len(runes) > 150 {
+		title = string(runes[:149]) + "…"
+	}
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, title, false, false)),
+	}
+	chunk := ""
+	for _, line := range strings.SplitAfter(markdown, "\n") {
+		for _, piece := range splitLine(line, maxSectionLength) {
+			if len(chunk)+len(piece) > maxSectionLength && chunk != "" {
+				blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, chunk, false, false), nil, nil))
+				chunk = ""
+			}
+			chunk += piece
+		}
+	}
+	if strings.TrimSpace(chunk) != "" {
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, chunk, false, false), nil, nil))
+	}
+	return blocks
+}
+
+// splitLine cuts line into pieces of at most n bytes, after a space where there is one and
+// otherwise on a rune boundary.
+func splitLine(line string, n int) []string {
+	pieces := []string{}
+	for len(line) > n {
+		cut := n
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		if i := strings.LastIndex(line[:cut], " "); i > 0 {
+			cut = i + 1
+		}
+		pieces = append(pieces, line[:cut])
+		line = line[cut:]
+	}
+	return append(pieces, line)
+}
+
+// fitLines joins header and as many of lines as fit in one section block, one per line, ending
+// with how many were left out.
+func fitLines(header string, lines []string) string {
+	text := header
+	for i, line := range lines {
+		room := maxSectionLength - len(text) - len("\n"+line)
+		if i < len(lines)-1 {
+			room -= len(fmt.Sprintf("\n…and %d more", len(lines)-i-1))
+		}
+		if room < 0 {
+			return text + fmt.Sprintf("\n…and %d more", len(lines)-i)
+		}
+		text += "\n" + line
+	}
+	return text
+}
+
+// fileName turns a title into a file name.
+func fileName(title string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '-'
+	}, title)
+	name = strings.Trim(name, "-")
+	if name == "" {
+		return "output"
+	}
+	return name
+}
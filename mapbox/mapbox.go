@@ -0,0 +1,81 @@
+// Package mapbox builds Mapbox Static Images API URLs for map thumbnails.
+package mapbox
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	geojson "github.com/paulmach/go.geojson"
+)
+
+const (
+	// DefaultStyle is the map style used for thumbnails.
+	DefaultStyle = "mapbox/light-v11"
+	// maxMarkers keeps URLs well under the API's 8192 character limit.
+	maxMarkers  = 50
+	markerColor = "e4572e"
+)
+
+// StaticMapURL returns the URL of a width×height image with a marker for each feature in fc,
+// framed to fit them all. Lines and polygons are marked at their first point. It returns "" if
+// there's nothing to mark.
+func StaticMapURL(token string, fc *geojson.FeatureCollection, width, height int) string {
+	markers := []string{}
+	var center string
+	for _, feature := range fc.Features {
+		if len(markers) == maxMarkers {
+			break
+		}
+		if lng, lat, ok := firstPoint(feature.Geometry); ok {
+			markers = append(markers, fmt.Sprintf("pin-s+%s(%.5f,%.5f)", markerColor, lng, lat))
+			center = fmt.Sprintf("%.5f,%.5f", lng, lat)
+		}
+	}
+	if len(markers) == 0 {
+		return ""
+	}
+
+	position := "auto"
+	if len(markers) == 1 {
+		// auto zooms all the way in on a single marker.
+		position = center + ",14"
+	}
+	return fmt.Sprintf("https://api.mapbox.com/styles/v1/%s/static/%s/%s/%dx%d@2x?access_token=%s",
+		DefaultStyle, strings.Join(markers, ","), position, width, height, url.QueryEscape(token))
+}
+
+func firstPoint(g *geojson.Geometry) (lng, lat float64, ok bool) {
+	if g == nil {
+		return 0, 0, false
+	}
+	var point []float64
+	switch g.Type {
+	case geojson.GeometryPoint:
+		point = g.Point
+	case geojson.GeometryMultiPoint:
+		if len(g.MultiPoint) > 0 {
+			point = g.MultiPoint[0]
+		}
+	case geojson.GeometryLineString:
+		if len(g.LineString) > 0 {
+			point = g.LineString[0]
+		}
+	case geojson.GeometryMultiLineString:
+		if len(g.MultiLineString) > 0 && len(g.MultiLineString[0]) > 0 {
+			point = g.MultiLineString[0][0]
+		}
+	case geojson.GeometryPolygon:
+		if len(g.Polygon) > 0 && len(g.Polygon[0]) > 0 {
+			point = g.Polygon[0][0]
+		}
+	case geojson.GeometryMultiPolygon:
+		if len(g.MultiPolygon) > 0 && len(g.MultiPolygon[0]) > 0 && len(g.MultiPolygon[0][0]) > 0 {
+			point = g.MultiPolygon[0][0][0]
+		}
+	}
+	if len(point) < 2 {
+		return 0, 0, false
+	}
+	return point[0], point[1], true
+}
@@ -0,0 +1,56 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+
+	geojson "github.com/paulmach/go.geojson"
+	"github.com/slack-go/slack"
+
+	"github.com/geomodulus/robots/github"
+	"github.com/geomodulus/robots/mapbox"
+)
+
+// MapPreviewRenderer renders locations.geojson previews with Mapbox and copies them to the
+// bucket, so links keep working and don't expose the Mapbox token. Pass it to
+// github.MapPreviewHook, eg.
+//
+//	github.WithPushHook(github.MapPreviewHook(
+//		robots.MapPreviewRenderer(uploader, mapboxToken),
+//		bot.MapPreviewNotifier(ev.Channel, ev.TimeStamp),
+//	))
+func MapPreviewRenderer(uploader *Uploader, mapboxToken string) github.MapPreviewRenderer {
+	return func(ctx context.Context, push *github.Push, p string, fc *geojson.FeatureCollection) (string, error) {
+		mapURL := mapbox.StaticMapURL(mapboxToken, fc, 600, 400)
+		if mapURL == "" {
+			return "", nil
+		}
+		// Each push gets its own image, so Slack and GitHub don't show a cached older map.
+		objectKey := fmt.Sprintf("map-previews/%s/%s.png", path.Dir(p), push.SHA)
+		return uploader.Upload(ctx, "", mapURL, NoAuth(), WithObjectKey(objectKey))
+	}
+}
+
+// MapPreviewNotifier posts map previews to a Slack thread.
+func (b *SlackBot) MapPreviewNotifier(channel, threadTS string) github.MapPreviewNotifyFunc {
+	return func(ctx context.Context, push *github.Push, images map[string]string) error {
+		paths := []string{}
+		for p := range images {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+
+		blocks := []slack.Block{
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType,
+				fmt.Sprintf(":world_map: Locations changed in <%s|PR #%d>:", push.PR.GetHTMLURL(), push.PR.GetNumber()),
+				false, false), nil, nil),
+		}
+		for _, p := range paths {
+			blocks = append(blocks, slack.NewImageBlock(images[p], "Map of "+path.Dir(p),
+				"", slack.NewTextBlockObject(slack.PlainTextType, p, false, false)))
+		}
+		return b.Reply(channel, threadTS, slack.MsgOptionBlocks(blocks...))
+	}
+}
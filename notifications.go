@@ -0,0 +1,266 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	gh "github.com/google/go-github/v53/github"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+
+	"github.com/geomodulus/robots/github"
+	"github.com/geomodulus/robots/state"
+)
+
+// NotificationEvent is a kind of bot event an editor can be DMed about.
+type NotificationEvent string
+
+const (
+	NotifyPRApproved        NotificationEvent = "pr_approved"
+	NotifyBuildFailed       NotificationEvent = "build_failed"
+	NotifyCorrectionMention NotificationEvent = "correction_mention"
+)
+
+// notificationEvents are the events in the order they're shown in settings, with their labels.
+var notificationEvents = []struct {
+	event NotificationEvent
+	label string
+}{
+	{NotifyPRApproved, "A pull request I opened is approved"},
+	{NotifyBuildFailed, "The build fails on a pull request I opened"},
+	{NotifyCorrectionMention, "I'm mentioned in a correction"},
+}
+
+// NotificationsCallbackID is the callback ID of the settings modal, and
+// NotificationsEditActionID the Home tab button that opens it. Add Notifications to
+// SlackBot.Modals and forward the button from the bot's HandleBlockAction.
+const (
+	NotificationsCallbackID   = "notification_prefs"
+	NotificationsEditActionID = "notification_prefs_edit"
+)
+
+const (
+	notificationsBlockID  = "notification_prefs_events"
+	notificationsActionID = "notification_prefs_value"
+)
+
+// Notifications sends bot events to the editors who want them, as DMs, instead of to one
+// channel. Editors choose which events they get from the bot's Home tab or a slash command,
+// eg. /notifications; until they do, they get all of them.
+type Notifications struct {
+	Bot   *SlackBot
+	Store state.Store
+	// Channel, if set, gets events that no one was DMed about, so nothing goes unseen.
+	Channel string
+}
+
+// notificationPrefs records only what an editor has switched off, so events added later are on
+// for everyone.
+type notificationPrefs struct {
+	Off map[NotificationEvent]bool `json:"off,omitempty"`
+}
+
+func notificationPrefsKey(userID string) string {
+	return "notification-prefs/" + userID
+}
+
+// Wants reports whether userID gets event.
+func (n *Notifications) Wants(ctx context.Context, userID string, event NotificationEvent) (bool, error) {
+	prefs, err := n.prefs(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return !prefs.Off[event], nil
+}
+
+// Notify DMs event to each of userIDs who wants it. If none of them do, it goes to Channel
+// instead, if there is one.
+func (n *Notifications) Notify(ctx context.Context, event NotificationEvent, userIDs []string, opts ...slack.MsgOption) error {
+	sent := 0
+	seen := map[string]bool{}
+	for _, userID := range userIDs {
+		if userID == "" || seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		wants, err := n.Wants(ctx, userID, event)
+		if err != nil {
+			return err
+		}
+		if !wants {
+			continue
+		}
+		// Posting to a user ID lands in their DM with the bot.
+		if _, _, err := n.Bot.PostMessageContext(ctx, userID, opts...); err != nil {
+			return fmt.Errorf("error notifying %s: %v", userID, err)
+		}
+		sent++
+	}
+	if sent == 0 && n.Channel != "" {
+		if _, _, err := n.Bot.PostMessageContext(ctx, n.Channel, opts...); err != nil {
+			return fmt.Errorf("error posting notification: %v", err)
+		}
+	}
+	return nil
+}
+
+// ApprovalNotifier tells userIDs, usually whoever asked for the PR, when it's approved. Pass it
+// to github.ApprovalHook.
+func (n *Notifications) ApprovalNotifier(userIDs ...string) github.ApprovalNotifyFunc {
+	return func(ctx context.Context, push *github.Push, review *gh.PullRequestReview) error {
+		text := fmt.Sprintf(":white_check_mark: %s approved <%s|PR #%d: %s>.",
+			review.GetUser().GetLogin(), push.PR.GetHTMLURL(), push.PR.GetNumber(), escapeMrkdwn(push.PR.GetTitle()))
+		return n.Notify(ctx, NotifyPRApproved, userIDs, slack.MsgOptionText(text, false))
+	}
+}
+
+// BuildFailureNotifier tells userIDs when the build fails on a PR, with the end of the failed
+// jobs' logs. Pass it to github.BuildFailureHook.
+func (n *Notifications) BuildFailureNotifier(userIDs ...string) github.BuildFailureNotifyFunc {
+	return func(ctx context.Context, push *github.Push, logs []*github.JobLog) error {
+		return n.Notify(ctx, NotifyBuildFailed, userIDs, slack.MsgOptionBlocks(buildFailureBlocks(push, logs)...))
+	}
+}
+
+// slackMentionRe matches a user mention, eg. <@U0123> or <@U0123|jane>.
+var slackMentionRe = regexp.MustCompile(`<@([UW][A-Z0-9]+)(?:\|[^>]*)?>`)
+
+// CorrectionMentions tells the editors mentioned in a correction note about it, eg. after
+// github.App.CreateCorrectionPullRequest.
+func (n *Notifications) CorrectionMentions(ctx context.Context, slug, note, prURL string) error {
+	userIDs := []string{}
+	for _, match := range slackMentionRe.FindAllStringSubmatch(note, -1) {
+		userIDs = append(userIDs, match[1])
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+	text := fmt.Sprintf(":memo: You were mentioned in a <%s|correction to `%s`>:\n> %s", prURL, slug, note)
+	return n.Notify(ctx, NotifyCorrectionMention, userIDs, slack.MsgOptionText(text, false))
+}
+
+// HandleSlashCommand opens the settings modal.
+func (n *Notifications) HandleSlashCommand(ctx context.Context, env *Envelope, cmd string) ([]slack.Block, error) {
+	slashCmd, ok := env.Raw.(slack.SlashCommand)
+	if !ok {
+		return nil, fmt.Errorf("%s must be run as a slash command", cmd)
+	}
+	return nil, n.open(ctx, env.UserID, slashCmd.TriggerID)
+}
+
+// HandleAppHomeOpened shows the user's settings on the bot's Home tab.
+func (n *Notifications) HandleAppHomeOpened(ctx context.Context, env *Envelope, ev *slackevents.AppHomeOpenedEvent) error {
+	return n.publishHome(ctx, env.UserID)
+}
+
+// HandlesAction reports whether actionID is the Home tab's edit button.
+func (n *Notifications) HandlesAction(actionID string) bool {
+	return actionID == NotificationsEditActionID
+}
+
+// HandleBlockAction opens the settings modal from the Home tab.
+func (n *Notifications) HandleBlockAction(ctx context.Context, env *Envelope, action, value string, callback slack.InteractionCallback) error {
+	if action != NotificationsEditActionID {
+		return fmt.Errorf("unknown action %q", action)
+	}
+	return n.open(ctx, env.UserID, callback.TriggerID)
+}
+
+// HandlesView reports whether view is the settings modal.
+func (n *Notifications) HandlesView(view slack.View) bool {
+	return view.CallbackID == NotificationsCallbackID
+}
+
+// RespondViewSubmission saves the settings, closes the modal and refreshes the Home tab.
+func (n *Notifications) RespondViewSubmission(ctx context.Context, env *Envelope, callback slack.InteractionCallback) (*slack.ViewSubmissionResponse, error) {
+	on := map[NotificationEvent]bool{}
+	for _, option := range callback.View.State.Values[notificationsBlockID][notificationsActionID].SelectedOptions {
+		on[NotificationEvent(option.Value)] = true
+	}
+	prefs := &notificationPrefs{Off: map[NotificationEvent]bool{}}
+	for _, e := range notificationEvents {
+		if !on[e.event] {
+			prefs.Off[e.event] = true
+		}
+	}
+	if err := n.Store.Put(ctx, notificationPrefsKey(env.UserID), prefs); err != nil {
+		return nil, fmt.Errorf("error saving notification settings: %v", err)
+	}
+	if err := n.publishHome(ctx, env.UserID); err != nil {
+		return nil, err
+	}
+	return slack.NewClearViewSubmissionResponse(), nil
+}
+
+func (n *Notifications) open(ctx context.Context, userID, triggerID string) error {
+	prefs, err := n.prefs(ctx, userID)
+	if err != nil {
+		return err
+	}
+	options := []*slack.OptionBlockObject{}
+	initial := []*slack.OptionBlockObject{}
+	for _, e := range notificationEvents {
+		option := slack.NewOptionBlockObject(string(e.event), slack.NewTextBlockObject(slack.PlainTextType, e.label, false, false), nil)
+		options = append(options, option)
+		if !prefs.Off[e.event] {
+			initial = append(initial, option)
+		}
+	}
+	checkboxes := slack.NewCheckboxGroupsBlockElement(notificationsActionID, options...)
+	checkboxes.InitialOptions = initial
+	input := slack.NewInputBlock(notificationsBlockID,
+		slack.NewTextBlockObject(slack.PlainTextType, "DM me when", false, false),
+		slack.NewTextBlockObject(slack.PlainTextType, "Unchecked events aren't sent to you.", false, false),
+		checkboxes)
+	input.Optional = true
+
+	if _, err := n.Bot.OpenViewContext(ctx, triggerID, slack.ModalViewRequest{
+		Type:       slack.VTModal,
+		CallbackID: NotificationsCallbackID,
+		Title:      slack.NewTextBlockObject(slack.PlainTextType, "Notifications", false, false),
+		Submit:     slack.NewTextBlockObject(slack.PlainTextType, "Save", false, false),
+		Close:      slack.NewTextBlockObject(slack.PlainTextType, "Cancel", false, false),
+		Blocks:     slack.Blocks{BlockSet: []slack.Block{input}},
+	}); err != nil {
+		return fmt.Errorf("error opening notification settings: %v", err)
+	}
+	return nil
+}
+
+func (n *Notifications) publishHome(ctx context.Context, userID string) error {
+	prefs, err := n.prefs(ctx, userID)
+	if err != nil {
+		return err
+	}
+	lines := []string{"*Notifications*"}
+	for _, e := range notificationEvents {
+		mark := ":white_check_mark:"
+		if prefs.Off[e.event] {
+			mark = ":no_bell:"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s", mark, e.label))
+	}
+	if _, err := n.Bot.PublishViewContext(ctx, userID, slack.HomeTabViewRequest{
+		Type: slack.VTHomeTab,
+		Blocks: slack.Blocks{BlockSet: []slack.Block{
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, strings.Join(lines, "\n"), false, false), nil, nil),
+			slack.NewActionBlock("",
+				slack.NewButtonBlockElement(NotificationsEditActionID, "",
+					slack.NewTextBlockObject(slack.PlainTextType, "Edit notifications", false, false)),
+			),
+		}},
+	}, ""); err != nil {
+		return fmt.Errorf("error publishing home tab: %v", err)
+	}
+	return nil
+}
+
+func (n *Notifications) prefs(ctx context.Context, userID string) (*notificationPrefs, error) {
+	prefs := &notificationPrefs{}
+	if _, err := n.Store.Get(ctx, notificationPrefsKey(userID), prefs); err != nil {
+		return nil, fmt.Errorf("error loading notification settings: %v", err)
+	}
+	return prefs, nil
+}
@@ -0,0 +1,190 @@
+// Package osm looks up OpenStreetMap data for places via the Overpass API.
+package osm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/geomodulus/citygraph"
+)
+
+// DefaultEndpoint is the public Overpass API interpreter.
+const DefaultEndpoint = "https://overpass-api.de/api/interpreter"
+
+// Source is recorded as the provenance of every attribute sourced from OpenStreetMap.
+const Source = "openstreetmap"
+
+// EnrichedTags are the OSM tags copied onto place records.
+var EnrichedTags = []string{"opening_hours", "cuisine", "wheelchair", "website"}
+
+// Attribute is a place attribute along with where and when it was sourced.
+type Attribute struct {
+	Value       string    `json:"value"`
+	Source      string    `json:"source"`
+	SourceID    string    `json:"source_id,omitempty"`
+	RetrievedAt time.Time `json:"retrieved_at"`
+}
+
+// Element is a node, way or relation returned by Overpass.
+type Element struct {
+	Type   string            `json:"type"`
+	ID     int64             `json:"id"`
+	Lat    float64           `json:"lat"`
+	Lon    float64           `json:"lon"`
+	Center *Center           `json:"center"`
+	Tags   map[string]string `json:"tags"`
+}
+
+// Center is the centroid Overpass reports for ways and relations.
+type Center struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// Ref identifies the element in OSM notation, eg. "node/123".
+func (e *Element) Ref() string {
+	return fmt.Sprintf("%s/%d", e.Type, e.ID)
+}
+
+// Location returns the element's point, or its center for ways and relations.
+func (e *Element) Location() citygraph.LngLat {
+	if e.Center != nil {
+		return citygraph.LngLat{Lng: e.Center.Lon, Lat: e.Center.Lat}
+	}
+	return citygraph.LngLat{Lng: e.Lon, Lat: e.Lat}
+}
+
+// LikelyClosed reports whether mappers have tagged the element as no longer operating, using
+// lifecycle prefixes like "disused:amenity" or closed opening hours.
+func (e *Element) LikelyClosed() bool {
+	for k, v := range e.Tags {
+		for _, prefix := range []string{"disused:", "abandoned:", "was:", "demolished:"} {
+			if strings.HasPrefix(k, prefix) {
+				return true
+			}
+		}
+		if (k == "opening_hours" && (v == "closed" || v == "off")) || (k == "shop" && v == "vacant") {
+			return true
+		}
+	}
+	return false
+}
+
+type Client struct {
+	HTTPClient *http.Client
+	Endpoint   string
+}
+
+func NewClient() *Client {
+	return &Client{
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		Endpoint:   DefaultEndpoint,
+	}
+}
+
+// Query runs an Overpass QL query and returns the matching elements.
+func (c *Client) Query(ctx context.Context, query string) ([]*Element, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.Endpoint, strings.NewReader(url.Values{"data": {query}}.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("http.NewRequest: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("overpass request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("overpass returned %s: %s", resp.Status, body)
+	}
+
+	var res struct {
+		Elements []*Element `json:"elements"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("error decoding overpass response: %v", err)
+	}
+	return res.Elements, nil
+}
+
+// FindPlace returns the OSM element named like the place within radius metres of it, or nil if
+// there isn't one. The closest match wins.
+func (c *Client) FindPlace(ctx context.Context, place *citygraph.Place, radius float64) (*Element, error) {
+	name := "^" + regexp.QuoteMeta(strings.TrimSpace(place.Name)) + "$"
+	query := fmt.Sprintf(
+		`[out:json][timeout:25];nwr(around:%.0f,%f,%f)["name"~"%s",i];out center tags;`,
+		radius,
+		place.Location.Lat,
+		place.Location.Lng,
+		strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name),
+	)
+	elements, err := c.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		closest  *Element
+		bestDist = math.Inf(1)
+	)
+	for _, el := range elements {
+		if d := distanceMeters(place.Location, el.Location()); d < bestDist {
+			closest, bestDist = el, d
+		}
+	}
+	return closest, nil
+}
+
+// Attributes returns the EnrichedTags present on the element.
+func Attributes(el *Element, retrievedAt time.Time) map[string]*Attribute {
+	attrs := map[string]*Attribute{}
+	for _, tag := range EnrichedTags {
+		if v := strings.TrimSpace(el.Tags[tag]); v != "" {
+			attrs[tag] = &Attribute{
+				Value:       v,
+				Source:      Source,
+				SourceID:    el.Ref(),
+				RetrievedAt: retrievedAt,
+			}
+		}
+	}
+	return attrs
+}
+
+// Merge copies fresh OSM attributes onto existing ones. Attributes that were set by hand (any
+// other source) are left alone.
+func Merge(existing, fresh map[string]*Attribute) map[string]*Attribute {
+	merged := map[string]*Attribute{}
+	for k, v := range existing {
+		merged[k] = v
+	}
+	for k, v := range fresh {
+		if cur, ok := merged[k]; ok && cur.Source != Source {
+			continue
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+func distanceMeters(a, b citygraph.LngLat) float64 {
+	const earthRadius = 6371000.0
+	lat1 := a.Lat * math.Pi / 180
+	lat2 := b.Lat * math.Pi / 180
+	dLat := (b.Lat - a.Lat) * math.Pi / 180
+	dLng := (b.Lng - a.Lng) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
@@ -0,0 +1,81 @@
+// Package pdf renders thumbnails of PDFs and pulls out their text, by shelling out to poppler's
+// pdftoppm and pdftotext, which must be on the PATH.
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeout bounds each poppler run, since malformed PDFs can make them spin.
+const timeout = time.Minute
+
+// MaxTextLength bounds the text returned by Text; more than this won't be embedded anyway.
+const MaxTextLength = 200_000
+
+// Thumbnail renders the first page of a PDF as a PNG width pixels wide.
+func Thumbnail(ctx context.Context, data []byte, width int) ([]byte, error) {
+	return withTempFile(data, func(dir, path string) ([]byte, error) {
+		root := filepath.Join(dir, "thumb")
+		if _, err := run(ctx, "pdftoppm", "-png", "-f", "1", "-l", "1", "-singlefile",
+			"-scale-to-x", strconv.Itoa(width), "-scale-to-y", "-1", path, root); err != nil {
+			return nil, err
+		}
+		png, err := os.ReadFile(root + ".png")
+		if err != nil {
+			return nil, fmt.Errorf("error reading thumbnail: %v", err)
+		}
+		return png, nil
+	})
+}
+
+// Text returns the text of a PDF, up to MaxTextLength bytes. Scanned PDFs without a text layer
+// return little or nothing.
+func Text(ctx context.Context, data []byte) (string, error) {
+	out, err := withTempFile(data, func(_, path string) ([]byte, error) {
+		return run(ctx, "pdftotext", "-enc", "UTF-8", "-nopgbrk", path, "-")
+	})
+	if err != nil {
+		return "", err
+	}
+	text := strings.Join(strings.Fields(string(out)), " ")
+	if len(text) > MaxTextLength {
+		text = strings.ToValidUTF8(text[:MaxTextLength], "")
+	}
+	return text, nil
+}
+
+func withTempFile(data []byte, fn func(dir, path string) ([]byte, error)) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "pdf")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "in.pdf")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return nil, fmt.Errorf("error writing PDF: %v", err)
+	}
+	return fn(dir, path)
+}
+
+func run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("error running %s: %w, stderr: %s", name, err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
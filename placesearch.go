@@ -0,0 +1,151 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	gh "github.com/google/go-github/v53/github"
+	geojson "github.com/paulmach/go.geojson"
+	"github.com/slack-go/slack"
+
+	"github.com/geomodulus/robots/github"
+	"github.com/geomodulus/robots/internal/cache"
+	"github.com/geomodulus/robots/mapbox"
+	"github.com/geomodulus/robots/search"
+)
+
+// Action IDs of the buttons on /places results. Both open GitHub, so handlers can ignore them.
+const (
+	PlacesOpenPRActionID = "places_open_pr"
+	PlacesEditActionID   = "places_edit"
+)
+
+// defaultPlacesLimit is how many places /places shows by default.
+const defaultPlacesLimit = 5
+
+// placesPRCacheTTL is how long /places reuses the open PRs it found, since finding them takes a
+// request per PR and Slack only waits 3 seconds for an answer.
+const placesPRCacheTTL = 5 * time.Minute
+
+// PlacesCommand searches places by what they are or where:
+//
+//	/places ramen near kensington
+//
+// Each result shows the place's neighbourhood, status and a map thumbnail, with buttons to open
+// its pull request, if it has one, and edit it. Open PRs are cached for a few minutes, so a
+// brand new one can take that long to show. Places are indexed by ReindexJob when its Places
+// is set. Call HandleSlashCommand from the bot's handler for the command it's registered as.
+type PlacesCommand struct {
+	Search *search.Client
+	Places *github.App
+	// MapboxToken, if set, adds a map thumbnail to each result.
+	MapboxToken string
+	// Limit is how many places are shown. Defaults to 5.
+	Limit int
+
+	prsOnce sync.Once
+	prs     *cache.TTL[map[string][]*gh.PullRequest]
+}
+
+func (c *PlacesCommand) HandleSlashCommand(ctx context.Context, env *Envelope, cmd string) ([]slack.Block, error) {
+	var query string
+	if slashCmd, ok := env.Raw.(slack.SlashCommand); ok {
+		query = strings.TrimSpace(slashCmd.Text)
+	}
+	if query == "" {
+		return nil, fmt.Errorf("usage: %s <query>", cmd)
+	}
+	limit := c.Limit
+	if limit == 0 {
+		limit = defaultPlacesLimit
+	}
+
+	results, err := c.Search.SearchPlaces(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	blocks := []slack.Block{slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType,
+		fmt.Sprintf("*Places matching* _%s_", escapeMrkdwn(query)), false, false), nil, nil)}
+	if len(results) == 0 {
+		return append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType,
+			":shrug: No places found.", false, false), nil, nil)), nil
+	}
+
+	prs, err := c.openPullRequests(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		blocks = append(blocks, slack.NewDividerBlock())
+		blocks = append(blocks, c.placeBlocks(result, prs[result.Slug])...)
+	}
+	return blocks, nil
+}
+
+// openPullRequests returns the open PRs that change each place, by slug.
+func (c *PlacesCommand) openPullRequests(ctx context.Context) (map[string][]*gh.PullRequest, error) {
+	c.prsOnce.Do(func() {
+		c.prs = cache.New[map[string][]*gh.PullRequest](1, placesPRCacheTTL)
+	})
+	if prs, ok := c.prs.Get("active_places"); ok {
+		return prs, nil
+	}
+	prs, err := c.Places.OpenPullRequestsByDir(ctx, "active_places")
+	if err != nil {
+		return nil, err
+	}
+	c.prs.Put("active_places", prs)
+	return prs, nil
+}
+
+func (c *PlacesCommand) placeBlocks(result *search.PlaceResult, prs []*gh.PullRequest) []slack.Block {
+	lines := []string{"*" + escapeMrkdwn(result.Name) + "*"}
+	details := []string{}
+	for _, detail := range []string{result.Type, result.Neighbourhood, result.Address} {
+		if detail != "" {
+			details = append(details, escapeMrkdwn(detail))
+		}
+	}
+	if len(details) > 0 {
+		lines = append(lines, strings.Join(details, " · "))
+	}
+	status := ":large_green_circle: Open"
+	if result.Status != "" {
+		status = ":red_circle: " + escapeMrkdwn(result.Status)
+	}
+	lines = append(lines, status)
+
+	var accessory *slack.Accessory
+	if c.MapboxToken != "" && (result.Lng != 0 || result.Lat != 0) {
+		fc := geojson.NewFeatureCollection()
+		fc.AddFeature(geojson.NewPointFeature([]float64{result.Lng, result.Lat}))
+		if mapURL := mapbox.StaticMapURL(c.MapboxToken, fc, 150, 150); mapURL != "" {
+			accessory = slack.NewAccessory(slack.NewImageBlockElement(mapURL, "Map of "+result.Name))
+		}
+	}
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, strings.Join(lines, "\n"), false, false), nil, accessory),
+	}
+
+	// Edits go to the open PR's branch if there is one, so they don't race it; GitHub offers to
+	// open a PR for edits to main.
+	branch := "main"
+	buttons := []slack.BlockElement{}
+	if len(prs) > 0 {
+		pr := prs[0]
+		branch = pr.GetHead().GetRef()
+		open := slack.NewButtonBlockElement(PlacesOpenPRActionID, result.Slug,
+			slack.NewTextBlockObject(slack.PlainTextType, fmt.Sprintf("Open PR #%d", pr.GetNumber()), false, false))
+		open.URL = pr.GetHTMLURL()
+		buttons = append(buttons, open)
+	}
+	edit := slack.NewButtonBlockElement(PlacesEditActionID, result.Slug,
+		slack.NewTextBlockObject(slack.PlainTextType, "Edit", false, false))
+	edit.URL = fmt.Sprintf("https://github.com/%s/%s/edit/%s/active_places/%s/poi.json",
+		c.Places.Owner, c.Places.Repo, branch, result.Slug)
+	buttons = append(buttons, edit)
+	return append(blocks, slack.NewActionBlock("", buttons...))
+}
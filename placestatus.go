@@ -0,0 +1,65 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	"github.com/geomodulus/robots/github"
+	"github.com/geomodulus/robots/osm"
+)
+
+// PlaceStatusJob checks places for dead websites and likely closures and posts what it finds
+// for review. Register Run with a Scheduler.
+type PlaceStatusJob struct {
+	Bot     *SlackBot
+	Places  *github.App
+	Channel string
+	// OSM, if set, is consulted for places mappers have marked closed.
+	OSM *osm.Client
+	// OpenPR opens a PR marking likely-closed places as closed. Dead websites alone never
+	// trigger a PR.
+	OpenPR bool
+}
+
+func (j *PlaceStatusJob) Run(ctx context.Context) error {
+	places, err := j.Places.ListPlaces(ctx)
+	if err != nil {
+		return fmt.Errorf("error listing places: %w", err)
+	}
+
+	findings := github.CheckPlaceStatus(ctx, places, &http.Client{Timeout: 15 * time.Second}, j.OSM)
+	if len(findings) == 0 {
+		return nil
+	}
+
+	lines := []string{}
+	closed := []string{}
+	for _, finding := range findings {
+		marker := ":link:"
+		if finding.LikelyClosed {
+			marker = ":no_entry:"
+			closed = append(closed, finding.Place.Slug)
+		}
+		lines = append(lines, fmt.Sprintf("%s *%s* (`%s`): %s", marker, finding.Place.Place.Name, finding.Place.Slug, finding.Reason))
+	}
+
+	text := fitLines(fmt.Sprintf("*Place status review* — %d places need a look:", len(findings)), lines)
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+	}
+	if j.OpenPR && len(closed) > 0 {
+		_, prURL, err := j.Places.MarkPlacesClosed(ctx, closed)
+		if err != nil {
+			return fmt.Errorf("error opening closures PR: %w", err)
+		}
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType,
+			fmt.Sprintf("Opened <%s|a PR> marking %d places closed.", prURL, len(closed)), false, false), nil, nil))
+	}
+
+	_, _, err = j.Bot.PostMessageContext(ctx, j.Channel, slack.MsgOptionBlocks(blocks...))
+	return err
+}
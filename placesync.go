@@ -0,0 +1,169 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/geomodulus/citygraph"
+	gh "github.com/google/go-github/v53/github"
+	"github.com/slack-go/slack"
+
+	"github.com/geomodulus/robots/events"
+	"github.com/geomodulus/robots/github"
+	"github.com/geomodulus/robots/reqid"
+)
+
+// defaultPlaceSyncLookback is how far back the first run of a PlaceSyncJob looks for merges.
+const defaultPlaceSyncLookback = 24 * time.Hour
+
+// PlaceSyncJob keeps the citygraph store, and so the live map, in step with the places repo:
+// each place changed by a PR merged since the last run is written to Graph, or removed from it if
+// the PR deleted the place, then Invalidate is told which, so cached map tiles and layers can be
+// dropped. Places that fail are retried on the next run. Register Run with a Scheduler, eg.
+//
+//	scheduler.Every("place-sync", 5*time.Minute, job.Run)
+type PlaceSyncJob struct {
+	Places *github.App
+	Graph  citygraph.GraphClient
+	// Invalidate, if set, is called with the slugs of the places written on each run, eg. to
+	// purge the map's tile cache.
+	Invalidate func(ctx context.Context, slugs []string) error
+	// Bot and Channel, if set, get a note of each run that synced something.
+	Bot     *SlackBot
+	Channel string
+	// Lookback is how far back the first run looks for merges, by default a day. Later runs
+	// pick up where the last left off.
+	Lookback time.Duration
+	// Events, if set, is told about each place written or removed.
+	Events events.Emitter
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+func (j *PlaceSyncJob) Run(ctx context.Context) error {
+	now := time.Now()
+	j.mu.Lock()
+	from := j.last
+	j.mu.Unlock()
+	if from.IsZero() {
+		lookback := j.Lookback
+		if lookback == 0 {
+			lookback = defaultPlaceSyncLookback
+		}
+		from = now.Add(-lookback)
+	}
+
+	activity, err := j.Places.PullRequestActivity(ctx, from, now)
+	if err != nil {
+		return fmt.Errorf("error listing merged pull requests: %w", err)
+	}
+	// Oldest merge first, so a place changed twice is written once, as it is on main.
+	slugs := []string{}
+	prs := map[string]*gh.PullRequest{}
+	firstMerged := map[string]time.Time{}
+	for i := len(activity.Merged) - 1; i >= 0; i-- {
+		pr := activity.Merged[i]
+		names, err := j.Places.ChangedDirs(ctx, pr.GetNumber(), "active_places")
+		if err != nil {
+			return err
+		}
+		for _, slug := range names {
+			if _, ok := prs[slug]; !ok {
+				slugs = append(slugs, slug)
+				firstMerged[slug] = pr.GetMergedAt().Time
+			}
+			prs[slug] = pr
+		}
+	}
+
+	synced := []string{}
+	failed := []string{}
+	written := map[string]bool{}
+	removals := map[string]*citygraph.Place{}
+	for _, slug := range slugs {
+		removed, err := j.Places.RemovedPlace(ctx, slug, prs[slug])
+		if err == nil && removed != nil {
+			removals[slug] = removed
+			continue
+		}
+		var checkout *github.PlaceCheckout
+		if err == nil {
+			checkout, err = j.Places.FetchPlace(ctx, slug)
+		}
+		if err == nil {
+			err = github.WritePlaceToGraph(ctx, j.Graph, checkout)
+		}
+		if err != nil {
+			reqid.Printf(ctx, "place sync: %s, from PR #%d: %v", slug, prs[slug].GetNumber(), err)
+			failed = append(failed, slug)
+			continue
+		}
+		synced = append(synced, slug)
+		written[checkout.Place.ID] = true
+		j.emit(ctx, slug, checkout.Place, prs[slug])
+	}
+	// A renamed place keeps its ID, and its vertex was just written under its new slug.
+	removed := []string{}
+	for _, slug := range slugs {
+		place, ok := removals[slug]
+		if !ok || written[place.ID] {
+			continue
+		}
+		if err := github.RemovePlaceFromGraph(ctx, j.Graph, place); err != nil {
+			reqid.Printf(ctx, "place sync: removing %s, from PR #%d: %v", slug, prs[slug].GetNumber(), err)
+			failed = append(failed, slug)
+			continue
+		}
+		removed = append(removed, slug)
+		j.emit(ctx, slug, place, prs[slug])
+	}
+
+	changed := append(append([]string{}, synced...), removed...)
+	if len(changed) > 0 && j.Invalidate != nil {
+		// The last run isn't moved on, so the next one writes these places again and retries.
+		if err := j.Invalidate(ctx, changed); err != nil {
+			return fmt.Errorf("error invalidating map caches: %w", err)
+		}
+	}
+	// The next run starts from the first merge with a place that failed, so it's retried.
+	next := now
+	for _, slug := range failed {
+		if merged := firstMerged[slug]; merged.Before(next) {
+			next = merged
+		}
+	}
+	j.mu.Lock()
+	j.last = next
+	j.mu.Unlock()
+
+	if j.Bot == nil || j.Channel == "" || len(slugs) == 0 {
+		return nil
+	}
+	text := fmt.Sprintf("*Place sync* — %d merged places written to the map", len(synced))
+	if len(synced) > 0 {
+		text += ": `" + strings.Join(synced, "`, `") + "`"
+	}
+	if len(removed) > 0 {
+		text += fmt.Sprintf("\n%d removed from the map: `%s`", len(removed), strings.Join(removed, "`, `"))
+	}
+	if len(failed) > 0 {
+		text += fmt.Sprintf("\n:warning: %d failed: `%s`", len(failed), strings.Join(failed, "`, `"))
+	}
+	_, _, err = j.Bot.PostMessageContext(ctx, j.Channel, slack.MsgOptionBlocks(
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+	))
+	return err
+}
+
+func (j *PlaceSyncJob) emit(ctx context.Context, slug string, place *citygraph.Place, pr *gh.PullRequest) {
+	events.Emit(ctx, j.Events, events.PlaceUpdated, events.PlaceUpdatedData{
+		Slug:  slug,
+		Name:  place.Name,
+		PRNum: pr.GetNumber(),
+		PRURL: pr.GetHTMLURL(),
+	})
+}
@@ -3,13 +3,145 @@ package prettier
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"os/exec"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/geomodulus/robots/internal/breaker"
 )
 
+// timeout bounds a single prettier run; npx can hang fetching the package.
+const timeout = 30 * time.Second
+
+// errUnavailable marks failures that mean prettier itself can't run, as opposed to it rejecting
+// the code it was given.
+var errUnavailable = errors.New("prettier unavailable")
+
+// prettierBreaker stops shelling out to prettier once it's failing to run, until a probe finds
+// it working again. Meanwhile Format falls back to a plain formatter.
+var prettierBreaker = &breaker.Breaker{
+	Name:      "prettier",
+	Threshold: 3,
+	Cooldown:  time.Minute,
+	Probe: func(ctx context.Context) error {
+		return exec.CommandContext(ctx, "npx", "prettier", "--version").Run()
+	},
+	IsFailure: func(err error) bool {
+		return errors.Is(err, errUnavailable)
+	},
+}
+
+// fallbacks counts files formatted without prettier.
+var fallbacks atomic.Uint64
+
+// Profile is how one kind of file is formatted. Zero values leave prettier's defaults.
+type Profile struct {
+	TabWidth   int
+	PrintWidth int
+	// NoSemi leaves semicolons off the ends of JavaScript statements.
+	NoSemi      bool
+	SingleQuote bool
+	// ProseWrap is "always", "never" or "preserve".
+	ProseWrap string
+	// HTMLWhitespaceSensitivity is "css", "strict" or "ignore".
+	HTMLWhitespaceSensitivity string
+}
+
+func (p Profile) args() []string {
+	args := []string{}
+	if p.TabWidth > 0 {
+		args = append(args, fmt.Sprintf("--tab-width=%d", p.TabWidth))
+	}
+	if p.PrintWidth > 0 {
+		args = append(args, fmt.Sprintf("--print-width=%d", p.PrintWidth))
+	}
+	if p.NoSemi {
+		args = append(args, "--no-semi")
+	}
+	if p.SingleQuote {
+		args = append(args, "--single-quote")
+	}
+	if p.ProseWrap != "" {
+		args = append(args, "--prose-wrap="+p.ProseWrap)
+	}
+	if p.HTMLWhitespaceSensitivity != "" {
+		args = append(args, "--html-whitespace-sensitivity="+p.HTMLWhitespaceSensitivity)
+	}
+	return args
+}
+
+// DefaultProfiles follow the content repos' conventions, by file extension.
+var DefaultProfiles = map[string]Profile{
+	".json":    {TabWidth: 2},
+	".geojson": {TabWidth: 2},
+	// Editors wrap article prose by hand; reflowing it would touch every line of a PR.
+	".html": {TabWidth: 2, ProseWrap: "preserve", HTMLWhitespaceSensitivity: "css"},
+	".js":   {TabWidth: 2, NoSemi: true},
+}
+
+// Formatter runs a pinned prettier with fixed options, so formatting doesn't depend on whatever
+// prettier version or config file npx finds in the working directory.
+type Formatter struct {
+	// Package is the prettier npx runs, eg. "prettier@3.0.0".
+	Package string
+	// Profiles are keyed by file extension, eg. ".json". Files without one get prettier's
+	// defaults.
+	Profiles map[string]Profile
+}
+
+// Default is the Formatter used by Format. It pins the version in package.json.
+var Default = &Formatter{Package: "prettier@3.0.0", Profiles: DefaultProfiles}
+
+// Format formats code with the Default formatter.
 func Format(code, filePath string) (string, error) {
-	cmd := exec.Command("npx", "prettier", "--stdin-filepath", filePath)
+	return Default.Format(code, filePath)
+}
+
+// Format formats code as the kind of file at filePath. If prettier can't run, JSON is indented
+// and everything else is returned unchanged, so commits aren't blocked on formatting.
+func (f *Formatter) Format(code, filePath string) (string, error) {
+	profile := f.Profiles[path.Ext(filePath)]
+	out, err := breaker.DoValue(context.Background(), prettierBreaker, func(ctx context.Context) (string, error) {
+		return f.run(ctx, code, filePath, profile)
+	})
+	if errors.Is(err, errUnavailable) || errors.Is(err, breaker.ErrOpen) {
+		log.Printf("formatting %s without prettier: %v", filePath, err)
+		fallbacks.Add(1)
+		return fallbackFormat(code, filePath, profile)
+	}
+	return out, err
+}
+
+// Fallbacks counts the files formatted without prettier so far. Compare it before and after
+// formatting to tell whether the fallback formatter ran.
+func Fallbacks() uint64 {
+	return fallbacks.Load()
+}
+
+// Available reports whether prettier is running, rather than the fallback formatter.
+func Available() bool {
+	return prettierBreaker.Available()
+}
+
+func (f *Formatter) run(ctx context.Context, code, filePath string, profile Profile) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	pkg := f.Package
+	if pkg == "" {
+		pkg = "prettier"
+	}
+	// The profile is the whole configuration; config files found from the working directory are
+	// ignored.
+	args := append([]string{"--yes", pkg, "--no-config", "--no-editorconfig", "--stdin-filepath", filePath}, profile.args()...)
+	cmd := exec.CommandContext(ctx, "npx", args...)
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -22,16 +154,43 @@ func Format(code, filePath string) (string, error) {
 
 	err = cmd.Start()
 	if err != nil {
-		return "", fmt.Errorf("error starting command: %w", err)
+		return "", fmt.Errorf("error starting command: %v: %w", err, errUnavailable)
 	}
 
 	io.WriteString(stdin, code)
 	stdin.Close()
 
 	err = cmd.Wait()
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("prettier timed out: %w", errUnavailable)
+	}
 	if err != nil {
+		// Prettier reports code it can't parse on stderr; anything else means it didn't run.
+		if !strings.Contains(stderr.String(), "SyntaxError") {
+			err = fmt.Errorf("%v: %w", err, errUnavailable)
+		}
 		return "", fmt.Errorf("error waiting for command: %w, stderr: %s", err, stderr.String())
 	}
 
 	return stdout.String(), nil
 }
+
+// fallbackFormat is used while prettier is unavailable.
+func fallbackFormat(code, filePath string, profile Profile) (string, error) {
+	switch path.Ext(filePath) {
+	case ".json", ".geojson":
+		indent := "  "
+		if profile.TabWidth > 0 {
+			indent = strings.Repeat(" ", profile.TabWidth)
+		}
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, []byte(code), "", indent); err != nil {
+			return "", fmt.Errorf("error formatting %s: %w", filePath, err)
+		}
+		code = buf.String()
+	}
+	if !strings.HasSuffix(code, "\n") {
+		code += "\n"
+	}
+	return code, nil
+}
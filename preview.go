@@ -0,0 +1,79 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/slack-go/slack"
+
+	"github.com/geomodulus/robots/a11y"
+	"github.com/geomodulus/robots/github"
+)
+
+// PreviewNotifier posts preview deployment links to a Slack thread. Pass it to
+// github.PreviewHook, eg.
+//
+//	github.WithPushHook(github.PreviewHook(10*time.Minute, bot.PreviewNotifier(ev.Channel, ev.TimeStamp)))
+func (b *SlackBot) PreviewNotifier(channel, threadTS string) github.PreviewNotifyFunc {
+	return func(ctx context.Context, push *github.Push, previewURL string) error {
+		return b.Reply(channel, threadTS, slack.MsgOptionText(
+			fmt.Sprintf(":eyes: Preview for <%s|PR #%d> is ready: %s", push.PR.GetHTMLURL(), push.PR.GetNumber(), previewURL),
+			false,
+		))
+	}
+}
+
+// BuildFailureNotifier posts failed build jobs, with the end of their logs, to a Slack thread.
+// Pass it to github.BuildFailureHook.
+func (b *SlackBot) BuildFailureNotifier(channel, threadTS string) github.BuildFailureNotifyFunc {
+	return func(ctx context.Context, push *github.Push, logs []*github.JobLog) error {
+		return b.Reply(channel, threadTS, slack.MsgOptionBlocks(buildFailureBlocks(push, logs)...))
+	}
+}
+
+func buildFailureBlocks(push *github.Push, logs []*github.JobLog) []slack.Block {
+	blocks := []slack.Block{
+		errorBlock(fmt.Sprintf(":x: The build for <%s|PR #%d> failed.", push.PR.GetHTMLURL(), push.PR.GetNumber())),
+	}
+	for _, jobLog := range logs {
+		title := fmt.Sprintf("*<%s|%s>*", jobLog.Job.GetHTMLURL(), jobLog.Job.GetName())
+		if jobLog.FailedStep != "" {
+			title += " failed at _" + jobLog.FailedStep + "_"
+		}
+		// Section text is capped at 3000 characters; keep the end, where the error is.
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType,
+			fmt.Sprintf("%s\n```%s```", title, excerptEnd(jobLog.Excerpt, 2500)), false, false), nil, nil))
+	}
+	return blocks
+}
+
+// AccessibilityNotifier posts accessibility findings to a Slack thread. Pass it to
+// github.AccessibilityHook.
+func (b *SlackBot) AccessibilityNotifier(channel, threadTS string) github.AccessibilityNotifyFunc {
+	return func(ctx context.Context, push *github.Push, findings map[string][]a11y.Finding) error {
+		paths := []string{}
+		for p := range findings {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+
+		blocks := []slack.Block{
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType,
+				fmt.Sprintf(":wheelchair: Accessibility problems in <%s|PR #%d>:", push.PR.GetHTMLURL(), push.PR.GetNumber()),
+				false, false), nil, nil),
+		}
+		for _, p := range paths {
+			lines := []string{"*" + p + "*"}
+			for _, finding := range findings[p] {
+				lines = append(lines, "• "+escapeMrkdwn(finding.String()))
+			}
+			text := strings.Join(lines, "\n")
+			// Section text is capped at 3000 characters.
+			text = excerpt(text, 2900)
+			blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil))
+		}
+		return b.Reply(channel, threadTS, slack.MsgOptionBlocks(blocks...))
+	}
+}
@@ -0,0 +1,87 @@
+package robots
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/geomodulus/robots/github"
+)
+
+// ChannelProfile configures the bot for the desk a channel serves, eg. news, places or data.
+// Handlers find the profile for the channel an event came from in Envelope.Profile.
+type ChannelProfile struct {
+	Name string `json:"name"`
+	// Reviewers are requested on pull requests opened from the channel. Empty leaves
+	// github.DefaultReviewers.
+	Reviewers []string `json:"reviewers,omitempty"`
+	// InArchive files articles drafted in the channel under archive/.
+	InArchive bool `json:"in_archive,omitempty"`
+	// Commands are the slash commands allowed in the channel, eg. "/flags". Empty allows all.
+	Commands []string `json:"commands,omitempty"`
+}
+
+// Allows reports whether a slash command may be used under the profile. A nil profile allows
+// everything.
+func (p *ChannelProfile) Allows(cmd string) bool {
+	if p == nil || len(p.Commands) == 0 {
+		return true
+	}
+	for _, allowed := range p.Commands {
+		if strings.TrimPrefix(allowed, "/") == strings.TrimPrefix(cmd, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// PullRequestOptions returns the profile's defaults for pull requests, followed by opts so the
+// caller can still override them, eg.
+//
+//	app.CreateOrUpdateArticlePullRequest(ctx, slug, env.Profile.PullRequestOptions(github.WithArticle(article))...)
+func (p *ChannelProfile) PullRequestOptions(opts ...github.Option) []github.Option {
+	if p == nil {
+		return opts
+	}
+	defaults := []github.Option{}
+	if len(p.Reviewers) > 0 {
+		defaults = append(defaults, github.WithReviewers(p.Reviewers...))
+	}
+	if p.InArchive {
+		defaults = append(defaults, github.InArchive(true))
+	}
+	return append(defaults, opts...)
+}
+
+// ChannelProfiles maps channels to their profiles.
+type ChannelProfiles struct {
+	// Default applies to channels without a profile of their own. It may be nil.
+	Default  *ChannelProfile            `json:"default,omitempty"`
+	Channels map[string]*ChannelProfile `json:"channels"`
+}
+
+// LoadChannelProfiles reads profiles from a JSON config keyed by channel ID, eg.
+//
+//	{
+//	  "default": {"name": "general", "commands": ["/recall"]},
+//	  "channels": {"C0123": {"name": "places", "reviewers": ["placesdesk"]}}
+//	}
+func LoadChannelProfiles(config io.Reader) (*ChannelProfiles, error) {
+	profiles := &ChannelProfiles{}
+	if err := json.NewDecoder(config).Decode(profiles); err != nil {
+		return nil, fmt.Errorf("error decoding channel profiles: %v", err)
+	}
+	return profiles, nil
+}
+
+// For returns the profile for a channel, or the default. It's nil-safe.
+func (p *ChannelProfiles) For(channelID string) *ChannelProfile {
+	if p == nil {
+		return nil
+	}
+	if profile, ok := p.Channels[channelID]; ok {
+		return profile
+	}
+	return p.Default
+}
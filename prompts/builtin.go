@@ -0,0 +1,82 @@
+package prompts
+
+// Names of the built-in prompts.
+const (
+	Draft     = "draft"
+	Summarize = "summarize"
+	AltText   = "alt_text"
+	Rerank    = "rerank"
+	// ExtractLocations asks for the places an article mentions, as JSON.
+	ExtractLocations = "extract_locations"
+	SummarizeThread  = "summarize_thread"
+)
+
+// Default holds the built-in prompts.
+var Default = NewRegistry()
+
+func init() {
+	Default.MustRegister(Template{
+		Name:    Draft,
+		Version: "2",
+		Text: `You are a reporter for Torontoverse, a local news site about Toronto.
+Write a short news article in HTML paragraphs (<p>) based on these notes.
+Don't invent facts, quotes or names that aren't in the notes.
+{{if .Headline}}
+Headline: {{.Headline}}
+{{else}}Start with a suggested headline in an <h2>.
+{{end}}Notes:
+{{.Notes}}`,
+	})
+	Default.MustRegister(Template{
+		Name:    Summarize,
+		Version: "1",
+		Text: `Summarize the following in {{if .Sentences}}{{.Sentences}}{{else}}three{{end}} sentences or fewer.
+Keep names, places and numbers exact.
+
+{{.Text}}`,
+	})
+	Default.MustRegister(Template{
+		Name:    AltText,
+		Version: "1",
+		Text: `Write alt text for an image in a Torontoverse article, in one sentence of at most 125 characters.
+Describe what's shown, not what it means. Don't start with "Image of".
+
+Article headline: {{.Headline}}
+Image caption: {{.Caption}}`,
+	})
+	Default.MustRegister(Template{
+		Name:    Rerank,
+		Version: "1",
+		Text: `Rank these search results by how well they answer the query. Reply with the result numbers, best first, separated by commas.
+
+Query: {{.Query}}
+{{range $i, $r := .Results}}
+{{$i}}. {{$r}}{{end}}`,
+	})
+	Default.MustRegister(Template{
+		Name:    ExtractLocations,
+		Version: "1",
+		Text: `List the specific places in Toronto that this article mentions: named places, street addresses and intersections.
+Skip neighbourhoods, the city itself and places outside Toronto. Don't list anything that isn't in the text.
+
+Reply with only a JSON array, one object per place, like:
+[{"name": "Union Station", "query": "Union Station, 65 Front Street West, Toronto", "kind": "place"}]
+
+"kind" is "place", "address" or "intersection". "query" is what to search a map for; for
+intersections use the form "Queen Street West & Spadina Avenue, Toronto".
+
+Article:
+{{.Text}}`,
+	})
+	Default.MustRegister(Template{
+		Name:    SummarizeThread,
+		Version: "1",
+		Text: `Summarize this Slack thread from the Torontoverse newsroom for someone who missed it.
+Reply in Slack mrkdwn with three parts: *Summary*, two or three sentences; *Decisions*, a bulleted
+list of what was agreed; and *Action items*, a bulleted list of who will do what, by when if
+said. Write "None" for a part with nothing in it. Only use what's in the thread.
+
+{{range .Messages}}{{.}}
+{{end}}`,
+	})
+}
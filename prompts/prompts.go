@@ -0,0 +1,136 @@
+// Package prompts keeps the LLM prompts used by the bots as named, versioned templates, so they
+// can be tuned from config and every generation can be traced to the prompt that made it.
+package prompts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/template"
+
+	"github.com/geomodulus/robots/audit"
+)
+
+// Template is a prompt in Go text/template syntax. Bump Version whenever Text changes.
+type Template struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Text    string `json:"text"`
+
+	tmpl *template.Template
+}
+
+// Prompt is a rendered template.
+type Prompt struct {
+	Name    string
+	Version string
+	Text    string
+}
+
+// Registry holds templates by name.
+type Registry struct {
+	// Audit, if set, records the name and version of every rendered prompt.
+	Audit audit.Log
+
+	mu        sync.RWMutex
+	templates map[string]*Template
+}
+
+func NewRegistry() *Registry {
+	return &Registry{templates: map[string]*Template{}}
+}
+
+// Register adds t, replacing any template with the same name.
+func (r *Registry) Register(t Template) error {
+	if t.Name == "" || t.Version == "" {
+		return fmt.Errorf("prompt templates need a name and version")
+	}
+	tmpl, err := template.New(t.Name).Parse(t.Text)
+	if err != nil {
+		return fmt.Errorf("error parsing prompt %s: %v", t.Name, err)
+	}
+	t.tmpl = tmpl
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.templates[t.Name] = &t
+	return nil
+}
+
+// MustRegister is Register for built-in templates, which panics on error.
+func (r *Registry) MustRegister(t Template) {
+	if err := r.Register(t); err != nil {
+		panic(err)
+	}
+}
+
+// Get returns the template named name.
+func (r *Registry) Get(name string) (Template, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.templates[name]
+	if !ok {
+		return Template{}, false
+	}
+	return *t, true
+}
+
+// Names lists the registered templates, sorted.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := []string{}
+	for name := range r.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render executes the template named name with data.
+func (r *Registry) Render(ctx context.Context, name string, data any) (*Prompt, error) {
+	r.mu.RLock()
+	t, ok := r.templates[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no prompt named %q", name)
+	}
+
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("error rendering prompt %s: %v", name, err)
+	}
+
+	if r.Audit != nil {
+		if err := r.Audit.Record(ctx, audit.Entry{
+			Actor:  audit.Actor(ctx),
+			Action: "prompt.render",
+			Fields: map[string]string{"name": t.Name, "version": t.Version},
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return &Prompt{Name: t.Name, Version: t.Version, Text: buf.String()}, nil
+}
+
+// LoadOverrides registers templates from a JSON config, replacing built-ins of the same name:
+//
+//	{"prompts": [{"name": "summarize", "version": "2-newsroom", "text": "…"}]}
+func (r *Registry) LoadOverrides(config io.Reader) error {
+	var overrides struct {
+		Prompts []Template `json:"prompts"`
+	}
+	if err := json.NewDecoder(config).Decode(&overrides); err != nil {
+		return fmt.Errorf("error decoding prompt overrides: %v", err)
+	}
+	for _, t := range overrides.Prompts {
+		if err := r.Register(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+// Package pubdate reads and writes articles' pub_date in one place. Pub dates are either a day,
+// "2006-01-02", or a time; both are in Toronto time unless they say otherwise. Ranges like
+// "this week" are computed in Toronto too, so a story published at 11pm doesn't land in
+// tomorrow's digest because the server runs on UTC.
+package pubdate
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	// Embedded so Toronto loads on hosts without a zoneinfo database, like distroless images.
+	_ "time/tzdata"
+)
+
+// DayLayout is the format of day-only pub dates.
+const DayLayout = "2006-01-02"
+
+// Toronto is the time zone pub dates are in.
+var Toronto = mustLoad("America/Toronto")
+
+func mustLoad(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(fmt.Sprintf("pubdate: loading %s: %v", name, err))
+	}
+	return loc
+}
+
+// layouts are the pub date formats Parse accepts, most common first. Those without a zone are
+// read as Toronto time.
+var layouts = []string{
+	DayLayout,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// Parse reads a pub date in any of the formats found in article.json, returning it in Toronto
+// time.
+func Parse(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, Toronto); err == nil {
+			return t.In(Toronto), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("pub date %q isn't a date (%s) or a time (RFC 3339)", s, DayLayout)
+}
+
+// Validate checks s is a pub date Parse can read.
+func Validate(s string) error {
+	_, err := Parse(s)
+	return err
+}
+
+// Normalize rewrites a pub date in its canonical form: days stay days, and times become RFC 3339
+// in Toronto time.
+func Normalize(s string) (string, error) {
+	t, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	if isDay(s) {
+		return t.Format(DayLayout), nil
+	}
+	return t.Format(time.RFC3339), nil
+}
+
+func isDay(s string) bool {
+	_, err := time.Parse(DayLayout, strings.TrimSpace(s))
+	return err == nil
+}
+
+// Day returns the Toronto day t falls on, in DayLayout.
+func Day(t time.Time) string {
+	return t.In(Toronto).Format(DayLayout)
+}
+
+// DayNumber returns the Toronto day t falls on as a number, eg. 20230810, for filtering on in
+// stores that only compare numbers.
+func DayNumber(t time.Time) int {
+	t = t.In(Toronto)
+	return t.Year()*10000 + int(t.Month())*100 + t.Day()
+}
+
+// Range is the pub dates from From, inclusive, to To, exclusive.
+type Range struct {
+	From, To time.Time
+}
+
+// Contains reports whether the pub date s falls in the range. Unreadable pub dates don't.
+func (r Range) Contains(s string) bool {
+	t, err := Parse(s)
+	if err != nil {
+		return false
+	}
+	return !t.Before(r.From) && t.Before(r.To)
+}
+
+// startOfDay is midnight in Toronto on the day t falls on.
+func startOfDay(t time.Time) time.Time {
+	t = t.In(Toronto)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, Toronto)
+}
+
+// Today is the Toronto day now falls on.
+func Today(now time.Time) Range {
+	from := startOfDay(now)
+	return Range{From: from, To: from.AddDate(0, 0, 1)}
+}
+
+// Yesterday is the Toronto day before the one now falls on.
+func Yesterday(now time.Time) Range {
+	to := startOfDay(now)
+	return Range{From: to.AddDate(0, 0, -1), To: to}
+}
+
+// ThisWeek is the week, Monday to Sunday, now falls in.
+func ThisWeek(now time.Time) Range {
+	day := startOfDay(now)
+	// Weekday counts from Sunday; weeks here start on Monday.
+	from := day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	return Range{From: from, To: from.AddDate(0, 0, 7)}
+}
+
+// LastWeek is the week before ThisWeek.
+func LastWeek(now time.Time) Range {
+	this := ThisWeek(now)
+	return Range{From: this.From.AddDate(0, 0, -7), To: this.From}
+}
+
+// ThisMonth is the calendar month now falls in.
+func ThisMonth(now time.Time) Range {
+	now = now.In(Toronto)
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, Toronto)
+	return Range{From: from, To: from.AddDate(0, 1, 0)}
+}
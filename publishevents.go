@@ -0,0 +1,122 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	gh "github.com/google/go-github/v53/github"
+
+	"github.com/geomodulus/robots/events"
+	"github.com/geomodulus/robots/github"
+	"github.com/geomodulus/robots/reqid"
+	"github.com/geomodulus/robots/state"
+)
+
+// publishEventsKey is where PublishEventsJob keeps where its last run left off.
+const publishEventsKey = "publish-events/last"
+
+// PublishEventsJob emits events.ArticlePublished for each article a PR merged since the last run
+// made live. Register Run with a Scheduler, eg.
+//
+//	scheduler.Every("publish-events", 5*time.Minute, job.Run)
+type PublishEventsJob struct {
+	Articles *github.App
+	Events   events.Emitter
+	// Store, if set, keeps where the last run left off, so a restart neither misses merges nor
+	// announces them twice.
+	Store state.Store
+	// Lookback is how far back the first run looks for merges, by default a day.
+	Lookback time.Duration
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+func (j *PublishEventsJob) Run(ctx context.Context) error {
+	now := time.Now()
+	from, err := j.from(ctx, now)
+	if err != nil {
+		return err
+	}
+	activity, err := j.Articles.PullRequestActivity(ctx, from, now)
+	if err != nil {
+		return fmt.Errorf("error listing merged pull requests: %w", err)
+	}
+
+	// Oldest merge first, and each article once: PublishedArticle compares the article before
+	// each merge with main, so every later merge would report it again.
+	next := now
+	seen := map[string]bool{}
+	for i := len(activity.Merged) - 1; i >= 0; i-- {
+		pr := activity.Merged[i]
+		slugs, err := j.Articles.ChangedDirs(ctx, pr.GetNumber(), "articles")
+		if err == nil {
+			err = j.emitPublished(ctx, pr, slugs, seen)
+		}
+		if err != nil {
+			// The next run starts from this merge, so it's retried.
+			reqid.Printf(ctx, "publish events: PR #%d: %v", pr.GetNumber(), err)
+			next = pr.GetMergedAt().Time
+			break
+		}
+	}
+	return j.save(ctx, next)
+}
+
+func (j *PublishEventsJob) emitPublished(ctx context.Context, pr *gh.PullRequest, slugs []string, seen map[string]bool) error {
+	for _, slug := range slugs {
+		if seen[slug] {
+			continue
+		}
+		article, err := j.Articles.PublishedArticle(ctx, slug, pr)
+		if err != nil {
+			return err
+		}
+		seen[slug] = true
+		if article == nil {
+			continue
+		}
+		events.Emit(ctx, j.Events, events.ArticlePublished, events.ArticlePublishedData{
+			ID:      article.ID,
+			Slug:    slug,
+			Name:    article.Name,
+			PubDate: article.PubDate,
+		})
+	}
+	return nil
+}
+
+// from returns where the last run left off, or Lookback before now on the first run.
+func (j *PublishEventsJob) from(ctx context.Context, now time.Time) (time.Time, error) {
+	j.mu.Lock()
+	last := j.last
+	j.mu.Unlock()
+	if last.IsZero() && j.Store != nil {
+		if _, err := j.Store.Get(ctx, publishEventsKey, &last); err != nil {
+			return time.Time{}, fmt.Errorf("error loading last publish check: %v", err)
+		}
+	}
+	if !last.IsZero() {
+		return last, nil
+	}
+	lookback := j.Lookback
+	if lookback == 0 {
+		lookback = defaultPlaceSyncLookback
+	}
+	return now.Add(-lookback), nil
+}
+
+func (j *PublishEventsJob) save(ctx context.Context, last time.Time) error {
+	j.mu.Lock()
+	j.last = last
+	j.mu.Unlock()
+	if j.Store == nil {
+		return nil
+	}
+	if err := j.Store.Put(ctx, publishEventsKey, last); err != nil {
+		return fmt.Errorf("error saving last publish check: %v", err)
+	}
+	return nil
+}
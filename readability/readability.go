@@ -0,0 +1,186 @@
+// Package readability measures how hard an article body is to read: its length, Flesch-Kincaid
+// grade level, how much of it is in the passive voice and which paragraphs run long.
+package readability
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// DefaultMaxParagraphWords is the longest a paragraph should be before it's flagged. Long
+// paragraphs read as walls of text on phones.
+const DefaultMaxParagraphWords = 120
+
+// Report describes an article body.
+type Report struct {
+	Words      int
+	Sentences  int
+	Syllables  int
+	Paragraphs int
+	// GradeLevel is the Flesch-Kincaid grade level, roughly the years of schooling needed to
+	// follow the text.
+	GradeLevel float64
+	// PassiveSentences counts sentences that look to be in the passive voice.
+	PassiveSentences int
+	// LongParagraphs are the paragraphs longer than the limit Analyze was given.
+	LongParagraphs []Paragraph
+}
+
+// Paragraph is a paragraph of the body.
+type Paragraph struct {
+	// Index counts paragraphs from 1.
+	Index int
+	Words int
+	Text  string
+}
+
+// PassiveRatio is the share of sentences in the passive voice, from 0 to 1.
+func (r *Report) PassiveRatio() float64 {
+	if r.Sentences == 0 {
+		return 0
+	}
+	return float64(r.PassiveSentences) / float64(r.Sentences)
+}
+
+// ReadingMinutes estimates how long the body takes to read at 230 words a minute.
+func (r *Report) ReadingMinutes() int {
+	return (r.Words + 229) / 230
+}
+
+var (
+	sentenceEndRe = regexp.MustCompile(`[.!?]+["'”’)]*(\s+|$)`)
+	// passiveRe matches a form of "to be" followed, maybe after an adverb, by a past participle.
+	// It misses some irregular verbs and catches some adjectives, which is fine for a ratio.
+	passiveRe = regexp.MustCompile(`(?i)\b(am|is|are|was|were|be|been|being)\s+(\w+ly\s+)?(\w+ed|built|made|done|found|held|paid|told|sold|sent|led|spent|won|lost|kept|left|run|set|put|cut|hit|brought|bought|thought|caught|taught|given|taken|seen|known|shown|thrown|grown|drawn|written|chosen|driven|hidden|begun|broken|eaten|forgotten|born)\b`)
+)
+
+// Analyze reports on an article body. Paragraphs are the text of each <p> and <li>; text in
+// scripts and styles is ignored. maxParagraphWords is zero for DefaultMaxParagraphWords.
+func Analyze(body string, maxParagraphWords int) *Report {
+	if maxParagraphWords == 0 {
+		maxParagraphWords = DefaultMaxParagraphWords
+	}
+	report := &Report{LongParagraphs: []Paragraph{}}
+	for _, text := range paragraphs(body) {
+		words := strings.Fields(text)
+		if len(words) == 0 {
+			continue
+		}
+		report.Paragraphs++
+		report.Words += len(words)
+		for _, word := range words {
+			report.Syllables += syllables(word)
+		}
+		for _, sentence := range sentences(text) {
+			report.Sentences++
+			if passiveRe.MatchString(sentence) {
+				report.PassiveSentences++
+			}
+		}
+		if len(words) > maxParagraphWords {
+			report.LongParagraphs = append(report.LongParagraphs, Paragraph{
+				Index: report.Paragraphs,
+				Words: len(words),
+				Text:  text,
+			})
+		}
+	}
+	if report.Words > 0 && report.Sentences > 0 {
+		report.GradeLevel = 0.39*float64(report.Words)/float64(report.Sentences) +
+			11.8*float64(report.Syllables)/float64(report.Words) - 15.59
+	}
+	return report
+}
+
+// paragraphs returns the text of each paragraph and list item, with whitespace collapsed.
+func paragraphs(body string) []string {
+	out := []string{}
+	var current strings.Builder
+	depth, skip := 0, 0
+	flush := func() {
+		if text := strings.Join(strings.Fields(current.String()), " "); text != "" {
+			out = append(out, text)
+		}
+		current.Reset()
+	}
+	tokenizer := html.NewTokenizer(strings.NewReader(body))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			flush()
+			return out
+		case html.StartTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "p", "li":
+				flush()
+				depth++
+			case "script", "style":
+				skip++
+			case "br":
+				current.WriteString(" ")
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "p", "li":
+				flush()
+				if depth > 0 {
+					depth--
+				}
+			case "script", "style":
+				if skip > 0 {
+					skip--
+				}
+			}
+		case html.TextToken:
+			if depth > 0 && skip == 0 {
+				current.Write(tokenizer.Text())
+				current.WriteString(" ")
+			}
+		}
+	}
+}
+
+func sentences(text string) []string {
+	out := []string{}
+	start := 0
+	for _, loc := range sentenceEndRe.FindAllStringIndex(text, -1) {
+		if sentence := strings.TrimSpace(text[start:loc[1]]); sentence != "" {
+			out = append(out, sentence)
+		}
+		start = loc[1]
+	}
+	// A paragraph that doesn't end in punctuation, eg. a list item, is still a sentence.
+	if sentence := strings.TrimSpace(text[start:]); sentence != "" {
+		out = append(out, sentence)
+	}
+	return out
+}
+
+// syllables estimates a word's syllables by counting groups of vowels, less a silent final e.
+func syllables(word string) int {
+	word = strings.ToLower(strings.TrimFunc(word, func(r rune) bool { return !unicode.IsLetter(r) }))
+	if word == "" {
+		return 0
+	}
+	count := 0
+	prevVowel := false
+	for _, r := range word {
+		vowel := strings.ContainsRune("aeiouy", r)
+		if vowel && !prevVowel {
+			count++
+		}
+		prevVowel = vowel
+	}
+	if strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "le") && count > 1 {
+		count--
+	}
+	if count == 0 {
+		count = 1
+	}
+	return count
+}
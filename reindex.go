@@ -0,0 +1,225 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/geomodulus/citygraph"
+	"github.com/slack-go/slack"
+
+	"github.com/geomodulus/robots/events"
+	"github.com/geomodulus/robots/flags"
+	"github.com/geomodulus/robots/github"
+	"github.com/geomodulus/robots/search"
+	"github.com/geomodulus/robots/state"
+)
+
+// Where ReindexJob keeps the live articles and the places it found on its last run.
+const (
+	reindexSeenKey       = "reindex/seen"
+	reindexSeenPlacesKey = "reindex/seen-places"
+)
+
+// ReindexJob keeps the search index in step with the articles repo: new and changed live
+// articles are embedded, and articles that were unpublished, archived or deleted are pruned. It
+// posts a summary to Channel. Register Run with a Scheduler, eg.
+//
+//	scheduler.Daily("reindex", 3, 0, toronto, job.Run)
+type ReindexJob struct {
+	Bot      *SlackBot
+	Articles *github.App
+	Search   *search.Client
+	Channel  string
+	// Flags, if set, can switch the job off with flags.Reindex.
+	Flags *flags.Set
+	// Events, if set, is told about each run.
+	Events events.Emitter
+	// Places, if set, has its places kept in step with search.PlacesNamespace too, for
+	// PlacesCommand.
+	Places *github.App
+	// Store, if set, keeps what the last run found, so articles and places deleted while the
+	// bot was down are still pruned. Without one the first run after a restart can't prune them.
+	Store state.Store
+
+	// seen is the IDs of live articles found on the last run, so ones deleted outright from the
+	// repo can be pruned, when there's no Store.
+	mu         sync.Mutex
+	seen       map[string]bool
+	seenPlaces map[string]bool
+}
+
+func (j *ReindexJob) Run(ctx context.Context) error {
+	if !j.Flags.Enabled(ctx, flags.Reindex) {
+		log.Printf("reindex is switched off, skipping")
+		return nil
+	}
+
+	checkouts, err := j.Articles.ListArticles(ctx)
+	if err != nil {
+		return fmt.Errorf("error listing articles: %w", err)
+	}
+	archived, err := j.Articles.ListArchivedArticles(ctx)
+	if err != nil {
+		return fmt.Errorf("error listing archived articles: %w", err)
+	}
+
+	articles := []*citygraph.Article{}
+	slugs := map[string]string{}
+	live := map[string]bool{}
+	remove := []string{}
+	for _, checkout := range checkouts {
+		articles = append(articles, checkout.Article)
+		slugs[checkout.Article.ID] = checkout.Slug
+		if checkout.Article.IsLive && checkout.Article.PubDate != "" {
+			live[checkout.Article.ID] = true
+		} else {
+			remove = append(remove, checkout.Article.ID)
+		}
+	}
+	for _, checkout := range archived {
+		remove = append(remove, checkout.Article.ID)
+	}
+
+	seen, err := j.lastSeen(ctx, reindexSeenKey, &j.seen)
+	if err != nil {
+		return err
+	}
+	for id := range seen {
+		if _, ok := slugs[id]; !ok {
+			remove = append(remove, id)
+		}
+	}
+
+	stats, err := j.Search.Sync(ctx, articles, func(ctx context.Context, article *citygraph.Article) (string, error) {
+		checkout, err := j.Articles.FetchArticle(ctx, slugs[article.ID])
+		if err != nil {
+			return "", err
+		}
+		return checkout.BodyHTML, nil
+	}, remove)
+	if err != nil {
+		return fmt.Errorf("error syncing search index: %w", err)
+	}
+
+	if err := j.saveSeen(ctx, reindexSeenKey, &j.seen, live); err != nil {
+		return err
+	}
+	events.Emit(ctx, j.Events, events.IndexRebuilt, events.IndexRebuiltData{
+		Indexed:   stats.Indexed,
+		Updated:   stats.Updated,
+		Unchanged: stats.Unchanged,
+		Deleted:   stats.Deleted,
+		Failed:    stats.Failed,
+	})
+
+	text := fmt.Sprintf("*Search reindex* — %d new, %d updated, %d upgraded, %d unchanged, %d pruned",
+		stats.Indexed, stats.Updated, stats.Upgraded, stats.Unchanged, stats.Deleted)
+	if stats.Failed > 0 {
+		text += fmt.Sprintf(", :warning: %d failed", stats.Failed)
+	}
+	if j.Places != nil {
+		placeStats, err := j.syncPlaces(ctx)
+		if err != nil {
+			return err
+		}
+		text += fmt.Sprintf("\nPlaces — %d new, %d updated, %d unchanged, %d pruned",
+			placeStats.Indexed, placeStats.Updated, placeStats.Unchanged, placeStats.Deleted)
+		if placeStats.Failed > 0 {
+			text += fmt.Sprintf(", :warning: %d failed", placeStats.Failed)
+		}
+		stats.Tokens += placeStats.Tokens
+	}
+	text += fmt.Sprintf("\n%d tokens embedded, about $%.4f", stats.Tokens, stats.Cost())
+
+	_, _, err = j.Bot.PostMessageContext(ctx, j.Channel, slack.MsgOptionBlocks(
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+	))
+	return err
+}
+
+// syncPlaces indexes the places repo. Places deleted from the repo since the last run are
+// pruned; closed ones stay, so /places can say they're closed.
+func (j *ReindexJob) syncPlaces(ctx context.Context) (*search.SyncStats, error) {
+	checkouts, err := j.Places.ListPlaces(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing places: %w", err)
+	}
+	places := []*search.Place{}
+	seen := map[string]bool{}
+	for _, checkout := range checkouts {
+		places = append(places, placeDocument(checkout))
+		seen[checkout.Place.ID] = true
+	}
+
+	last, err := j.lastSeen(ctx, reindexSeenPlacesKey, &j.seenPlaces)
+	if err != nil {
+		return nil, err
+	}
+	remove := []string{}
+	for id := range last {
+		if !seen[id] {
+			remove = append(remove, id)
+		}
+	}
+
+	stats, err := j.Search.SyncPlaces(ctx, places, remove)
+	if err != nil {
+		return nil, fmt.Errorf("error syncing places index: %w", err)
+	}
+	if err := j.saveSeen(ctx, reindexSeenPlacesKey, &j.seenPlaces, seen); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// lastSeen returns the IDs found on the last run, from Store if it's set or else from cached.
+// It returns nil if there hasn't been a run.
+func (j *ReindexJob) lastSeen(ctx context.Context, key string, cached *map[string]bool) (map[string]bool, error) {
+	if j.Store == nil {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		return *cached, nil
+	}
+	var seen map[string]bool
+	if _, err := j.Store.Get(ctx, key, &seen); err != nil {
+		return nil, fmt.Errorf("error loading %s: %v", key, err)
+	}
+	return seen, nil
+}
+
+// saveSeen remembers the IDs found on this run, in Store if it's set or else in cached.
+func (j *ReindexJob) saveSeen(ctx context.Context, key string, cached *map[string]bool, seen map[string]bool) error {
+	if j.Store == nil {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		*cached = seen
+		return nil
+	}
+	if err := j.Store.Put(ctx, key, seen); err != nil {
+		return fmt.Errorf("error saving %s: %v", key, err)
+	}
+	return nil
+}
+
+// placeDocument is what's indexed about a place. Its neighbourhood comes from a hand-set
+// "neighbourhood" attribute, if it has one.
+func placeDocument(checkout *github.PlaceCheckout) *search.Place {
+	place := checkout.Place
+	doc := &search.Place{
+		ID:      place.ID,
+		Slug:    checkout.Slug,
+		Name:    place.Name,
+		Type:    place.Type,
+		Status:  place.Status,
+		Address: place.Address,
+		Lng:     place.Location.Lng,
+		Lat:     place.Location.Lat,
+		Text:    place.Desc,
+	}
+	if attr, ok := checkout.Attributes["neighbourhood"]; ok {
+		doc.Neighbourhood = attr.Value
+	}
+	return doc
+}
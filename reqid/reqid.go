@@ -0,0 +1,52 @@
+// Package reqid tags everything done for one Slack event with a short ID, so when two editors
+// publish at once their log lines, errors and audit entries can be told apart. The bot attaches
+// an ID to the context of each handler call; packages that log take it from there.
+package reqid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sync/atomic"
+)
+
+// fallback numbers IDs if the system's randomness is unavailable.
+var fallback atomic.Uint64
+
+// New returns a new ID, eg. "3f9a1c2e".
+func New() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("n%07d", fallback.Add(1))
+	}
+	return hex.EncodeToString(b)
+}
+
+type idKey struct{}
+
+// With attaches id to ctx.
+func With(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, idKey{}, id)
+}
+
+// From returns the ID attached to ctx, if any.
+func From(ctx context.Context) string {
+	id, _ := ctx.Value(idKey{}).(string)
+	return id
+}
+
+// Printf logs like log.Printf, prefixed with ctx's ID if it has one.
+func Printf(ctx context.Context, format string, args ...any) {
+	if id := From(ctx); id != "" {
+		format = "[" + id + "] " + format
+	}
+	log.Printf(format, args...)
+}
+
+// Detach returns a context that carries ctx's ID but not its deadline or cancellation, for
+// background work that outlives the request, like waiting on a PR's builds.
+func Detach(ctx context.Context) context.Context {
+	return With(context.Background(), From(ctx))
+}
@@ -2,42 +2,90 @@ package robots
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"time"
 
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
 	"github.com/slack-go/slack/socketmode"
+
+	"github.com/geomodulus/robots/internal/cache"
+	"github.com/geomodulus/robots/internal/llm"
+	"github.com/geomodulus/robots/internal/retry"
+	"github.com/geomodulus/robots/reqid"
+	"github.com/geomodulus/robots/search"
 )
 
 type SlackAppMentionHandler interface {
-	HandleAppMention(ctx context.Context, ev *slackevents.AppMentionEvent) error
+	HandleAppMention(ctx context.Context, env *Envelope, ev *slackevents.AppMentionEvent) error
 }
 
 type SlackMessageHandler interface {
-	HandleMessage(ctx context.Context, ev *slackevents.MessageEvent) error
+	HandleMessage(ctx context.Context, env *Envelope, ev *slackevents.MessageEvent) error
+}
+
+type SlackLinkSharedHandler interface {
+	HandleLinkShared(ctx context.Context, env *Envelope, ev *slackevents.LinkSharedEvent) error
+}
+
+// SlackAppHomeOpenedHandler is called when a user opens the bot's Home tab, eg. to publish a
+// view of their settings.
+type SlackAppHomeOpenedHandler interface {
+	HandleAppHomeOpened(ctx context.Context, env *Envelope, ev *slackevents.AppHomeOpenedEvent) error
 }
 
 type SlackSlashCommandHandler interface {
-	HandleSlashCommand(ctx context.Context, cmd string) ([]slack.Block, error)
+	HandleSlashCommand(ctx context.Context, env *Envelope, cmd string) ([]slack.Block, error)
 }
 
 type SlackBlockActionHandler interface {
-	HandleBlockAction(ctx context.Context, action, value string, callback slack.InteractionCallback) error
+	HandleBlockAction(ctx context.Context, env *Envelope, action, value string, callback slack.InteractionCallback) error
 }
 
 type SlackViewSubmissionHandler interface {
-	HandleViewSubmission(ctx context.Context, action, value, privateMetadata string, callback slack.InteractionCallback) error
+	HandleViewSubmission(ctx context.Context, env *Envelope, action, value, privateMetadata string, callback slack.InteractionCallback) error
+}
+
+// SlackModalFlow answers submissions of its own modals in place, eg. to move to the next page
+// or show validation errors. Flows are tried before SlackViewSubmissionHandler.
+type SlackModalFlow interface {
+	HandlesView(view slack.View) bool
+	RespondViewSubmission(ctx context.Context, env *Envelope, callback slack.InteractionCallback) (*slack.ViewSubmissionResponse, error)
+}
+
+// SlackModalCloser is a SlackModalFlow that's told when one of its modals is closed without
+// being submitted, eg. to discard a draft. Its views must set NotifyOnClose.
+type SlackModalCloser interface {
+	HandleViewClosed(ctx context.Context, env *Envelope, callback slack.InteractionCallback) error
 }
 
 type SlackBot struct {
 	*slack.Client
 	Handler any
 	Socket  *socketmode.Client
+	// Modals are multi-step modal flows, like ArticleEditor.
+	Modals []SlackModalFlow
+	// Memory, if set, keeps per-thread conversation history for handlers.
+	Memory *ConversationMemory
+	// Token is the bot token, for Web API methods slack-go doesn't wrap yet, like canvases.
+	Token string
+	// Profiles, if set, configure the bot per channel. Slash commands a channel's profile
+	// doesn't allow are refused before they reach the Handler.
+	Profiles *ChannelProfiles
+
+	startedAt    time.Time
+	errMu        sync.Mutex
+	recentErrors []HandlerError
+	usersOnce    sync.Once
+	users        *cache.TTL[string]
 }
 
 // Run starts the bot.
 func (b *SlackBot) Run(ctx context.Context) {
+	b.startedAt = time.Now()
 	// TODO(chris): How do we gracefully shutdown the socket?
 	go b.Socket.Run()
 
@@ -56,9 +104,12 @@ func (b *SlackBot) Run(ctx context.Context) {
 			case *slackevents.AppMentionEvent:
 				if handler, ok := b.Handler.(SlackAppMentionHandler); ok {
 					//log.Printf("⭐ app mention handler: %s", ev.Text)
-					if err := handler.HandleAppMention(ctx, ev); err != nil {
-						b.Reply(ev.Channel, ev.TimeStamp, slack.MsgOptionBlocks(
-							errorBlock(fmt.Sprintf(":warning: Error! `%s`: %v", ev.Text, err)),
+					env := b.appMentionEnvelope(eventsAPIEvent.TeamID, ev)
+					ctx := reqid.With(ctx, env.RequestID)
+					if err := handler.HandleAppMention(ctx, env, ev); err != nil {
+						b.recordError(env, "app_mention", err)
+						b.ReplyTo(env, slack.MsgOptionBlocks(
+							handlerErrorBlock(env, ev.Text, err),
 						))
 					}
 				}
@@ -66,12 +117,37 @@ func (b *SlackBot) Run(ctx context.Context) {
 			case *slackevents.MessageEvent:
 				if handler, ok := b.Handler.(SlackMessageHandler); ok {
 					//log.Printf("⭐ message handler: %s", ev.Text)
-					if err := handler.HandleMessage(ctx, ev); err != nil {
-						b.Reply(ev.Channel, ev.TimeStamp, slack.MsgOptionBlocks(
-							errorBlock(fmt.Sprintf(":warning: Error! `%s`: %v", ev.Text, err)),
+					env := b.messageEnvelope(eventsAPIEvent.TeamID, ev)
+					ctx := reqid.With(ctx, env.RequestID)
+					if err := handler.HandleMessage(ctx, env, ev); err != nil {
+						b.recordError(env, "message", err)
+						b.ReplyTo(env, slack.MsgOptionBlocks(
+							handlerErrorBlock(env, ev.Text, err),
 						))
 					}
 				}
+
+			case *slackevents.LinkSharedEvent:
+				if handler, ok := b.Handler.(SlackLinkSharedHandler); ok {
+					// Replying to every pasted link with an error would be noisy, so just log.
+					env := b.linkSharedEnvelope(eventsAPIEvent.TeamID, ev)
+					ctx := reqid.With(ctx, env.RequestID)
+					if err := handler.HandleLinkShared(ctx, env, ev); err != nil {
+						b.recordError(env, "link_shared", err)
+						reqid.Printf(ctx, "link shared handler: %v", err)
+					}
+				}
+
+			case *slackevents.AppHomeOpenedEvent:
+				if handler, ok := b.Handler.(SlackAppHomeOpenedHandler); ok && ev.Tab == "home" {
+					// There's no thread to reply in, so just log.
+					env := b.appHomeEnvelope(eventsAPIEvent.TeamID, ev)
+					ctx := reqid.With(ctx, env.RequestID)
+					if err := handler.HandleAppHomeOpened(ctx, env, ev); err != nil {
+						b.recordError(env, "app_home_opened", err)
+						reqid.Printf(ctx, "app home handler: %v", err)
+					}
+				}
 			}
 
 		case socketmode.EventTypeSlashCommand:
@@ -83,11 +159,22 @@ func (b *SlackBot) Run(ctx context.Context) {
 			}
 
 			if handler, ok := b.Handler.(SlackSlashCommandHandler); ok {
-				blocks, err := handler.HandleSlashCommand(ctx, cmd.Command)
+				env := b.slashCommandEnvelope(cmd)
+				ctx := reqid.With(ctx, env.RequestID)
+				if !env.Profile.Allows(cmd.Command) {
+					b.Socket.Ack(*evt.Request, map[string]interface{}{
+						"blocks": []slack.Block{
+							errorBlock(fmt.Sprintf(":no_entry: `%s` isn't available in this channel.", cmd.Command)),
+						},
+					})
+					continue
+				}
+				blocks, err := handler.HandleSlashCommand(ctx, env, cmd.Command)
 				if err != nil {
+					b.recordError(env, cmd.Command, err)
 					b.Socket.Ack(*evt.Request, map[string]interface{}{
 						"blocks": []slack.Block{
-							errorBlock(fmt.Sprintf(":warning: Error! `%s`: %v", cmd.Command, err)),
+							handlerErrorBlock(env, cmd.Command, err),
 						},
 					})
 				}
@@ -103,29 +190,54 @@ func (b *SlackBot) Run(ctx context.Context) {
 				log.Printf("Unexpected data: %v", evt.Data)
 				continue
 			}
+			env := b.interactionEnvelope(callback)
+			ctx := reqid.With(ctx, env.RequestID)
+			if callback.Type == slack.InteractionTypeViewSubmission {
+				if flow := b.modalFlow(callback.View); flow != nil {
+					resp, err := flow.RespondViewSubmission(ctx, env, callback)
+					if err != nil {
+						b.recordError(env, callback.View.CallbackID, err)
+						reqid.Printf(ctx, "modal %s: %v", callback.View.CallbackID, err)
+						b.Socket.Ack(*evt.Request)
+						continue
+					}
+					b.Socket.Ack(*evt.Request, resp)
+					continue
+				}
+			}
 			b.Socket.Ack(*evt.Request)
 
 			switch callback.Type {
 			case slack.InteractionTypeBlockActions:
 				for _, action := range callback.ActionCallback.BlockActions {
-					log.Printf("button pushed: %s %s", action.ActionID, action.Value)
+					reqid.Printf(ctx, "button pushed: %s %s", action.ActionID, action.Value)
 					if handler, ok := b.Handler.(SlackBlockActionHandler); ok {
-						if err := handler.HandleBlockAction(ctx, action.ActionID, action.Value, callback); err != nil {
-							b.Reply(callback.Channel.ID, callback.MessageTs, slack.MsgOptionBlocks(
-								errorBlock(fmt.Sprintf(":warning: Error! `%s`: %v", action.ActionID, err)),
+						if err := handler.HandleBlockAction(ctx, env, action.ActionID, action.Value, callback); err != nil {
+							b.recordError(env, action.ActionID, err)
+							b.ReplyTo(env, slack.MsgOptionBlocks(
+								handlerErrorBlock(env, action.ActionID, err),
 							))
 						}
 					}
 				}
 
+			case slack.InteractionTypeViewClosed:
+				if closer, ok := b.modalFlow(callback.View).(SlackModalCloser); ok {
+					if err := closer.HandleViewClosed(ctx, env, callback); err != nil {
+						b.recordError(env, callback.View.CallbackID, err)
+						reqid.Printf(ctx, "modal %s closed: %v", callback.View.CallbackID, err)
+					}
+				}
+
 			case slack.InteractionTypeViewSubmission:
 				inputs := callback.View.State.Values
 				for _, input := range inputs {
 					for actionID, value := range input {
 						if handler, ok := b.Handler.(SlackViewSubmissionHandler); ok {
-							if err := handler.HandleViewSubmission(ctx, actionID, value.Value, callback.View.PrivateMetadata, callback); err != nil {
-								b.Reply(callback.Channel.ID, callback.MessageTs, slack.MsgOptionBlocks(
-									errorBlock(fmt.Sprintf(":warning: Error! `%s`: %v", actionID, err)),
+							if err := handler.HandleViewSubmission(ctx, env, actionID, value.Value, callback.View.PrivateMetadata, callback); err != nil {
+								b.recordError(env, actionID, err)
+								b.ReplyTo(env, slack.MsgOptionBlocks(
+									handlerErrorBlock(env, actionID, err),
 								))
 							}
 						}
@@ -136,11 +248,61 @@ func (b *SlackBot) Run(ctx context.Context) {
 	}
 }
 
+func (b *SlackBot) modalFlow(view slack.View) SlackModalFlow {
+	for _, flow := range b.Modals {
+		if flow.HandlesView(view) {
+			return flow
+		}
+	}
+	return nil
+}
+
 func (b *SlackBot) Reply(channel string, ts string, opts ...slack.MsgOption) error {
-	_, _, err := b.PostMessage(
-		channel,
-		append(opts, slack.MsgOptionTS(ts))...)
-	return err
+	return retry.Do(context.Background(), slackRetryPolicy, func(ctx context.Context) error {
+		_, _, err := b.PostMessageContext(
+			ctx,
+			channel,
+			append(opts, slack.MsgOptionTS(ts))...)
+		return err
+	})
+}
+
+// slackRetryPolicy retries Slack calls on rate limits, 5xx responses and network errors, waiting
+// as long as Slack asks when rate limited.
+var slackRetryPolicy = retry.Policy{
+	MaxAttempts: retry.Default.MaxAttempts,
+	BaseDelay:   retry.Default.BaseDelay,
+	MaxDelay:    retry.Default.MaxDelay,
+	Retryable: func(err error) bool {
+		var rateErr *slack.RateLimitedError
+		if errors.As(err, &rateErr) {
+			return true
+		}
+		var statusErr slack.StatusCodeError
+		if errors.As(err, &statusErr) {
+			return retry.RetryableStatus(statusErr.Code)
+		}
+		return retry.IsTransient(err)
+	},
+	RetryAfter: func(err error) time.Duration {
+		var rateErr *slack.RateLimitedError
+		if errors.As(err, &rateErr) {
+			return rateErr.RetryAfter
+		}
+		return 0
+	},
+}
+
+// handlerErrorBlock reports a handler's error, with the request ID so it can be found in the
+// logs.
+func handlerErrorBlock(env *Envelope, subject string, err error) *slack.SectionBlock {
+	if errors.Is(err, llm.ErrUnavailable) {
+		return errorBlock(fmt.Sprintf(":hourglass: %s\n_Request `%s`_", llm.UnavailableMessage, env.RequestID))
+	}
+	if errors.Is(err, search.ErrUnavailable) {
+		return errorBlock(fmt.Sprintf(":hourglass: %s\n_Request `%s`_", search.UnavailableMessage, env.RequestID))
+	}
+	return errorBlock(fmt.Sprintf(":warning: Error! `%s`: %v\n_Request `%s`_", subject, err, env.RequestID))
 }
 
 func errorBlock(msg string) *slack.SectionBlock {
@@ -0,0 +1,250 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/slack-go/slack"
+
+	"github.com/geomodulus/robots/audit"
+	"github.com/geomodulus/robots/command"
+	"github.com/geomodulus/robots/github"
+)
+
+// Action IDs of the buttons on a rollback preview. Forward them from the bot's
+// HandleBlockAction to RollbackCommand.HandleBlockAction.
+const (
+	RollbackConfirmActionID = "rollback_confirm"
+	RollbackCancelActionID  = "rollback_cancel"
+)
+
+const (
+	// rollbackHistory is how many revisions a rollback preview lists.
+	rollbackHistory = 5
+	// rollbackJSONPreviews is how many of the JSON files a rollback restores are previewed.
+	rollbackJSONPreviews = 3
+)
+
+// RollbackCommand undoes a bad publish:
+//
+//	/rollback bike-lanes-bloor
+//	/rollback bike-lanes-bloor 1a2b3c4
+//
+// It posts the article's recent history and what rolling back would change, by default to the
+// revision before the latest, and commits the old version to main once someone confirms. Call
+// HandleSlashCommand from the bot's handler for the command it's registered as.
+type RollbackCommand struct {
+	Bot      *SlackBot
+	Articles *github.App
+	// Admins are the Slack user IDs allowed to confirm a rollback. Empty means anyone.
+	Admins []string
+	// Locks, if set, holds the article for whoever asked for a rollback preview until it's
+	// confirmed or cancelled, so the rollback doesn't race someone else's edits.
+	Locks *DraftLocks
+	// Previews, if set, shows the JSON and GeoJSON files the rollback restores as they'll be
+	// once it's done.
+	Previews *JSONPreviews
+}
+
+// HandlesAction reports whether actionID is one of the rollback buttons.
+func (c *RollbackCommand) HandlesAction(actionID string) bool {
+	return actionID == RollbackConfirmActionID || actionID == RollbackCancelActionID
+}
+
+var rollbackGrammar = &command.Grammar{
+	Commands: []*command.Command{{
+		Args: []command.Arg{{Name: "slug", Kind: command.Slug}, {Name: "commit", Optional: true}},
+		Help: "Roll an article back to the given commit, by default the revision before the latest.",
+	}},
+}
+
+func (c *RollbackCommand) HandleSlashCommand(ctx context.Context, env *Envelope, cmd string) ([]slack.Block, error) {
+	inv, help, err := parseCommand(env, rollbackGrammar.WithName(cmd))
+	if inv == nil {
+		return help, err
+	}
+	slug := inv.Arg("slug")
+
+	history, err := c.Articles.ArticleHistory(ctx, slug, rollbackHistory)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) == 0 {
+		return nil, fmt.Errorf("no history for `%s`; check the slug", slug)
+	}
+	head := history[0]
+
+	target := inv.Arg("commit")
+	if target == "" {
+		if len(history) < 2 {
+			return nil, fmt.Errorf("`%s` has only one revision, so there's nothing to roll back to", slug)
+		}
+		target = history[1].SHA
+	}
+	if strings.HasPrefix(head.SHA, target) {
+		return nil, fmt.Errorf("`%.7s` is already the latest revision of `%s`", target, slug)
+	}
+
+	diffs, err := c.Articles.DiffArticle(ctx, slug, target, head.SHA)
+	if err != nil {
+		return nil, err
+	}
+	if c.Locks != nil {
+		if ok, err := c.Locks.Acquire(ctx, env, slug); !ok || err != nil {
+			return nil, err
+		}
+	}
+
+	lines := []string{fmt.Sprintf("*Roll back `%s` to `%.7s`?*", slug, target), "", "*Recent revisions*"}
+	for _, rev := range history {
+		marker := "•"
+		switch {
+		case rev == head:
+			marker = ":arrow_right:"
+		case strings.HasPrefix(rev.SHA, target):
+			marker = ":leftwards_arrow_with_hook:"
+		}
+		lines = append(lines, fmt.Sprintf("%s <%s|%.7s> %s — %s, %s", marker, rev.URL, rev.SHA,
+			escapeMrkdwn(rev.Subject), escapeMrkdwn(rev.Author), slackDate(rev.Time)))
+	}
+	lines = append(lines, "", "*What changes*")
+	if len(diffs) == 0 {
+		lines = append(lines, "Nothing: the article's files are the same at both revisions.")
+	}
+	for _, diff := range diffs {
+		lines = append(lines, fmt.Sprintf("• `%s` %s (+%d −%d)", diff.Path, diff.Status, diff.Additions, diff.Deletions))
+	}
+	compareURL := fmt.Sprintf("https://github.com/%s/%s/compare/%s...%s", c.Articles.Owner, c.Articles.Repo, target, head.SHA)
+	lines = append(lines, fmt.Sprintf("<%s|Full diff on GitHub>", compareURL))
+
+	// The head is part of the value, so a confirm after someone else's change is refused.
+	value := strings.Join([]string{slug, target, head.SHA}, " ")
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, strings.Join(lines, "\n"), false, false), nil, nil),
+	}
+	if c.Previews != nil {
+		previews, err := c.jsonPreviews(ctx, diffs, target)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, previews...)
+	}
+	blocks = append(blocks,
+		slack.NewActionBlock("",
+			slack.NewButtonBlockElement(RollbackConfirmActionID, value,
+				slack.NewTextBlockObject(slack.PlainTextType, "Roll back", false, false)).WithStyle(slack.StyleDanger),
+			slack.NewButtonBlockElement(RollbackCancelActionID, value,
+				slack.NewTextBlockObject(slack.PlainTextType, "Cancel", false, false)),
+		),
+	)
+	// Posted to the channel rather than returned, so everyone sees the rollback and who did it.
+	if _, _, err := c.Bot.PostMessageContext(ctx, env.ChannelID, slack.MsgOptionBlocks(blocks...)); err != nil {
+		return nil, fmt.Errorf("error posting rollback preview: %v", err)
+	}
+	return []slack.Block{slack.NewContextBlock("",
+		slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("Posted a rollback preview for `%s`.", slug), false, false))}, nil
+}
+
+// jsonPreviews previews the JSON and GeoJSON files among diffs as they are at target, which is
+// what the rollback puts back. Files added since target are deleted by it, so they're skipped.
+func (c *RollbackCommand) jsonPreviews(ctx context.Context, diffs []*github.ArticleFileDiff, target string) ([]slack.Block, error) {
+	blocks := []slack.Block{}
+	previewed := 0
+	for _, diff := range diffs {
+		if diff.Status == "added" || !isJSONFile(diff.Path) {
+			continue
+		}
+		if previewed == rollbackJSONPreviews {
+			break
+		}
+		content, found, err := c.Articles.FileAt(ctx, diff.Path, target)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+		// An old revision may hold a file that isn't valid JSON; that shouldn't stop the rollback.
+		preview, err := c.Previews.Blocks(ctx, path.Base(diff.Path), []byte(content))
+		if err != nil {
+			log.Printf("error previewing %s at %.7s: %v", diff.Path, target, err)
+			continue
+		}
+		blocks = append(blocks, preview...)
+		previewed++
+	}
+	return blocks, nil
+}
+
+// HandleBlockAction rolls back, or cancels, the preview a button belongs to.
+func (c *RollbackCommand) HandleBlockAction(ctx context.Context, env *Envelope, action, value string, callback slack.InteractionCallback) error {
+	parts := strings.Fields(value)
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed rollback %q", value)
+	}
+	slug, target, head := parts[0], parts[1], parts[2]
+
+	var outcome string
+	switch action {
+	case RollbackConfirmActionID:
+		if len(c.Admins) > 0 && !isAdmin(c.Admins, env.UserID) {
+			return fmt.Errorf("only admins can roll back articles")
+		}
+		history, err := c.Articles.ArticleHistory(ctx, slug, 1)
+		if err != nil {
+			return err
+		}
+		if len(history) == 0 || history[0].SHA != head {
+			return fmt.Errorf("`%s` has changed since this preview; run the command again", slug)
+		}
+
+		commit := &github.CommitMessage{
+			Type:    "update",
+			Scope:   "article",
+			Summary: fmt.Sprintf("Roll back %s to %.7s", slug, target),
+			Body:    "Undoes: " + history[0].Subject,
+			Slug:    slug,
+		}
+		commit.ThreadURL, _ = env.Permalink(ctx)
+		commitMessage, err := commit.Build()
+		if err != nil {
+			return err
+		}
+		if c.Locks != nil {
+			if ok, err := c.Locks.Acquire(ctx, env, slug); !ok || err != nil {
+				return err
+			}
+		}
+		// The commit is made as the lock holder, so DraftLocks.PreCommitHook lets it through.
+		sha, err := c.Articles.RevertArticleTo(audit.WithActor(ctx, env.UserID), slug, target, github.WithCommitMessage(commitMessage))
+		if err != nil {
+			return fmt.Errorf("error rolling back: %v", err)
+		}
+		outcome = fmt.Sprintf(":leftwards_arrow_with_hook: <@%s> rolled back `%s` to `%.7s` in <https://github.com/%s/%s/commit/%s|%.7s>.",
+			env.UserID, slug, target, c.Articles.Owner, c.Articles.Repo, sha, sha)
+	case RollbackCancelActionID:
+		outcome = fmt.Sprintf(":x: <@%s> cancelled this rollback.", env.UserID)
+	default:
+		return fmt.Errorf("unknown action %q", action)
+	}
+	if c.Locks != nil {
+		if err := c.Locks.Release(ctx, env, slug); err != nil {
+			log.Printf("error releasing lock on %s: %v", slug, err)
+		}
+	}
+
+	// Swap the buttons for the outcome so the rollback can't be confirmed twice.
+	blocks := []slack.Block{}
+	for _, block := range callback.Message.Blocks.BlockSet {
+		if !hasButton(block, RollbackConfirmActionID, RollbackCancelActionID) {
+			blocks = append(blocks, block)
+		}
+	}
+	blocks = append(blocks, slack.NewContextBlock("",
+		slack.NewTextBlockObject(slack.MarkdownType, outcome, false, false)))
+	_, _, _, err := c.Bot.UpdateMessageContext(ctx, env.ChannelID, env.MessageTS, slack.MsgOptionBlocks(blocks...))
+	return err
+}
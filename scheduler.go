@@ -0,0 +1,142 @@
+package robots
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/geomodulus/robots/reqid"
+)
+
+// JobFunc is the work done by a scheduled job.
+type JobFunc func(ctx context.Context) error
+
+// JobStatus reports on a scheduled job.
+type JobStatus struct {
+	Name    string
+	NextRun time.Time
+	LastRun time.Time
+	LastErr error
+}
+
+type scheduledJob struct {
+	name string
+	next func(time.Time) time.Time
+	run  JobFunc
+
+	mu     sync.Mutex
+	status JobStatus
+}
+
+// Scheduler runs jobs periodically until its context is cancelled.
+type Scheduler struct {
+	mu   sync.Mutex
+	jobs []*scheduledJob
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{}
+}
+
+// Every runs fn every interval, starting one interval after Run is called.
+func (s *Scheduler) Every(name string, interval time.Duration, fn JobFunc) {
+	s.add(name, fn, func(t time.Time) time.Time {
+		return t.Add(interval)
+	})
+}
+
+// Daily runs fn once a day at the given hour and minute in loc.
+func (s *Scheduler) Daily(name string, hour, minute int, loc *time.Location, fn JobFunc) {
+	s.add(name, fn, func(t time.Time) time.Time {
+		t = t.In(loc)
+		next := time.Date(t.Year(), t.Month(), t.Day(), hour, minute, 0, 0, loc)
+		if !next.After(t) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next
+	})
+}
+
+// Weekly runs fn once a week on the given day, at the given hour and minute in loc.
+func (s *Scheduler) Weekly(name string, day time.Weekday, hour, minute int, loc *time.Location, fn JobFunc) {
+	s.add(name, fn, func(t time.Time) time.Time {
+		t = t.In(loc)
+		next := time.Date(t.Year(), t.Month(), t.Day(), hour, minute, 0, 0, loc)
+		next = next.AddDate(0, 0, (int(day)-int(next.Weekday())+7)%7)
+		if !next.After(t) {
+			next = next.AddDate(0, 0, 7)
+		}
+		return next
+	})
+}
+
+func (s *Scheduler) add(name string, fn JobFunc, next func(time.Time) time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, &scheduledJob{
+		name:   name,
+		next:   next,
+		run:    fn,
+		status: JobStatus{Name: name},
+	})
+}
+
+// Jobs reports the status of every registered job.
+func (s *Scheduler) Jobs() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := []JobStatus{}
+	for _, job := range s.jobs {
+		job.mu.Lock()
+		res = append(res, job.status)
+		job.mu.Unlock()
+	}
+	return res
+}
+
+// Run starts every job and blocks until ctx is done. Jobs never overlap with themselves; errors
+// are logged and recorded in the job's status.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	jobs := append([]*scheduledJob{}, s.jobs...)
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func(job *scheduledJob) {
+			defer wg.Done()
+			job.loop(ctx)
+		}(job)
+	}
+	wg.Wait()
+}
+
+func (j *scheduledJob) loop(ctx context.Context) {
+	for {
+		next := j.next(time.Now())
+		j.mu.Lock()
+		j.status.NextRun = next
+		j.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		start := time.Now()
+		runCtx := reqid.With(ctx, reqid.New())
+		err := j.run(runCtx)
+		if err != nil {
+			reqid.Printf(runCtx, "scheduled job %s failed: %v", j.name, err)
+		}
+
+		j.mu.Lock()
+		j.status.LastRun = start
+		j.status.LastErr = err
+		j.mu.Unlock()
+	}
+}
@@ -0,0 +1,188 @@
+package search
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/geomodulus/citygraph"
+	"github.com/nekomeowww/go-pinecone"
+
+	"github.com/geomodulus/robots/reqid"
+)
+
+// maxListedVectors is the most IDs a single Pinecone query returns, which bounds how many
+// vectors Audit can list.
+const maxListedVectors = 10000
+
+// AuditReport compares the live corpus with what's in the index.
+type AuditReport struct {
+	// Vectors is how many vectors the default namespace holds.
+	Vectors int
+	// Missing are live articles with no vector.
+	Missing []*citygraph.Article
+	// Stale are live articles whose vector's metadata or content hash no longer matches.
+	Stale []*citygraph.Article
+	// Orphaned are IDs of vectors with no live article.
+	Orphaned []string
+	// Truncated is set when the index holds more vectors than could be listed, so Orphaned may
+	// be incomplete.
+	Truncated bool
+	// Repaired is set when AutoRepair fixed everything above.
+	Repaired bool
+}
+
+// OK reports whether the index matches the corpus.
+func (r *AuditReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Stale) == 0 && len(r.Orphaned) == 0
+}
+
+func (r *AuditReport) String() string {
+	return fmt.Sprintf("%d vectors: %d missing, %d stale, %d orphaned", r.Vectors, len(r.Missing), len(r.Stale), len(r.Orphaned))
+}
+
+type auditParams struct {
+	repair bool
+}
+
+// AuditOption configures a call to Audit.
+type AuditOption func(*auditParams)
+
+// AutoRepair embeds missing and stale articles and deletes orphaned vectors.
+func AutoRepair() AuditOption {
+	return func(params *auditParams) {
+		params.repair = true
+	}
+}
+
+// Audit compares the live articles with the default namespace, reporting articles missing from
+// the index, vectors whose metadata or content has drifted from their article, and vectors left
+// behind by deleted articles.
+func (s *Client) Audit(ctx context.Context, articles []*ArticleWithBody, opts ...AuditOption) (*AuditReport, error) {
+	params := auditParams{}
+	for _, opt := range opts {
+		opt(&params)
+	}
+
+	report := &AuditReport{}
+	live := map[string]*ArticleWithBody{}
+	ids := []string{}
+	for _, article := range articles {
+		if article.PubDate != "" && article.IsLive {
+			live[article.ID] = article
+			ids = append(ids, article.ID)
+		}
+	}
+
+	for start := 0; start < len(ids); start += fetchBatchSize {
+		existing, err := s.fetchVectors(ctx, ids[start:min(start+fetchBatchSize, len(ids))])
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids[start:min(start+fetchBatchSize, len(ids))] {
+			article := live[id]
+			vector, found := existing[id]
+			switch {
+			case !found:
+				report.Missing = append(report.Missing, article.Article)
+			case !metadataMatches(vector.Metadata, article.Article) || !contentMatches(vector.Metadata, article):
+				report.Stale = append(report.Stale, article.Article)
+			}
+		}
+	}
+
+	indexed, total, err := s.listVectorIDs(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	report.Vectors = total
+	report.Truncated = len(indexed) < total
+	for _, id := range indexed {
+		if live[id] == nil {
+			report.Orphaned = append(report.Orphaned, id)
+		}
+	}
+
+	if params.repair && !report.OK() {
+		if err := s.repair(ctx, report, live); err != nil {
+			return report, err
+		}
+		report.Repaired = true
+	}
+	return report, nil
+}
+
+func (s *Client) repair(ctx context.Context, report *AuditReport, live map[string]*ArticleWithBody) error {
+	loadBody := func(_ context.Context, article *citygraph.Article) (string, error) {
+		return live[article.ID].Body, nil
+	}
+	for _, article := range append(append([]*citygraph.Article{}, report.Missing...), report.Stale...) {
+		if _, err := s.indexArticle(ctx, article, loadBody); err != nil {
+			return fmt.Errorf("failed to reindex article %s: %v", article.Name, err)
+		}
+		reqid.Printf(ctx, "Audit reindexed article %s", article.Name)
+	}
+	if len(report.Orphaned) > 0 {
+		if err := s.deleteVectors(ctx, "", report.Orphaned); err != nil {
+			return err
+		}
+	}
+	s.resultCache.Clear()
+	return nil
+}
+
+// listVectorIDs returns the IDs in a namespace, up to maxListedVectors, and how many vectors it
+// holds. The Pinecone API has no listing, so this queries for every vector at once.
+func (s *Client) listVectorIDs(ctx context.Context, namespace string) ([]string, int, error) {
+	stats, err := withPineconeRetry(ctx, s.pineconeBreaker, func(ctx context.Context) (*pinecone.DescribeIndexStatsResponse, error) {
+		return s.pineconeIndexClient.DescribeIndexStats(ctx, pinecone.DescribeIndexStatsParams{})
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to describe index: %v", err)
+	}
+	count := 0
+	if ns := stats.Namespaces[s.namespace(namespace)]; ns != nil {
+		count = int(ns.VectorCount)
+	}
+	if count == 0 {
+		return nil, 0, nil
+	}
+
+	probe := make([]float32, stats.Dimensions)
+	for i := range probe {
+		probe[i] = 1
+	}
+	resp, err := withPineconeRetry(ctx, s.pineconeBreaker, func(ctx context.Context) (*pinecone.QueryResponse, error) {
+		return s.pineconeIndexClient.Query(ctx, pinecone.QueryParams{
+			Vector:    probe,
+			TopK:      int64(min(count, maxListedVectors)),
+			Namespace: s.namespace(namespace),
+		})
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list vectors: %v", err)
+	}
+	ids := make([]string, 0, len(resp.Matches))
+	for _, match := range resp.Matches {
+		ids = append(ids, match.ID)
+	}
+	return ids, count, nil
+}
+
+// contentHash identifies the text an article was embedded from.
+func contentHash(article *citygraph.Article, body string) string {
+	text, err := embeddingText(article, body)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:8])
+}
+
+// contentMatches reports whether a vector was embedded from the article's current text.
+// Vectors stored before content hashes were recorded are given the benefit of the doubt.
+func contentMatches(metadata map[string]interface{}, article *ArticleWithBody) bool {
+	stored, ok := metadata["content_hash"].(string)
+	return !ok || stored == contentHash(article.Article, article.Body)
+}
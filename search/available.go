@@ -0,0 +1,15 @@
+package search
+
+import "github.com/geomodulus/robots/internal/breaker"
+
+// ErrUnavailable is wrapped by errors from searches made while OpenAI or Pinecone is down. The
+// client fails fast until they recover, so callers can say search is off rather than time out.
+var ErrUnavailable = breaker.ErrOpen
+
+// UnavailableMessage is a user-facing explanation for ErrUnavailable.
+const UnavailableMessage = "Search is temporarily unavailable while one of its services is down. Please try again in a few minutes."
+
+// Available reports whether OpenAI and Pinecone are both reachable, as far as the client knows.
+func (s *Client) Available() bool {
+	return s.openAIBreaker.Available() && s.pineconeBreaker.Available()
+}
@@ -0,0 +1,135 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// BlockOptions controls how search results are rendered as Slack blocks.
+type BlockOptions struct {
+	// Query, when set, is echoed back in a header above the results.
+	Query string
+	// ShowScore adds a relevance bar next to each result.
+	ShowScore bool
+	// SnippetLength truncates snippets to this many characters. Zero hides snippets.
+	SnippetLength int
+	// OpenPRActionID is the action ID used for the "Open PR" button on results that have a PR.
+	OpenPRActionID string
+}
+
+// DefaultBlockOptions are suitable for most search commands.
+var DefaultBlockOptions = BlockOptions{
+	ShowScore:      true,
+	SnippetLength:  200,
+	OpenPRActionID: "search_open_pr",
+}
+
+// ResultsToBlocks renders search results as Slack Block Kit blocks.
+func ResultsToBlocks(results []*SearchResult, opts BlockOptions) []slack.Block {
+	blocks := []slack.Block{}
+
+	if opts.Query != "" {
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*Results for* _%s_", escapeMrkdwn(opts.Query)), false, false),
+			nil,
+			nil,
+		))
+	}
+
+	if len(results) == 0 {
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, ":shrug: No results found.", false, false),
+			nil,
+			nil,
+		))
+		return blocks
+	}
+
+	for i, result := range results {
+		if i > 0 {
+			blocks = append(blocks, slack.NewDividerBlock())
+		}
+		blocks = append(blocks, resultBlocks(result, opts)...)
+	}
+
+	return blocks
+}
+
+func resultBlocks(result *SearchResult, opts BlockOptions) []slack.Block {
+	name := result.Name
+	if name == "" {
+		name = result.Slug
+	}
+	title := "*" + escapeMrkdwn(name) + "*"
+	if result.Path != "" {
+		title = fmt.Sprintf("*<%s|%s>*", result.Path, escapeMrkdwn(name))
+	}
+	if opts.SnippetLength > 0 && result.Snippet != "" {
+		title += "\n" + escapeMrkdwn(truncate(result.Snippet, opts.SnippetLength))
+	}
+
+	var accessory *slack.Accessory
+	if result.PRURL != "" {
+		button := slack.NewButtonBlockElement(
+			opts.OpenPRActionID,
+			result.Slug,
+			slack.NewTextBlockObject(slack.PlainTextType, "Open PR", false, false),
+		)
+		button.URL = result.PRURL
+		accessory = slack.NewAccessory(button)
+	}
+
+	blocks := []slack.Block{
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, title, false, false),
+			nil,
+			accessory,
+		),
+	}
+
+	meta := []slack.MixedElement{}
+	if result.Draft {
+		meta = append(meta, slack.NewTextBlockObject(slack.MarkdownType, ":construction: Draft", false, false))
+	}
+	if result.PubDate != "" {
+		meta = append(meta, slack.NewTextBlockObject(slack.MarkdownType, ":calendar: "+result.PubDate, false, false))
+	}
+	if opts.ShowScore {
+		meta = append(meta, slack.NewTextBlockObject(slack.MarkdownType, scoreBar(result.Score), false, false))
+	}
+	if len(meta) > 0 {
+		blocks = append(blocks, slack.NewContextBlock("", meta...))
+	}
+
+	return blocks
+}
+
+// scoreBar renders a similarity score in [0, 1] as a small bar, eg. "▰▰▰▱▱ 0.62".
+func scoreBar(score float32) string {
+	const width = 5
+	filled := int(math.Round(float64(score) * width))
+	if filled < 0 {
+		filled = 0
+	}
+	if filled > width {
+		filled = width
+	}
+	return fmt.Sprintf("`%s%s` %.2f", strings.Repeat("▰", filled), strings.Repeat("▱", width-filled), score)
+}
+
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:n])) + "…"
+}
+
+// escapeMrkdwn escapes the characters Slack treats as control sequences in mrkdwn text.
+func escapeMrkdwn(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
@@ -0,0 +1,37 @@
+package search
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultEmbeddingCacheSize is how many query embeddings are kept by default.
+	DefaultEmbeddingCacheSize = 256
+	// DefaultEmbeddingCacheTTL is how long a query embedding is kept by default. Embeddings for
+	// the same text don't change, so this only bounds memory for stale queries.
+	DefaultEmbeddingCacheTTL = 24 * time.Hour
+)
+
+// WithEmbeddingCache sets how many query embeddings are cached, and for how long. A size of 0
+// turns the cache off.
+func WithEmbeddingCache(size int, ttl time.Duration) ClientOption {
+	return func(params *clientParams) {
+		params.embeddingCacheSize = size
+		params.embeddingCacheTTL = ttl
+	}
+}
+
+// WithResultCache also caches search results for ttl. Results won't reflect reindexing until
+// they expire, so keep it short.
+func WithResultCache(size int, ttl time.Duration) ClientOption {
+	return func(params *clientParams) {
+		params.resultCacheSize = size
+		params.resultCacheTTL = ttl
+	}
+}
+
+// normalizeQuery folds case and whitespace so trivially different queries share cache entries.
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
@@ -0,0 +1,150 @@
+package search
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/geomodulus/robots/audit"
+	"github.com/geomodulus/robots/internal/cache"
+	"github.com/geomodulus/robots/reqid"
+	"github.com/geomodulus/robots/state"
+)
+
+// AuditClick is the action recorded for each search result followed through ClickHandler.
+const AuditClick = "search.click"
+
+// clickIDLength is how many bytes of the HMAC make a link's ID, 72 bits, or 12 characters.
+const clickIDLength = 9
+
+// storedClicks bounds how many links the handler remembers having stored, so repeated searches
+// don't rewrite them.
+const (
+	storedClicksSize = 10000
+	storedClicksTTL  = 24 * time.Hour
+)
+
+// WithClickTracking has Handler return each result's URL as a short link under baseURL, eg.
+// "https://go.torontoverse.com/s/", that ClickHandler records and redirects. What each link
+// stands for is kept in store, under an ID derived from it with key, so the same search always
+// gets the same links and the links give nothing away about the search.
+func WithClickTracking(store state.Store, key []byte, baseURL string) HandlerOption {
+	return func(params *handlerParams) {
+		params.clickStore = store
+		params.clickKey = key
+		params.clickBaseURL = baseURL
+		params.storedClicks = cache.New[bool](storedClicksSize, storedClicksTTL)
+	}
+}
+
+// trackedClick is what a link stands for.
+type trackedClick struct {
+	Query    string `json:"q"`
+	ResultID string `json:"r"`
+	URL      string `json:"u"`
+	Position int    `json:"p"`
+}
+
+func clickKey(id string) string {
+	return "search-clicks/" + id
+}
+
+// trackedURL stores click and returns its link. Relative result paths are resolved against the
+// site, since the link is served from another host.
+func (p *handlerParams) trackedURL(ctx context.Context, click *trackedClick) (string, error) {
+	click.URL = absoluteURL(click.URL)
+	data, err := json.Marshal(click)
+	if err != nil {
+		return "", fmt.Errorf("error encoding search link: %v", err)
+	}
+	mac := hmac.New(sha256.New, p.clickKey)
+	mac.Write(data)
+	id := base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:clickIDLength])
+
+	if _, ok := p.storedClicks.Get(id); !ok {
+		if err := p.clickStore.Put(ctx, clickKey(id), click); err != nil {
+			return "", fmt.Errorf("error saving search link: %v", err)
+		}
+		p.storedClicks.Put(id, true)
+	}
+	return p.clickBaseURL + id, nil
+}
+
+// readClick returns the click a link's ID stands for.
+func readClick(ctx context.Context, store state.Store, id string) (*trackedClick, bool, error) {
+	click := &trackedClick{}
+	found, err := store.Get(ctx, clickKey(id), click)
+	if err != nil {
+		return nil, false, fmt.Errorf("error loading search link: %v", err)
+	}
+	if !found || (!strings.HasPrefix(click.URL, "https://") && !strings.HasPrefix(click.URL, "http://")) {
+		return nil, false, nil
+	}
+	return click, true, nil
+}
+
+// ClickHandler serves the short links made by WithClickTracking, recording each click with
+// WithAudit before redirecting to the result. Mount it where the links point, eg.
+//
+//	mux.Handle("/s/", http.StripPrefix("/s/", search.ClickHandler(store, search.WithAudit(log))))
+//
+// store must be the one given to WithClickTracking. Only WithAudit applies; clicks aren't rate
+// limited.
+func ClickHandler(store state.Store, opts ...HandlerOption) http.Handler {
+	params := handlerParams{}
+	for _, opt := range opts {
+		opt(&params)
+	}
+	return &clickHandler{store: store, params: params}
+}
+
+type clickHandler struct {
+	store  state.Store
+	params handlerParams
+}
+
+func (h *clickHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := reqid.New()
+	r = r.WithContext(reqid.With(r.Context(), id))
+	w.Header().Set("X-Request-ID", id)
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	click, ok, err := readClick(r.Context(), h.store, strings.Trim(r.URL.Path, "/"))
+	if err != nil {
+		reqid.Printf(r.Context(), "search clicks: %v", err)
+		http.Error(w, "error loading link", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Link unfurlers and prefetchers send HEAD, which isn't a click.
+	if h.params.audit != nil && r.Method == http.MethodGet {
+		if err := h.params.audit.Record(r.Context(), audit.Entry{
+			Action: AuditClick,
+			Fields: map[string]string{
+				"query":    click.Query,
+				"result":   click.ResultID,
+				"url":      click.URL,
+				"position": strconv.Itoa(click.Position),
+			},
+		}); err != nil {
+			reqid.Printf(r.Context(), "search clicks: %v", err)
+		}
+	}
+	// Clicks are only counted if the browser comes back through the link each time.
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, click.URL, http.StatusFound)
+}
@@ -0,0 +1,63 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// DocumentsNamespace holds vectors for documents attached to stories, like PDFs, kept apart
+// from articles.
+const DocumentsNamespace = "documents"
+
+// Document is a file attached to a story.
+type Document struct {
+	ID    string
+	Title string
+	// URL is where the document is served, eg. from the media bucket.
+	URL string
+	// ArticleID is the story the document belongs to, if any.
+	ArticleID string
+	Text      string
+}
+
+// IncludeDocuments adds matching documents to the results.
+func IncludeDocuments() QueryOption {
+	return func(params *queryParams) {
+		params.includeDocuments = true
+	}
+}
+
+// IndexDocument embeds a document into DocumentsNamespace, replacing any earlier version. It
+// returns the tokens billed.
+func (s *Client) IndexDocument(ctx context.Context, doc *Document) (int, error) {
+	if strings.TrimSpace(doc.Text) == "" {
+		return 0, fmt.Errorf("document %s has no text to index", doc.ID)
+	}
+	embeddings, tokens, err := s.embedText(doc.Title + "\n\n" + doc.Text)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get embeddings for document %s: %v", doc.ID, err)
+	}
+	metadata := map[string]interface{}{
+		"article_name": doc.Title,
+		"url":          doc.URL,
+		"snippet":      snippet(doc.Text),
+	}
+	if doc.ArticleID != "" {
+		metadata["article_id"] = doc.ArticleID
+	}
+	if err := s.storeEmbeddings(DocumentsNamespace, doc.ID, embeddings, metadata); err != nil {
+		return tokens, err
+	}
+	s.resultCache.Clear()
+	return tokens, nil
+}
+
+// RemoveDocument deletes a document's vector.
+func (s *Client) RemoveDocument(ctx context.Context, id string) error {
+	if err := s.deleteVectors(ctx, DocumentsNamespace, []string{id}); err != nil {
+		return fmt.Errorf("failed to delete document vector: %v", err)
+	}
+	s.resultCache.Clear()
+	return nil
+}
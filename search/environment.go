@@ -0,0 +1,45 @@
+package search
+
+import "fmt"
+
+// Environment is the deployment a Client reads and writes vectors for. Each has its own
+// namespaces in the shared index, so a staging reindex can't leak into production results.
+type Environment string
+
+const (
+	Production  Environment = "prod"
+	Staging     Environment = "staging"
+	Development Environment = "dev"
+)
+
+// WithEnvironment sets the environment every upsert, query, fetch and delete is made in.
+// Production uses the namespaces as named, so its vectors stay where they've always been; other
+// environments prefix them, eg. "staging" for articles and "staging-drafts" for drafts. There's
+// no default: NewClient fails without it, so a misconfigured deployment can't write to
+// production by accident.
+func WithEnvironment(env Environment) ClientOption {
+	return func(params *clientParams) {
+		params.environment = env
+	}
+}
+
+func (env Environment) valid() error {
+	switch env {
+	case "":
+		return fmt.Errorf("no search environment set; pass WithEnvironment")
+	case Production, Staging, Development:
+		return nil
+	}
+	return fmt.Errorf("unknown search environment %q", env)
+}
+
+// namespace is where the client keeps vectors for the named namespace, "" being articles.
+func (s *Client) namespace(name string) string {
+	if s.environment == Production {
+		return name
+	}
+	if name == "" {
+		return string(s.environment)
+	}
+	return string(s.environment) + "-" + name
+}
@@ -0,0 +1,123 @@
+package search
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// GoldenCase is a query and the slug of the article it should find.
+type GoldenCase struct {
+	Query string `json:"query"`
+	Slug  string `json:"slug"`
+}
+
+// ReadGoldenSet reads golden cases, one JSON object per line:
+//
+//	{"query": "where can I skate downtown", "slug": "outdoor-rinks-guide"}
+//
+// Blank lines and lines starting with # are skipped.
+func ReadGoldenSet(r io.Reader) ([]GoldenCase, error) {
+	cases := []GoldenCase{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var c GoldenCase
+		if err := json.Unmarshal([]byte(text), &c); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if c.Query == "" || c.Slug == "" {
+			return nil, fmt.Errorf("line %d: query and slug are required", line)
+		}
+		cases = append(cases, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading golden set: %v", err)
+	}
+	return cases, nil
+}
+
+// EvalResult is how one golden case fared.
+type EvalResult struct {
+	GoldenCase
+	// Rank is the 1-based position of the expected article in the results, or 0 if it wasn't in
+	// the top K.
+	Rank int
+	// Slugs are the slugs that came back, best first.
+	Slugs []string
+}
+
+// EvalReport summarizes a run over a golden set.
+type EvalReport struct {
+	K       int
+	Results []*EvalResult
+	// Recall is the fraction of cases whose article was in the top K.
+	Recall float64
+	// MRR is the mean reciprocal rank, counting misses as 0.
+	MRR float64
+}
+
+// Misses returns the cases whose article wasn't in the top K.
+func (r *EvalReport) Misses() []*EvalResult {
+	out := []*EvalResult{}
+	for _, result := range r.Results {
+		if result.Rank == 0 {
+			out = append(out, result)
+		}
+	}
+	return out
+}
+
+func (r *EvalReport) String() string {
+	return fmt.Sprintf("%d queries, recall@%d %.3f, MRR %.3f", len(r.Results), r.K, r.Recall, r.MRR)
+}
+
+// Evaluate runs every query in golden and scores where the expected article ranks, so changes to
+// embeddings or chunking can be compared against the same set. opts work as they do for RunQuery;
+// WithLimit sets K. Only published articles are searched, and results aren't served from the
+// cache, so each run measures the index as it is.
+func (s *Client) Evaluate(ctx context.Context, golden []GoldenCase, opts ...QueryOption) (*EvalReport, error) {
+	params := queryParams{limit: topK}
+	for _, opt := range opts {
+		opt(&params)
+	}
+	report := &EvalReport{K: int(params.limit), Results: []*EvalResult{}}
+	if len(golden) == 0 {
+		return report, nil
+	}
+
+	var hits int
+	var reciprocal float64
+	for _, c := range golden {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		results, err := s.runQueryUncached(c.Query, params)
+		if err != nil {
+			return nil, fmt.Errorf("error running %q: %v", c.Query, err)
+		}
+		result := &EvalResult{GoldenCase: c, Slugs: []string{}}
+		for i, r := range results {
+			result.Slugs = append(result.Slugs, r.Slug)
+			if result.Rank == 0 && r.Slug == c.Slug {
+				result.Rank = i + 1
+			}
+		}
+		if result.Rank > 0 {
+			hits++
+			reciprocal += 1 / float64(result.Rank)
+		}
+		report.Results = append(report.Results, result)
+	}
+	report.Recall = float64(hits) / float64(len(golden))
+	report.MRR = reciprocal / float64(len(golden))
+	return report, nil
+}
@@ -0,0 +1,63 @@
+package search
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nekomeowww/go-pinecone"
+)
+
+// exportBatchSize is how many vectors Export fetches at once. Fetches pass IDs in the URL, so
+// large batches run into length limits.
+const exportBatchSize = 100
+
+// ExportedNamespaces are the namespaces Export copies by default: everything but Slack
+// messages, which can be reindexed from Slack.
+var ExportedNamespaces = []string{"", DraftsNamespace, PlacesNamespace, DocumentsNamespace}
+
+// NamespaceExport is every vector in a namespace, with its embedding and metadata, so the
+// namespace can be restored without embedding everything again.
+type NamespaceExport struct {
+	// Namespace is the name the client knows it by, "" being articles, not its name in the
+	// index.
+	Namespace string    `json:"namespace"`
+	Vectors   []*Vector `json:"vectors"`
+	// Truncated is set when the namespace held more vectors than could be listed.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Export copies every vector in a namespace of the client's environment.
+func (s *Client) Export(ctx context.Context, namespace string) (*NamespaceExport, error) {
+	ids, count, err := s.listVectorIDs(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	export := &NamespaceExport{
+		Namespace: namespace,
+		Vectors:   []*Vector{},
+		Truncated: len(ids) < count,
+	}
+	for start := 0; start < len(ids); start += exportBatchSize {
+		batch := ids[start:min(start+exportBatchSize, len(ids))]
+		resp, err := withPineconeRetry(ctx, s.pineconeBreaker, func(ctx context.Context) (*pinecone.FetchVectorsResponse, error) {
+			return s.pineconeIndexClient.FetchVectors(ctx, pinecone.FetchVectorsParams{
+				IDs:       batch,
+				Namespace: s.namespace(namespace),
+			})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch vectors: %v", err)
+		}
+		for _, id := range batch {
+			// Vectors deleted since they were listed are skipped.
+			if vector, ok := resp.Vectors[id]; ok {
+				export.Vectors = append(export.Vectors, &Vector{
+					ID:       id,
+					Values:   vector.Values,
+					Metadata: vector.Metadata,
+				})
+			}
+		}
+	}
+	return export, nil
+}
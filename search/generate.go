@@ -33,9 +33,10 @@ type EmbeddingsRequest struct {
 }
 
 // Helper function to upsert embeddings into Pinecone
-func storeEmbeddings(pineconeClient *pinecone.IndexClient, id string, embeddings []float32, metadata map[string]interface{}) error {
+func (s *Client) storeEmbeddings(namespace, id string, embeddings []float32, metadata map[string]interface{}) error {
 	ctx := context.Background()
 	params := pinecone.UpsertVectorsParams{
+		Namespace: s.namespace(namespace),
 		Vectors: []*pinecone.Vector{
 			{
 				ID:       id,         // Article ID from graph
@@ -46,7 +47,9 @@ func storeEmbeddings(pineconeClient *pinecone.IndexClient, id string, embeddings
 	}
 	fmt.Printf("Upserting vector with ID: %s, Metadata: %v\n", id, metadata)
 
-	resp, err := pineconeClient.UpsertVectors(ctx, params)
+	resp, err := withPineconeRetry(ctx, s.pineconeBreaker, func(ctx context.Context) (*pinecone.UpsertVectorsResponse, error) {
+		return s.pineconeIndexClient.UpsertVectors(ctx, params)
+	})
 	if err != nil {
 		return fmt.Errorf("failed to upsert vectors: %v", err)
 	}
@@ -60,13 +63,16 @@ type FetchVectorsResponse struct {
 }
 
 // / Helper function to fetch embeddings from Pinecone
-func fetchEmbeddings(pineconeClient *pinecone.IndexClient, id string, article *citygraph.Article) ([]float32, map[string]interface{}, error) {
+func (s *Client) fetchEmbeddings(namespace, id string, article *citygraph.Article) ([]float32, map[string]interface{}, error) {
 	ctx := context.Background()
 	params := pinecone.FetchVectorsParams{
-		IDs: []string{id},
+		IDs:       []string{id},
+		Namespace: s.namespace(namespace),
 	}
 
-	resp, err := pineconeClient.FetchVectors(ctx, params)
+	resp, err := withPineconeRetry(ctx, s.pineconeBreaker, func(ctx context.Context) (*pinecone.FetchVectorsResponse, error) {
+		return s.pineconeIndexClient.FetchVectors(ctx, params)
+	})
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to fetch vector: %v", err)
 	}
@@ -117,6 +123,24 @@ func fetchEmbeddings(pineconeClient *pinecone.IndexClient, id string, article *c
 	return embeddings, vector.Metadata, nil
 }
 
+// Template for es
+var embeddingTmpl = template.Must(template.New("es").Parse(`headline: {{.Article.Name}} subhead:{{.Article.Description}} authors:{{.Article.Authors}} pub_date:{{.Article.PubDate}} body: {{.Body}}`))
+
+// embeddingText strips the HTML from body and renders the text that gets embedded for an article.
+func embeddingText(article *citygraph.Article, body string) (string, error) {
+	// Create instance of ArticleWithBody
+	awb := ArticleWithBody{
+		Article: article,
+		Body:    StripHTML(body),
+	}
+
+	var esBuilder strings.Builder
+	if err := embeddingTmpl.Execute(&esBuilder, awb); err != nil {
+		return "", err
+	}
+	return esBuilder.String(), nil
+}
+
 // Processes articles from Torontoverse Corpus and Indexes them in Pinecone
 // Generate is method on Client struct defined in search.go
 func (s *Client) Generate(articles []*citygraph.Article) error {
@@ -131,7 +155,7 @@ func (s *Client) Generate(articles []*citygraph.Article) error {
 		fmt.Printf("-- Processing article %d: %s\n", liveArticleCount, article.Name)
 
 		// Try to fetch existing embedding from Pinecone
-		existingEmbedding, metadata, err := fetchEmbeddings(s.pineconeIndexClient, article.ID, article)
+		existingEmbedding, metadata, err := s.fetchEmbeddings("", article.ID, article)
 		if err == nil && existingEmbedding != nil && metadata != nil {
 			// If there's no error and we get an embedding, it means that the embedding already exists
 
@@ -144,53 +168,29 @@ func (s *Client) Generate(articles []*citygraph.Article) error {
 				log.Printf("Failed to read body text for article %s: %v", article.Name, err)
 				continue
 			}
-			// Strip HTML tags from article body
-			body = StripHTML(body)
-
-			// Create instance of ArticleWithBody
-			awb := ArticleWithBody{
-				Article: article,
-				Body:    body,
-			}
 
-			// Tempalte for es
-			tmpl, err := template.New("es").Parse(`headline: {{.Article.Name}} subhead:{{.Article.Description}} authors:{{.Article.Authors}} pub_date:{{.Article.PubDate}} body: {{.Body}}`)
-			if err != nil {
-				return err
-			}
-
-			// Get path of article
-			path, err := article.Path()
+			// Metadata to include when upserting embeddings to Pinecone
+			metadata, err := articleMetadata(article, body)
 			if err != nil {
 				log.Printf("Failed to get path for article %s: %v", article.Name, err)
 				continue
 			}
 
 			// Print path
-			fmt.Println("Path for article:", path)
-
-			// Metadata to include when upserting embeddings to Pinecone
-			metadata := map[string]interface{}{
-				"article_name": article.Name,
-				"path":         path,
-				"pub_date":     article.PubDate,
-				"slug":         article.Slug,
-			}
+			fmt.Println("Path for article:", metadata["path"])
 
-			// Create the es variable using the template, tml
-			var esBuilder strings.Builder
-			err = tmpl.Execute(&esBuilder, awb)
+			// Create the es variable using the template
+			es, err := embeddingText(article, body)
 			if err != nil {
 				log.Printf("Failed to execute template for article %s: %v", article.Name, err)
 				continue
 			}
-			es := esBuilder.String()
 
 			// Print es variable
 			fmt.Println(es)
 
 			// Call OpenAI API to create embeddings for article content
-			embeddings, err := getEmbeddings(s.openAIClient, es)
+			embeddings, err := s.getEmbeddings(es)
 			if err != nil {
 				// Log the error and continue with the next article
 				log.Printf("Failed to get embeddings for article %s: %v", article.Name, err)
@@ -198,7 +198,7 @@ func (s *Client) Generate(articles []*citygraph.Article) error {
 			}
 
 			// Store embeddings in Pinecone
-			err = storeEmbeddings(s.pineconeIndexClient, article.ID, embeddings, metadata)
+			err = s.storeEmbeddings("", article.ID, embeddings, metadata)
 			if err != nil {
 				// Log the error and continue with the next article
 				log.Printf("Failed to store embeddings for article %s in Pinecone: %v", article.Name, err)
@@ -209,5 +209,45 @@ func (s *Client) Generate(articles []*citygraph.Article) error {
 		}
 	}
 
+	s.resultCache.Clear()
+	return nil
+}
+
+// IndexDraft embeds an unpublished article into the drafts namespace so editors can find
+// work-in-progress with RunQuery(query, IncludeDrafts()). bodyHTML is the draft's article.html
+// and prURL, if set, links results back to the draft's pull request.
+func (s *Client) IndexDraft(article *citygraph.Article, bodyHTML, prURL string) error {
+	metadata, err := articleMetadata(article, bodyHTML)
+	if err != nil {
+		return fmt.Errorf("failed to get path for draft %s: %v", article.Name, err)
+	}
+
+	es, err := embeddingText(article, bodyHTML)
+	if err != nil {
+		return fmt.Errorf("failed to execute template for draft %s: %v", article.Name, err)
+	}
+
+	embeddings, err := s.getEmbeddings(es)
+	if err != nil {
+		return fmt.Errorf("failed to get embeddings for draft %s: %v", article.Name, err)
+	}
+
+	if prURL != "" {
+		metadata["pr_url"] = prURL
+	}
+
+	if err := s.storeEmbeddings(DraftsNamespace, article.ID, embeddings, metadata); err != nil {
+		return err
+	}
+	s.resultCache.Clear()
+	return nil
+}
+
+// RemoveDraft deletes a draft's vector, eg. once the article has been published.
+func (s *Client) RemoveDraft(id string) error {
+	if err := s.deleteVectors(context.Background(), DraftsNamespace, []string{id}); err != nil {
+		return fmt.Errorf("failed to delete draft vector: %v", err)
+	}
+	s.resultCache.Clear()
 	return nil
 }
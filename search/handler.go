@@ -0,0 +1,344 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/geomodulus/robots/audit"
+	"github.com/geomodulus/robots/internal/cache"
+	"github.com/geomodulus/robots/pubdate"
+	"github.com/geomodulus/robots/reqid"
+	"github.com/geomodulus/robots/state"
+)
+
+const (
+	defaultPerPage = 10
+	maxPerPage     = 25
+	// maxResults bounds page × per_page, since every page re-runs the query for all results
+	// up to it.
+	maxResults = 100
+	// maxQueryLength keeps pasted essays from being embedded on our dime.
+	maxQueryLength = 256
+)
+
+type handlerParams struct {
+	allowedOrigins    []string
+	requestsPerMinute int
+	trustedProxies    int
+	audit             audit.Log
+	clickStore        state.Store
+	clickKey          []byte
+	clickBaseURL      string
+	storedClicks      *cache.TTL[bool]
+}
+
+// HandlerOption configures Handler.
+type HandlerOption func(*handlerParams)
+
+// WithAllowedOrigins sets the origins allowed to call the API from browsers. "*" allows any.
+// By default only https://www.torontoverse.com is allowed.
+func WithAllowedOrigins(origins ...string) HandlerOption {
+	return func(params *handlerParams) {
+		params.allowedOrigins = origins
+	}
+}
+
+// WithRateLimit sets how many searches each client IP may make per minute. Zero turns rate
+// limiting off. The default is 30.
+func WithRateLimit(requestsPerMinute int) HandlerOption {
+	return func(params *handlerParams) {
+		params.requestsPerMinute = requestsPerMinute
+	}
+}
+
+// TrustForwardedFor rate limits by the X-Forwarded-For address the first of proxies trusted
+// proxies saw, rather than the connection's, for use behind load balancers. Each proxy appends
+// the address it was called from, so that's the proxies'th address from the right; addresses
+// further left are whatever the client sent and can't be trusted.
+func TrustForwardedFor(proxies int) HandlerOption {
+	return func(params *handlerParams) {
+		params.trustedProxies = proxies
+	}
+}
+
+// AuditQuery is the action recorded for each search made through Handler.
+const AuditQuery = "search.query"
+
+// WithAudit records every search to log, eg. for the daily digest's top queries.
+func WithAudit(auditLog audit.Log) HandlerOption {
+	return func(params *handlerParams) {
+		params.audit = auditLog
+	}
+}
+
+// APIResult is a search result as returned by Handler.
+type APIResult struct {
+	ID      string  `json:"id"`
+	Title   string  `json:"title"`
+	URL     string  `json:"url"`
+	Slug    string  `json:"slug,omitempty"`
+	PubDate string  `json:"pub_date,omitempty"`
+	Snippet string  `json:"snippet,omitempty"`
+	Score   float32 `json:"score"`
+}
+
+// APIResponse is the body of a successful Handler response.
+type APIResponse struct {
+	Query   string       `json:"query"`
+	Page    int          `json:"page"`
+	PerPage int          `json:"per_page"`
+	HasMore bool         `json:"has_more"`
+	Results []*APIResult `json:"results"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// publishedRanges are the values of the published parameter.
+var publishedRanges = map[string]func(time.Time) pubdate.Range{
+	"today": pubdate.Today,
+	"week":  pubdate.ThisWeek,
+	"month": pubdate.ThisMonth,
+}
+
+// Handler serves a JSON search API over published articles, for the site's search box:
+//
+//	GET /?q=bike+lanes&category=transit&author=Jane+Doe&page=1&per_page=10
+//
+// q is required; category and author filter results, as does published, one of "today",
+// "week" or "month" in Toronto time; page and per_page paginate. Drafts are never included.
+func Handler(client *Client, opts ...HandlerOption) http.Handler {
+	params := handlerParams{
+		allowedOrigins:    []string{"https://www.torontoverse.com"},
+		requestsPerMinute: 30,
+	}
+	for _, opt := range opts {
+		opt(&params)
+	}
+
+	h := &handler{client: client, params: params}
+	if params.requestsPerMinute > 0 {
+		h.limiter = newRateLimiter(params.requestsPerMinute, time.Minute)
+	}
+	return h
+}
+
+type handler struct {
+	client  *Client
+	params  handlerParams
+	limiter *rateLimiter
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := reqid.New()
+	r = r.WithContext(reqid.With(r.Context(), id))
+	w.Header().Set("X-Request-ID", id)
+	h.setCORSHeaders(w, r)
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{"only GET is supported"})
+		return
+	}
+	if h.limiter != nil && !h.limiter.allow(h.clientIP(r)) {
+		w.Header().Set("Retry-After", "60")
+		writeJSON(w, http.StatusTooManyRequests, apiError{"too many searches, try again in a minute"})
+		return
+	}
+
+	values := r.URL.Query()
+	query := strings.TrimSpace(values.Get("q"))
+	if query == "" {
+		writeJSON(w, http.StatusBadRequest, apiError{"q is required"})
+		return
+	}
+	if len(query) > maxQueryLength {
+		writeJSON(w, http.StatusBadRequest, apiError{"q is too long"})
+		return
+	}
+	page, err := intParam(values.Get("page"), 1)
+	if err != nil || page < 1 {
+		writeJSON(w, http.StatusBadRequest, apiError{"page must be a positive number"})
+		return
+	}
+	perPage, err := intParam(values.Get("per_page"), defaultPerPage)
+	if err != nil || perPage < 1 || perPage > maxPerPage {
+		writeJSON(w, http.StatusBadRequest, apiError{"per_page must be between 1 and " + strconv.Itoa(maxPerPage)})
+		return
+	}
+	if page*perPage > maxResults {
+		writeJSON(w, http.StatusBadRequest, apiError{"no more results are available"})
+		return
+	}
+
+	// Ask for one extra result to know whether there's another page.
+	queryOpts := []QueryOption{WithLimit(page*perPage + 1)}
+	if category := values.Get("category"); category != "" {
+		queryOpts = append(queryOpts, InCategory(category))
+	}
+	if author := values.Get("author"); author != "" {
+		queryOpts = append(queryOpts, ByAuthor(author))
+	}
+	if published := values.Get("published"); published != "" {
+		r, ok := publishedRanges[published]
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, apiError{"published must be today, week or month"})
+			return
+		}
+		queryOpts = append(queryOpts, PublishedIn(r(time.Now())))
+	}
+	results, err := h.client.RunQuery(query, queryOpts...)
+	if errors.Is(err, ErrUnavailable) {
+		w.Header().Set("Retry-After", "60")
+		writeJSON(w, http.StatusServiceUnavailable, apiError{"search is temporarily disabled"})
+		return
+	}
+	if err != nil {
+		reqid.Printf(r.Context(), "search API: %v", err)
+		writeJSON(w, http.StatusBadGateway, apiError{"search is unavailable right now"})
+		return
+	}
+
+	if h.params.audit != nil && page == 1 {
+		if err := h.params.audit.Record(r.Context(), audit.Entry{
+			Action: AuditQuery,
+			Fields: auditQueryFields(normalizeQuery(query), len(results), h.client.ExpandQuery(query).Terms),
+		}); err != nil {
+			reqid.Printf(r.Context(), "search API: %v", err)
+		}
+	}
+
+	resp := &APIResponse{Query: query, Page: page, PerPage: perPage, Results: []*APIResult{}}
+	start := (page - 1) * perPage
+	for i := start; i < len(results) && i < start+perPage; i++ {
+		result := results[i]
+		url := result.Path
+		if h.params.clickStore != nil {
+			tracked, err := h.params.trackedURL(r.Context(), &trackedClick{
+				Query:    normalizeQuery(query),
+				ResultID: result.ID,
+				URL:      result.Path,
+				Position: i + 1,
+			})
+			if err != nil {
+				// An untracked link beats a missing result.
+				reqid.Printf(r.Context(), "search API: %v", err)
+			} else {
+				url = tracked
+			}
+		}
+		resp.Results = append(resp.Results, &APIResult{
+			ID:      result.ID,
+			Title:   result.Name,
+			URL:     url,
+			Slug:    result.Slug,
+			PubDate: result.PubDate,
+			Snippet: result.Snippet,
+			Score:   result.Score,
+		})
+	}
+	resp.HasMore = len(results) > start+perPage && start+perPage < maxResults
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *handler) setCORSHeaders(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+	for _, allowed := range h.params.allowedOrigins {
+		if allowed == "*" || allowed == origin {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+			w.Header().Set("Access-Control-Max-Age", "86400")
+			w.Header().Add("Vary", "Origin")
+			return
+		}
+	}
+}
+
+func (h *handler) clientIP(r *http.Request) string {
+	if h.params.trustedProxies > 0 {
+		// Proxies may each add their own header rather than append to one.
+		addrs := []string{}
+		for _, forwarded := range r.Header.Values("X-Forwarded-For") {
+			addrs = append(addrs, strings.Split(forwarded, ",")...)
+		}
+		if len(addrs) > 0 {
+			i := len(addrs) - h.params.trustedProxies
+			if i < 0 {
+				i = 0
+			}
+			return strings.TrimSpace(addrs[i])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// auditQueryFields records the synonyms a query was expanded with, if any, so the same terms
+// are at hand for keyword matching and for judging the dictionary.
+func auditQueryFields(query string, results int, expanded []string) map[string]string {
+	fields := map[string]string{"query": query, "results": strconv.Itoa(results)}
+	if len(expanded) > 0 {
+		fields["expanded"] = strings.Join(expanded, ", ")
+	}
+	return fields
+}
+
+func intParam(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("search API: error writing response: %v", err)
+	}
+}
+
+// rateLimiter is a fixed-window limiter keyed by client.
+type rateLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	start  time.Time
+	counts map[string]int
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{limit: limit, window: window, start: time.Now(), counts: map[string]int{}}
+}
+
+func (l *rateLimiter) allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Starting a fresh window also forgets clients that have gone quiet.
+	if time.Since(l.start) >= l.window {
+		l.start = time.Now()
+		l.counts = map[string]int{}
+	}
+	if l.counts[key] >= l.limit {
+		return false
+	}
+	l.counts[key]++
+	return true
+}
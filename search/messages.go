@@ -0,0 +1,129 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MessagesNamespace holds vectors for Slack messages from channels opted in to the archive, kept
+// apart from articles so they never show up in public search.
+const MessagesNamespace = "slack-messages"
+
+// Message is a Slack message to archive.
+type Message struct {
+	ChannelID string
+	UserID    string
+	TS        string
+	// ThreadTS is the thread's root, if the message is a reply.
+	ThreadTS  string
+	Text      string
+	Permalink string
+}
+
+// ID is the message's vector ID.
+func (m *Message) ID() string {
+	return m.ChannelID + "-" + m.TS
+}
+
+// RecalledMessage is a message found by Recall.
+type RecalledMessage struct {
+	Message
+	Score float32
+	// Snippet is the start of the message's text.
+	Snippet string
+}
+
+// Time is when the message was posted.
+func (m *Message) Time() time.Time {
+	seconds, _ := strconv.ParseFloat(m.TS, 64)
+	return time.Unix(int64(seconds), 0)
+}
+
+// InChannel limits Recall to one channel.
+func InChannel(channelID string) QueryOption {
+	return withFilter("channel_id", channelID)
+}
+
+// InChannels limits Recall to messages from any of channelIDs, eg. the channels whoever's asking
+// is in. No channels matches nothing.
+func InChannels(channelIDs ...string) QueryOption {
+	return func(params *queryParams) {
+		if params.filter == nil {
+			params.filter = map[string]any{}
+		}
+		params.filter["channel_id"] = map[string]any{"$in": channelIDs}
+	}
+}
+
+// IndexMessage embeds a Slack message into MessagesNamespace, replacing any earlier version, eg.
+// after an edit. It returns the tokens billed.
+func (s *Client) IndexMessage(ctx context.Context, msg *Message) (int, error) {
+	if strings.TrimSpace(msg.Text) == "" {
+		return 0, fmt.Errorf("message %s has no text to index", msg.ID())
+	}
+	embeddings, tokens, err := s.embedText(msg.Text)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get embeddings for message %s: %v", msg.ID(), err)
+	}
+	metadata := map[string]interface{}{
+		"channel_id": msg.ChannelID,
+		"user_id":    msg.UserID,
+		"ts":         msg.TS,
+		"permalink":  msg.Permalink,
+		"snippet":    snippet(msg.Text),
+	}
+	if msg.ThreadTS != "" {
+		metadata["thread_ts"] = msg.ThreadTS
+	}
+	if err := s.storeEmbeddings(MessagesNamespace, msg.ID(), embeddings, metadata); err != nil {
+		return tokens, err
+	}
+	return tokens, nil
+}
+
+// RemoveMessage deletes a message's vector, eg. once it's been deleted in Slack.
+func (s *Client) RemoveMessage(ctx context.Context, channelID, ts string) error {
+	msg := &Message{ChannelID: channelID, TS: ts}
+	if err := s.deleteVectors(ctx, MessagesNamespace, []string{msg.ID()}); err != nil {
+		return fmt.Errorf("failed to delete message vector: %v", err)
+	}
+	return nil
+}
+
+// Recall finds archived Slack messages about query, best match first. It takes WithLimit,
+// InChannel and InChannels.
+func (s *Client) Recall(query string, opts ...QueryOption) ([]*RecalledMessage, error) {
+	params := queryParams{limit: topK}
+	for _, opt := range opts {
+		opt(&params)
+	}
+
+	embeddings, err := s.queryEmbeddings(query)
+	if err != nil {
+		return nil, err
+	}
+	results, err := s.searchPinecone(MessagesNamespace, embeddings, params.limit, params.filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search Pinecone %s: %w", MessagesNamespace, err)
+	}
+
+	out := []*RecalledMessage{}
+	for _, match := range results.Matches {
+		msg := &RecalledMessage{Score: match.Score}
+		msg.ChannelID, _ = match.Metadata["channel_id"].(string)
+		msg.UserID, _ = match.Metadata["user_id"].(string)
+		msg.TS, _ = match.Metadata["ts"].(string)
+		msg.ThreadTS, _ = match.Metadata["thread_ts"].(string)
+		msg.Permalink, _ = match.Metadata["permalink"].(string)
+		msg.Snippet, _ = match.Metadata["snippet"].(string)
+		out = append(out, msg)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Score > out[j].Score
+	})
+	return out, nil
+}
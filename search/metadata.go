@@ -0,0 +1,127 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/geomodulus/citygraph"
+	"github.com/nekomeowww/go-pinecone"
+
+	"github.com/geomodulus/robots/pubdate"
+)
+
+// MetadataVersion is the schema version of the metadata stored with article vectors. Bump it
+// when adding fields to articleMetadata; Sync and Backfill then upgrade older vectors in place,
+// without re-embedding.
+//
+//	1: article_name, path, pub_date, slug
+//	2: adds schema_version, snippet, authors and categories
+//	3: adds content_hash, so Audit can tell when a body changed after it was embedded. Upgrading
+//	   to it hashes the current body, trusting that the embedding is up to date.
+//	4: adds pub_day, the pub date as a number like 20230810, so PublishedIn can filter on it.
+const MetadataVersion = 4
+
+// snippetLength is how much of the body is stored as the result snippet.
+const snippetLength = 300
+
+// identityKeys are the metadata fields that, if they change, mean the article needs embedding
+// again. Others can be upgraded in place.
+var identityKeys = []string{"article_name", "path", "pub_date", "slug"}
+
+// articleMetadata is the metadata stored with an article's vector.
+func articleMetadata(article *citygraph.Article, body string) (map[string]interface{}, error) {
+	path, err := article.Path()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get path: %v", err)
+	}
+	metadata := map[string]interface{}{
+		"schema_version": MetadataVersion,
+		"article_name":   article.Name,
+		"path":           path,
+		"pub_date":       article.PubDate,
+		"slug":           article.Slug,
+		"snippet":        snippet(body),
+		"content_hash":   contentHash(article, body),
+	}
+	// Pinecone only compares numbers.
+	if t, err := pubdate.Parse(article.PubDate); err == nil {
+		metadata["pub_day"] = pubdate.DayNumber(t)
+	}
+	// Pinecone rejects empty lists.
+	if len(article.Authors) > 0 {
+		metadata["authors"] = article.Authors
+	}
+	if len(article.Categories) > 0 {
+		metadata["categories"] = article.Categories
+	}
+	return metadata, nil
+}
+
+// metadataVersion reads the schema version of stored metadata. Vectors from before versioning
+// are version 1.
+func metadataVersion(metadata map[string]interface{}) int {
+	// Numbers come back from Pinecone as float64.
+	if version, ok := metadata["schema_version"].(float64); ok {
+		return int(version)
+	}
+	return 1
+}
+
+// metadataMatches reports whether a stored vector still describes article, regardless of
+// schema version.
+func metadataMatches(metadata map[string]interface{}, article *citygraph.Article) bool {
+	want, err := articleMetadata(article, "")
+	if err != nil {
+		return false
+	}
+	for _, key := range identityKeys {
+		if got, _ := metadata[key].(string); got != want[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// snippet is the start of body as plain text, cut at a word boundary.
+func snippet(body string) string {
+	text := []rune(strings.Join(strings.Fields(StripHTML(body)), " "))
+	if len(text) <= snippetLength {
+		return string(text)
+	}
+	cut := string(text[:snippetLength])
+	if space := strings.LastIndex(cut, " "); space > 0 {
+		cut = cut[:space]
+	}
+	return cut + "…"
+}
+
+// upgradeMetadata rewrites a vector's metadata at the current version, keeping its embedding.
+func (s *Client) upgradeMetadata(ctx context.Context, article *citygraph.Article, loadBody BodyLoader) error {
+	body, err := loadBody(ctx, article)
+	if err != nil {
+		return fmt.Errorf("failed to load body: %v", err)
+	}
+	metadata, err := articleMetadata(article, body)
+	if err != nil {
+		return err
+	}
+	_, err = withPineconeRetry(ctx, s.pineconeBreaker, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, s.pineconeIndexClient.UpdateVector(ctx, pinecone.UpdateVectorParams{
+			ID:          article.ID,
+			SetMetadata: metadata,
+			Namespace:   s.namespace(""),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update metadata: %v", err)
+	}
+	return nil
+}
+
+// Backfill upgrades the metadata of every indexed live article stored at an older schema
+// version. Unlike Sync it never embeds, so it's cheap to run in bulk after bumping
+// MetadataVersion.
+func (s *Client) Backfill(ctx context.Context, articles []*citygraph.Article, loadBody BodyLoader) (*SyncStats, error) {
+	return s.sync(ctx, articles, loadBody, nil, false)
+}
@@ -0,0 +1,178 @@
+package search
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/nekomeowww/go-pinecone"
+
+	"github.com/geomodulus/robots/reqid"
+)
+
+// PlacesNamespace holds vectors for places, kept apart from articles so place lookups don't
+// crowd out stories and vice versa.
+const PlacesNamespace = "places"
+
+// Place is what's indexed about a place.
+type Place struct {
+	ID      string
+	Slug    string
+	Name    string
+	Type    string
+	Status  string
+	Address string
+	// Neighbourhood is optional.
+	Neighbourhood string
+	Lng, Lat      float64
+	// Text is the place's description, eg. its body HTML.
+	Text string
+}
+
+// PlaceResult is a place matching a query.
+type PlaceResult struct {
+	ID            string
+	Slug          string
+	Name          string
+	Type          string
+	Status        string
+	Address       string
+	Neighbourhood string
+	Lng, Lat      float64
+	Score         float32
+}
+
+func (p *Place) metadata() map[string]interface{} {
+	metadata := map[string]interface{}{
+		"name":         p.Name,
+		"slug":         p.Slug,
+		"lng":          p.Lng,
+		"lat":          p.Lat,
+		"content_hash": p.hash(),
+	}
+	// Empty fields aren't stored.
+	for key, value := range map[string]string{
+		"type":          p.Type,
+		"status":        p.Status,
+		"address":       p.Address,
+		"neighbourhood": p.Neighbourhood,
+	} {
+		if value != "" {
+			metadata[key] = value
+		}
+	}
+	return metadata
+}
+
+// hash changes whenever anything embedded or stored about the place does.
+func (p *Place) hash() string {
+	h := sha256.New()
+	for _, field := range []string{p.Slug, p.Name, p.Type, p.Status, p.Address, p.Neighbourhood,
+		fmt.Sprintf("%.6f,%.6f", p.Lng, p.Lat), p.Text} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (p *Place) embeddingText() string {
+	lines := []string{p.Name}
+	for _, line := range []string{p.Type, p.Address, p.Neighbourhood, strings.Join(strings.Fields(StripHTML(p.Text)), " ")} {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SyncPlaces brings PlacesNamespace up to date: places with no vector, or whose details have
+// changed, are embedded, and the remove IDs are deleted. Failures for single places are logged
+// and counted rather than stopping the sync.
+func (s *Client) SyncPlaces(ctx context.Context, places []*Place, remove []string) (*SyncStats, error) {
+	stats := &SyncStats{}
+	for start := 0; start < len(places); start += fetchBatchSize {
+		batch := places[start:min(start+fetchBatchSize, len(places))]
+		ids := make([]string, len(batch))
+		for i, place := range batch {
+			ids[i] = place.ID
+		}
+		existing, err := s.fetchVectorsIn(ctx, PlacesNamespace, ids)
+		if err != nil {
+			return stats, err
+		}
+
+		for _, place := range batch {
+			vector, found := existing[place.ID]
+			if found {
+				if hash, _ := vector.Metadata["content_hash"].(string); hash == place.hash() {
+					stats.Unchanged++
+					continue
+				}
+			}
+			embeddings, tokens, err := s.embedText(place.embeddingText())
+			stats.Tokens += tokens
+			if err == nil {
+				err = s.storeEmbeddings(PlacesNamespace, place.ID, embeddings, place.metadata())
+			}
+			switch {
+			case err != nil:
+				reqid.Printf(ctx, "Failed to index place %s: %v", place.Name, err)
+				stats.Failed++
+			case found:
+				stats.Updated++
+			default:
+				stats.Indexed++
+			}
+		}
+	}
+
+	indexed := []string{}
+	for start := 0; start < len(remove); start += fetchBatchSize {
+		existing, err := s.fetchVectorsIn(ctx, PlacesNamespace, remove[start:min(start+fetchBatchSize, len(remove))])
+		if err != nil {
+			return stats, err
+		}
+		for id := range existing {
+			indexed = append(indexed, id)
+		}
+	}
+	if len(indexed) > 0 {
+		if err := s.deleteVectors(ctx, PlacesNamespace, indexed); err != nil {
+			return stats, err
+		}
+		stats.Deleted = len(indexed)
+	}
+	return stats, nil
+}
+
+// SearchPlaces returns up to limit places matching query, best first.
+func (s *Client) SearchPlaces(query string, limit int) ([]*PlaceResult, error) {
+	embeddings, err := s.queryEmbeddings(query)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.searchPinecone(PlacesNamespace, embeddings, int64(limit), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search places: %w", err)
+	}
+	return placeResults(resp.Matches), nil
+}
+
+func placeResults(matches []*pinecone.QueryVector) []*PlaceResult {
+	out := []*PlaceResult{}
+	for _, match := range matches {
+		result := &PlaceResult{ID: match.ID, Score: match.Score}
+		result.Name, _ = match.Metadata["name"].(string)
+		result.Slug, _ = match.Metadata["slug"].(string)
+		result.Type, _ = match.Metadata["type"].(string)
+		result.Status, _ = match.Metadata["status"].(string)
+		result.Address, _ = match.Metadata["address"].(string)
+		result.Neighbourhood, _ = match.Metadata["neighbourhood"].(string)
+		result.Lng, _ = match.Metadata["lng"].(float64)
+		result.Lat, _ = match.Metadata["lat"].(float64)
+		out = append(out, result)
+	}
+	return out
+}
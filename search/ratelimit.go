@@ -0,0 +1,53 @@
+package search
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit is OpenAI's rate limit as of the last response.
+type RateLimit struct {
+	RemainingRequests int
+	RemainingTokens   int
+	// ResetRequests and ResetTokens are how long until the limits reset, eg. "6m0s".
+	ResetRequests string
+	ResetTokens   string
+	// Updated is when the response was received. It's zero if no response has been seen.
+	Updated time.Time
+}
+
+// rateLimitTransport records OpenAI's x-ratelimit-* headers from every response.
+type rateLimitTransport struct {
+	base http.RoundTripper
+
+	mu    sync.Mutex
+	limit RateLimit
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.Header.Get("x-ratelimit-remaining-requests") == "" {
+		return resp, err
+	}
+	requests, _ := strconv.Atoi(resp.Header.Get("x-ratelimit-remaining-requests"))
+	tokens, _ := strconv.Atoi(resp.Header.Get("x-ratelimit-remaining-tokens"))
+	t.mu.Lock()
+	t.limit = RateLimit{
+		RemainingRequests: requests,
+		RemainingTokens:   tokens,
+		ResetRequests:     resp.Header.Get("x-ratelimit-reset-requests"),
+		ResetTokens:       resp.Header.Get("x-ratelimit-reset-tokens"),
+		Updated:           time.Now(),
+	}
+	t.mu.Unlock()
+	return resp, nil
+}
+
+// OpenAIRateLimit returns OpenAI's rate limit as of the client's last request.
+func (c *Client) OpenAIRateLimit() RateLimit {
+	c.rateLimit.mu.Lock()
+	defer c.rateLimit.mu.Unlock()
+	return c.rateLimit.limit
+}
@@ -0,0 +1,37 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+
+	"github.com/nekomeowww/go-pinecone"
+
+	"github.com/geomodulus/robots/internal/breaker"
+	"github.com/geomodulus/robots/internal/retry"
+)
+
+// pineconeStatusRe pulls the status code out of pinecone.ErrRequestFailed errors, which is the
+// only place the client exposes it.
+var pineconeStatusRe = regexp.MustCompile(`status code: (\d+)$`)
+
+// pineconePolicy retries Pinecone calls on network errors and 429/5xx responses.
+var pineconePolicy = retry.Default.WithRetryable(func(err error) bool {
+	if errors.Is(err, pinecone.ErrRequestFailed) {
+		m := pineconeStatusRe.FindStringSubmatch(err.Error())
+		if m == nil {
+			return false
+		}
+		code, _ := strconv.Atoi(m[1])
+		return retry.RetryableStatus(code)
+	}
+	return retry.IsTransient(err)
+})
+
+// withPineconeRetry runs a Pinecone call under pineconePolicy, behind the client's breaker.
+func withPineconeRetry[T any](ctx context.Context, b *breaker.Breaker, fn func(ctx context.Context) (T, error)) (T, error) {
+	return breaker.DoValue(ctx, b, func(ctx context.Context) (T, error) {
+		return retry.DoValue(ctx, pineconePolicy, fn)
+	})
+}
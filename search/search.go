@@ -3,10 +3,19 @@ package search
 import (
 	"context"
 	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
 
 	"github.com/nekomeowww/go-pinecone"
-	"github.com/pkoukk/tiktoken-go"
 	"github.com/sashabaranov/go-openai"
+
+	"github.com/geomodulus/robots/internal/breaker"
+	"github.com/geomodulus/robots/internal/cache"
+	"github.com/geomodulus/robots/internal/llm"
+	"github.com/geomodulus/robots/internal/retry"
+	"github.com/geomodulus/robots/pubdate"
 )
 
 // Constants
@@ -15,8 +24,24 @@ const (
 	pineconeProjectName   = "8432451" // index created with default project name in pinecone
 	pineconeIndexName     = "search"  // index created with default project name in pinecone
 	topK                  = int64(3)  // set default topK value
+
+	// DraftsNamespace holds vectors for unpublished drafts, kept apart from the reader-facing
+	// default namespace.
+	DraftsNamespace = "drafts"
 )
 
+// siteURL is where article paths are served.
+const siteURL = "https://www.torontoverse.com"
+
+// absoluteURL resolves a path on the site, eg. "/articles/<id>/<title>", to its URL. URLs are
+// returned as they are.
+func absoluteURL(path string) string {
+	if strings.HasPrefix(path, "/") {
+		return siteURL + path
+	}
+	return path
+}
+
 type Vector struct {
 	ID       string
 	Values   []float32              `json:"values"`
@@ -36,13 +61,37 @@ type UpsertVectorsResponse struct {
 type Client struct {
 	openAIClient        *openai.Client
 	pineconeIndexClient *pinecone.IndexClient
+	stopKeepAlive       context.CancelFunc
+	embeddingCache      *cache.TTL[[]float32]
+	resultCache         *cache.TTL[[]SearchResult]
+	rateLimit           *rateLimitTransport
+	openAIBreaker       *breaker.Breaker
+	pineconeBreaker     *breaker.Breaker
+	environment         Environment
+	synonyms            *expander
 }
 
 // Create Client instance
-func NewClient(openAIKey string, pineconeAPIKey string) (*Client, error) {
+func NewClient(openAIKey string, pineconeAPIKey string, opts ...ClientOption) (*Client, error) {
+	params := clientParams{
+		warmUp:             true,
+		embeddingCacheSize: DefaultEmbeddingCacheSize,
+		embeddingCacheTTL:  DefaultEmbeddingCacheTTL,
+	}
+	for _, opt := range opts {
+		opt(&params)
+	}
+	if err := params.environment.valid(); err != nil {
+		return nil, err
+	}
 
 	// Create OpenAI client
-	openAIClient := openai.NewClient(openAIKey)
+	// OpenAI calls are retried on network errors, rate limits and 5xx responses, over a pooled
+	// transport that keeps connections to the API open between queries.
+	openAIConfig := openai.DefaultConfig(openAIKey)
+	rateLimit := &rateLimitTransport{base: pooledTransport()}
+	openAIConfig.HTTPClient = &http.Client{Transport: retry.NewTransport(rateLimit)}
+	openAIClient := openai.NewClientWithConfig(openAIConfig)
 
 	if openAIClient == nil {
 		return nil, fmt.Errorf("failed to create OpenAI client")
@@ -59,21 +108,57 @@ func NewClient(openAIKey string, pineconeAPIKey string) (*Client, error) {
 		return nil, fmt.Errorf("failed to create Pinecone client: %v", err)
 	}
 
-	return &Client{
+	client := &Client{
 		openAIClient:        openAIClient,
 		pineconeIndexClient: pineconeIndexClient,
-	}, nil
+		embeddingCache:      cache.New[[]float32](params.embeddingCacheSize, params.embeddingCacheTTL),
+		resultCache:         cache.New[[]SearchResult](params.resultCacheSize, params.resultCacheTTL),
+		rateLimit:           rateLimit,
+		environment:         params.environment,
+		synonyms:            params.synonyms.compile(),
+	}
+	client.openAIBreaker = &breaker.Breaker{
+		Name:      "OpenAI",
+		Threshold: breaker.DefaultThreshold,
+		Cooldown:  breaker.DefaultCooldown,
+		Probe:     client.pingOpenAI,
+		IsFailure: llm.IsOutage,
+	}
+	client.pineconeBreaker = &breaker.Breaker{
+		Name:      "Pinecone",
+		Threshold: breaker.DefaultThreshold,
+		Cooldown:  breaker.DefaultCooldown,
+		Probe:     client.pingPinecone,
+		IsFailure: pineconePolicy.Retryable,
+	}
+	if params.warmUp {
+		go func() {
+			if err := client.WarmUp(context.Background()); err != nil {
+				log.Printf("search warm-up: %v", err)
+			}
+		}()
+	}
+	if params.keepAlive > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		client.stopKeepAlive = cancel
+		go client.keepAlive(ctx, params.keepAlive)
+	}
+	return client, nil
 }
 
 // Helper function take query convert to embeddings OpenAI
-func getEmbeddings(client *openai.Client, query string) ([]float32, error) {
+func (s *Client) getEmbeddings(query string) ([]float32, error) {
+	embedding, _, err := s.embedText(query)
+	return embedding, err
+}
 
-	encoding := "cl100k_base" // sets the encoding model to use
+// embedText is getEmbeddings that also reports how many tokens were billed.
+func (s *Client) embedText(query string) ([]float32, int, error) {
 
-	// Create a TikToken encoding instance
-	tke, err := tiktoken.GetEncoding(encoding)
+	// Get the shared TikToken encoding instance
+	tke, err := tokenEncoding()
 	if err != nil {
-		return nil, fmt.Errorf("getEncoding: %v", err)
+		return nil, 0, fmt.Errorf("getEncoding: %v", err)
 	}
 
 	// Tokenize the query using TikToen
@@ -95,16 +180,32 @@ func getEmbeddings(client *openai.Client, query string) ([]float32, error) {
 	ctx := context.Background()
 
 	// Generate embeddings
-	resp, err := client.CreateEmbeddings(ctx, req)
+	resp, err := breaker.DoValue(ctx, s.openAIBreaker, func(ctx context.Context) (openai.EmbeddingResponse, error) {
+		return s.openAIClient.CreateEmbeddings(ctx, req)
+	})
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	if len(resp.Data[0].Embedding) == 0 {
-		return nil, fmt.Errorf("no embeddings returned")
+	if len(resp.Data) == 0 || len(resp.Data[0].Embedding) == 0 {
+		return nil, 0, fmt.Errorf("no embeddings returned")
 	}
 
-	return resp.Data[0].Embedding, nil
+	return resp.Data[0].Embedding, resp.Usage.TotalTokens, nil
+}
+
+// queryEmbeddings gets the embedding of a user query from OpenAI, unless it was asked recently.
+func (s *Client) queryEmbeddings(query string) ([]float32, error) {
+	normalized := normalizeQuery(query)
+	if embeddings, ok := s.embeddingCache.Get(normalized); ok {
+		return embeddings, nil
+	}
+	embeddings, err := s.getEmbeddings(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get embeddings: %w", err)
+	}
+	s.embeddingCache.Put(normalized, embeddings)
+	return embeddings, nil
 }
 
 // Data struct for query response
@@ -126,17 +227,21 @@ type QueryParams struct {
 }
 
 // Helper function to search Pinecone index
-func searchPinecone(pineconeClient *pinecone.IndexClient, embedding []float32, topK int64) (*pinecone.QueryResponse, error) {
+func (s *Client) searchPinecone(namespace string, embedding []float32, topK int64, filter map[string]any) (*pinecone.QueryResponse, error) {
 	// Search Pinecone index
 	ctx := context.Background()
 	params := pinecone.QueryParams{
 		Vector:          embedding,
 		TopK:            topK,
 		IncludeMetadata: true,
+		Namespace:       s.namespace(namespace),
+		Filter:          filter,
 	}
-	resp, err := pineconeClient.Query(ctx, params)
+	resp, err := withPineconeRetry(ctx, s.pineconeBreaker, func(ctx context.Context) (*pinecone.QueryResponse, error) {
+		return s.pineconeIndexClient.Query(ctx, params)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to search Pinecone index: %v", err)
+		return nil, fmt.Errorf("failed to search Pinecone index: %w", err)
 	}
 
 	return resp, nil
@@ -150,41 +255,170 @@ type SearchResult struct {
 	Slug    string
 	Score   float32
 	PubDate string
+	Snippet string
+	PRURL   string
+	// Draft is set for results from the drafts namespace.
+	Draft bool
+	// Document is set for results from the documents namespace. Path is the document's URL.
+	Document bool
+}
+
+type queryParams struct {
+	includeDrafts    bool
+	includeDocuments bool
+	limit            int64
+	filter           map[string]any
+}
+
+// QueryOption configures a call to RunQuery.
+type QueryOption func(*queryParams)
+
+// IncludeDrafts adds matching unpublished drafts to the results.
+func IncludeDrafts() QueryOption {
+	return func(params *queryParams) {
+		params.includeDrafts = true
+	}
+}
+
+// WithLimit returns up to n results instead of the default three.
+func WithLimit(n int) QueryOption {
+	return func(params *queryParams) {
+		params.limit = int64(n)
+	}
+}
+
+// InCategory only matches articles in category.
+func InCategory(category string) QueryOption {
+	return withFilter("categories", category)
+}
+
+// ByAuthor only matches articles by author.
+func ByAuthor(author string) QueryOption {
+	return withFilter("authors", author)
+}
+
+// PublishedIn only matches articles published in r, eg. pubdate.ThisWeek(time.Now()). Vectors
+// stored before MetadataVersion 4 have no pub_day, so won't match until they're upgraded.
+func PublishedIn(r pubdate.Range) QueryOption {
+	return func(params *queryParams) {
+		if params.filter == nil {
+			params.filter = map[string]any{}
+		}
+		params.filter["pub_day"] = map[string]any{
+			"$gte": pubdate.DayNumber(r.From),
+			"$lt":  pubdate.DayNumber(r.To),
+		}
+	}
+}
+
+// withFilter requires a list metadata field to contain value. Vectors stored before
+// MetadataVersion 2 have no lists, so won't match until they're upgraded.
+func withFilter(field, value string) QueryOption {
+	return func(params *queryParams) {
+		if params.filter == nil {
+			params.filter = map[string]any{}
+		}
+		params.filter[field] = map[string]any{"$in": []string{value}}
+	}
 }
 
 // RunQuery is a method of Client struct, that returns results using the SearchResult struct
-func (s *Client) RunQuery(query string) ([]*SearchResult, error) {
+func (s *Client) RunQuery(query string, opts ...QueryOption) ([]*SearchResult, error) {
+	params := queryParams{limit: topK}
+	for _, opt := range opts {
+		opt(&params)
+	}
+
+	normalized := normalizeQuery(query)
+	resultKey := fmt.Sprintf("%t:%t:%d:%v:%s", params.includeDrafts, params.includeDocuments, params.limit, params.filter, normalized)
+	if cached, ok := s.resultCache.Get(resultKey); ok {
+		return copyResults(cached), nil
+	}
+
+	out, err := s.runQueryUncached(query, params)
+	if err != nil {
+		return nil, err
+	}
+	if s.resultCache != nil {
+		stored := make([]SearchResult, len(out))
+		for i, result := range out {
+			stored[i] = *result
+		}
+		s.resultCache.Put(resultKey, stored)
+	}
+	return out, nil
+}
 
-	// Get embedding of user query from OpenAI
-	embeddings, err := getEmbeddings(s.openAIClient, query)
+// runQueryUncached is RunQuery without the result cache, so Evaluate measures the same search.
+func (s *Client) runQueryUncached(query string, params queryParams) ([]*SearchResult, error) {
+	embeddings, err := s.queryEmbeddings(s.synonyms.Expand(query).Query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get embeddings: %v", err)
+		return nil, err
 	}
 
 	// Search query embeddings in Pinecone index
-	searchResults, err := searchPinecone(s.pineconeIndexClient, embeddings, topK)
+	searchResults, err := s.searchPinecone("", embeddings, params.limit, params.filter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search Pinecone index: %v", err)
+		return nil, fmt.Errorf("failed to search Pinecone index: %w", err)
 	}
+	out := matchesToResults(searchResults.Matches, "")
 
-	// Return the search results
-	out := []*SearchResult{}
+	extra := []string{}
+	if params.includeDrafts {
+		extra = append(extra, DraftsNamespace)
+	}
+	if params.includeDocuments {
+		extra = append(extra, DocumentsNamespace)
+	}
+	for _, namespace := range extra {
+		results, err := s.searchPinecone(namespace, embeddings, params.limit, params.filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search Pinecone %s: %w", namespace, err)
+		}
+		out = append(out, matchesToResults(results.Matches, namespace)...)
+	}
+	if len(extra) > 0 {
+		sort.SliceStable(out, func(i, j int) bool {
+			return out[i].Score > out[j].Score
+		})
+		if len(out) > int(params.limit) {
+			out = out[:params.limit]
+		}
+	}
+	return out, nil
+}
 
-	baseURL := "https://www.torontoverse.com"
+// copyResults hands out fresh copies of cached results, so callers can't modify the cache.
+func copyResults(cached []SearchResult) []*SearchResult {
+	out := make([]*SearchResult, len(cached))
+	for i := range cached {
+		result := cached[i]
+		out[i] = &result
+	}
+	return out
+}
+
+func matchesToResults(matches []*pinecone.QueryVector, namespace string) []*SearchResult {
+	out := []*SearchResult{}
 
-	for _, result := range searchResults.Matches {
+	for _, result := range matches {
 
 		searchResult := &SearchResult{
-			ID:    result.ID,
-			Score: result.Score,
+			ID:       result.ID,
+			Score:    result.Score,
+			Draft:    namespace == DraftsNamespace,
+			Document: namespace == DocumentsNamespace,
 		}
 		if result.Metadata["article_name"] != nil {
 			searchResult.Name, _ = result.Metadata["article_name"].(string)
 		}
 		if result.Metadata["path"] != nil {
 			path, _ := result.Metadata["path"].(string)
-			// Prepend the base URL to the path
-			searchResult.Path = baseURL + path
+			searchResult.Path = absoluteURL(path)
+		}
+		// Documents are served from elsewhere, so keep their full URL.
+		if url, ok := result.Metadata["url"].(string); ok {
+			searchResult.Path = url
 		}
 		if result.Metadata["slug"] != nil { // Check if "slug" exists in the metadata
 			searchResult.Slug, _ = result.Metadata["slug"].(string) // Add the slug to the SearchResult
@@ -193,8 +427,14 @@ func (s *Client) RunQuery(query string) ([]*SearchResult, error) {
 		if result.Metadata["pub_date"] != nil {
 			searchResult.PubDate, _ = result.Metadata["pub_date"].(string)
 		}
+		if result.Metadata["snippet"] != nil {
+			searchResult.Snippet, _ = result.Metadata["snippet"].(string)
+		}
+		if result.Metadata["pr_url"] != nil {
+			searchResult.PRURL, _ = result.Metadata["pr_url"].(string)
+		}
 		out = append(out, searchResult)
 	}
 
-	return out, nil
+	return out
 }
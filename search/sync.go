@@ -0,0 +1,181 @@
+package search
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/geomodulus/citygraph"
+	"github.com/nekomeowww/go-pinecone"
+
+	"github.com/geomodulus/robots/reqid"
+)
+
+const (
+	// EmbeddingCostPer1KTokens is what OpenAI charges for ada-002 embeddings, in US dollars.
+	EmbeddingCostPer1KTokens = 0.0001
+	// fetchBatchSize is how many vectors are fetched from Pinecone per request.
+	fetchBatchSize = 100
+	// deleteBatchSize is Pinecone's limit on IDs per delete.
+	deleteBatchSize = 1000
+)
+
+// SyncStats counts what a Sync did.
+type SyncStats struct {
+	Indexed   int
+	Updated   int
+	Unchanged int
+	// Upgraded counts vectors whose metadata was rewritten at the current MetadataVersion.
+	Upgraded int
+	Deleted  int
+	Failed   int
+	// Tokens is the number of tokens embedded.
+	Tokens int
+}
+
+// Cost estimates what the embeddings cost, in US dollars.
+func (st *SyncStats) Cost() float64 {
+	return float64(st.Tokens) / 1000 * EmbeddingCostPer1KTokens
+}
+
+// BodyLoader returns an article's body HTML.
+type BodyLoader func(ctx context.Context, article *citygraph.Article) (string, error)
+
+// Sync brings the default namespace up to date: live articles with no vector, or whose vector's
+// metadata no longer matches, are embedded with bodies from loadBody, vectors with metadata
+// from an older MetadataVersion are upgraded, and the remove IDs are deleted. Unlike Generate it
+// doesn't need the articles on disk. Failures for single articles are logged and counted rather
+// than stopping the sync.
+func (s *Client) Sync(ctx context.Context, articles []*citygraph.Article, loadBody BodyLoader, remove []string) (*SyncStats, error) {
+	return s.sync(ctx, articles, loadBody, remove, true)
+}
+
+func (s *Client) sync(ctx context.Context, articles []*citygraph.Article, loadBody BodyLoader, remove []string, embed bool) (*SyncStats, error) {
+	stats := &SyncStats{}
+
+	live := []*citygraph.Article{}
+	for _, article := range articles {
+		if article.PubDate != "" && article.IsLive {
+			live = append(live, article)
+		}
+	}
+
+	for start := 0; start < len(live); start += fetchBatchSize {
+		batch := live[start:min(start+fetchBatchSize, len(live))]
+		ids := make([]string, len(batch))
+		for i, article := range batch {
+			ids[i] = article.ID
+		}
+		existing, err := s.fetchVectors(ctx, ids)
+		if err != nil {
+			return stats, err
+		}
+
+		for _, article := range batch {
+			vector, found := existing[article.ID]
+			if found && metadataMatches(vector.Metadata, article) {
+				if metadataVersion(vector.Metadata) >= MetadataVersion {
+					stats.Unchanged++
+				} else if err := s.upgradeMetadata(ctx, article, loadBody); err != nil {
+					reqid.Printf(ctx, "Failed to upgrade metadata for article %s: %v", article.Name, err)
+					stats.Failed++
+				} else {
+					stats.Upgraded++
+				}
+				continue
+			}
+			if !embed {
+				continue
+			}
+
+			tokens, err := s.indexArticle(ctx, article, loadBody)
+			stats.Tokens += tokens
+			switch {
+			case err != nil:
+				reqid.Printf(ctx, "Failed to index article %s: %v", article.Name, err)
+				stats.Failed++
+			case found:
+				stats.Updated++
+			default:
+				stats.Indexed++
+			}
+		}
+	}
+
+	// Only count, and delete, the vectors that are actually there.
+	indexed := []string{}
+	for start := 0; start < len(remove); start += fetchBatchSize {
+		existing, err := s.fetchVectors(ctx, remove[start:min(start+fetchBatchSize, len(remove))])
+		if err != nil {
+			return stats, err
+		}
+		for id := range existing {
+			indexed = append(indexed, id)
+		}
+	}
+	if len(indexed) > 0 {
+		if err := s.deleteVectors(ctx, "", indexed); err != nil {
+			return stats, err
+		}
+		stats.Deleted = len(indexed)
+	}
+
+	s.resultCache.Clear()
+	return stats, nil
+}
+
+func (s *Client) fetchVectors(ctx context.Context, ids []string) (map[string]*pinecone.Vector, error) {
+	return s.fetchVectorsIn(ctx, "", ids)
+}
+
+func (s *Client) fetchVectorsIn(ctx context.Context, namespace string, ids []string) (map[string]*pinecone.Vector, error) {
+	resp, err := withPineconeRetry(ctx, s.pineconeBreaker, func(ctx context.Context) (*pinecone.FetchVectorsResponse, error) {
+		return s.pineconeIndexClient.FetchVectors(ctx, pinecone.FetchVectorsParams{IDs: ids, Namespace: s.namespace(namespace)})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch vectors: %v", err)
+	}
+	return resp.Vectors, nil
+}
+
+func (s *Client) indexArticle(ctx context.Context, article *citygraph.Article, loadBody BodyLoader) (int, error) {
+	body, err := loadBody(ctx, article)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load body: %v", err)
+	}
+	metadata, err := articleMetadata(article, body)
+	if err != nil {
+		return 0, err
+	}
+	es, err := embeddingText(article, body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute template: %v", err)
+	}
+	embeddings, tokens, err := s.embedText(es)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get embeddings: %v", err)
+	}
+	return tokens, s.storeEmbeddings("", article.ID, embeddings, metadata)
+}
+
+func (s *Client) deleteVectors(ctx context.Context, namespace string, ids []string) error {
+	for start := 0; start < len(ids); start += deleteBatchSize {
+		batch := ids[start:min(start+deleteBatchSize, len(ids))]
+		_, err := withPineconeRetry(ctx, s.pineconeBreaker, func(ctx context.Context) (struct{}, error) {
+			return struct{}{}, s.pineconeIndexClient.DeleteVectors(ctx, pinecone.DeleteVectorsParams{
+				IDs:       batch,
+				Namespace: s.namespace(namespace),
+			})
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete vectors: %v", err)
+		}
+	}
+	return nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
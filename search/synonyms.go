@@ -0,0 +1,142 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Synonyms maps local shorthand to what it stands for, eg. "TCHC" to "Toronto Community
+// Housing". Aliases match whole words, ignoring case.
+type Synonyms map[string][]string
+
+// DefaultSynonyms covers shorthand common enough in reader searches to be worth expanding
+// everywhere. Pass it to WithSynonyms, or merge it into a dictionary of your own.
+var DefaultSynonyms = Synonyms{
+	"the 6ix":      {"Toronto"},
+	"t.o.":         {"Toronto"},
+	"ttc":          {"Toronto Transit Commission"},
+	"tchc":         {"Toronto Community Housing"},
+	"tdsb":         {"Toronto District School Board"},
+	"eg west":      {"Eglinton West"},
+	"the ex":       {"Canadian National Exhibition"},
+	"cne":          {"Canadian National Exhibition"},
+	"dvp":          {"Don Valley Parkway"},
+	"the gardiner": {"Gardiner Expressway"},
+	"qew":          {"Queen Elizabeth Way"},
+	"yonge-dundas": {"Yonge and Dundas"},
+}
+
+// LoadSynonyms reads a dictionary from JSON, eg.
+//
+//	{"tchc": ["Toronto Community Housing"], "eg west": ["Eglinton West"]}
+func LoadSynonyms(r io.Reader) (Synonyms, error) {
+	synonyms := Synonyms{}
+	if err := json.NewDecoder(r).Decode(&synonyms); err != nil {
+		return nil, fmt.Errorf("error decoding synonyms: %v", err)
+	}
+	return synonyms, nil
+}
+
+// WithSynonyms expands aliases in queries before they're embedded. The aliases are compiled
+// once, by NewClient.
+func WithSynonyms(synonyms Synonyms) ClientOption {
+	return func(params *clientParams) {
+		params.synonyms = synonyms
+	}
+}
+
+// Expansion is a query with its aliases expanded.
+type Expansion struct {
+	// Query is the original query followed by the expansions, eg. "tchc repairs (Toronto
+	// Community Housing)", for embedding.
+	Query string
+	// Terms are the expansions added, for keyword matching.
+	Terms []string
+}
+
+// Expand appends what each alias in query stands for. Expansions already in the query aren't
+// repeated, and a nil dictionary leaves the query as it is. It compiles the dictionary each
+// time; clients given it with WithSynonyms compile it once.
+func (s Synonyms) Expand(query string) Expansion {
+	return s.compile().Expand(query)
+}
+
+// expander is a dictionary with its aliases compiled, longest first, so "eg west" wins over an
+// alias for "west" on its own.
+type expander struct {
+	aliases []compiledAlias
+}
+
+type compiledAlias struct {
+	re    *regexp.Regexp
+	width int
+	terms []string
+}
+
+// compile returns s ready to expand queries, or nil if it's empty.
+func (s Synonyms) compile() *expander {
+	if len(s) == 0 {
+		return nil
+	}
+	aliases := make([]string, 0, len(s))
+	for alias := range s {
+		aliases = append(aliases, alias)
+	}
+	sort.Slice(aliases, func(i, j int) bool {
+		if len(aliases[i]) != len(aliases[j]) {
+			return len(aliases[i]) > len(aliases[j])
+		}
+		return aliases[i] < aliases[j]
+	})
+
+	e := &expander{}
+	for _, alias := range aliases {
+		e.aliases = append(e.aliases, compiledAlias{re: aliasRe(alias), width: len(alias), terms: s[alias]})
+	}
+	return e
+}
+
+// Expand is Synonyms.Expand. A nil expander leaves the query as it is.
+func (e *expander) Expand(query string) Expansion {
+	expansion := Expansion{Query: query}
+	if e == nil {
+		return expansion
+	}
+
+	lower := strings.ToLower(query)
+	seen := map[string]bool{}
+	for _, alias := range e.aliases {
+		if !alias.re.MatchString(lower) {
+			continue
+		}
+		// Blank out the match so shorter aliases inside it don't match too.
+		lower = alias.re.ReplaceAllString(lower, "${1}"+strings.Repeat(" ", alias.width)+"${2}")
+		for _, term := range alias.terms {
+			key := strings.ToLower(term)
+			if seen[key] || strings.Contains(strings.ToLower(query), key) {
+				continue
+			}
+			seen[key] = true
+			expansion.Terms = append(expansion.Terms, term)
+		}
+	}
+	if len(expansion.Terms) > 0 {
+		expansion.Query = fmt.Sprintf("%s (%s)", query, strings.Join(expansion.Terms, ", "))
+	}
+	return expansion
+}
+
+// aliasRe matches alias as whole words in a lowercased query.
+func aliasRe(alias string) *regexp.Regexp {
+	return regexp.MustCompile(`(^|[^\pL\pN])` + regexp.QuoteMeta(strings.ToLower(alias)) + `($|[^\pL\pN])`)
+}
+
+// ExpandQuery expands query with the client's synonyms, as RunQuery does before embedding it.
+// Keyword matching should use the same terms so both halves of a search agree.
+func (s *Client) ExpandQuery(query string) Expansion {
+	return s.synonyms.Expand(query)
+}
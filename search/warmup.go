@@ -0,0 +1,131 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nekomeowww/go-pinecone"
+	"github.com/pkoukk/tiktoken-go"
+)
+
+type clientParams struct {
+	warmUp             bool
+	keepAlive          time.Duration
+	embeddingCacheSize int
+	embeddingCacheTTL  time.Duration
+	resultCacheSize    int
+	resultCacheTTL     time.Duration
+	environment        Environment
+	synonyms           Synonyms
+}
+
+// ClientOption configures NewClient.
+type ClientOption func(*clientParams)
+
+// WithoutWarmUp skips opening connections and loading the tokenizer at NewClient, eg. for
+// one-off scripts that don't care about first-query latency.
+func WithoutWarmUp() ClientOption {
+	return func(params *clientParams) {
+		params.warmUp = false
+	}
+}
+
+// WithKeepAlive pings OpenAI and Pinecone every interval so their connections don't go cold
+// between searches. Stop it with Close.
+func WithKeepAlive(interval time.Duration) ClientOption {
+	return func(params *clientParams) {
+		params.keepAlive = interval
+	}
+}
+
+// pooledTransport keeps a few HTTP/2 connections per host open for longer than the default, so
+// searches a few minutes apart don't pay for a new TLS handshake.
+func pooledTransport() *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          20,
+		MaxIdleConnsPerHost:   4,
+		IdleConnTimeout:       5 * time.Minute,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+var (
+	tokenEncodingOnce sync.Once
+	tokenEncodingTke  *tiktoken.Tiktoken
+	tokenEncodingErr  error
+)
+
+// tokenEncoding loads the cl100k_base encoding once; building it takes a noticeable moment.
+func tokenEncoding() (*tiktoken.Tiktoken, error) {
+	tokenEncodingOnce.Do(func() {
+		tokenEncodingTke, tokenEncodingErr = tiktoken.GetEncoding("cl100k_base")
+	})
+	return tokenEncodingTke, tokenEncodingErr
+}
+
+// WarmUp loads the tokenizer and opens connections to OpenAI and Pinecone, so the first search
+// doesn't pay for them. NewClient runs it in the background unless WithoutWarmUp is given.
+func (s *Client) WarmUp(ctx context.Context) error {
+	if _, err := tokenEncoding(); err != nil {
+		return fmt.Errorf("error loading tokenizer: %v", err)
+	}
+	return s.ping(ctx)
+}
+
+// ping makes cheap calls to both services.
+func (s *Client) ping(ctx context.Context) error {
+	if err := s.pingOpenAI(ctx); err != nil {
+		return err
+	}
+	return s.pingPinecone(ctx)
+}
+
+// pingOpenAI also serves as the OpenAI breaker's recovery probe.
+func (s *Client) pingOpenAI(ctx context.Context) error {
+	if _, err := s.openAIClient.ListModels(ctx); err != nil {
+		return fmt.Errorf("error reaching OpenAI: %v", err)
+	}
+	return nil
+}
+
+// pingPinecone also serves as the Pinecone breaker's recovery probe.
+func (s *Client) pingPinecone(ctx context.Context) error {
+	if _, err := s.pineconeIndexClient.DescribeIndexStats(ctx, pinecone.DescribeIndexStatsParams{}); err != nil {
+		return fmt.Errorf("error reaching Pinecone: %v", err)
+	}
+	return nil
+}
+
+func (s *Client) keepAlive(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.ping(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("search keep-alive: %v", err)
+			}
+		}
+	}
+}
+
+// Close stops the keep-alive pings, if any.
+func (s *Client) Close() {
+	if s.stopKeepAlive != nil {
+		s.stopKeepAlive()
+	}
+}
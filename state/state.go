@@ -0,0 +1,132 @@
+// Package state stores small bits of bot state, like conversation history, as JSON under
+// string keys.
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/storage"
+)
+
+// Store keeps JSON-encoded values under keys. Keys are slash-separated paths, eg.
+// "conversations/T123/C456/1690000000.000100".
+type Store interface {
+	// Get decodes the value at key into v, reporting false if there isn't one.
+	Get(ctx context.Context, key string, v any) (bool, error)
+	Put(ctx context.Context, key string, v any) error
+	// Delete removes key. Deleting a missing key isn't an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Memory is a Store that lives and dies with the process, for tests and single-instance bots
+// that can afford to forget on restart.
+type Memory struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+func NewMemory() *Memory {
+	return &Memory{values: map[string][]byte{}}
+}
+
+func (m *Memory) Get(_ context.Context, key string, v any) (bool, error) {
+	m.mu.Lock()
+	data, ok := m.values[key]
+	m.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("error decoding %s: %v", key, err)
+	}
+	return true, nil
+}
+
+func (m *Memory) Put(_ context.Context, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error encoding %s: %v", key, err)
+	}
+	m.mu.Lock()
+	m.values[key] = data
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Memory) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	delete(m.values, key)
+	m.mu.Unlock()
+	return nil
+}
+
+// GCS is a Store that keeps each value as an object in a Cloud Storage bucket.
+type GCS struct {
+	client *storage.Client
+	bucket string
+	prefix string
+}
+
+// NewGCS stores values in bucket, under prefix.
+func NewGCS(client *storage.Client, bucket, prefix string) *GCS {
+	return &GCS{client: client, bucket: bucket, prefix: strings.Trim(prefix, "/")}
+}
+
+func (g *GCS) object(key string) *storage.ObjectHandle {
+	name := key + ".json"
+	if g.prefix != "" {
+		name = g.prefix + "/" + name
+	}
+	return g.client.Bucket(g.bucket).Object(name)
+}
+
+func (g *GCS) Get(ctx context.Context, key string, v any) (bool, error) {
+	r, err := g.object(key).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error reading %s: %v", key, err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return false, fmt.Errorf("error reading %s: %v", key, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("error decoding %s: %v", key, err)
+	}
+	return true, nil
+}
+
+func (g *GCS) Put(ctx context.Context, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error encoding %s: %v", key, err)
+	}
+	w := g.object(key).NewWriter(ctx)
+	w.ContentType = "application/json"
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return fmt.Errorf("error writing %s: %v", key, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("error writing %s: %v", key, err)
+	}
+	return nil
+}
+
+func (g *GCS) Delete(ctx context.Context, key string) error {
+	err := g.object(key).Delete(ctx)
+	if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("error deleting %s: %v", key, err)
+	}
+	return nil
+}
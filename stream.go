@@ -0,0 +1,84 @@
+package robots
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/slack-go/slack"
+
+	"github.com/geomodulus/robots/internal/llm"
+)
+
+// streamUpdateInterval is how often a streamed reply's message is updated. Slack allows
+// chat.update about once a second per channel.
+var streamUpdateInterval = time.Second
+
+// streamCursor is shown at the end of a reply while it's still being written.
+const streamCursor = " ▍"
+
+// maxStreamLength, in runes, keeps streamed replies under Slack's message length limit.
+const maxStreamLength = 39000
+
+// StreamReply runs a chat completion and shows it in env's thread as it's generated, updating
+// the message with the text so far about once a second, so long drafts and answers don't sit in
+// silence. format, if set, turns the text so far into the message shown, eg. to fence it. It
+// returns the full reply.
+func (b *SlackBot) StreamReply(ctx context.Context, env *Envelope, client *openai.Client, req openai.ChatCompletionRequest, format func(string) string) (string, error) {
+	req.Stream = true
+	stream, err := llm.Stream(ctx, client, req)
+	if err != nil {
+		return "", fmt.Errorf("error starting completion: %w", err)
+	}
+	defer stream.Close()
+
+	_, ts, err := b.PostMessageContext(ctx, env.ChannelID,
+		slack.MsgOptionText(":writing_hand:"+streamCursor, false),
+		slack.MsgOptionTS(env.ThreadTS),
+	)
+	if err != nil {
+		return "", fmt.Errorf("error posting reply: %v", err)
+	}
+
+	var reply strings.Builder
+	lastUpdate := time.Now()
+	shown := ""
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			b.updateStream(ctx, env, ts, format, reply.String()+"\n\n:warning: _The reply was cut short._")
+			return reply.String(), fmt.Errorf("error reading completion: %v", err)
+		}
+		if len(resp.Choices) > 0 {
+			reply.WriteString(resp.Choices[0].Delta.Content)
+		}
+
+		if time.Since(lastUpdate) >= streamUpdateInterval && reply.String() != shown {
+			shown = reply.String()
+			// A failed progress update isn't worth stopping for; the final one will catch up.
+			b.updateStream(ctx, env, ts, format, shown+streamCursor)
+			lastUpdate = time.Now()
+		}
+	}
+
+	if err := b.updateStream(ctx, env, ts, format, reply.String()); err != nil {
+		return reply.String(), fmt.Errorf("error updating reply: %v", err)
+	}
+	return reply.String(), nil
+}
+
+func (b *SlackBot) updateStream(ctx context.Context, env *Envelope, ts string, format func(string) string, text string) error {
+	text = excerptEnd(text, maxStreamLength)
+	if format != nil {
+		text = format(text)
+	}
+	_, _, _, err := b.UpdateMessageContext(ctx, env.ChannelID, ts, slack.MsgOptionText(text, false))
+	return err
+}
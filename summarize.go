@@ -0,0 +1,153 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/slack-go/slack/slackevents"
+
+	"github.com/geomodulus/robots/command"
+	"github.com/geomodulus/robots/prompts"
+)
+
+// maxTranscriptLength bounds the thread text sent to the model. Longer threads keep their root
+// and most recent messages.
+const maxTranscriptLength = 40000
+
+var userMentionRe = regexp.MustCompile(`<@([A-Z0-9]+)(\|[^>]*)?>`)
+
+var summarizeGrammar = &command.Grammar{
+	Name: "@bot",
+	Commands: []*command.Command{{
+		Verb:    "summarize",
+		Aliases: []string{"summarise"},
+		Args:    []command.Arg{{Name: "what", Optional: true, Rest: true}},
+		Help:    "Summarize the thread it's asked in, eg. \"summarize this thread\".",
+	}, {
+		Verb:    "tldr",
+		Aliases: []string{"tl;dr"},
+		Args:    []command.Arg{{Name: "what", Optional: true, Rest: true}},
+		Help:    "Summarize the thread it's asked in.",
+	}},
+}
+
+// ThreadSummarizer answers "@bot summarize this thread" with the thread's gist, decisions and
+// action items, shown as they're written. Check HandlesMention from the bot's app mention handler and pass matching
+// mentions to HandleAppMention.
+type ThreadSummarizer struct {
+	Bot    *SlackBot
+	OpenAI *openai.Client
+	// Prompts defaults to prompts.Default.
+	Prompts *prompts.Registry
+	// Model defaults to GPT-3.5 Turbo 16K, since threads run long.
+	Model string
+}
+
+// HandlesMention reports whether a mention asks for a summary: "tl;dr", or "summarize" the
+// thread, so "summarize this article" is left to other handlers.
+func (s *ThreadSummarizer) HandlesMention(text string) bool {
+	inv, err := summarizeGrammar.Search(text)
+	if err != nil {
+		return false
+	}
+	if inv.Verb() == "tldr" {
+		return true
+	}
+	for _, word := range strings.Fields(inv.Arg("what")) {
+		if strings.EqualFold(strings.Trim(word, ".,:;!?"), "thread") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *ThreadSummarizer) HandleAppMention(ctx context.Context, env *Envelope, ev *slackevents.AppMentionEvent) error {
+	if ev.ThreadTimeStamp == "" {
+		return fmt.Errorf("ask me in the thread you want summarized")
+	}
+
+	msgs, err := s.Bot.ThreadMessages(ctx, env.ChannelID, env.ThreadTS)
+	if err != nil {
+		return err
+	}
+	lines := []string{}
+	for _, msg := range msgs {
+		// Leave out the request itself.
+		if msg.Timestamp == env.MessageTS || strings.TrimSpace(msg.Text) == "" {
+			continue
+		}
+		name := msg.Username
+		if msg.User != "" {
+			name = s.userName(ctx, msg.User)
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", name, s.resolveMentions(ctx, msg.Text)))
+	}
+	if len(lines) == 0 {
+		return fmt.Errorf("there's nothing in this thread to summarize")
+	}
+
+	registry := s.Prompts
+	if registry == nil {
+		registry = prompts.Default
+	}
+	prompt, err := registry.Render(ctx, prompts.SummarizeThread, map[string]any{"Messages": trimTranscript(lines)})
+	if err != nil {
+		return err
+	}
+	model := s.Model
+	if model == "" {
+		model = openai.GPT3Dot5Turbo16K
+	}
+
+	title := fmt.Sprintf("*Thread summary* (%d messages)\n", len(lines))
+	_, err = s.Bot.StreamReply(ctx, env, s.OpenAI, openai.ChatCompletionRequest{
+		Model:       model,
+		Temperature: 0.2,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleUser, Content: prompt.Text},
+		},
+	}, func(text string) string {
+		return title + text
+	})
+	if err != nil {
+		return fmt.Errorf("error summarizing thread: %w", err)
+	}
+	return nil
+}
+
+// userName falls back to the user ID, so one failed lookup doesn't sink the summary.
+func (s *ThreadSummarizer) userName(ctx context.Context, userID string) string {
+	name, err := s.Bot.UserName(ctx, userID)
+	if err != nil || name == "" {
+		return userID
+	}
+	return name
+}
+
+// resolveMentions replaces <@U123> mentions with names the model can follow.
+func (s *ThreadSummarizer) resolveMentions(ctx context.Context, text string) string {
+	return userMentionRe.ReplaceAllStringFunc(text, func(mention string) string {
+		return "@" + s.userName(ctx, userMentionRe.FindStringSubmatch(mention)[1])
+	})
+}
+
+// trimTranscript drops messages after the root, oldest first, until the thread fits.
+func trimTranscript(lines []string) []string {
+	total := 0
+	for _, line := range lines {
+		total += len(line) + 1
+	}
+	dropped := 0
+	for total > maxTranscriptLength && len(lines)-dropped > 2 {
+		total -= len(lines[1+dropped]) + 1
+		dropped++
+	}
+	if dropped == 0 {
+		return lines
+	}
+	trimmed := []string{lines[0], fmt.Sprintf("[%d earlier replies omitted]", dropped)}
+	return append(trimmed, lines[1+dropped:]...)
+}
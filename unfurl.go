@@ -0,0 +1,150 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+
+	"github.com/geomodulus/robots/github"
+	"github.com/geomodulus/robots/mapbox"
+)
+
+// unfurlIndexTTL is how long the slug ID → article directory index is reused before the article
+// list is fetched again.
+const unfurlIndexTTL = 10 * time.Minute
+
+// Unfurler shows a headline, dek, feature image and map for torontoverse.com article links
+// pasted in Slack. Call its HandleLinkShared from the bot's link_shared handler; the Slack app
+// must be subscribed to link_shared for the torontoverse.com domain.
+type Unfurler struct {
+	Bot      *SlackBot
+	Articles *github.App
+	// MapboxToken, if set, adds a thumbnail of the article's locations.
+	MapboxToken string
+
+	mu       sync.Mutex
+	slugs    map[string]string
+	loadedAt time.Time
+}
+
+// HandleLinkShared unfurls every article link in the event. Links that aren't articles, or that
+// can't be found, are left alone.
+func (u *Unfurler) HandleLinkShared(ctx context.Context, env *Envelope, ev *slackevents.LinkSharedEvent) error {
+	unfurls := map[string]slack.Attachment{}
+	for _, link := range ev.Links {
+		attachment, err := u.ArticleUnfurl(ctx, link.URL)
+		if err != nil {
+			log.Printf("error unfurling %s: %v", link.URL, err)
+			continue
+		}
+		if attachment != nil {
+			unfurls[link.URL] = *attachment
+		}
+	}
+	if len(unfurls) == 0 {
+		return nil
+	}
+
+	if _, _, _, err := u.Bot.UnfurlMessageContext(ctx, ev.Channel, ev.MessageTimeStamp, unfurls); err != nil {
+		return fmt.Errorf("error unfurling links: %v", err)
+	}
+	return nil
+}
+
+// ArticleUnfurl builds the unfurl for an article URL, or returns nil if link isn't one.
+func (u *Unfurler) ArticleUnfurl(ctx context.Context, link string) (*slack.Attachment, error) {
+	slugID, ok := articleSlugID(link)
+	if !ok {
+		return nil, nil
+	}
+	slug, err := u.articleSlug(ctx, slugID)
+	if err != nil || slug == "" {
+		return nil, err
+	}
+	checkout, err := u.Articles.FetchArticle(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	article := checkout.Article
+
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType,
+			fmt.Sprintf("*<%s|%s>*\n%s", link, escapeMrkdwn(article.Name), escapeMrkdwn(article.Description)),
+			false, false), nil, nil),
+	}
+	if article.FeatureImage != "" {
+		blocks = append(blocks, slack.NewImageBlock(article.FeatureImage, article.Name, "", nil))
+	}
+	if u.MapboxToken != "" && checkout.LocationsGeoJSON != nil {
+		if mapURL := mapbox.StaticMapURL(u.MapboxToken, checkout.LocationsGeoJSON, 600, 300); mapURL != "" {
+			blocks = append(blocks, slack.NewImageBlock(mapURL, "Map of "+article.Name, "", nil))
+		}
+	}
+	byline := []string{}
+	if len(article.Authors) > 0 {
+		byline = append(byline, strings.Join(article.Authors, ", "))
+	}
+	if article.PubDate != "" {
+		byline = append(byline, article.PubDate)
+	}
+	if len(byline) > 0 {
+		blocks = append(blocks, slack.NewContextBlock("",
+			slack.NewTextBlockObject(slack.MarkdownType, escapeMrkdwn(strings.Join(byline, " · ")), false, false)))
+	}
+
+	return &slack.Attachment{Blocks: slack.Blocks{BlockSet: blocks}}, nil
+}
+
+// articleSlug finds the directory of the article with slugID, refreshing the index when it's
+// stale or doesn't know the article yet.
+func (u *Unfurler) articleSlug(ctx context.Context, slugID string) (string, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if slug, ok := u.slugs[slugID]; ok && time.Since(u.loadedAt) < unfurlIndexTTL {
+		return slug, nil
+	}
+	// A miss only forces a refresh once a minute, so links to missing articles stay cheap.
+	if u.slugs != nil && time.Since(u.loadedAt) < time.Minute {
+		return u.slugs[slugID], nil
+	}
+
+	articles, err := u.Articles.ListArticles(ctx)
+	if err != nil {
+		return "", err
+	}
+	u.slugs = map[string]string{}
+	for _, checkout := range articles {
+		id, err := checkout.Article.SlugID()
+		if err != nil {
+			continue
+		}
+		u.slugs[id] = checkout.Slug
+	}
+	u.loadedAt = time.Now()
+	return u.slugs[slugID], nil
+}
+
+// articleSlugID pulls the slug ID out of a torontoverse.com/articles/<slug id>/<title> URL.
+func articleSlugID(link string) (string, bool) {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return "", false
+	}
+	host := strings.TrimPrefix(parsed.Hostname(), "www.")
+	if host != "torontoverse.com" {
+		return "", false
+	}
+	parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "articles" || parts[1] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
@@ -0,0 +1,120 @@
+package robots
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// DefaultAllowedTypes are the media types Upload accepts unless told otherwise. Entries ending
+// in "/" allow a whole family. SVGs are sanitized before they're stored.
+var DefaultAllowedTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp", "image/avif", "image/heic", svgType, "video/", "application/pdf"}
+
+const svgType = "image/svg+xml"
+
+// maxSVGSize bounds SVGs, which are sanitized in memory.
+const maxSVGSize = 10 << 20
+
+// AllowTypes replaces the media types the upload accepts.
+func AllowTypes(types ...string) UploadOption {
+	return func(params *uploadParams) {
+		params.allowedTypes = types
+	}
+}
+
+// RejectedContentError is returned when a download isn't a type the bucket should hold, eg. an
+// HTML login page served in place of an image.
+type RejectedContentError struct {
+	Declared string
+	Sniffed  string
+}
+
+func (e *RejectedContentError) Error() string {
+	return fmt.Sprintf("refusing to upload %s content (declared %q)", e.Sniffed, e.Declared)
+}
+
+// integrityError is returned when the download doesn't match what was expected. It's worth
+// retrying.
+type integrityError struct {
+	msg string
+}
+
+func (e *integrityError) Error() string {
+	return e.msg
+}
+
+// executableMagic are the signatures of executables, which sniff as application/octet-stream.
+var executableMagic = [][]byte{
+	[]byte("MZ"),
+	[]byte("\x7fELF"),
+	[]byte("\xfe\xed\xfa\xce"), []byte("\xfe\xed\xfa\xcf"),
+	[]byte("\xce\xfa\xed\xfe"), []byte("\xcf\xfa\xed\xfe"),
+	[]byte("\xca\xfe\xba\xbe"),
+	[]byte("#!"),
+}
+
+// contentType checks a download's declared type and first bytes against allowed, returning the
+// type to store it as.
+func contentType(declaredHeader string, head []byte, allowed []string) (string, error) {
+	declared, _, _ := mime.ParseMediaType(declaredHeader)
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+
+	for _, magic := range executableMagic {
+		if bytes.HasPrefix(head, magic) {
+			return "", &RejectedContentError{Declared: declared, Sniffed: "executable"}
+		}
+	}
+
+	// SVG sniffs as XML or plain text, so go by the declared type or the markup itself.
+	if declared == svgType || looksLikeSVG(head) {
+		if !typeAllowed(svgType, allowed) {
+			return "", &RejectedContentError{Declared: declared, Sniffed: svgType}
+		}
+		return svgType, nil
+	}
+
+	if sniffed == "application/octet-stream" {
+		// Go can't sniff every format we take, eg. HEIC, so trust an allowed declared type.
+		if typeAllowed(declared, allowed) {
+			return declared, nil
+		}
+		return "", &RejectedContentError{Declared: declared, Sniffed: sniffed}
+	}
+	if !typeAllowed(sniffed, allowed) {
+		return "", &RejectedContentError{Declared: declared, Sniffed: sniffed}
+	}
+	// A declared type from a different family, eg. image/png for a PDF, means the source is
+	// confused; go with what the bytes say, as long as that's allowed.
+	if declared != "" && declared != "application/octet-stream" && typeAllowed(declared, allowed) &&
+		family(declared) == family(sniffed) {
+		return declared, nil
+	}
+	return sniffed, nil
+}
+
+func typeAllowed(mediaType string, allowed []string) bool {
+	if mediaType == "" {
+		return false
+	}
+	for _, entry := range allowed {
+		if strings.HasSuffix(entry, "/") && strings.HasPrefix(mediaType, entry) || entry == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+func family(mediaType string) string {
+	family, _, _ := strings.Cut(mediaType, "/")
+	return family
+}
+
+func looksLikeSVG(head []byte) bool {
+	text := bytes.ToLower(bytes.TrimSpace(head))
+	if !bytes.HasPrefix(text, []byte("<?xml")) && !bytes.HasPrefix(text, []byte("<svg")) && !bytes.HasPrefix(text, []byte("<!--")) {
+		return false
+	}
+	return bytes.Contains(text, []byte("<svg"))
+}
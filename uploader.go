@@ -1,24 +1,45 @@
 package robots
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"path"
+	"strconv"
+	"strings"
 
 	"cloud.google.com/go/storage"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+
+	"github.com/geomodulus/robots/audit"
+	"github.com/geomodulus/robots/htmlguard"
+	"github.com/geomodulus/robots/internal/retry"
+	"github.com/geomodulus/robots/reqid"
 )
 
 const bucketName = "media.geomodul.us"
 
 type Uploader struct {
+	// Audit, if set, records every stored file.
+	Audit audit.Log
+
 	client     *storage.Client
 	slackToken string
 	prefix     string
 }
 
+// AuditUpload is the action recorded for each file stored by Upload.
+const AuditUpload = "upload"
+
 func NewUploader(ctx context.Context, slackToken string, prefix string) (*Uploader, error) {
 	client, err := storage.NewClient(ctx)
 	if err != nil {
@@ -32,9 +53,69 @@ func NewUploader(ctx context.Context, slackToken string, prefix string) (*Upload
 	}, nil
 }
 
-func (u *Uploader) Upload(ctx context.Context, slug, downloadURL string) (string, error) {
+// HeaderProvider adds credentials to a download request.
+type HeaderProvider func(ctx context.Context, req *http.Request) error
+
+type uploadParams struct {
+	auth         HeaderProvider
+	objectKey    string
+	allowedTypes []string
+	progress     ProgressFunc
+}
+
+// UploadOption configures a single Upload.
+type UploadOption func(*uploadParams)
+
+// NoAuth downloads without credentials, for public URLs.
+func NoAuth() UploadOption {
+	return func(params *uploadParams) {
+		params.auth = nil
+	}
+}
+
+// SlackAuth downloads with the uploader's Slack token, for files shared in Slack. It's the
+// default.
+func (u *Uploader) SlackAuth() UploadOption {
+	return WithHeaders(u.slackHeaders)
+}
+
+// WithHeaders downloads with credentials from provider, eg. for Google Drive.
+func WithHeaders(provider HeaderProvider) UploadOption {
+	return func(params *uploadParams) {
+		params.auth = provider
+	}
+}
+
+// BearerToken downloads with OAuth2 tokens from ts, eg. a Google Drive token source.
+func BearerToken(ts oauth2.TokenSource) UploadOption {
+	return WithHeaders(func(_ context.Context, req *http.Request) error {
+		token, err := ts.Token()
+		if err != nil {
+			return retry.Permanent(fmt.Errorf("error getting token: %v", err))
+		}
+		token.SetAuthHeader(req)
+		return nil
+	})
+}
+
+// WithObjectKey stores the file at key, under the uploader's prefix, rather than a key derived
+// from the slug and URL.
+func WithObjectKey(key string) UploadOption {
+	return func(params *uploadParams) {
+		params.objectKey = key
+	}
+}
+
+func (u *Uploader) Upload(ctx context.Context, slug, downloadURL string, opts ...UploadOption) (string, error) {
+	params := uploadParams{auth: u.slackHeaders, allowedTypes: DefaultAllowedTypes}
+	for _, opt := range opts {
+		opt(&params)
+	}
+
 	var objectKey string
-	if slug == "" {
+	if params.objectKey != "" {
+		objectKey = path.Join(u.prefix, params.objectKey)
+	} else if slug == "" {
 		objectKey = fmt.Sprintf("img/%s", path.Base(downloadURL))
 	} else {
 		parsedURL, err := url.Parse(downloadURL)
@@ -44,30 +125,321 @@ func (u *Uploader) Upload(ctx context.Context, slug, downloadURL string) (string
 		objectKey = fmt.Sprintf("%s/%s/%s", u.prefix, slug, path.Base(parsedURL.Path))
 	}
 
-	// Create a new HTTP request to download the file.
-	req, err := http.NewRequest("GET", downloadURL, nil)
+	// A failure downloading or storing the file retries both.
+	var attrs *storage.ObjectAttrs
+	err := retry.Do(ctx, uploadRetryPolicy, func(ctx context.Context) error {
+		var err error
+		attrs, err = u.copyToBucket(ctx, objectKey, downloadURL, params)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	fmt.Printf("Blob %s uploaded.\n", attrs.Name)
+	if u.Audit != nil {
+		if err := u.Audit.Record(ctx, audit.Entry{
+			Actor:  audit.Actor(ctx),
+			Action: AuditUpload,
+			Fields: map[string]string{
+				"object":       objectKey,
+				"content_type": attrs.ContentType,
+				"size":         strconv.FormatInt(attrs.Size, 10),
+			},
+		}); err != nil {
+			reqid.Printf(ctx, "error auditing upload of %s: %v", objectKey, err)
+		}
+	}
+	return fmt.Sprintf("https://%s/%s", bucketName, objectKey), nil
+}
+
+// Download is Upload in reverse: it reads a file Upload stored straight from the bucket, given
+// the URL Upload returned. Other URLs are fetched over HTTP. Pass it to
+// github.WithMediaFetcher.
+func (u *Uploader) Download(ctx context.Context, mediaURL string) (io.ReadCloser, error) {
+	parsedURL, err := url.Parse(mediaURL)
+	if err != nil {
+		return nil, fmt.Errorf("url.Parse: %v", err)
+	}
+	if parsedURL.Host != bucketName {
+		req, err := http.NewRequestWithContext(ctx, "GET", mediaURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("http.NewRequest: %v", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("http.DefaultClient.Do: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, &downloadStatusError{code: resp.StatusCode, status: resp.Status}
+		}
+		return resp.Body, nil
+	}
+	objectKey := strings.TrimPrefix(parsedURL.Path, "/")
+	rc, err := u.client.Bucket(bucketName).Object(objectKey).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", objectKey, err)
+	}
+	return rc, nil
+}
+
+// fetch downloads a file without storing it, checked like an Upload, and returns it with its
+// media type. Files over limit bytes are refused.
+func (u *Uploader) fetch(ctx context.Context, downloadURL string, limit int64, opts ...UploadOption) ([]byte, string, error) {
+	params := uploadParams{auth: u.slackHeaders, allowedTypes: DefaultAllowedTypes}
+	for _, opt := range opts {
+		opt(&params)
+	}
+
+	var (
+		content   []byte
+		mediaType string
+	)
+	err := retry.Do(ctx, uploadRetryPolicy, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, "GET", downloadURL, nil)
+		if err != nil {
+			return retry.Permanent(fmt.Errorf("http.NewRequest: %v", err))
+		}
+		if params.auth != nil {
+			if err := params.auth(ctx, req); err != nil {
+				return err
+			}
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("http.DefaultClient.Do: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return &downloadStatusError{code: resp.StatusCode, status: resp.Status}
+		}
+		content, err = io.ReadAll(io.LimitReader(resp.Body, limit+1))
+		if err != nil {
+			return fmt.Errorf("error reading download: %w", err)
+		}
+		if int64(len(content)) > limit {
+			return retry.Permanent(fmt.Errorf("file is over %s", formatBytes(limit)))
+		}
+		if resp.ContentLength >= 0 && int64(len(content)) != resp.ContentLength {
+			return &integrityError{fmt.Sprintf("download truncated: got %d of %d bytes", len(content), resp.ContentLength)}
+		}
+		head := content
+		if len(head) > 512 {
+			head = head[:512]
+		}
+		mediaType, err = contentType(resp.Header.Get("Content-Type"), head, params.allowedTypes)
+		if err != nil {
+			return retry.Permanent(err)
+		}
+		return nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("http.NewRequest: %v", err)
+		return nil, "", err
 	}
+	return content, mediaType, nil
+}
+
+// Put stores content at key in bucket, as is. Unlike Upload it takes a bucket, so files that
+// mustn't be public, eg. backups, can be kept out of the media bucket.
+func (u *Uploader) Put(ctx context.Context, bucket, key string, content []byte, contentType string) error {
+	return retry.Do(ctx, uploadRetryPolicy, func(ctx context.Context) error {
+		// Each attempt rewrites the whole object, so it's safe to retry whatever the
+		// preconditions.
+		wc := u.client.Bucket(bucket).Object(key).Retryer(storage.WithPolicy(storage.RetryAlways)).NewWriter(ctx)
+		wc.ContentType = contentType
+		wc.SendCRC32C = true
+		wc.CRC32C = crc32.Checksum(content, crc32.MakeTable(crc32.Castagnoli))
+		if _, err := wc.Write(content); err != nil {
+			wc.Close()
+			return fmt.Errorf("error writing %s: %w", key, err)
+		}
+		if err := wc.Close(); err != nil {
+			return fmt.Errorf("Writer.Close: %w", err)
+		}
+		return nil
+	})
+}
+
+// Folders lists the "folders" directly under prefix in bucket, eg. "backups/2023-06-01/" under
+// "backups/".
+func (u *Uploader) Folders(ctx context.Context, bucket, prefix string) ([]string, error) {
+	folders := []string{}
+	it := u.client.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: prefix, Delimiter: "/"})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error listing %s: %w", prefix, err)
+		}
+		if attrs.Prefix != "" {
+			folders = append(folders, attrs.Prefix)
+		}
+	}
+	return folders, nil
+}
+
+// DeleteFolder deletes everything under prefix in bucket and returns how many objects it
+// deleted.
+func (u *Uploader) DeleteFolder(ctx context.Context, bucket, prefix string) (int, error) {
+	deleted := 0
+	it := u.client.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return deleted, fmt.Errorf("error listing %s: %w", prefix, err)
+		}
+		if err := u.client.Bucket(bucket).Object(attrs.Name).Delete(ctx); err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+			return deleted, fmt.Errorf("error deleting %s: %w", attrs.Name, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
 
-	// Add the authorization header to the request.
+func (u *Uploader) slackHeaders(_ context.Context, req *http.Request) error {
 	req.Header.Add("Authorization", "Bearer "+u.slackToken)
+	return nil
+}
+
+// uploadRetryPolicy retries uploads on network errors and 429/5xx from Slack or GCS.
+var uploadRetryPolicy = retry.Default.WithRetryable(func(err error) bool {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return retry.RetryableStatus(apiErr.Code)
+	}
+	var statusErr *downloadStatusError
+	if errors.As(err, &statusErr) {
+		return retry.RetryableStatus(statusErr.code)
+	}
+	var integrityErr *integrityError
+	if errors.As(err, &integrityErr) {
+		return true
+	}
+	return retry.IsTransient(err)
+})
+
+type downloadStatusError struct {
+	code   int
+	status string
+}
+
+func (e *downloadStatusError) Error() string {
+	return fmt.Sprintf("download failed: %s", e.status)
+}
+
+func (u *Uploader) copyToBucket(ctx context.Context, objectKey, downloadURL string, params uploadParams) (*storage.ObjectAttrs, error) {
+	// Create a new HTTP request to download the file.
+	req, err := http.NewRequestWithContext(ctx, "GET", downloadURL, nil)
+	if err != nil {
+		return nil, retry.Permanent(fmt.Errorf("http.NewRequest: %v", err))
+	}
+
+	// Add the source's credentials, if it needs any.
+	if params.auth != nil {
+		if err := params.auth(ctx, req); err != nil {
+			return nil, err
+		}
+	}
 
 	// Do the request.
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("http.DefaultClient.Do: %v", err)
+		return nil, fmt.Errorf("http.DefaultClient.Do: %w", err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &downloadStatusError{code: resp.StatusCode, status: resp.Status}
+	}
 
-	// Write the file to the specified GCS bucket.
-	wc := u.client.Bucket(bucketName).Object(objectKey).NewWriter(ctx)
-	if _, err = io.Copy(wc, resp.Body); err != nil {
-		return "", fmt.Errorf("io.Copy: %v", err)
+	// Check what we're about to store before any of it reaches the public bucket.
+	download := newResumingReader(req, resp)
+	defer download.Close()
+	body := bufio.NewReaderSize(download, 512)
+	head, err := body.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, fmt.Errorf("error reading download: %w", err)
+	}
+	mediaType, err := contentType(resp.Header.Get("Content-Type"), head, params.allowedTypes)
+	if err != nil {
+		return nil, retry.Permanent(err)
+	}
+
+	// SVGs can carry scripts, so they're sanitized in memory before anything is stored.
+	var src io.Reader = body
+	expected := resp.ContentLength
+	if mediaType == svgType {
+		raw, err := io.ReadAll(io.LimitReader(body, maxSVGSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("error reading download: %w", err)
+		}
+		if len(raw) > maxSVGSize {
+			return nil, retry.Permanent(fmt.Errorf("SVG is over %d bytes", maxSVGSize))
+		}
+		if expected >= 0 && int64(len(raw)) != expected {
+			return nil, &integrityError{fmt.Sprintf("download truncated: got %d of %d bytes", len(raw), expected)}
+		}
+		clean, err := htmlguard.SanitizeSVG(bytes.NewReader(raw))
+		if err != nil {
+			return nil, retry.Permanent(err)
+		}
+		src = bytes.NewReader(clean)
+		expected = int64(len(clean))
+	}
+
+	// GCS only checks a checksum it's given before the first byte arrives, so the file is
+	// spooled to disk first, where its length is checked and its checksum taken. The upload then
+	// carries the checksum, and GCS rejects it rather than storing anything that doesn't match.
+	spool, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, retry.Permanent(fmt.Errorf("error creating spool file: %v", err))
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+	checksum := crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	n, err := io.Copy(spool, io.TeeReader(src, checksum))
+	if err != nil {
+		return nil, fmt.Errorf("error reading download: %w", err)
+	}
+	if expected >= 0 && n != expected {
+		return nil, &integrityError{fmt.Sprintf("download truncated: got %d of %d bytes", n, expected)}
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("error rewinding spool file: %v", err)
+	}
+
+	// Write the file to the specified GCS bucket. Chunks of the resumable session can be retried
+	// whatever the object's preconditions, since the session stores them at fixed offsets.
+	// Cancelling writeCtx abandons the upload without storing anything, whereas closing the
+	// writer would publish whatever was written.
+	writeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	obj := u.client.Bucket(bucketName).Object(objectKey)
+	wc := obj.Retryer(storage.WithPolicy(storage.RetryAlways)).NewWriter(writeCtx)
+	wc.ContentType = mediaType
+	wc.ChunkSize = uploadChunkSize
+	wc.ChunkRetryDeadline = uploadChunkDeadline
+	wc.SendCRC32C = true
+	wc.CRC32C = checksum.Sum32()
+	if params.progress != nil {
+		wc.ProgressFunc = func(written int64) {
+			params.progress(written, n)
+		}
+	}
+	if _, err := io.Copy(wc, spool); err != nil {
+		cancel()
+		return nil, fmt.Errorf("io.Copy: %w", err)
 	}
 	if err := wc.Close(); err != nil {
-		return "", fmt.Errorf("Writer.Close: %v", err)
+		return nil, fmt.Errorf("Writer.Close: %w", err)
 	}
-	fmt.Printf("Blob %s uploaded.\n", wc.Attrs().Name)
-	return fmt.Sprintf("https://%s/%s", bucketName, objectKey), nil
+	attrs := wc.Attrs()
+	if params.progress != nil {
+		params.progress(n, n)
+	}
+	return attrs, nil
 }
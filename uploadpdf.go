@@ -0,0 +1,93 @@
+package robots
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/geomodulus/robots/pdf"
+)
+
+const (
+	// pdfThumbnailWidth is the width of PDF preview images.
+	pdfThumbnailWidth = 600
+	// maxPDFSize is the largest PDF read back for a thumbnail and text.
+	maxPDFSize = 100 << 20
+)
+
+// PDFUpload is a stored PDF with its preview and text.
+type PDFUpload struct {
+	URL          string
+	ThumbnailURL string
+	// Text is the PDF's text, for search.IndexDocument.
+	Text string
+}
+
+// UploadPDF stores a PDF like Upload, then renders its first page to a PNG stored alongside it
+// and extracts its text. The preview and text are made independently, so a PDF whose first page
+// won't render is still searchable. If either can't be made, eg. because the PDF is over 100 MB,
+// the original is still stored and returned, with whatever else worked, and the errors.
+func (u *Uploader) UploadPDF(ctx context.Context, slug, downloadURL string, opts ...UploadOption) (*PDFUpload, error) {
+	// Copied, so appending can't write into the caller's slice.
+	opts = append(append([]UploadOption{}, opts...), AllowTypes("application/pdf"))
+	fileURL, err := u.Upload(ctx, slug, downloadURL, opts...)
+	if err != nil {
+		return nil, err
+	}
+	upload := &PDFUpload{URL: fileURL}
+
+	// Work from our stored copy, which has passed the upload checks.
+	data, err := u.readBack(ctx, fileURL, maxPDFSize)
+	if err != nil {
+		return upload, err
+	}
+
+	var thumbnailErr, textErr error
+	upload.ThumbnailURL, thumbnailErr = u.storePDFThumbnail(ctx, fileURL, data)
+	upload.Text, textErr = pdf.Text(ctx, data)
+	if textErr != nil {
+		textErr = fmt.Errorf("error extracting text: %v", textErr)
+	}
+	return upload, errors.Join(thumbnailErr, textErr)
+}
+
+// storePDFThumbnail renders the first page of data, the PDF stored at fileURL, and stores it
+// alongside, returning its URL.
+func (u *Uploader) storePDFThumbnail(ctx context.Context, fileURL string, data []byte) (string, error) {
+	thumbnail, err := pdf.Thumbnail(ctx, data, pdfThumbnailWidth)
+	if err != nil {
+		return "", fmt.Errorf("error rendering thumbnail: %v", err)
+	}
+	objectKey := strings.TrimPrefix(fileURL, fmt.Sprintf("https://%s/", bucketName))
+	thumbnailKey := strings.TrimSuffix(objectKey, ".pdf") + ".thumb.png"
+	wc := u.client.Bucket(bucketName).Object(thumbnailKey).NewWriter(ctx)
+	wc.ContentType = "image/png"
+	if _, err := wc.Write(thumbnail); err != nil {
+		wc.Close()
+		return "", fmt.Errorf("error storing thumbnail: %v", err)
+	}
+	if err := wc.Close(); err != nil {
+		return "", fmt.Errorf("error storing thumbnail: %v", err)
+	}
+	return fmt.Sprintf("https://%s/%s", bucketName, thumbnailKey), nil
+}
+
+// readBack reads a file Upload stored, given the URL it returned, refusing files over limit.
+func (u *Uploader) readBack(ctx context.Context, fileURL string, limit int64) ([]byte, error) {
+	objectKey := strings.TrimPrefix(fileURL, fmt.Sprintf("https://%s/", bucketName))
+	r, err := u.client.Bucket(bucketName).Object(objectKey).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error reading back %s: %v", objectKey, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading back %s: %v", objectKey, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s is over %s, too large to preview", objectKey, formatBytes(limit))
+	}
+	return data, nil
+}
@@ -0,0 +1,182 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	"github.com/geomodulus/robots/internal/retry"
+	"github.com/geomodulus/robots/reqid"
+)
+
+const (
+	// uploadChunkSize is how much of an upload GCS takes per request of its resumable session. A
+	// failed chunk is retried on its own rather than restarting the upload.
+	uploadChunkSize = 16 << 20
+	// uploadChunkDeadline bounds retries of a single chunk.
+	uploadChunkDeadline = 2 * time.Minute
+	// maxDownloadResumes bounds how many times a dropped download is picked up where it stopped.
+	maxDownloadResumes = 5
+)
+
+// ProgressFunc is told how many bytes of an upload have been stored. total is -1 if the source
+// didn't say how big the file is. It's called from the upload's goroutine and should return
+// quickly.
+type ProgressFunc func(written, total int64)
+
+// WithProgress reports the upload's progress to fn after each chunk, and once it's done.
+func WithProgress(fn ProgressFunc) UploadOption {
+	return func(params *uploadParams) {
+		params.progress = fn
+	}
+}
+
+// resumingReader reads a download, re-requesting the rest of it with a Range header when the
+// connection drops, so a large file doesn't start over from zero. It only resumes if the server
+// advertised range support, and uses If-Range so a file that changed meanwhile isn't spliced.
+type resumingReader struct {
+	req       *http.Request
+	body      io.ReadCloser
+	offset    int64
+	validator string
+	resumes   int
+}
+
+func newResumingReader(req *http.Request, resp *http.Response) *resumingReader {
+	r := &resumingReader{req: req, body: resp.Body}
+	if resp.Header.Get("Accept-Ranges") == "bytes" {
+		r.validator = resp.Header.Get("ETag")
+		if r.validator == "" || strings.HasPrefix(r.validator, "W/") {
+			r.validator = resp.Header.Get("Last-Modified")
+		}
+	}
+	return r
+}
+
+func (r *resumingReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	if err == nil || err == io.EOF || !retry.IsTransient(err) {
+		return n, err
+	}
+	if r.validator == "" || r.resumes >= maxDownloadResumes {
+		return n, err
+	}
+	if resumeErr := r.resume(); resumeErr != nil {
+		reqid.Printf(r.req.Context(), "error resuming download at byte %d: %v", r.offset, resumeErr)
+		return n, err
+	}
+	return n, nil
+}
+
+func (r *resumingReader) resume() error {
+	ctx := r.req.Context()
+	timer := time.NewTimer(retry.Default.Delay(r.resumes))
+	select {
+	case <-ctx.Done():
+		timer.Stop()
+		return ctx.Err()
+	case <-timer.C:
+	}
+	r.resumes++
+
+	req := r.req.Clone(ctx)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", r.offset))
+	req.Header.Set("If-Range", r.validator)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("http.DefaultClient.Do: %w", err)
+	}
+	// A 200 means the server sent the whole file again, eg. because it changed.
+	if resp.StatusCode != http.StatusPartialContent || contentRangeStart(resp.Header.Get("Content-Range")) != r.offset {
+		resp.Body.Close()
+		return fmt.Errorf("server didn't resume: %s", resp.Status)
+	}
+	r.body.Close()
+	r.body = resp.Body
+	reqid.Printf(ctx, "resumed download of %s at byte %d", r.req.URL, r.offset)
+	return nil
+}
+
+func (r *resumingReader) Close() error {
+	return r.body.Close()
+}
+
+// contentRangeStart returns the first byte of a "bytes start-end/size" Content-Range, or -1.
+func contentRangeStart(header string) int64 {
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return -1
+	}
+	start, _, _ := strings.Cut(spec, "-")
+	n, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// uploadProgressInterval is how often UploadProgress updates its message.
+var uploadProgressInterval = 5 * time.Second
+
+// UploadProgress returns a ProgressFunc that shows an upload's progress in env's thread, for
+// files big enough to take a while, eg.
+//
+//	uploader.Upload(ctx, slug, file.URLPrivateDownload, WithProgress(b.UploadProgress(ctx, env, file.Name)))
+func (b *SlackBot) UploadProgress(ctx context.Context, env *Envelope, name string) ProgressFunc {
+	var (
+		mu         sync.Mutex
+		ts         string
+		lastUpdate time.Time
+	)
+	return func(written, total int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		done := total >= 0 && written >= total
+		if !done && time.Since(lastUpdate) < uploadProgressInterval {
+			return
+		}
+		lastUpdate = time.Now()
+
+		text := fmt.Sprintf(":arrow_up: Uploading %s: %s", name, formatBytes(written))
+		if total > 0 {
+			text = fmt.Sprintf(":arrow_up: Uploading %s: %s of %s (%d%%)", name, formatBytes(written), formatBytes(total), written*100/total)
+		}
+		if done {
+			text = fmt.Sprintf(":white_check_mark: Uploaded %s (%s).", name, formatBytes(written))
+		}
+
+		// Progress messages are best effort; the upload carries on either way.
+		if ts == "" {
+			_, posted, err := b.PostMessageContext(ctx, env.ChannelID, slack.MsgOptionText(text, false), slack.MsgOptionTS(env.ThreadTS))
+			if err != nil {
+				reqid.Printf(ctx, "error posting upload progress: %v", err)
+				return
+			}
+			ts = posted
+			return
+		}
+		if _, _, _, err := b.UpdateMessageContext(ctx, env.ChannelID, ts, slack.MsgOptionText(text, false)); err != nil {
+			reqid.Printf(ctx, "error updating upload progress: %v", err)
+		}
+	}
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
@@ -0,0 +1,85 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/slack-go/slack"
+
+	"github.com/geomodulus/robots/internal/cache"
+)
+
+const (
+	// userCacheSize is how many user names the bot keeps.
+	userCacheSize = 1000
+	// userCacheTTL is how long a user name is kept, so renames show up within the day.
+	userCacheTTL = 6 * time.Hour
+)
+
+// UserName returns a user's display name, or their real name if they haven't set one. Names are
+// cached, since summarizing a long thread would otherwise look up the same people over and over.
+func (b *SlackBot) UserName(ctx context.Context, userID string) (string, error) {
+	b.usersOnce.Do(func() {
+		b.users = cache.New[string](userCacheSize, userCacheTTL)
+	})
+	if name, ok := b.users.Get(userID); ok {
+		return name, nil
+	}
+
+	user, err := b.GetUserInfoContext(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("error looking up user %s: %v", userID, err)
+	}
+	name := user.Profile.DisplayName
+	if name == "" {
+		name = user.RealName
+	}
+	if name == "" {
+		name = user.Name
+	}
+	b.users.Put(userID, name)
+	return name, nil
+}
+
+// ThreadMessages returns every message in a thread, root first, following the pagination of
+// conversations.replies.
+func (b *SlackBot) ThreadMessages(ctx context.Context, channelID, threadTS string) ([]slack.Message, error) {
+	messages := []slack.Message{}
+	params := &slack.GetConversationRepliesParameters{ChannelID: channelID, Timestamp: threadTS, Limit: 200}
+	for {
+		msgs, hasMore, cursor, err := b.GetConversationRepliesContext(ctx, params)
+		if err != nil {
+			return nil, fmt.Errorf("error reading thread %s: %v", threadTS, err)
+		}
+		messages = append(messages, msgs...)
+		if !hasMore || cursor == "" {
+			return messages, nil
+		}
+		params.Cursor = cursor
+	}
+}
+
+// UserChannels returns the IDs of the channels a user is in, following the pagination of
+// users.conversations. Only channels the bot can see are listed.
+func (b *SlackBot) UserChannels(ctx context.Context, userID string) ([]string, error) {
+	channels := []string{}
+	params := &slack.GetConversationsForUserParameters{
+		UserID: userID,
+		Types:  []string{"public_channel", "private_channel"},
+		Limit:  200,
+	}
+	for {
+		page, cursor, err := b.GetConversationsForUserContext(ctx, params)
+		if err != nil {
+			return nil, fmt.Errorf("error listing channels of %s: %v", userID, err)
+		}
+		for _, channel := range page {
+			channels = append(channels, channel.ID)
+		}
+		if cursor == "" {
+			return channels, nil
+		}
+		params.Cursor = cursor
+	}
+}
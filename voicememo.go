@@ -0,0 +1,122 @@
+package robots
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+
+	"github.com/geomodulus/robots/flags"
+)
+
+const (
+	// maxVoiceMemoSize is the most Whisper takes in one request.
+	maxVoiceMemoSize = 25 << 20
+	// voiceMemoPrefix is where voice memos are stored in Bucket.
+	voiceMemoPrefix = "voice-memos/"
+)
+
+// voiceMemoTypes are what voice memos sniff as. Slack records clips as WebM or MP4 and Go sniffs
+// both as video.
+var voiceMemoTypes = []string{"audio/", "video/webm", "video/mp4"}
+
+// VoiceMemos transcribes audio files reporters share in Slack and posts the transcript, and a
+// first draft from it, in the file's thread. Pass message events to HandleMessage.
+type VoiceMemos struct {
+	Bot      *SlackBot
+	Uploader *Uploader
+	OpenAI   *openai.Client
+	// Bucket, if set, keeps a copy of the audio. Memos are often interviews, so it should be
+	// private, never the media bucket.
+	Bucket string
+	// Drafter turns transcripts into drafts. Without it only the transcript is posted.
+	Drafter *Drafter
+	// Channels are where voice memos are picked up. Empty means everywhere the bot is.
+	Channels []string
+}
+
+// HandleMessage transcribes the audio files in a file_share message. Other messages are ignored.
+func (v *VoiceMemos) HandleMessage(ctx context.Context, env *Envelope, ev *slackevents.MessageEvent) error {
+	if ev.SubType != "file_share" || ev.BotID != "" || !v.watches(ev.Channel) {
+		return nil
+	}
+	for _, file := range ev.Files {
+		if !strings.HasPrefix(file.Mimetype, "audio/") {
+			continue
+		}
+		if err := v.transcribe(ctx, env, file); err != nil {
+			return fmt.Errorf("error transcribing %s: %v", file.Name, err)
+		}
+	}
+	return nil
+}
+
+func (v *VoiceMemos) watches(channel string) bool {
+	if len(v.Channels) == 0 {
+		return true
+	}
+	for _, c := range v.Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *VoiceMemos) transcribe(ctx context.Context, env *Envelope, file slackevents.File) error {
+	if file.Size > maxVoiceMemoSize {
+		return fmt.Errorf("it's %s, and transcription takes up to %s", formatBytes(int64(file.Size)), formatBytes(maxVoiceMemoSize))
+	}
+	if err := v.Bot.ReplyTo(env, slack.MsgOptionText(":studio_microphone: Transcribing "+file.Name+"…", false)); err != nil {
+		return err
+	}
+
+	audio, mediaType, err := v.Uploader.fetch(ctx, file.URLPrivateDownload, maxVoiceMemoSize,
+		v.Uploader.SlackAuth(), AllowTypes(voiceMemoTypes...))
+	if err != nil {
+		return fmt.Errorf("error downloading audio: %v", err)
+	}
+
+	// Whisper goes by the file extension, which Slack's voice clips don't always have.
+	name := file.Name
+	if path.Ext(name) == "" && file.Filetype != "" {
+		name += "." + file.Filetype
+	}
+	if v.Bucket != "" {
+		if err := v.Uploader.Put(ctx, v.Bucket, voiceMemoPrefix+file.ID+path.Ext(name), audio, mediaType); err != nil {
+			return fmt.Errorf("error storing audio: %v", err)
+		}
+	}
+	resp, err := v.OpenAI.CreateTranscription(ctx, openai.AudioRequest{
+		Model:    openai.Whisper1,
+		FilePath: name,
+		Reader:   bytes.NewReader(audio),
+	})
+	if err != nil {
+		return fmt.Errorf("error from Whisper: %v", err)
+	}
+	transcript := strings.TrimSpace(resp.Text)
+	if transcript == "" {
+		return v.Bot.ReplyTo(env, slack.MsgOptionText(fmt.Sprintf("I couldn't hear any speech in <%s|%s>.", file.Permalink, file.Name), false))
+	}
+
+	if _, err := v.Bot.PostLong(ctx, env, "Transcript of "+file.Name,
+		fmt.Sprintf("%s\n\n[Audio](%s)", transcript, file.Permalink), OutputAuto); err != nil {
+		return err
+	}
+	if v.Drafter == nil {
+		return nil
+	}
+	_, err = v.Drafter.DraftInThread(ctx, v.Bot, env, "", transcript)
+	if errors.Is(err, flags.ErrDisabled) {
+		// The transcript is still worth having.
+		return nil
+	}
+	return err
+}
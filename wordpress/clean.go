@@ -0,0 +1,91 @@
+package wordpress
+
+import (
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/geomodulus/robots/htmlguard"
+)
+
+var (
+	commentRe    = regexp.MustCompile(`(?s)<!--.*?-->`)
+	captionRe    = regexp.MustCompile(`(?s)\[(?:wp_)?caption[^\]]*\](.*?)\[/(?:wp_)?caption\]`)
+	captionImgRe = regexp.MustCompile(`(?s)^\s*((?:<a[^>]*>)?\s*<img[^>]*>\s*(?:</a>)?)(.*)$`)
+	embedRe      = regexp.MustCompile(`(?s)\[(?:embed|video|audio)[^\]]*\](.*?)\[/(?:embed|video|audio)\]`)
+	// shortcodeRe matches the other shortcodes WordPress and common plugins leave in content.
+	// Brackets in prose, like [sic], aren't shortcodes and are left alone.
+	shortcodeRe  = regexp.MustCompile(`\[/?(?:gallery|playlist|embed|video|audio|caption|wp_caption|vc_\w+|et_pb_\w+|su_\w+)\b[^\]]*\]`)
+	blockStartRe = regexp.MustCompile(`(?i)^<(p|figure|h[1-6]|ul|ol|blockquote|div|table|iframe|hr|pre)\b`)
+	imgSrcRe     = regexp.MustCompile(`(?i)<img[^>]+src\s*=\s*["']([^"']+)["']`)
+	blankLineRe  = regexp.MustCompile(`\n\s*\n`)
+	tagRe        = regexp.MustCompile(`<[^>]*>`)
+)
+
+// CleanHTML turns a post's content into article HTML: captions become figures, embeds become
+// links, other shortcodes and block editor comments are dropped, paragraphs are wrapped as
+// WordPress's wpautop would have, and whatever htmlguard.DefaultPolicy doesn't allow, like
+// inline styles and wp-image classes, is stripped.
+func CleanHTML(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = commentRe.ReplaceAllString(content, "")
+	content = captionRe.ReplaceAllStringFunc(content, func(shortcode string) string {
+		inner := captionRe.FindStringSubmatch(shortcode)[1]
+		parts := captionImgRe.FindStringSubmatch(inner)
+		if parts == nil {
+			return inner
+		}
+		figure := "<figure>" + parts[1]
+		if caption := strings.TrimSpace(parts[2]); caption != "" {
+			figure += `<figcaption class="caption">` + caption + "</figcaption>"
+		}
+		return figure + "</figure>"
+	})
+	content = embedRe.ReplaceAllStringFunc(content, func(shortcode string) string {
+		url := strings.TrimSpace(embedRe.FindStringSubmatch(shortcode)[1])
+		if url == "" {
+			return ""
+		}
+		escaped := html.EscapeString(url)
+		return `<p><a href="` + escaped + `">` + escaped + "</a></p>"
+	})
+	content = shortcodeRe.ReplaceAllString(content, "")
+	return htmlguard.DefaultPolicy.Sanitize(autop(content))
+}
+
+// autop wraps blocks of text separated by blank lines in paragraphs and turns the remaining line
+// breaks into <br>, leaving blocks that already start with a block element as they are.
+func autop(content string) string {
+	var b strings.Builder
+	for _, block := range blankLineRe.Split(content, -1) {
+		block = strings.TrimSpace(block)
+		if block == "" {
+			continue
+		}
+		if blockStartRe.MatchString(block) {
+			b.WriteString(block + "\n")
+			continue
+		}
+		b.WriteString("<p>" + strings.ReplaceAll(block, "\n", "<br>") + "</p>\n")
+	}
+	return b.String()
+}
+
+// Images returns the src of every image in body, in order, without repeats.
+func Images(body string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, match := range imgSrcRe.FindAllStringSubmatch(body, -1) {
+		src := html.UnescapeString(match[1])
+		if !seen[src] {
+			seen[src] = true
+			out = append(out, src)
+		}
+	}
+	return out
+}
+
+// Text strips the tags from an excerpt or title.
+func Text(s string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(tagRe.ReplaceAllString(s, " "))), " ")
+}
@@ -0,0 +1,137 @@
+// Package wordpress reads WordPress WXR exports, the XML files written by Tools > Export, and
+// cleans up post HTML for the article format.
+package wordpress
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/geomodulus/robots/pubdate"
+)
+
+// wxrDateLayout is how WXR writes post_date and post_date_gmt.
+const wxrDateLayout = "2006-01-02 15:04:05"
+
+// Post is a published post from an export.
+type Post struct {
+	ID    string
+	Title string
+	// Slug is the post's name in its permalink. It may be empty for posts that were never
+	// published under a pretty permalink.
+	Slug    string
+	Link    string
+	Author  string
+	Content string
+	Excerpt string
+	PubDate time.Time
+	// Categories are display names, eg. "City Hall". Tags are left out.
+	Categories []string
+	// FeaturedImage is the URL of the post's thumbnail, if it has one.
+	FeaturedImage string
+}
+
+type wxr struct {
+	Items []wxrItem `xml:"channel>item"`
+}
+
+type wxrItem struct {
+	Title   string `xml:"title"`
+	Link    string `xml:"link"`
+	Creator string `xml:"creator"`
+	// Encoded holds both content:encoded and excerpt:encoded, told apart by namespace, since the
+	// excerpt namespace changes between WXR versions.
+	Encoded []struct {
+		XMLName xml.Name
+		Value   string `xml:",chardata"`
+	} `xml:"encoded"`
+	PostID        string `xml:"post_id"`
+	PostDate      string `xml:"post_date"`
+	PostDateGMT   string `xml:"post_date_gmt"`
+	PostName      string `xml:"post_name"`
+	Status        string `xml:"status"`
+	PostType      string `xml:"post_type"`
+	AttachmentURL string `xml:"attachment_url"`
+	Categories    []struct {
+		Domain string `xml:"domain,attr"`
+		Name   string `xml:",chardata"`
+	} `xml:"category"`
+	Meta []struct {
+		Key   string `xml:"meta_key"`
+		Value string `xml:"meta_value"`
+	} `xml:"postmeta"`
+}
+
+// Parse reads the published posts from a WXR export, in the order they're exported. Pages,
+// drafts and attachments are left out, though attachments are used to find featured images.
+func Parse(r io.Reader) ([]*Post, error) {
+	export := &wxr{}
+	decoder := xml.NewDecoder(r)
+	// Exports declare UTF-8 but are sometimes written by plugins that say otherwise.
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+	if err := decoder.Decode(export); err != nil {
+		return nil, fmt.Errorf("error decoding wxr: %v", err)
+	}
+
+	attachments := map[string]string{}
+	for _, item := range export.Items {
+		if item.PostType == "attachment" && item.AttachmentURL != "" {
+			attachments[item.PostID] = strings.TrimSpace(item.AttachmentURL)
+		}
+	}
+
+	posts := []*Post{}
+	for _, item := range export.Items {
+		if item.PostType != "post" || item.Status != "publish" {
+			continue
+		}
+		post := &Post{
+			ID:     item.PostID,
+			Title:  strings.TrimSpace(html.UnescapeString(item.Title)),
+			Slug:   strings.TrimSpace(item.PostName),
+			Link:   strings.TrimSpace(item.Link),
+			Author: strings.TrimSpace(item.Creator),
+		}
+		for _, encoded := range item.Encoded {
+			switch {
+			case strings.Contains(encoded.XMLName.Space, "excerpt"):
+				post.Excerpt = strings.TrimSpace(encoded.Value)
+			case strings.Contains(encoded.XMLName.Space, "content"):
+				post.Content = encoded.Value
+			}
+		}
+		for _, category := range item.Categories {
+			if category.Domain == "category" && category.Name != "Uncategorized" {
+				post.Categories = append(post.Categories, strings.TrimSpace(html.UnescapeString(category.Name)))
+			}
+		}
+		for _, meta := range item.Meta {
+			if meta.Key == "_thumbnail_id" {
+				post.FeaturedImage = attachments[strings.TrimSpace(meta.Value)]
+			}
+		}
+		date, err := postDate(item.PostDateGMT, item.PostDate)
+		if err != nil {
+			return nil, fmt.Errorf("post %s: %v", item.PostID, err)
+		}
+		post.PubDate = date
+		posts = append(posts, post)
+	}
+	return posts, nil
+}
+
+// postDate prefers the GMT date, which WordPress leaves as zeros for some imported posts, and
+// falls back to the local date, taken to be Toronto time.
+func postDate(gmt, local string) (time.Time, error) {
+	if t, err := time.Parse(wxrDateLayout, strings.TrimSpace(gmt)); err == nil && t.Year() > 1 {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(wxrDateLayout, strings.TrimSpace(local), pubdate.Toronto)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid post date %q", local)
+	}
+	return t, nil
+}
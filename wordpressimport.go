@@ -0,0 +1,155 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/geomodulus/citygraph"
+
+	"github.com/geomodulus/robots/github"
+	"github.com/geomodulus/robots/pubdate"
+	"github.com/geomodulus/robots/reqid"
+	"github.com/geomodulus/robots/wordpress"
+)
+
+// WordPressImport moves the published posts from a WordPress export into archive/articles/.
+// Post HTML is cleaned up for the article format, images are re-hosted with Uploader, and the
+// articles are committed to a single PR in batches.
+type WordPressImport struct {
+	Articles *github.App
+	// Uploader, if set, re-hosts the images in each post. Without it, images keep pointing at
+	// the WordPress site.
+	Uploader *Uploader
+	// BatchSize is how many articles go in each commit. It defaults to 25.
+	BatchSize int
+	// Authors maps WordPress usernames to bylines. Usernames without an entry are used as they
+	// are.
+	Authors map[string]string
+}
+
+// WordPressImportResult describes a finished import.
+type WordPressImportResult struct {
+	PRNum int
+	PRURL string
+	// Slugs are the slugs the posts were archived under, in order, with "" for posts skipped.
+	Slugs []string
+	// Skipped counts the posts that were already archived.
+	Skipped int
+	// ImageErrors counts the images that couldn't be re-hosted and were left pointing at
+	// WordPress.
+	ImageErrors int
+}
+
+// Import reads a WXR export from r and opens a PR archiving its published posts.
+func (w *WordPressImport) Import(ctx context.Context, r io.Reader, opts ...github.Option) (*WordPressImportResult, error) {
+	posts, err := wordpress.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(posts) == 0 {
+		return nil, fmt.Errorf("no published posts found in export")
+	}
+
+	// Posts already imported are left out before their images are re-hosted again.
+	imported, err := w.Articles.ImportedSources(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := &WordPressImportResult{Slugs: make([]string, len(posts))}
+	articles := make([]*github.ImportedArticle, 0, len(posts))
+	// indexes maps each article to its post.
+	indexes := []int{}
+	for i, post := range posts {
+		if imported[wordPressSourceID(post)] {
+			res.Skipped++
+			continue
+		}
+		article, imageErrors, err := w.convert(ctx, post)
+		if err != nil {
+			return nil, fmt.Errorf("error converting post %s: %v", post.ID, err)
+		}
+		res.ImageErrors += imageErrors
+		articles = append(articles, article)
+		indexes = append(indexes, i)
+	}
+	if len(articles) == 0 {
+		return nil, fmt.Errorf("every post is already archived")
+	}
+
+	var slugs []string
+	res.PRNum, res.PRURL, slugs, err = w.Articles.ImportArchivedArticles(ctx, articles, w.BatchSize, opts...)
+	if err != nil {
+		return nil, err
+	}
+	for i, slug := range slugs {
+		if slug == "" {
+			res.Skipped++
+		}
+		res.Slugs[indexes[i]] = slug
+	}
+	return res, nil
+}
+
+// wordPressSourceID identifies a post as an ImportedArticle's SourceID.
+func wordPressSourceID(post *wordpress.Post) string {
+	return "wordpress:" + post.ID
+}
+
+// convert builds the archived article for post, returning how many of its images couldn't be
+// re-hosted.
+func (w *WordPressImport) convert(ctx context.Context, post *wordpress.Post) (*github.ImportedArticle, int, error) {
+	article := &citygraph.Article{
+		ID:           citygraph.NewID().String(),
+		Name:         wordpress.Text(post.Title),
+		Description:  wordpress.Text(post.Excerpt),
+		IsLive:       true,
+		Categories:   post.Categories,
+		PubDate:      post.PubDate.In(pubdate.Toronto).Format(time.RFC3339),
+		FeatureImage: post.FeaturedImage,
+	}
+	if post.Author != "" {
+		author := post.Author
+		if byline, ok := w.Authors[author]; ok {
+			author = byline
+		}
+		article.Authors = []string{author}
+	}
+	slug := post.Slug
+	if slug == "" {
+		slug = article.SlugTitle()
+	}
+
+	body := wordpress.CleanHTML(post.Content)
+	imageErrors := 0
+	if w.Uploader != nil {
+		for _, src := range wordpress.Images(body) {
+			rehosted, err := w.Uploader.Upload(ctx, slug, src, NoAuth())
+			if err != nil {
+				reqid.Printf(ctx, "wordpress import: error re-hosting %s: %v", src, err)
+				imageErrors++
+				continue
+			}
+			// The sanitizer escapes ampersands in attributes, so that's how src appears in body.
+			body = strings.ReplaceAll(body, html.EscapeString(src), html.EscapeString(rehosted))
+		}
+		if post.FeaturedImage != "" {
+			if rehosted, err := w.Uploader.Upload(ctx, slug, post.FeaturedImage, NoAuth()); err != nil {
+				reqid.Printf(ctx, "wordpress import: error re-hosting %s: %v", post.FeaturedImage, err)
+				imageErrors++
+			} else {
+				article.FeatureImage = rehosted
+			}
+		}
+	}
+
+	return &github.ImportedArticle{
+		Slug:     slug,
+		SourceID: wordPressSourceID(post),
+		Article:  article,
+		BodyHTML: body,
+	}, imageErrors, nil
+}